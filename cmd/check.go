@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/geoip"
+	"github.com/nerdneilsfield/tiny-auth/internal/policy"
+	"github.com/nerdneilsfield/tiny-auth/internal/server"
+)
+
+type checkOptions struct {
+	host        string
+	uri         string
+	method      string
+	headers     []string
+	clientIP    string
+	country     string
+	asn         uint
+	at          string
+	callWebhook bool
+	jsonOutput  bool
+}
+
+// checkReport check 命令的输出（--json 模式直接序列化）
+type checkReport struct {
+	Request        checkRequest      `json:"request"`
+	Policy         string            `json:"policy,omitempty"`
+	Authenticators []string          `json:"authenticators"`
+	Identity       *checkIdentity    `json:"identity,omitempty"`
+	Allowed        bool              `json:"allowed"`
+	Status         int               `json:"status"`
+	Reason         string            `json:"reason,omitempty"`
+	Message        string            `json:"message,omitempty"`
+	Code           string            `json:"code,omitempty"`
+	Requirement    string            `json:"failed_requirement,omitempty"`
+	Rule           string            `json:"rule,omitempty"`
+	WebhookSkipped bool              `json:"webhook_skipped,omitempty"`
+	Challenges     []string          `json:"challenges,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+}
+
+type checkRequest struct {
+	Host     string    `json:"host"`
+	URI      string    `json:"uri"`
+	Method   string    `json:"method"`
+	ClientIP string    `json:"client_ip"`
+	Country  string    `json:"country,omitempty"`
+	ASN      uint      `json:"asn,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type checkIdentity struct {
+	Method string   `json:"method"`
+	Name   string   `json:"name,omitempty"`
+	User   string   `json:"user,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+func newCheckCmd() *cobra.Command {
+	opts := &checkOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Simulate a forward-auth request against the configuration",
+		Long: `Load the configuration and run a request through the same policy matching,
+authentication and policy checks as the server, without starting it.
+
+Example:
+  tiny-auth check --host api.example.com --uri /admin/users --method GET \
+    --header 'Authorization: Basic YWRtaW46c2VjcmV0' --ip 10.0.0.5
+
+External authorization webhooks are not called unless --webhook is given.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCheck(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.host, "host", "", "Forwarded host (X-Forwarded-Host)")
+	cmd.Flags().StringVar(&opts.uri, "uri", "/", "Forwarded URI including query (X-Forwarded-Uri)")
+	cmd.Flags().StringVar(&opts.method, "method", "GET", "Forwarded method (X-Forwarded-Method)")
+	cmd.Flags().StringArrayVar(&opts.headers, "header", nil, "Request header 'Name: value' (repeatable)")
+	cmd.Flags().StringVar(&opts.clientIP, "ip", "127.0.0.1", "Client IP")
+	cmd.Flags().StringVar(&opts.country, "country", "", "Client country code (default: looked up in geoip.country_db)")
+	cmd.Flags().UintVar(&opts.asn, "asn", 0, "Client AS number (default: looked up in geoip.asn_db)")
+	cmd.Flags().StringVar(&opts.at, "time", "", "Evaluate at this time (RFC 3339, default: now)")
+	cmd.Flags().BoolVar(&opts.callWebhook, "webhook", false, "Call external authorization webhooks")
+	cmd.Flags().BoolVar(&opts.jsonOutput, "json", false, "Output result as JSON")
+
+	return cmd
+}
+
+func runCheck(opts *checkOptions) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	req, err := buildCheckRequest(opts)
+	if err != nil {
+		return err
+	}
+
+	// 没有指定国家 / ASN 时从配置的 GeoIP 数据库查询
+	if opts.country == "" && opts.asn == 0 && cfg.GeoIP.Enabled() {
+		db, err := geoip.Open(geoip.Options{CountryDB: cfg.GeoIP.CountryDB, ASNDB: cfg.GeoIP.ASNDB}, nil)
+		if err != nil {
+			return err
+		}
+		info := db.Lookup(req.ClientIP)
+		db.Close()
+		req.Country, req.ASN = info.Country, info.ASN
+	}
+
+	report, err := evaluateCheck(cfg, req)
+	if err != nil {
+		return err
+	}
+
+	if opts.jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	printCheckReport(report)
+	return nil
+}
+
+// evaluateCheck 按 HandleAuth 的顺序评估请求：全局客户端过滤，然后是完整的认证决策（不包括速率限制）
+func evaluateCheck(cfg *config.Config, req *server.AuthRequest) (*checkReport, error) {
+	filter, err := server.NewIPFilter(cfg.IPFilter, logger)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		defer filter.Stop()
+	}
+
+	pipeline := server.NewPipeline(cfg, auth.BuildStore(cfg), logger)
+	out, _ := pipeline.ScreenClient(filter, req.ClientIP, geoip.Info{Country: req.Country, ASN: req.ASN})
+	if out == nil {
+		out = pipeline.Evaluate(context.Background(), req)
+	}
+
+	report := &checkReport{
+		Request: checkRequest{
+			Host:     req.Host,
+			URI:      req.URI,
+			Method:   req.Method,
+			ClientIP: req.ClientIP,
+			Country:  req.Country,
+			ASN:      req.ASN,
+			Time:     req.Time,
+		},
+		Policy:         out.PolicyName(),
+		Authenticators: out.Authenticators,
+		Allowed:        out.Allowed,
+		Status:         out.Status,
+		Reason:         out.Reason,
+		Message:        out.Message,
+		Requirement:    out.Requirement,
+		Rule:           out.Rule,
+		WebhookSkipped: out.WebhookSkipped,
+		Challenges:     out.Challenges,
+		Headers:        out.Headers(cfg, req.Host+req.URI),
+	}
+	if report.Authenticators == nil {
+		report.Authenticators = []string{}
+	}
+	if out.Error != nil {
+		report.Code = string(out.Error.Code)
+	}
+	if out.Result != nil {
+		report.Identity = &checkIdentity{
+			Method: out.Result.Method,
+			Name:   out.Result.Name,
+			User:   out.Result.User,
+			Roles:  out.Result.Roles,
+			Groups: out.Result.Groups,
+		}
+	}
+	return report, nil
+}
+
+// buildCheckRequest 根据命令行参数构建待评估的请求
+func buildCheckRequest(opts *checkOptions) (*server.AuthRequest, error) {
+	now := time.Now()
+	if opts.at != "" {
+		t, err := time.Parse(time.RFC3339, opts.at)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --time %q: %w", opts.at, err)
+		}
+		now = t
+	}
+
+	req := &server.AuthRequest{
+		Request: policy.Request{
+			Host:     opts.host,
+			URI:      opts.uri,
+			Method:   strings.ToUpper(opts.method),
+			ClientIP: opts.clientIP,
+			Headers:  make(map[string]string),
+			Time:     now,
+			Country:  strings.ToUpper(opts.country),
+			ASN:      opts.asn,
+		},
+		SkipWebhook: !opts.callWebhook,
+	}
+
+	// 与 requestHeaders 一致：名称转为小写，同名 header 取第一个值
+	for _, h := range opts.headers {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --header %q: expected 'Name: value'", h)
+		}
+		name = strings.ToLower(name)
+		if _, exists := req.Headers[name]; !exists {
+			req.Headers[name] = strings.TrimSpace(value)
+		}
+	}
+	req.Authorization = req.Headers["authorization"]
+	req.APIKey = req.Headers["x-api-key"]
+
+	return req, nil
+}
+
+func printCheckReport(r *checkReport) {
+	fmt.Printf("Request:        %s %s%s (client %s, %s)\n",
+		r.Request.Method, r.Request.Host, r.Request.URI, r.Request.ClientIP, r.Request.Time.Format(time.RFC3339))
+	if r.Request.Country != "" || r.Request.ASN != 0 {
+		fmt.Printf("Origin:         country=%s asn=%d\n", r.Request.Country, r.Request.ASN)
+	}
+
+	if r.Policy != "" {
+		fmt.Printf("Policy:         %s\n", r.Policy)
+	} else {
+		fmt.Printf("Policy:         (none matched)\n")
+	}
+
+	if len(r.Authenticators) > 0 {
+		fmt.Printf("Authenticators: %s\n", strings.Join(r.Authenticators, " → "))
+	} else {
+		fmt.Printf("Authenticators: (no credentials)\n")
+	}
+
+	if r.Identity != nil {
+		fmt.Printf("Identity:       method=%s", r.Identity.Method)
+		if r.Identity.Name != "" {
+			fmt.Printf(" name=%s", r.Identity.Name)
+		}
+		if r.Identity.User != "" {
+			fmt.Printf(" user=%s", r.Identity.User)
+		}
+		fmt.Println()
+		if len(r.Identity.Roles) > 0 {
+			fmt.Printf("  - Roles: %s\n", strings.Join(r.Identity.Roles, ", "))
+		}
+		if len(r.Identity.Groups) > 0 {
+			fmt.Printf("  - Groups: %s\n", strings.Join(r.Identity.Groups, ", "))
+		}
+	}
+
+	fmt.Println()
+	if r.Allowed {
+		fmt.Printf("✅ Allowed (%d)\n", r.Status)
+	} else {
+		fmt.Printf("❌ Denied (%d): %s - %s\n", r.Status, r.Reason, r.Message)
+	}
+	if r.Code != "" {
+		fmt.Printf("  - Code: %s\n", r.Code)
+	}
+	if r.Requirement != "" {
+		fmt.Printf("  - Failed requirement: %s\n", r.Requirement)
+	}
+	if r.Rule != "" {
+		fmt.Printf("  - Rule: %s\n", r.Rule)
+	}
+	if r.WebhookSkipped {
+		fmt.Printf("  - Authz webhook: skipped (use --webhook to call it)\n")
+	}
+	for _, challenge := range r.Challenges {
+		fmt.Printf("  - WWW-Authenticate: %s\n", challenge)
+	}
+
+	if len(r.Headers) > 0 {
+		fmt.Println()
+		fmt.Println("Injected headers:")
+		names := make([]string, 0, len(r.Headers))
+		for name := range r.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, r.Headers[name])
+		}
+	}
+}
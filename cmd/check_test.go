@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestEvaluateCheck_GlobalFilters 测试 check 与 HandleAuth 一样应用全局 IP 过滤和国家 / ASN 规则
+func TestEvaluateCheck_GlobalFilters(t *testing.T) {
+	cfg := &config.Config{
+		IPFilter: config.IPFilterConfig{Allow: []string{"9.9.9.9"}, Deny: []string{"1.2.3.0/24"}},
+		GeoIP:    config.GeoIPConfig{DeniedCountries: []string{"RU"}, DeniedASNs: []uint{64500}},
+		BasicAuths: []config.BasicAuthConfig{
+			{Name: "u", User: "u", Pass: "p", Roles: []string{"user"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		clientIP string
+		country  string
+		asn      uint
+		allowed  bool
+		status   int
+		reason   string
+		rule     string
+	}{
+		{"Denied IP", "1.2.3.4", "", 0, false, 403, "ip_denied", "deny"},
+		{"Other IP", "5.6.7.8", "DE", 0, true, 200, "", ""},
+		{"Denied country", "5.6.7.8", "ru", 0, false, 403, "country_denied", ""},
+		{"Denied ASN", "5.6.7.8", "DE", 64500, false, 403, "asn_denied", ""},
+		{"Allow list bypasses geo rules", "9.9.9.9", "RU", 64500, true, 200, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := buildCheckRequest(&checkOptions{
+				uri:      "/",
+				method:   "GET",
+				clientIP: tt.clientIP,
+				country:  tt.country,
+				asn:      tt.asn,
+				headers:  []string{"Authorization: Basic dTpw"}, // u:p
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := evaluateCheck(cfg, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Allowed != tt.allowed || report.Status != tt.status || report.Reason != tt.reason || report.Rule != tt.rule {
+				t.Errorf("got allowed=%v status=%d reason=%q rule=%q, want %v %d %q %q",
+					report.Allowed, report.Status, report.Reason, report.Rule, tt.allowed, tt.status, tt.reason, tt.rule)
+			}
+		})
+	}
+}
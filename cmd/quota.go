@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/quota"
+)
+
+// quotaRow quota show 的一行（--json 模式直接序列化）
+type quotaRow struct {
+	Credential   string `json:"credential"`
+	Day          string `json:"day"`
+	DayCount     int64  `json:"day_count"`
+	PerDay       int64  `json:"per_day,omitempty"`
+	Month        string `json:"month"`
+	MonthCount   int64  `json:"month_count"`
+	PerMonth     int64  `json:"per_month,omitempty"`
+	Unconfigured bool   `json:"unconfigured,omitempty"` // 数据库中有记录但配置中已没有该凭证的配额
+}
+
+func newQuotaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "quota",
+		Short: "Inspect and reset credential usage quotas",
+		Long: `Inspect and reset the usage counters of bearer tokens and API keys with quotas.
+
+Credentials are given as "apikey:<name>" or "bearer:<name>"; a bare name matches
+either type. The commands work while the server is running: the server writes
+its counters every quota.flush_interval seconds and picks up resets on the next write.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newQuotaShowCmd())
+	cmd.AddCommand(newQuotaResetCmd())
+	return cmd
+}
+
+func newQuotaShowCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "show [credential...]",
+		Short: "Show current usage against the configured quotas",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runQuotaShow(args, jsonOutput)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output usage as JSON")
+	return cmd
+}
+
+func newQuotaResetCmd() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "reset [credential...]",
+		Short: "Reset the usage counters of credentials",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) > 0) {
+				return fmt.Errorf("specify credentials to reset or --all")
+			}
+			return runQuotaReset(args)
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "Reset the counters of all credentials")
+	return cmd
+}
+
+// loadQuotaConfig 加载配置并检查配额存储路径
+func loadQuotaConfig() (*config.Config, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Quota.Path == "" {
+		return nil, fmt.Errorf("quota.path is not configured in %s", configPath)
+	}
+	return cfg, nil
+}
+
+func runQuotaShow(args []string, jsonOutput bool) error {
+	cfg, err := loadQuotaConfig()
+	if err != nil {
+		return err
+	}
+
+	usage, err := quota.List(cfg.Quota.Path, cfg.Quota.Location())
+	if err != nil {
+		return err
+	}
+
+	limits := quota.LimitsFromConfig(cfg)
+	rows := quotaRows(limits, usage, time.Now().In(cfg.Quota.Location()))
+	if len(args) > 0 {
+		keys, err := resolveQuotaKeys(cfg, args)
+		if err != nil {
+			return err
+		}
+		rows = filterQuotaRows(rows, keys)
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No credentials with quotas")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "CREDENTIAL\tDAY\tUSED\tLIMIT\tMONTH\tUSED\tLIMIT\n")
+	for _, r := range rows {
+		name := r.Credential
+		if r.Unconfigured {
+			name += " (no quota configured)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%d\t%s\n",
+			name, r.Day, r.DayCount, formatQuotaLimit(r.PerDay), r.Month, r.MonthCount, formatQuotaLimit(r.PerMonth))
+	}
+	return w.Flush()
+}
+
+func runQuotaReset(args []string) error {
+	cfg, err := loadQuotaConfig()
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	if len(args) > 0 {
+		if keys, err = resolveQuotaKeys(cfg, args); err != nil {
+			return err
+		}
+	}
+
+	removed, err := quota.Reset(cfg.Quota.Path, keys...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Reset usage of %d credential(s)\n", removed)
+	return nil
+}
+
+// quotaRows 合并配置的配额和数据库中的用量（按凭证排序，没有用量记录的凭证计数为 0）
+func quotaRows(limits map[string]quota.Limits, usage []quota.Usage, now time.Time) []quotaRow {
+	rows := make(map[string]*quotaRow)
+	for key, l := range limits {
+		rows[key] = &quotaRow{
+			Credential: key,
+			Day:        now.Format("2006-01-02"),
+			PerDay:     l.PerDay,
+			Month:      now.Format("2006-01"),
+			PerMonth:   l.PerMonth,
+		}
+	}
+	for _, u := range usage {
+		r, exists := rows[u.Key]
+		if !exists {
+			r = &quotaRow{Credential: u.Key, Unconfigured: true}
+			rows[u.Key] = r
+		}
+		r.Day, r.DayCount = u.Day, u.DayCount
+		r.Month, r.MonthCount = u.Month, u.MonthCount
+	}
+
+	result := make([]quotaRow, 0, len(rows))
+	for _, r := range rows {
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Credential < result[j].Credential })
+	return result
+}
+
+func filterQuotaRows(rows []quotaRow, keys []string) []quotaRow {
+	wanted := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		wanted[k] = true
+	}
+	var filtered []quotaRow
+	for _, r := range rows {
+		if wanted[r.Credential] {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// resolveQuotaKeys 将命令行参数解析为配额 key
+// "apikey:<name>" / "bearer:<name>" 原样使用；不带类型的名称匹配同名的 Bearer Token 和 API Key
+func resolveQuotaKeys(cfg *config.Config, args []string) ([]string, error) {
+	var keys []string
+	for _, arg := range args {
+		if method, name, ok := strings.Cut(arg, ":"); ok {
+			if (method != "apikey" && method != "bearer") || name == "" {
+				return nil, fmt.Errorf("invalid credential %q (expected apikey:<name> or bearer:<name>)", arg)
+			}
+			keys = append(keys, arg)
+			continue
+		}
+
+		found := false
+		for _, t := range cfg.BearerTokens {
+			if t.Name == arg {
+				keys = append(keys, quota.Key("bearer", arg))
+				found = true
+			}
+		}
+		for _, k := range cfg.APIKeys {
+			if k.Name == arg {
+				keys = append(keys, quota.Key("apikey", arg))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no bearer token or API key named %q", arg)
+		}
+	}
+	return keys, nil
+}
+
+func formatQuotaLimit(limit int64) string {
+	if limit <= 0 {
+		return "-"
+	}
+	return strconv.FormatInt(limit, 10)
+}
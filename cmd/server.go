@@ -44,20 +44,31 @@ func runServer(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// 4. 设置信号处理（优雅关闭 + 配置重载）
+	// 4. 设置信号处理（优雅关闭 + 配置重载 + 重新打开审计日志）
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	signal.Notify(sigChan, append([]os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}, reopenSignals...)...)
 
 	// 启动后台协程处理信号
 	go func() {
 		for sig := range sigChan {
+			if isReopenSignal(sig) {
+				// 外部轮转工具已移走审计日志文件
+				logger.Info("Received SIGUSR1, reopening audit log...")
+				if err := srv.ReopenAudit(); err != nil {
+					logger.Error("Failed to reopen audit log", zap.Error(err))
+				}
+				continue
+			}
+
 			switch sig {
 			case syscall.SIGHUP:
 				// 配置热重载
 				logger.Info("Received SIGHUP, reloading configuration...")
-				if err := reloadConfig(srv); err != nil {
+				err := reloadConfig(srv)
+				if err != nil {
 					logger.Error("Failed to reload config", zap.Error(err))
 				}
+				srv.ObserveReload(err)
 
 			case os.Interrupt, syscall.SIGTERM:
 				// 优雅关闭
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// reopenSignals 通知重新打开审计日志文件的信号（供 logrotate 等外部轮转工具使用）
+var reopenSignals = []os.Signal{syscall.SIGUSR1}
+
+// isReopenSignal 判断是否为重新打开日志文件的信号
+func isReopenSignal(sig os.Signal) bool {
+	return sig == syscall.SIGUSR1
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package cmd
+
+import "os"
+
+// reopenSignals Windows 没有 SIGUSR1，不支持通过信号重新打开审计日志文件
+var reopenSignals []os.Signal
+
+// isReopenSignal 判断是否为重新打开日志文件的信号
+func isReopenSignal(os.Signal) bool {
+	return false
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -99,6 +100,19 @@ func printConfigSummary(cfg *config.Config) {
 		fmt.Println()
 	}
 
+	if len(cfg.Roles) > 0 {
+		fmt.Printf("✓ Role Hierarchy: %d roles configured\n", len(cfg.Roles))
+		names := make([]string, 0, len(cfg.Roles))
+		for name := range cfg.Roles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  - %s → %v\n", name, cfg.ExpandRoles([]string{name})[1:])
+		}
+		fmt.Println()
+	}
+
 	if cfg.JWT.Secret != "" {
 		fmt.Printf("✓ JWT: enabled\n")
 		if cfg.JWT.Issuer != "" {
@@ -118,8 +132,15 @@ func printConfigSummary(cfg *config.Config) {
 			fmt.Printf("  - %s", p.Name)
 			if p.Host != "" {
 				fmt.Printf(" (host=%s", p.Host)
-				if p.PathPrefix != "" {
+				switch {
+				case p.PathPrefix != "":
 					fmt.Printf(", path=%s", p.PathPrefix)
+				case p.PathExact != "":
+					fmt.Printf(", path_exact=%s", p.PathExact)
+				case p.PathGlob != "":
+					fmt.Printf(", path_glob=%s", p.PathGlob)
+				case p.PathRegex != "":
+					fmt.Printf(", path_regex=%s", p.PathRegex)
 				}
 				fmt.Printf(")")
 			}
@@ -18,6 +18,8 @@ type Event struct {
 	RequestID    string    `json:"request_id,omitempty"`
 	ClientIP     string    `json:"client_ip,omitempty"`
 	DirectIP     string    `json:"direct_ip,omitempty"`
+	Country      string    `json:"country,omitempty"` // 客户端国家代码（GeoIP）
+	ASN          uint      `json:"asn,omitempty"`     // 客户端自治系统号（GeoIP）
 	TrustedProxy bool      `json:"trusted_proxy"`
 	Host         string    `json:"host,omitempty"`
 	URI          string    `json:"uri,omitempty"`
@@ -26,7 +28,11 @@ type Event struct {
 	AuthName     string    `json:"auth_name,omitempty"`
 	User         string    `json:"user,omitempty"`
 	Roles        []string  `json:"roles,omitempty"`
+	Groups       []string  `json:"groups,omitempty"`
 	Policy       string    `json:"policy,omitempty"`
+	Rule         string    `json:"rule,omitempty"`
+	Target       string    `json:"target,omitempty"` // 管理操作的对象（如被解封的限流 key）
+	Scope        string    `json:"scope,omitempty"`  // 管理操作涉及的限流器（逗号分隔，如 "global,policy:login"）
 	Result       string    `json:"result"`
 	Reason       string    `json:"reason,omitempty"`
 	Status       int       `json:"status"`
@@ -39,10 +45,12 @@ type Logger struct {
 	mu      sync.Mutex
 	encoder *json.Encoder
 	closer  io.Closer
+	file    *rotatingFile // 文件输出（stdout/stderr 时为 nil）
 }
 
 // NewLogger 创建审计日志记录器
-func NewLogger(cfg config.AuditConfig) (*Logger, error) {
+// 文件输出按配置轮转；轮转、压缩和清理旧文件的错误通过 onError 报告（不影响写入）
+func NewLogger(cfg config.AuditConfig, onError func(error)) (*Logger, error) {
 	if !cfg.Enabled {
 		return &Logger{enabled: false}, nil
 	}
@@ -55,6 +63,7 @@ func NewLogger(cfg config.AuditConfig) (*Logger, error) {
 	var (
 		writer io.Writer
 		closer io.Closer
+		file   *rotatingFile
 	)
 
 	switch output {
@@ -63,9 +72,10 @@ func NewLogger(cfg config.AuditConfig) (*Logger, error) {
 	case "stderr":
 		writer = os.Stderr
 	default:
-		file, err := os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		var err error
+		file, err = newRotatingFile(output, RotateOptionsFromConfig(cfg), onError, time.Now)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open audit log file: %w", err)
+			return nil, err
 		}
 		writer = file
 		closer = file
@@ -78,9 +88,21 @@ func NewLogger(cfg config.AuditConfig) (*Logger, error) {
 		enabled: true,
 		encoder: encoder,
 		closer:  closer,
+		file:    file,
 	}, nil
 }
 
+// RotateOptionsFromConfig 将审计配置转换为轮转选项
+func RotateOptionsFromConfig(cfg config.AuditConfig) RotateOptions {
+	return RotateOptions{
+		MaxSize:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+		Interval:   cfg.RotateInterval,
+		Compress:   cfg.Compress,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     time.Duration(cfg.MaxAgeDays) * 24 * time.Hour,
+	}
+}
+
 // Log 输出一条审计日志
 func (l *Logger) Log(event *Event) error {
 	if l == nil || !l.enabled || event == nil {
@@ -93,6 +115,14 @@ func (l *Logger) Log(event *Event) error {
 	return l.encoder.Encode(event)
 }
 
+// Reopen 重新打开输出文件（外部轮转工具移走文件后调用）；输出到 stdout/stderr 时不做任何事
+func (l *Logger) Reopen() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Reopen()
+}
+
 // Close 关闭底层资源（如果有）
 func (l *Logger) Close() error {
 	if l == nil || l.closer == nil {
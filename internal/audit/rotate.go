@@ -0,0 +1,322 @@
+package audit
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat 轮转文件名中的时间格式（本地时间），如 audit-2024-01-02T15-04-05.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// compressSuffix 压缩后的轮转文件后缀
+const compressSuffix = ".gz"
+
+// RotateOptions 文件输出的轮转选项，零值表示不轮转
+type RotateOptions struct {
+	MaxSize    int64         // 文件达到该字节数后轮转（<= 0 不按大小轮转）
+	Interval   string        // "hourly" / "daily"：在本地时间的整点 / 零点后轮转（空表示不按时间轮转）
+	Compress   bool          // gzip 压缩轮转出的文件
+	MaxBackups int           // 最多保留的轮转文件数（<= 0 不限制）
+	MaxAge     time.Duration // 轮转文件的最长保留时间（<= 0 不限制）
+}
+
+// rotatingFile 按大小 / 时间轮转的日志文件
+// 轮转在 Write 中持锁完成：先把当前文件改名为带时间戳的备份，再打开新文件，写入不会丢失或交错。
+// 压缩和清理旧文件在后台协程中进行，不阻塞写入
+type rotatingFile struct {
+	path    string
+	opts    RotateOptions
+	onError func(error)
+	now     func() time.Time
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time // 下一次按时间轮转的时刻（未启用时为零值）
+
+	cleanup  chan struct{} // 通知后台协程压缩 / 清理（容量 1，合并多次通知）
+	stop     chan struct{}
+	finished chan struct{}
+}
+
+// newRotatingFile 打开（或创建）日志文件并启动后台清理协程
+func newRotatingFile(path string, opts RotateOptions, onError func(error), now func() time.Time) (*rotatingFile, error) {
+	if onError == nil {
+		onError = func(error) {}
+	}
+	f := &rotatingFile{
+		path:     path,
+		opts:     opts,
+		onError:  onError,
+		now:      now,
+		cleanup:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	if opts.Interval != "" {
+		// 已有文件从最后修改时间计算轮转时刻，重启后仍按原来的周期轮转
+		start := now()
+		if info, err := f.file.Stat(); err == nil && info.Size() > 0 {
+			start = info.ModTime()
+		}
+		f.nextRotate = nextBoundary(start, opts.Interval)
+	}
+
+	go f.run()
+	// 处理上次运行遗留的未压缩 / 过期文件
+	f.cleanup <- struct{}{}
+	return f, nil
+}
+
+// open 以追加方式打开日志文件，调用方持有锁（或在初始化时调用）
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat audit log file: %w", err)
+	}
+	old := f.file
+	f.file = file
+	f.size = info.Size()
+	if old != nil {
+		_ = old.Close()
+	}
+	return nil
+}
+
+// Write 写入一条记录，需要时先轮转；轮转失败时通过 onError 报告并继续写入当前文件
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	now := f.now()
+	if !f.nextRotate.IsZero() && !now.Before(f.nextRotate) {
+		if f.size > 0 {
+			if err := f.rotate(now); err != nil {
+				f.onError(err)
+			}
+		}
+		f.nextRotate = nextBoundary(now, f.opts.Interval)
+	}
+	if f.opts.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.opts.MaxSize {
+		if err := f.rotate(now); err != nil {
+			f.onError(err)
+			// 避免每次写入都重试失败的轮转：写满下一个 MaxSize 后再试
+			f.size = 0
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate 将当前文件改名为备份并打开新文件，调用方持有锁
+func (f *rotatingFile) rotate(now time.Time) error {
+	backup := f.backupName(now)
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate audit log file: %w", err)
+	}
+	// 新文件打开失败时继续写入已改名的文件，不丢失事件
+	if err := f.open(); err != nil {
+		return err
+	}
+	select {
+	case f.cleanup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Reopen 关闭并重新打开日志文件（供外部轮转工具在移走文件后通知）
+func (f *rotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.open()
+}
+
+// Close 关闭文件并等待后台协程处理完剩余的清理
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	if f.file == nil {
+		f.mu.Unlock()
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	f.mu.Unlock()
+
+	close(f.stop)
+	<-f.finished
+	return err
+}
+
+// run 后台处理压缩和清理，停止前处理完尚未处理的通知
+func (f *rotatingFile) run() {
+	defer close(f.finished)
+	for {
+		select {
+		case <-f.cleanup:
+			f.cleanupBackups()
+		case <-f.stop:
+			select {
+			case <-f.cleanup:
+				f.cleanupBackups()
+			default:
+			}
+			return
+		}
+	}
+}
+
+// backup 一个轮转出的文件
+type backup struct {
+	path       string
+	time       time.Time
+	compressed bool
+}
+
+// backupName 返回轮转文件名；同一时刻已有备份时顺延 1 毫秒，保证文件名唯一且可排序
+func (f *rotatingFile) backupName(now time.Time) string {
+	dir, prefix, ext := f.nameParts()
+	for t := now; ; t = t.Add(time.Millisecond) {
+		name := filepath.Join(dir, prefix+t.Local().Format(backupTimeFormat)+ext)
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			if _, err := os.Lstat(name + compressSuffix); os.IsNotExist(err) {
+				return name
+			}
+		}
+	}
+}
+
+// nameParts 拆分日志文件路径：目录、备份文件名前缀（"audit-"）和扩展名（".log"）
+func (f *rotatingFile) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(f.path)
+	base := filepath.Base(f.path)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// backups 列出所有轮转文件，按时间从新到旧排序
+func (f *rotatingFile) backups() ([]backup, error) {
+	dir, prefix, ext := f.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []backup
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		compressed := strings.HasSuffix(name, compressSuffix)
+		stamp := strings.TrimSuffix(name, compressSuffix)
+		if !strings.HasPrefix(stamp, prefix) || !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimPrefix(stamp, prefix), ext)
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		result = append(result, backup{path: filepath.Join(dir, name), time: t, compressed: compressed})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].time.After(result[j].time) })
+	return result, nil
+}
+
+// cleanupBackups 删除超出数量或过期的轮转文件，并压缩剩余的未压缩文件
+func (f *rotatingFile) cleanupBackups() {
+	backups, err := f.backups()
+	if err != nil {
+		f.onError(fmt.Errorf("failed to list audit log backups: %w", err))
+		return
+	}
+
+	now := f.now()
+	for i, b := range backups {
+		expired := f.opts.MaxAge > 0 && now.Sub(b.time) > f.opts.MaxAge
+		if (f.opts.MaxBackups > 0 && i >= f.opts.MaxBackups) || expired {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				f.onError(fmt.Errorf("failed to remove audit log backup: %w", err))
+			}
+			continue
+		}
+		if f.opts.Compress && !b.compressed {
+			if err := compressFile(b.path); err != nil {
+				f.onError(fmt.Errorf("failed to compress audit log backup: %w", err))
+			}
+		}
+	}
+}
+
+// compressFile 将文件压缩为 path.gz 并删除原文件；先写临时文件再改名，中途失败不会留下不完整的 .gz
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+compressSuffix); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// nextBoundary 返回 t 之后的下一个整点（hourly）或零点（daily）
+func nextBoundary(t time.Time, interval string) time.Time {
+	switch interval {
+	case "hourly":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+	case "daily":
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Time{}
+	}
+}
@@ -0,0 +1,280 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// fakeClock 测试用的可调时钟
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.t = t
+	c.mu.Unlock()
+}
+
+// readLines 读取目录中所有日志文件（包括 .gz）的行，以及轮转文件名（按名称排序）
+func readLines(t *testing.T, dir string) (lines []string, backups []string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = file
+		if strings.HasSuffix(entry.Name(), ".gz") {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				t.Fatalf("%s: %v", entry.Name(), err)
+			}
+			r = gz
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		_ = file.Close()
+		if entry.Name() != "audit.log" {
+			backups = append(backups, entry.Name())
+		}
+	}
+	sort.Strings(lines)
+	return lines, backups
+}
+
+// TestRotatingFile_Size 测试按大小轮转不丢失记录
+func TestRotatingFile_Size(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local)}
+	f, err := newRotatingFile(filepath.Join(dir, "audit.log"), RotateOptions{MaxSize: 100}, nil, clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for i := 0; i < 10; i++ {
+		line := fmt.Sprintf("event-%02d-%s", i, strings.Repeat("x", 30))
+		want = append(want, line)
+		if _, err := f.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, backups := readLines(t, dir)
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lost or corrupted lines: %v", lines)
+	}
+	// 每个文件最多 2 行（2 * 40 字节 <= 100）
+	if len(backups) != 4 {
+		t.Errorf("expected 4 backups, got %v", backups)
+	}
+	for _, name := range backups {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 100 {
+			t.Errorf("%s is %d bytes, exceeds max size", name, info.Size())
+		}
+	}
+}
+
+// TestRotatingFile_Interval 测试按时间轮转（空文件不轮转）
+func TestRotatingFile_Interval(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Date(2024, 1, 2, 23, 59, 0, 0, time.Local)}
+	f, err := newRotatingFile(filepath.Join(dir, "audit.log"), RotateOptions{Interval: "daily"}, nil, clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.Write([]byte("day1\n"))
+	clock.Set(time.Date(2024, 1, 3, 0, 0, 1, 0, time.Local))
+	_, _ = f.Write([]byte("day2\n"))
+	// 第三天没有写入，第四天的第一条记录轮转出第二天的文件
+	clock.Set(time.Date(2024, 1, 5, 8, 0, 0, 0, time.Local))
+	_, _ = f.Write([]byte("day4\n"))
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, backups := readLines(t, dir)
+	want := []string{"audit-2024-01-03T00-00-01.000.log", "audit-2024-01-05T08-00-00.000.log"}
+	if strings.Join(backups, ",") != strings.Join(want, ",") {
+		t.Errorf("backups = %v, want %v", backups, want)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "audit.log"))
+	if string(data) != "day4\n" {
+		t.Errorf("current file = %q", data)
+	}
+}
+
+// TestRotatingFile_Retention 测试压缩、最大备份数和最长保留时间
+func TestRotatingFile_Retention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)
+	// 上次运行遗留的过期文件和未压缩文件
+	stale := filepath.Join(dir, "audit-2024-01-01T00-00-00.000.log.gz")
+	leftover := filepath.Join(dir, "audit-2024-01-09T00-00-00.000.log")
+	unrelated := filepath.Join(dir, "audit-notes.log")
+	for _, path := range []string{stale, leftover, unrelated} {
+		if err := os.WriteFile(path, []byte("old\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock := &fakeClock{t: now}
+	f, err := newRotatingFile(filepath.Join(dir, "audit.log"), RotateOptions{
+		MaxSize:    10,
+		Compress:   true,
+		MaxBackups: 3,
+		MaxAge:     7 * 24 * time.Hour,
+	}, func(err error) { t.Errorf("unexpected error: %v", err) }, clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		clock.Set(now.Add(time.Duration(i) * time.Minute))
+		if _, err := f.Write([]byte(fmt.Sprintf("event-%d\n", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, backups := readLines(t, dir)
+	want := []string{
+		"audit-2024-01-10T12-02-00.000.log.gz",
+		"audit-2024-01-10T12-03-00.000.log.gz",
+		"audit-2024-01-10T12-04-00.000.log.gz",
+		"audit-notes.log",
+	}
+	if strings.Join(backups, ",") != strings.Join(want, ",") {
+		t.Errorf("backups = %v, want %v", backups, want)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Error("leftover backup beyond max_backups should be removed")
+	}
+}
+
+// TestRotatingFile_ConcurrentWrites 测试并发写入和轮转时记录不丢失、不交错
+func TestRotatingFile_ConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	var tick sync.Mutex
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local)
+	clock := func() time.Time {
+		tick.Lock()
+		defer tick.Unlock()
+		now = now.Add(time.Millisecond)
+		return now
+	}
+	f, err := newRotatingFile(filepath.Join(dir, "audit.log"), RotateOptions{MaxSize: 512, Compress: true}, nil, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, events = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < events; i++ {
+				_, _ = f.Write([]byte(fmt.Sprintf("{\"writer\":%d,\"event\":%d}\n", w, i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, _ := readLines(t, dir)
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		seen[line] = true
+	}
+	for w := 0; w < writers; w++ {
+		for i := 0; i < events; i++ {
+			if line := fmt.Sprintf("{\"writer\":%d,\"event\":%d}", w, i); !seen[line] {
+				t.Fatalf("missing or corrupted line %s", line)
+			}
+		}
+	}
+	if len(lines) != writers*events {
+		t.Errorf("expected %d lines, got %d", writers*events, len(lines))
+	}
+}
+
+// TestLogger_Reopen 测试外部轮转工具移走文件后重新打开
+func TestLogger_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	logger, err := NewLogger(config.AuditConfig{Enabled: true, Output: path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	if err := logger.Log(&Event{Result: "success"}); err != nil {
+		t.Fatal(err)
+	}
+	moved := filepath.Join(dir, "audit.log.1")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Log(&Event{Result: "denied"}); err != nil {
+		t.Fatal(err)
+	}
+
+	old, _ := os.ReadFile(moved)
+	current, _ := os.ReadFile(path)
+	if !strings.Contains(string(old), `"result":"success"`) || strings.Contains(string(old), "denied") {
+		t.Errorf("moved file = %q", old)
+	}
+	if !strings.Contains(string(current), `"result":"denied"`) {
+		t.Errorf("reopened file = %q", current)
+	}
+
+	// stdout 输出不需要重新打开
+	stdout, err := NewLogger(config.AuditConfig{Enabled: true, Output: "stdout"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stdout.Reopen(); err != nil {
+		t.Error(err)
+	}
+}
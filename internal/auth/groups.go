@@ -0,0 +1,83 @@
+package auth
+
+// ResolveGroups 解析认证结果所属的组，并追加组和映射规则授予的角色
+// 成员按用户名（JWT 为 subject）和凭证名称匹配；group_mapping 只对带 claims 的结果（JWT）生效
+// 返回副本，不修改原结果；没有任何组时原样返回
+func ResolveGroups(result *AuthResult, store *AuthStore) *AuthResult {
+	if len(store.GroupsByMember) == 0 && len(store.GroupMappings) == 0 {
+		return result
+	}
+
+	resolved := *result
+	groups := newOrderedSet(result.Groups)
+	roles := newOrderedSet(result.Roles)
+
+	// 1. 静态成员关系
+	for _, member := range []string{result.User, result.Name} {
+		if member == "" {
+			continue
+		}
+		groups.add(store.GroupsByMember[member]...)
+	}
+
+	// 2. 外部身份映射
+	for i := range store.GroupMappings {
+		m := &store.GroupMappings[i]
+		for _, value := range claimValues(result.Claims, m.ClaimName()) {
+			if m.MatchValue(value) {
+				groups.add(m.Groups...)
+				roles.add(m.Roles...)
+				break
+			}
+		}
+	}
+
+	// 3. 组授予的角色
+	for _, g := range groups.items {
+		roles.add(store.GroupRoles[g]...)
+	}
+
+	resolved.Groups = groups.items
+	resolved.Roles = roles.items
+	return &resolved
+}
+
+// claimValues 读取 claim 的字符串值（字符串或字符串数组），其他类型忽略
+func claimValues(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// orderedSet 保持插入顺序的去重集合
+type orderedSet struct {
+	items []string
+	seen  map[string]bool
+}
+
+func newOrderedSet(initial []string) *orderedSet {
+	s := &orderedSet{seen: make(map[string]bool, len(initial))}
+	s.add(initial...)
+	return s
+}
+
+func (s *orderedSet) add(values ...string) {
+	for _, v := range values {
+		if !s.seen[v] {
+			s.seen[v] = true
+			s.items = append(s.items, v)
+		}
+	}
+}
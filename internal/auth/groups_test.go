@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestResolveGroups 测试组成员关系和外部身份映射
+func TestResolveGroups(t *testing.T) {
+	cfg := &config.Config{
+		Groups: []config.GroupConfig{
+			{Name: "platform", Members: []string{"alice", "deploy-token"}, Roles: []string{"ops"}},
+			{Name: "auditors", Members: []string{"alice"}, Roles: []string{"auditor"}},
+			{Name: "engineering", Roles: []string{"developer"}},
+		},
+		GroupMappings: []config.GroupMapping{
+			{Claim: "groups", Match: "prefix", Value: "eng-", Groups: []string{"engineering"}},
+			{Claim: "groups", Match: "regex", Value: `admins-(eu|us)`, Roles: []string{"admin"}},
+			{Claim: "department", Value: "finance", Roles: []string{"finance"}},
+		},
+	}
+	store := BuildStore(cfg)
+
+	tests := []struct {
+		name       string
+		result     *AuthResult
+		wantGroups []string
+		wantRoles  []string
+	}{
+		{
+			name:       "Basic user in two groups",
+			result:     &AuthResult{Method: "basic", Name: "alice-cred", User: "alice", Roles: []string{"user"}},
+			wantGroups: []string{"platform", "auditors"},
+			wantRoles:  []string{"user", "ops", "auditor"},
+		},
+		{
+			name:       "Credential name membership",
+			result:     &AuthResult{Method: "bearer", Name: "deploy-token", Roles: []string{"service"}},
+			wantGroups: []string{"platform"},
+			wantRoles:  []string{"service", "ops"},
+		},
+		{
+			name: "JWT claims mapped to groups and roles",
+			result: &AuthResult{Method: "jwt", User: "bob", Roles: []string{"user"}, Claims: map[string]interface{}{
+				"groups":     []interface{}{"eng-backend", "admins-eu", 42},
+				"department": "finance",
+			}},
+			wantGroups: []string{"engineering"},
+			wantRoles:  []string{"user", "admin", "finance", "developer"},
+		},
+		{
+			name: "Regex must match whole value",
+			result: &AuthResult{Method: "jwt", User: "carol", Claims: map[string]interface{}{
+				"groups": []interface{}{"admins-eu-contractors"},
+			}},
+			wantGroups: nil,
+			wantRoles:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveGroups(tt.result, store)
+			if !reflect.DeepEqual(got.Groups, tt.wantGroups) {
+				t.Errorf("Groups = %v, want %v", got.Groups, tt.wantGroups)
+			}
+			if !reflect.DeepEqual(got.Roles, tt.wantRoles) {
+				t.Errorf("Roles = %v, want %v", got.Roles, tt.wantRoles)
+			}
+		})
+	}
+
+	// 原结果不应被修改
+	original := &AuthResult{Method: "basic", User: "alice", Roles: []string{"user"}}
+	ResolveGroups(original, store)
+	if len(original.Roles) != 1 || original.Groups != nil {
+		t.Errorf("Expected original result to be unchanged, got %+v", original)
+	}
+}
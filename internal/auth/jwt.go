@@ -119,6 +119,7 @@ func TryJWT(tokenString string, jwtCfg *config.JWTConfig) *AuthResult {
 		User:     user,
 		Roles:    roles,
 		Metadata: metadata,
+		Claims:   claims,
 	}
 }
 
@@ -24,5 +24,14 @@ func BuildStore(cfg *config.Config) *AuthStore {
 		store.APIKeyByName[k.Name] = k
 	}
 
+	// 构建用户组索引
+	for _, g := range cfg.Groups {
+		store.GroupRoles[g.Name] = g.Roles
+		for _, member := range g.Members {
+			store.GroupsByMember[member] = append(store.GroupsByMember[member], g.Name)
+		}
+	}
+	store.GroupMappings = cfg.GroupMappings
+
 	return store
 }
@@ -6,11 +6,13 @@ import "github.com/nerdneilsfield/tiny-auth/internal/config"
 //
 //nolint:revive // exported name is stable API surface
 type AuthResult struct {
-	Method   string            // 认证方法: "basic", "bearer", "apikey", "jwt", "anonymous"
-	Name     string            // 配置名称（如 "admin-user"）
-	User     string            // 用户名或 subject
-	Roles    []string          // 关联的角色
-	Metadata map[string]string // 额外的元数据（如 JWT issuer）
+	Method   string                 // 认证方法: "basic", "bearer", "apikey", "jwt", "anonymous"
+	Name     string                 // 配置名称（如 "admin-user"）
+	User     string                 // 用户名或 subject
+	Roles    []string               // 关联的角色
+	Groups   []string               // 所属的组（认证后由 ResolveGroups 填充）
+	Metadata map[string]string      // 额外的元数据（如 JWT issuer）
+	Claims   map[string]interface{} // JWT claims（仅 JWT 认证，用于条件表达式）
 }
 
 // AuthStore 认证存储，用于快速查找
@@ -26,6 +28,11 @@ type AuthStore struct {
 	BasicByName  map[string]config.BasicAuthConfig
 	BearerByName map[string]config.BearerConfig
 	APIKeyByName map[string]config.APIKeyConfig
+
+	// 用户组（认证后解析成员关系）
+	GroupsByMember map[string][]string // 成员（用户名 / subject / 凭证名称）→ 组名
+	GroupRoles     map[string][]string // 组名 → 角色
+	GroupMappings  []config.GroupMapping
 }
 
 // NewAuthStore 创建新的认证存储
@@ -37,5 +44,8 @@ func NewAuthStore() *AuthStore {
 		BasicByName:   make(map[string]config.BasicAuthConfig),
 		BearerByName:  make(map[string]config.BearerConfig),
 		APIKeyByName:  make(map[string]config.APIKeyConfig),
+
+		GroupsByMember: make(map[string][]string),
+		GroupRoles:     make(map[string][]string),
 	}
 }
@@ -0,0 +1,69 @@
+package authz
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCacheEntries 单个 Webhook 决策缓存的最大条目数
+const maxCacheEntries = 10000
+
+// decisionCache Webhook 决策缓存（按决策输入哈希索引）
+type decisionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	decision  *Decision
+	expiresAt time.Time
+}
+
+func newDecisionCache(ttl time.Duration) *decisionCache {
+	return &decisionCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// get 获取未过期的缓存决策
+func (c *decisionCache) get(key string) (*Decision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return entry.decision, true
+}
+
+// put 写入缓存，达到容量上限时先清理过期条目，仍然已满则整体清空
+func (c *decisionCache) put(key string, decision *Decision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			c.entries = make(map[string]cacheEntry)
+		}
+	}
+
+	c.entries[key] = cacheEntry{
+		decision:  decision,
+		expiresAt: now.Add(c.ttl),
+	}
+}
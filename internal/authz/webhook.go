@@ -0,0 +1,253 @@
+package authz
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/tracing"
+)
+
+const (
+	// SignatureHeader 请求体 HMAC-SHA256 签名（格式: sha256=<hex>）
+	SignatureHeader = "X-Tiny-Auth-Signature"
+	// TimestampHeader 签名时间戳（Unix 秒），参与签名计算
+	TimestampHeader = "X-Tiny-Auth-Timestamp"
+
+	// maxResponseBytes Webhook 响应体的最大读取字节数
+	maxResponseBytes = 64 * 1024
+)
+
+// Request 发送给 Webhook 的决策输入
+type Request struct {
+	Auth    AuthInfo    `json:"auth"`
+	Request RequestInfo `json:"request"`
+}
+
+// AuthInfo 认证结果（AuthResult 的 JSON 表示）
+type AuthInfo struct {
+	Method   string            `json:"method"`
+	Name     string            `json:"name,omitempty"`
+	User     string            `json:"user,omitempty"`
+	Roles    []string          `json:"roles,omitempty"`
+	Groups   []string          `json:"groups,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// RequestInfo 原始请求信息
+type RequestInfo struct {
+	Host     string `json:"host"`
+	URI      string `json:"uri"`
+	Method   string `json:"method"`
+	ClientIP string `json:"client_ip"`
+}
+
+// Decision Webhook 返回的授权决策
+type Decision struct {
+	Allow   bool              `json:"allow"`             // 是否放行
+	Reason  string            `json:"reason,omitempty"`  // 拒绝原因（写入日志）
+	Headers map[string]string `json:"headers,omitempty"` // 额外注入的 headers
+	Roles   []string          `json:"roles,omitempty"`   // 额外授予的角色
+}
+
+// NewRequest 根据认证结果和请求信息构建决策输入
+func NewRequest(result *auth.AuthResult, host, uri, method, clientIP string) *Request {
+	return &Request{
+		Auth: AuthInfo{
+			Method:   result.Method,
+			Name:     result.Name,
+			User:     result.User,
+			Roles:    result.Roles,
+			Groups:   result.Groups,
+			Metadata: result.Metadata,
+		},
+		Request: RequestInfo{
+			Host:     host,
+			URI:      uri,
+			Method:   method,
+			ClientIP: clientIP,
+		},
+	}
+}
+
+// Webhook 外部授权 Webhook 客户端
+type Webhook struct {
+	url     string
+	secret  []byte
+	timeout time.Duration
+	client  *http.Client
+	cache   *decisionCache // 为 nil 时不缓存
+}
+
+// NewWebhook 根据配置创建 Webhook 客户端
+func NewWebhook(cfg *config.AuthzWebhookConfig) (*Webhook, error) {
+	tlsConfig, err := buildTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	w := &Webhook{
+		url:     cfg.URL,
+		secret:  []byte(cfg.Secret),
+		timeout: time.Duration(cfg.TimeoutMs) * time.Millisecond,
+		client: &http.Client{
+			Transport: transport,
+			// 不跟随重定向，避免把决策输入发送到非预期地址
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	if cfg.CacheTTLSecs > 0 {
+		w.cache = newDecisionCache(time.Duration(cfg.CacheTTLSecs) * time.Second)
+	}
+
+	return w, nil
+}
+
+// buildTLSConfig 构建 mTLS / 自定义 CA 配置
+func buildTLSConfig(cfg *config.AuthzWebhookConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load webhook client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// Caching 是否启用了决策缓存
+func (w *Webhook) Caching() bool {
+	return w.cache != nil
+}
+
+// Decide 请求 Webhook 做出授权决策，cached 表示决策来自缓存
+// 返回的 Decision 可能来自缓存，调用方不得修改
+func (w *Webhook) Decide(ctx context.Context, req *Request) (decision *Decision, cached bool, err error) {
+	// 追踪：每次决策一个 client span（包括缓存命中），trace context 随请求传给 Webhook
+	ctx, span := tracing.Start(ctx, "authz.webhook", trace.WithSpanKind(trace.SpanKindClient))
+	status := 0
+	defer func() {
+		endSpan(span, w.url, cached, status, err)
+	}()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode webhook request: %w", err)
+	}
+
+	// 缓存键为决策输入的哈希
+	sum := sha256.Sum256(body)
+	key := hex.EncodeToString(sum[:])
+	if w.cache != nil {
+		if hit, ok := w.cache.get(key); ok {
+			return hit, true, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	tracing.Inject(ctx, httpReq.Header)
+
+	if len(w.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		httpReq.Header.Set(TimestampHeader, timestamp)
+		httpReq.Header.Set(SignatureHeader, Signature(w.secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return nil, false, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	status = resp.StatusCode
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	decision = &Decision{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(decision); err != nil {
+		return nil, false, fmt.Errorf("invalid webhook response: %w", err)
+	}
+
+	if w.cache != nil {
+		w.cache.put(key, decision)
+	}
+
+	return decision, false, nil
+}
+
+// endSpan 记录 Webhook 调用的结果并结束 span
+// 只记录 Webhook 的 host，不记录可能包含凭证的完整 URL
+func endSpan(span trace.Span, target string, cached bool, status int, err error) {
+	if span.IsRecording() {
+		span.SetAttributes(attribute.Bool("tinyauth.cache_hit", cached))
+		if u, parseErr := url.Parse(target); parseErr == nil {
+			span.SetAttributes(attribute.String("server.address", u.Host))
+		}
+		if status != 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+// Signature 计算请求签名: sha256=HEX(HMAC-SHA256(secret, timestamp + "." + body))
+// Webhook 服务端可用相同方式校验请求来源
+func Signature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,223 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+func testRequest() *Request {
+	return NewRequest(&auth.AuthResult{
+		Method: "basic",
+		Name:   "alice-cred",
+		User:   "alice",
+		Roles:  []string{"user"},
+	}, "api.example.com", "/tenants/42", "GET", "203.0.113.7")
+}
+
+// TestWebhook_Decide 测试 Webhook 返回的允许/拒绝决策
+func TestWebhook_Decide(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		wantAllow bool
+		wantRoles []string
+	}{
+		{
+			name:      "Allow with extra roles",
+			response:  `{"allow": true, "roles": ["tenant-admin"], "headers": {"X-Tenant": "42"}}`,
+			wantAllow: true,
+			wantRoles: []string{"tenant-admin"},
+		},
+		{
+			name:      "Deny",
+			response:  `{"allow": false, "reason": "not a member"}`,
+			wantAllow: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Request
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("Expected POST, got %s", r.Method)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("Failed to decode request: %v", err)
+				}
+				_, _ = io.WriteString(w, tt.response)
+			}))
+			defer ts.Close()
+
+			webhook, err := NewWebhook(&config.AuthzWebhookConfig{URL: ts.URL, TimeoutMs: 1000, FailureMode: "closed"})
+			if err != nil {
+				t.Fatalf("NewWebhook() error = %v", err)
+			}
+
+			decision, _, err := webhook.Decide(context.Background(), testRequest())
+			if err != nil {
+				t.Fatalf("Decide() error = %v", err)
+			}
+
+			if decision.Allow != tt.wantAllow {
+				t.Errorf("Allow = %v, want %v", decision.Allow, tt.wantAllow)
+			}
+			if len(decision.Roles) != len(tt.wantRoles) {
+				t.Errorf("Roles = %v, want %v", decision.Roles, tt.wantRoles)
+			}
+
+			// 验证决策输入
+			if got.Auth.User != "alice" || got.Request.Host != "api.example.com" ||
+				got.Request.URI != "/tenants/42" || got.Request.ClientIP != "203.0.113.7" {
+				t.Errorf("Unexpected decision input: %+v", got)
+			}
+		})
+	}
+}
+
+// TestWebhook_Errors 测试超时与非 200 响应
+func TestWebhook_Errors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		_, _ = io.WriteString(w, `{"allow": true}`)
+	}))
+	defer slow.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "Timeout", url: slow.URL},
+		{name: "Server error", url: broken.URL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook, err := NewWebhook(&config.AuthzWebhookConfig{URL: tt.url, TimeoutMs: 50, FailureMode: "closed"})
+			if err != nil {
+				t.Fatalf("NewWebhook() error = %v", err)
+			}
+			if _, _, err := webhook.Decide(context.Background(), testRequest()); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+// TestWebhook_Cache 测试相同决策输入命中缓存
+func TestWebhook_Cache(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = io.WriteString(w, `{"allow": true}`)
+	}))
+	defer ts.Close()
+
+	webhook, err := NewWebhook(&config.AuthzWebhookConfig{URL: ts.URL, TimeoutMs: 1000, FailureMode: "closed", CacheTTLSecs: 60})
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+
+	if !webhook.Caching() {
+		t.Fatal("expected caching to be enabled")
+	}
+	for i := 0; i < 3; i++ {
+		_, cached, err := webhook.Decide(context.Background(), testRequest())
+		if err != nil {
+			t.Fatalf("Decide() error = %v", err)
+		}
+		if cached != (i > 0) {
+			t.Errorf("call %d: cached = %v", i, cached)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 webhook call, got %d", calls.Load())
+	}
+
+	// 不同的决策输入不应命中缓存
+	other := testRequest()
+	other.Request.URI = "/tenants/43"
+	if _, _, err := webhook.Decide(context.Background(), other); err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 webhook calls, got %d", calls.Load())
+	}
+}
+
+// TestWebhook_Signature 测试共享密钥签名
+func TestWebhook_Signature(t *testing.T) {
+	secret := []byte("webhook-shared-secret")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(TimestampHeader)
+		if timestamp == "" {
+			t.Error("Missing timestamp header")
+		}
+		if got, want := r.Header.Get(SignatureHeader), Signature(secret, timestamp, body); got != want {
+			t.Errorf("Signature = %q, want %q", got, want)
+		}
+		_, _ = io.WriteString(w, `{"allow": true}`)
+	}))
+	defer ts.Close()
+
+	webhook, err := NewWebhook(&config.AuthzWebhookConfig{URL: ts.URL, TimeoutMs: 1000, FailureMode: "closed", Secret: string(secret)})
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	if _, _, err := webhook.Decide(context.Background(), testRequest()); err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+}
+
+// TestWebhook_CustomCA 测试使用自定义 CA 校验 HTTPS Webhook
+func TestWebhook_CustomCA(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"allow": true}`)
+	}))
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+
+	webhook, err := NewWebhook(&config.AuthzWebhookConfig{URL: ts.URL, TimeoutMs: 1000, FailureMode: "closed", TLSCAFile: caFile})
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	decision, _, err := webhook.Decide(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if !decision.Allow {
+		t.Error("Expected allow decision")
+	}
+
+	// 未配置 CA 时应校验失败
+	untrusted, err := NewWebhook(&config.AuthzWebhookConfig{URL: ts.URL, TimeoutMs: 1000, FailureMode: "closed"})
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	if _, _, err := untrusted.Decide(context.Background(), testRequest()); err == nil {
+		t.Error("Expected TLS verification error, got nil")
+	}
+}
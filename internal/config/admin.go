@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// AllowedNetworks 返回解析后的 allowed_ips
+// 未经 Validate 的配置会按需解析；配置无效时返回错误
+func (c *AdminConfig) AllowedNetworks() ([]*net.IPNet, error) {
+	if len(c.AllowedIPs) == 0 || c.allowedNets != nil {
+		return c.allowedNets, nil
+	}
+	return parseSourceCIDRs(c.AllowedIPs)
+}
+
+// validateAdmin 验证管理 API 配置，并预解析 allowed_ips
+func validateAdmin(cfg *AdminConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if len(cfg.Roles) == 0 {
+		return fmt.Errorf("roles cannot be empty when the admin API is enabled")
+	}
+	for _, role := range cfg.Roles {
+		if role == "" {
+			return fmt.Errorf("roles cannot contain empty values")
+		}
+	}
+
+	if len(cfg.AllowedIPs) > 0 {
+		nets, err := parseSourceCIDRs(cfg.AllowedIPs)
+		if err != nil {
+			return fmt.Errorf("allowed_ips: %w", err)
+		}
+		cfg.allowedNets = nets
+	}
+	return nil
+}
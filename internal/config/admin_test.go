@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateAdmin 测试管理 API 配置验证
+func TestValidateAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    AdminConfig
+		errMsg string
+	}{
+		{"Disabled", AdminConfig{Roles: nil}, ""},
+		{"Enabled", AdminConfig{Enabled: true, Roles: []string{"admin"}}, ""},
+		{"Allowed IPs", AdminConfig{Enabled: true, Roles: []string{"admin"}, AllowedIPs: []string{"10.0.0.0/8", "::1"}}, ""},
+		{"No roles", AdminConfig{Enabled: true}, "roles cannot be empty"},
+		{"Empty role", AdminConfig{Enabled: true, Roles: []string{""}}, "empty values"},
+		{"Invalid IP", AdminConfig{Enabled: true, Roles: []string{"admin"}, AllowedIPs: []string{"office"}}, "allowed_ips"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAdmin(&tt.cfg)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+
+	cfg := AdminConfig{Enabled: true, Roles: []string{"admin"}, AllowedIPs: []string{"192.168.1.10"}}
+	if err := validateAdmin(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if nets, err := cfg.AllowedNetworks(); err != nil || len(nets) != 1 || nets[0].String() != "192.168.1.10/32" {
+		t.Errorf("AllowedNetworks() = %v, %v", nets, err)
+	}
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/expr"
+	"github.com/nerdneilsfield/tiny-auth/internal/schedule"
+)
+
+// ConditionVariables 条件表达式可使用的顶层变量
+var ConditionVariables = []string{"auth", "claims", "request", "now"}
+
+// ConditionProgram 返回预编译的条件表达式
+// 未经 Validate 的配置（如测试中直接构造）会按需编译；表达式无效时返回错误
+func (p *RoutePolicy) ConditionProgram() (*expr.Program, error) {
+	if p.Condition == "" || p.condition != nil {
+		return p.condition, nil
+	}
+	return expr.Compile(p.Condition, ConditionVariables...)
+}
+
+// ConditionProgram 返回规则预编译的条件表达式
+// 未经 Validate 的配置会按需编译；表达式无效时返回错误
+func (r *PolicyRule) ConditionProgram() (*expr.Program, error) {
+	if r.Condition == "" || r.condition != nil {
+		return r.condition, nil
+	}
+	return expr.Compile(r.Condition, ConditionVariables...)
+}
+
+// PathRegexp 返回预编译的 path_regex（已锚定为整段匹配）
+// 未经 Validate 的配置会按需编译；表达式无效时返回错误
+func (p *RoutePolicy) PathRegexp() (*regexp.Regexp, error) {
+	if p.PathRegex == "" || p.pathRegex != nil {
+		return p.pathRegex, nil
+	}
+	return compilePathRegex(p.PathRegex)
+}
+
+// compilePathRegex 编译路径正则，并锚定到整个路径
+func compilePathRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// validatePathGlob 检查 path_glob 语法
+// ** 必须单独构成一个路径段，其余路径段使用 path.Match 语法
+func validatePathGlob(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("must start with /")
+	}
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "**" {
+			continue
+		}
+		if strings.Contains(seg, "**") {
+			return fmt.Errorf("** must be a whole path segment in %q", pattern)
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid segment %q: %w", seg, err)
+		}
+	}
+	return nil
+}
+
+// ValueMatcher header / query 参数匹配条件
+// 配置语法："*" 表示参数存在即可，"~" 前缀表示正则（不锚定，需要时自行使用 ^ 和 $），其余为精确值
+type ValueMatcher struct {
+	Name    string         // header 名（小写）或 query 参数名
+	Present bool           // 仅要求存在
+	Value   string         // 精确值
+	Regexp  *regexp.Regexp // 正则
+}
+
+// Match 检查值是否满足条件，present 表示参数是否存在
+func (m *ValueMatcher) Match(value string, present bool) bool {
+	if !present {
+		return false
+	}
+	switch {
+	case m.Present:
+		return true
+	case m.Regexp != nil:
+		return m.Regexp.MatchString(value)
+	}
+	return value == m.Value
+}
+
+// HeaderMatchers 返回预编译的 match_headers（按名称排序）
+// 未经 Validate 的配置会按需编译；配置无效时返回错误
+func (p *RoutePolicy) HeaderMatchers() ([]ValueMatcher, error) {
+	if len(p.MatchHeaders) == 0 || p.headerMatches != nil {
+		return p.headerMatches, nil
+	}
+	return compileValueMatchers(p.MatchHeaders, true)
+}
+
+// QueryMatchers 返回预编译的 match_query（按名称排序）
+// 未经 Validate 的配置会按需编译；配置无效时返回错误
+func (p *RoutePolicy) QueryMatchers() ([]ValueMatcher, error) {
+	if len(p.MatchQuery) == 0 || p.queryMatches != nil {
+		return p.queryMatches, nil
+	}
+	return compileValueMatchers(p.MatchQuery, false)
+}
+
+// SourceNetworks 返回解析后的 source_cidrs
+// 未经 Validate 的配置会按需解析；配置无效时返回错误
+func (p *RoutePolicy) SourceNetworks() ([]*net.IPNet, error) {
+	if len(p.SourceCIDRs) == 0 || p.sourceNets != nil {
+		return p.sourceNets, nil
+	}
+	return parseSourceCIDRs(p.SourceCIDRs)
+}
+
+// headerNamePattern 合法的 HTTP header 名（RFC 7230 token）
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// compileValueMatchers 编译 header / query 匹配条件
+// header 名不区分大小写，统一转为小写
+func compileValueMatchers(patterns map[string]string, header bool) ([]ValueMatcher, error) {
+	matchers := make([]ValueMatcher, 0, len(patterns))
+	for name, pattern := range patterns {
+		if header {
+			if !headerNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("invalid header name %q", name)
+			}
+			name = strings.ToLower(name)
+		} else if name == "" {
+			return nil, fmt.Errorf("query parameter name cannot be empty")
+		}
+
+		m := ValueMatcher{Name: name}
+		switch {
+		case pattern == "*":
+			m.Present = true
+		case strings.HasPrefix(pattern, "~"):
+			re, err := regexp.Compile(pattern[1:])
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", name, err)
+			}
+			m.Regexp = re
+		default:
+			m.Value = pattern
+		}
+		matchers = append(matchers, m)
+	}
+
+	sort.Slice(matchers, func(i, j int) bool {
+		return matchers[i].Name < matchers[j].Name
+	})
+	return matchers, nil
+}
+
+// parseSourceCIDRs 解析 CIDR 列表（单个 IP 视为 /32 或 /128）
+func parseSourceCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", cidr)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		nets = append(nets, network)
+	}
+	return nets, nil
+}
+
+// Compiled 返回预编译的时间窗口
+// 未经 Validate 的配置会按需编译；配置无效时返回错误
+func (s *ScheduleConfig) Compiled() (*schedule.Schedule, error) {
+	if s.compiled != nil {
+		return s.compiled, nil
+	}
+	return schedule.Parse(s.Timezone, s.Windows, s.Cron)
+}
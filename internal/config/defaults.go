@@ -11,6 +11,22 @@ const (
 	defaultMethodHeader = "X-Auth-Method"
 	defaultLogFormat    = "text"
 	defaultLogLevel     = "info"
+
+	defaultWebhookTimeoutMs   = 2000
+	defaultWebhookFailureMode = "closed"
+
+	defaultDenyStatus   = 403
+	defaultDenyResponse = "detailed"
+
+	// DefaultRealm WWW-Authenticate challenge 的默认 realm
+	DefaultRealm = "api"
+
+	defaultGroupClaim = "groups"
+
+	defaultMetricsPath = "/metrics"
+
+	defaultTracingServiceName = "tiny-auth"
+	defaultTracingTimeoutMs   = 10000
 )
 
 // ApplyDefaults 应用默认值到配置
@@ -68,6 +84,36 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.RateLimit.BanSecs == 0 {
 		cfg.RateLimit.BanSecs = 300 // 默认封禁 5 分钟
 	}
+	if cfg.RateLimit.MaxEntries == 0 {
+		cfg.RateLimit.MaxEntries = 100000
+	}
+	if cfg.RateLimit.IPv6Prefix == 0 {
+		cfg.RateLimit.IPv6Prefix = 64 // 默认按 /64 聚合
+	}
+	if cfg.RateLimit.Mode == "" {
+		cfg.RateLimit.Mode = "ban"
+	}
+	if cfg.RateLimit.Backoff.BaseMs == 0 {
+		cfg.RateLimit.Backoff.BaseMs = 1000
+	}
+	if cfg.RateLimit.Backoff.Factor == 0 {
+		cfg.RateLimit.Backoff.Factor = 2
+	}
+	if cfg.RateLimit.Backoff.MaxSecs == 0 {
+		cfg.RateLimit.Backoff.MaxSecs = 300
+	}
+	if cfg.RateLimit.Backoff.SuccessDecay == 0 {
+		cfg.RateLimit.Backoff.SuccessDecay = 1
+	}
+	if cfg.RateLimit.Backend == "" {
+		cfg.RateLimit.Backend = "memory"
+	}
+	if cfg.RateLimit.Redis.KeyPrefix == "" {
+		cfg.RateLimit.Redis.KeyPrefix = "tiny-auth:"
+	}
+	if cfg.RateLimit.Redis.TimeoutMs == 0 {
+		cfg.RateLimit.Redis.TimeoutMs = 100
+	}
 
 	// Basic Auth 默认角色
 	for i := range cfg.BasicAuths {
@@ -90,6 +136,112 @@ func ApplyDefaults(cfg *Config) {
 		}
 	}
 
+	// 外部身份映射默认值
+	for i := range cfg.GroupMappings {
+		if cfg.GroupMappings[i].Claim == "" {
+			cfg.GroupMappings[i].Claim = defaultGroupClaim
+		}
+		if cfg.GroupMappings[i].Match == "" {
+			cfg.GroupMappings[i].Match = "exact"
+		}
+	}
+
+	// 授权失败的默认状态码、响应方式和认证 realm
+	for i := range cfg.RoutePolicies {
+		if cfg.RoutePolicies[i].DenyStatus == 0 {
+			cfg.RoutePolicies[i].DenyStatus = defaultDenyStatus
+		}
+		if cfg.RoutePolicies[i].DenyResponse == "" {
+			cfg.RoutePolicies[i].DenyResponse = defaultDenyResponse
+		}
+		if cfg.RoutePolicies[i].Realm == "" {
+			cfg.RoutePolicies[i].Realm = DefaultRealm
+		}
+	}
+
+	// 管理 API 默认角色
+	if len(cfg.Admin.Roles) == 0 {
+		cfg.Admin.Roles = []string{"admin"}
+	}
+
+	// IP 过滤默认值
+	if cfg.IPFilter.DecisionsFormat == "" {
+		cfg.IPFilter.DecisionsFormat = "crowdsec"
+	}
+	if cfg.IPFilter.ReloadSecs == 0 {
+		cfg.IPFilter.ReloadSecs = 10
+	}
+
+	// GeoIP 默认值
+	if cfg.GeoIP.ReloadSecs == 0 {
+		cfg.GeoIP.ReloadSecs = 60
+	}
+
+	// 指标端点默认路径
+	if cfg.Metrics.Path == "" {
+		cfg.Metrics.Path = defaultMetricsPath
+	}
+
+	// 追踪默认值
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = defaultTracingServiceName
+	}
+	if cfg.Tracing.SampleRatio == 0 {
+		cfg.Tracing.SampleRatio = 1
+	}
+	if cfg.Tracing.TimeoutMs == 0 {
+		cfg.Tracing.TimeoutMs = defaultTracingTimeoutMs
+	}
+
+	// 用量配额默认值
+	if cfg.Quota.Timezone == "" {
+		cfg.Quota.Timezone = "UTC"
+	}
+	if cfg.Quota.FlushInterval == 0 {
+		cfg.Quota.FlushInterval = 1
+	}
+
+	// 策略级速率限制默认值
+	for i := range cfg.RoutePolicies {
+		limit := cfg.RoutePolicies[i].RateLimit
+		if limit == nil {
+			continue
+		}
+		if limit.FailedAttempts > 0 {
+			if limit.FailedWindowSecs == 0 {
+				limit.FailedWindowSecs = 60
+			}
+			if limit.FailedBanSecs == 0 {
+				limit.FailedBanSecs = 300
+			}
+		}
+		if limit.Requests > 0 {
+			if limit.PeriodSecs == 0 {
+				limit.PeriodSecs = 1
+			}
+			if limit.Burst == 0 {
+				limit.Burst = limit.Requests
+			}
+		}
+		if limit.Key == "" {
+			limit.Key = "ip"
+		}
+	}
+
+	// 外部授权 Webhook 默认值
+	for i := range cfg.RoutePolicies {
+		webhook := cfg.RoutePolicies[i].AuthzWebhook
+		if webhook == nil {
+			continue
+		}
+		if webhook.TimeoutMs == 0 {
+			webhook.TimeoutMs = defaultWebhookTimeoutMs
+		}
+		if webhook.FailureMode == "" {
+			webhook.FailureMode = defaultWebhookFailureMode
+		}
+	}
+
 	// 环境变量覆盖端口
 	if port := os.Getenv("PORT"); port != "" {
 		cfg.Server.Port = port
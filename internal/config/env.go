@@ -53,6 +53,37 @@ func ResolveEnvVars(cfg *Config) error {
 		cfg.JWT.Secret = resolved
 	}
 
+	// 解析 Redis 密码
+	if cfg.RateLimit.Redis.Password != "" {
+		resolved, err := resolveValue(cfg.RateLimit.Redis.Password)
+		if err != nil {
+			return fmt.Errorf("rate_limit.redis.password: %w", err)
+		}
+		cfg.RateLimit.Redis.Password = resolved
+	}
+
+	// 解析外部授权 Webhook 共享密钥
+	for i := range cfg.RoutePolicies {
+		webhook := cfg.RoutePolicies[i].AuthzWebhook
+		if webhook == nil || webhook.Secret == "" {
+			continue
+		}
+		resolved, err := resolveValue(webhook.Secret)
+		if err != nil {
+			return fmt.Errorf("route_policy[%s].authz_webhook.secret: %w", cfg.RoutePolicies[i].Name, err)
+		}
+		webhook.Secret = resolved
+	}
+
+	// 解析追踪导出 headers（如 collector 的认证 token）
+	for name, value := range cfg.Tracing.Headers {
+		resolved, err := resolveValue(value)
+		if err != nil {
+			return fmt.Errorf("tracing.headers.%s: %w", name, err)
+		}
+		cfg.Tracing.Headers[name] = resolved
+	}
+
 	return nil
 }
 
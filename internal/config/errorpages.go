@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// DefaultErrorTemplate 模板目录中替换内置错误页面的文件名
+const DefaultErrorTemplate = "default.html"
+
+// ErrorTemplates 返回从 error_pages.template_dir 加载的模板集合（按文件名查找，如 "corp.html"）
+// 未配置模板目录时返回 nil；未经 Validate 的配置会按需加载
+func (c *Config) ErrorTemplates() (*template.Template, error) {
+	if c.ErrorPages.TemplateDir == "" || c.errorTemplates != nil {
+		return c.errorTemplates, nil
+	}
+	return loadErrorTemplates(c.ErrorPages.TemplateDir)
+}
+
+// loadErrorTemplates 加载目录中的所有 *.html 模板
+// 所有文件共享同一个命名空间，可以通过 {{define}} / {{template}} 复用公共部分
+func loadErrorTemplates(dir string) (*template.Template, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("template_dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("template_dir: %q is not a directory", dir)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("template_dir: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("template_dir: no *.html templates in %q", dir)
+	}
+
+	tmpl, err := template.ParseFiles(files...)
+	if err != nil {
+		return nil, fmt.Errorf("template_dir: %w", err)
+	}
+	return tmpl, nil
+}
+
+// validateErrorPages 加载错误页面模板，并验证策略引用的模板和未认证重定向地址
+func validateErrorPages(cfg *Config) error {
+	if cfg.ErrorPages.TemplateDir != "" {
+		tmpl, err := loadErrorTemplates(cfg.ErrorPages.TemplateDir)
+		if err != nil {
+			return fmt.Errorf("error_pages: %w", err)
+		}
+		cfg.errorTemplates = tmpl
+	}
+
+	for i := range cfg.RoutePolicies {
+		policy := &cfg.RoutePolicies[i]
+
+		if policy.ErrorTemplate != "" {
+			if cfg.errorTemplates == nil {
+				return fmt.Errorf("route_policy: [%s] error_template requires error_pages.template_dir", policy.Name)
+			}
+			if cfg.errorTemplates.Lookup(policy.ErrorTemplate) == nil {
+				return fmt.Errorf("route_policy: [%s] error_template %q not found in %s", policy.Name, policy.ErrorTemplate, cfg.ErrorPages.TemplateDir)
+			}
+		}
+
+		if policy.UnauthenticatedRedirect != "" {
+			u, err := url.Parse(policy.UnauthenticatedRedirect)
+			if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("route_policy: [%s] unauthenticated_redirect must be an absolute http(s) URL, got %q", policy.Name, policy.UnauthenticatedRedirect)
+			}
+			if u.Query().Has("rd") {
+				fmt.Fprintf(os.Stderr, "⚠ Warning: Policy [%s] unauthenticated_redirect already has an rd parameter (it will be replaced)\n", policy.Name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestValidateErrorPages 测试错误页面模板和未认证重定向配置验证
+func TestValidateErrorPages(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "corp.html"), []byte(`<h1>{{.Status}}</h1>`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	badDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(badDir, "broken.html"), []byte(`{{.Status`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		dir       string
+		policy    RoutePolicy
+		expectErr string
+	}{
+		{name: "Template found", dir: dir, policy: RoutePolicy{Name: "p", ErrorTemplate: "corp.html"}},
+		{name: "Redirect", policy: RoutePolicy{Name: "p", UnauthenticatedRedirect: "https://login.example.com/start"}},
+		{name: "Missing directory", dir: filepath.Join(dir, "missing"), expectErr: "template_dir"},
+		{name: "Empty directory", dir: t.TempDir(), expectErr: "no *.html templates"},
+		{name: "Parse error", dir: badDir, expectErr: "template_dir"},
+		{name: "Template without directory", policy: RoutePolicy{Name: "p", ErrorTemplate: "corp.html"}, expectErr: "requires error_pages.template_dir"},
+		{name: "Unknown template", dir: dir, policy: RoutePolicy{Name: "p", ErrorTemplate: "other.html"}, expectErr: "not found"},
+		{name: "Relative redirect", policy: RoutePolicy{Name: "p", UnauthenticatedRedirect: "/login"}, expectErr: "absolute http(s) URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				ErrorPages:    ErrorPagesConfig{TemplateDir: tt.dir},
+				RoutePolicies: []RoutePolicy{tt.policy},
+			}
+			err := validateErrorPages(cfg)
+			if tt.expectErr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+// TestErrorTemplates 测试模板按需加载
+func TestErrorTemplates(t *testing.T) {
+	cfg := &Config{}
+	if tmpl, err := cfg.ErrorTemplates(); tmpl != nil || err != nil {
+		t.Fatalf("expected no templates without template_dir, got %v, %v", tmpl, err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "default.html"), []byte(`{{.Message}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.ErrorPages.TemplateDir = dir
+	tmpl, err := cfg.ErrorTemplates()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tmpl.Lookup(DefaultErrorTemplate) == nil {
+		t.Errorf("expected %s to be loaded", DefaultErrorTemplate)
+	}
+}
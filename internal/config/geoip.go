@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/geoip"
+)
+
+// Enabled 是否配置了 GeoIP 数据库
+func (c *GeoIPConfig) Enabled() bool {
+	return c.CountryDB != "" || c.ASNDB != ""
+}
+
+// Rules 返回全局国家 / ASN 规则
+func (c *GeoIPConfig) Rules() geoip.Rules {
+	return geoip.Rules{
+		AllowedCountries: c.AllowedCountries,
+		DeniedCountries:  c.DeniedCountries,
+		DeniedASNs:       c.DeniedASNs,
+	}
+}
+
+// GeoRules 返回策略的国家 / ASN 规则
+func (p *RoutePolicy) GeoRules() geoip.Rules {
+	return geoip.Rules{
+		AllowedCountries: p.AllowedCountries,
+		DeniedCountries:  p.DeniedCountries,
+		DeniedASNs:       p.DeniedASNs,
+	}
+}
+
+// validateGeoIP 验证 GeoIP 数据库文件、全局规则和 country_header
+func validateGeoIP(cfg *Config) error {
+	geo := &cfg.GeoIP
+	if geo.ReloadSecs < 0 {
+		return fmt.Errorf("reload_secs cannot be negative")
+	}
+	for _, path := range []string{geo.CountryDB, geo.ASNDB} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("database %s is not readable: %w", path, err)
+		}
+	}
+	if cfg.Headers.CountryHeader != "" && geo.CountryDB == "" {
+		return fmt.Errorf("headers.country_header requires country_db")
+	}
+	return validateGeoRules(geo.Rules(), geo)
+}
+
+// validateGeoRules 验证国家代码，并检查规则需要的数据库已配置
+func validateGeoRules(rules geoip.Rules, geo *GeoIPConfig) error {
+	for _, list := range [][]string{rules.AllowedCountries, rules.DeniedCountries} {
+		for _, code := range list {
+			if !isCountryCode(code) {
+				return fmt.Errorf("invalid country code %q (expected ISO 3166-1 alpha-2, e.g. \"DE\")", code)
+			}
+		}
+	}
+	if (len(rules.AllowedCountries) > 0 || len(rules.DeniedCountries) > 0) && geo.CountryDB == "" {
+		return fmt.Errorf("country rules require geoip.country_db")
+	}
+	if len(rules.DeniedASNs) > 0 && geo.ASNDB == "" {
+		return fmt.Errorf("denied_asns requires geoip.asn_db")
+	}
+	return nil
+}
+
+// isCountryCode 两个 ASCII 字母
+func isCountryCode(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		c := code[i] | 0x20 // 转小写
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/geoip/geoiptest"
+)
+
+// TestValidateGeoIP 测试 GeoIP 配置和策略国家 / ASN 规则验证
+func TestValidateGeoIP(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "geo.mmdb")
+	if err := geoiptest.Write(db, []geoiptest.Record{{Network: "192.0.2.0/24", Country: "DE", ASN: 64496}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		errMsg string
+	}{
+		{"Disabled", Config{}, ""},
+		{"Global rules", Config{GeoIP: GeoIPConfig{CountryDB: db, ASNDB: db, AllowedCountries: []string{"de", "FR"}, DeniedASNs: []uint{64496}}}, ""},
+		{"Country header", Config{GeoIP: GeoIPConfig{CountryDB: db}, Headers: HeadersConfig{CountryHeader: "X-Auth-Country"}}, ""},
+		{"Missing database", Config{GeoIP: GeoIPConfig{CountryDB: db + ".missing"}}, "not readable"},
+		{"Invalid country", Config{GeoIP: GeoIPConfig{CountryDB: db, DeniedCountries: []string{"Germany"}}}, "invalid country code"},
+		{"Countries without database", Config{GeoIP: GeoIPConfig{ASNDB: db, AllowedCountries: []string{"DE"}}}, "require geoip.country_db"},
+		{"ASNs without database", Config{GeoIP: GeoIPConfig{CountryDB: db, DeniedASNs: []uint{1}}}, "requires geoip.asn_db"},
+		{"Header without database", Config{Headers: HeadersConfig{CountryHeader: "X-Auth-Country"}}, "country_header"},
+		{"Negative reload", Config{GeoIP: GeoIPConfig{ReloadSecs: -1}}, "reload_secs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateGeoIP(&tt.cfg)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+
+	// 策略规则同样需要数据库
+	cfg := Config{RoutePolicies: []RoutePolicy{{Name: "admin", PathPrefix: "/admin", AllowedCountries: []string{"DE"}}}}
+	if err := validateRoutePolicies(cfg.RoutePolicies, &cfg); err == nil || !strings.Contains(err.Error(), "[admin] country rules") {
+		t.Errorf("expected policy country rules to require a database, got %v", err)
+	}
+	cfg.GeoIP.CountryDB = db
+	if err := validateRoutePolicies(cfg.RoutePolicies, &cfg); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// MatchValue 检查外部值（如 IdP 组名）是否满足映射规则
+func (m *GroupMapping) MatchValue(value string) bool {
+	switch m.Match {
+	case "prefix":
+		return strings.HasPrefix(value, m.Value)
+	case "regex":
+		re := m.valueRegex
+		if re == nil {
+			// 未经 Validate 的配置按需编译；无效正则不匹配任何值
+			compiled, err := compileFullMatch(m.Value)
+			if err != nil {
+				return false
+			}
+			re = compiled
+		}
+		return re.MatchString(value)
+	}
+	return value == m.Value
+}
+
+// ClaimName 返回映射读取的 claim 名称（默认 "groups"）
+func (m *GroupMapping) ClaimName() string {
+	if m.Claim == "" {
+		return defaultGroupClaim
+	}
+	return m.Claim
+}
+
+// compileFullMatch 编译正则并锚定为匹配整个值
+func compileFullMatch(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// validateGroups 验证组定义和外部身份映射规则
+func validateGroups(cfg *Config) error {
+	names := make(map[string]bool, len(cfg.Groups))
+	for _, g := range cfg.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("group: name cannot be empty")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("group: duplicate name %q", g.Name)
+		}
+		names[g.Name] = true
+		if len(g.Members) == 0 {
+			fmt.Fprintf(os.Stderr, "⚠ Warning: Group [%s] has no members (only reachable via group_mapping)\n", g.Name)
+		}
+	}
+
+	for i := range cfg.GroupMappings {
+		m := &cfg.GroupMappings[i]
+		if m.Value == "" {
+			return fmt.Errorf("group_mapping[%d]: value cannot be empty", i)
+		}
+		if len(m.Groups) == 0 && len(m.Roles) == 0 {
+			return fmt.Errorf("group_mapping[%d]: at least one of groups or roles must be set", i)
+		}
+		switch m.Match {
+		case "", "exact", "prefix":
+		case "regex":
+			re, err := compileFullMatch(m.Value)
+			if err != nil {
+				return fmt.Errorf("group_mapping[%d]: invalid regex: %w", i, err)
+			}
+			m.valueRegex = re
+		default:
+			return fmt.Errorf("group_mapping[%d]: match must be \"exact\", \"prefix\" or \"regex\", got %q", i, m.Match)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/ipfilter"
+)
+
+// Enabled 是否配置了任何 IP 允许 / 拒绝来源
+func (c *IPFilterConfig) Enabled() bool {
+	return len(c.Allow) > 0 || len(c.Deny) > 0 || len(c.AllowFiles) > 0 || len(c.DenyFiles) > 0 ||
+		c.DecisionsFile != ""
+}
+
+// validateIPFilter 验证 IP 过滤配置
+// 列表文件不存在时只发出警告：文件可能由其他服务稍后生成，出现后会自动加载
+func validateIPFilter(cfg *IPFilterConfig) error {
+	for _, cidr := range cfg.Allow {
+		if _, err := ipfilter.ParsePrefix(cidr); err != nil {
+			return fmt.Errorf("allow: %w", err)
+		}
+	}
+	for _, cidr := range cfg.Deny {
+		if _, err := ipfilter.ParsePrefix(cidr); err != nil {
+			return fmt.Errorf("deny: %w", err)
+		}
+	}
+
+	switch cfg.DecisionsFormat {
+	case "", ipfilter.FormatCrowdSec, ipfilter.FormatFail2ban:
+	default:
+		return fmt.Errorf("decisions_format must be %q or %q, got %q",
+			ipfilter.FormatCrowdSec, ipfilter.FormatFail2ban, cfg.DecisionsFormat)
+	}
+	if cfg.ReloadSecs < 0 {
+		return fmt.Errorf("reload_secs cannot be negative")
+	}
+
+	files := append(append([]string{}, cfg.AllowFiles...), cfg.DenyFiles...)
+	if cfg.DecisionsFile != "" {
+		files = append(files, cfg.DecisionsFile)
+	}
+	for _, path := range files {
+		if path == "" {
+			return fmt.Errorf("file paths cannot be empty")
+		}
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ Warning: ip_filter file %s is not readable yet (%v); it will be loaded once it appears\n", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateIPFilter 测试 IP 过滤配置验证
+func TestValidateIPFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    IPFilterConfig
+		errMsg string
+	}{
+		{"Empty", IPFilterConfig{}, ""},
+		{"Static lists", IPFilterConfig{Allow: []string{"10.0.0.0/8"}, Deny: []string{"192.0.2.1", "2001:db8::/32"}}, ""},
+		{"Decisions", IPFilterConfig{DecisionsFile: "/nonexistent/decisions.json", DecisionsFormat: "fail2ban"}, ""},
+		{"Invalid allow", IPFilterConfig{Allow: []string{"office"}}, "allow"},
+		{"Invalid deny", IPFilterConfig{Deny: []string{"10.0.0.0/40"}}, "deny"},
+		{"Invalid format", IPFilterConfig{DecisionsFormat: "csv"}, "decisions_format"},
+		{"Negative reload", IPFilterConfig{ReloadSecs: -1}, "reload_secs"},
+		{"Empty path", IPFilterConfig{DenyFiles: []string{""}}, "file paths"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIPFilter(&tt.cfg)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+
+	if (&IPFilterConfig{}).Enabled() || !(&IPFilterConfig{DecisionsFile: "x"}).Enabled() {
+		t.Error("unexpected Enabled() result")
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// validateMetrics 验证指标端点配置
+// 在主端口上提供时，路径不能与其他端点冲突
+func validateMetrics(cfg *Config) error {
+	m := &cfg.Metrics
+	if !m.Enabled {
+		return nil
+	}
+
+	if !strings.HasPrefix(m.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+
+	if m.Listen != "" {
+		_, port, err := net.SplitHostPort(m.Listen)
+		if err != nil {
+			return fmt.Errorf("invalid listen address %q: %w", m.Listen, err)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+			return fmt.Errorf("invalid listen port %q", port)
+		}
+		return nil
+	}
+
+	for _, reserved := range []string{cfg.Server.AuthPath, cfg.Server.HealthPath, "/admin/ratelimit", "/debug/config"} {
+		if m.Path == reserved || strings.HasPrefix(m.Path, reserved+"/") {
+			return fmt.Errorf("path %q conflicts with %s", m.Path, reserved)
+		}
+	}
+	return nil
+}
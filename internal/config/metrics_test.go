@@ -0,0 +1,44 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateMetrics 测试指标端点配置验证
+func TestValidateMetrics(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    MetricsConfig
+		errMsg string
+	}{
+		{"Disabled", MetricsConfig{Path: "metrics"}, ""},
+		{"Main port", MetricsConfig{Enabled: true, Path: "/metrics"}, ""},
+		{"Separate listener", MetricsConfig{Enabled: true, Path: "/auth", Listen: "127.0.0.1:9100"}, ""},
+		{"Any interface", MetricsConfig{Enabled: true, Path: "/metrics", Listen: ":9100"}, ""},
+		{"Relative path", MetricsConfig{Enabled: true, Path: "metrics"}, "must start with /"},
+		{"Auth path", MetricsConfig{Enabled: true, Path: "/auth"}, "conflicts with /auth"},
+		{"Under admin", MetricsConfig{Enabled: true, Path: "/admin/ratelimit/metrics"}, "conflicts"},
+		{"Missing port", MetricsConfig{Enabled: true, Path: "/metrics", Listen: "localhost"}, "invalid listen address"},
+		{"Bad port", MetricsConfig{Enabled: true, Path: "/metrics", Listen: ":http"}, "invalid listen port"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:  ServerConfig{AuthPath: "/auth", HealthPath: "/health"},
+				Metrics: tt.cfg,
+			}
+			err := validateMetrics(cfg)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
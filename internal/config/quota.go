@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// HasQuotas 是否有 Bearer Token / API Key 配置了用量配额
+func (c *Config) HasQuotas() bool {
+	for _, t := range c.BearerTokens {
+		if t.Quota != nil {
+			return true
+		}
+	}
+	for _, k := range c.APIKeys {
+		if k.Quota != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Location 返回计算配额周期边界的时区（未配置时为 UTC）
+// 未经 Validate 的配置会按需加载，时区无效时同样退回 UTC
+func (c *QuotaStoreConfig) Location() *time.Location {
+	if c.location != nil {
+		return c.location
+	}
+	if loc, err := time.LoadLocation(c.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// validateQuotas 验证凭证配额和配额存储配置
+func validateQuotas(cfg *Config) error {
+	for _, t := range cfg.BearerTokens {
+		if err := validateQuota(t.Quota); err != nil {
+			return fmt.Errorf("bearer_token: [%s] quota: %w", t.Name, err)
+		}
+	}
+	for _, k := range cfg.APIKeys {
+		if err := validateQuota(k.Quota); err != nil {
+			return fmt.Errorf("api_key: [%s] quota: %w", k.Name, err)
+		}
+	}
+
+	loc, err := time.LoadLocation(cfg.Quota.Timezone)
+	if err != nil {
+		return fmt.Errorf("quota: invalid timezone %q: %w", cfg.Quota.Timezone, err)
+	}
+	cfg.Quota.location = loc
+
+	if cfg.Quota.FlushInterval < 0 {
+		return fmt.Errorf("quota: flush_interval cannot be negative")
+	}
+	if cfg.HasQuotas() && cfg.Quota.Path == "" {
+		return fmt.Errorf("quota: path is required when bearer_token or api_key quotas are configured")
+	}
+	return nil
+}
+
+// validateQuota 验证单个凭证的配额
+func validateQuota(q *QuotaConfig) error {
+	if q == nil {
+		return nil
+	}
+	if q.PerDay < 0 || q.PerMonth < 0 {
+		return fmt.Errorf("per_day and per_month cannot be negative")
+	}
+	if q.PerDay == 0 && q.PerMonth == 0 {
+		return fmt.Errorf("at least one of per_day or per_month is required")
+	}
+	if q.PerDay > 0 && q.PerMonth > 0 && q.PerDay > q.PerMonth {
+		return fmt.Errorf("per_day (%d) cannot exceed per_month (%d)", q.PerDay, q.PerMonth)
+	}
+	return nil
+}
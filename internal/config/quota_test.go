@@ -0,0 +1,50 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateQuotas 测试凭证配额和配额存储配置验证
+func TestValidateQuotas(t *testing.T) {
+	tests := []struct {
+		name      string
+		store     QuotaStoreConfig
+		bearer    *QuotaConfig
+		apiKey    *QuotaConfig
+		expectErr string
+	}{
+		{name: "No quotas"},
+		{name: "Daily and monthly", store: QuotaStoreConfig{Path: "quota.db"}, apiKey: &QuotaConfig{PerDay: 100, PerMonth: 1000}},
+		{name: "Monthly only", store: QuotaStoreConfig{Path: "quota.db", Timezone: "Asia/Shanghai"}, bearer: &QuotaConfig{PerMonth: 1000}},
+		{name: "Missing path", apiKey: &QuotaConfig{PerDay: 100}, expectErr: "path is required"},
+		{name: "Negative", store: QuotaStoreConfig{Path: "quota.db"}, bearer: &QuotaConfig{PerDay: -1}, expectErr: "bearer_token: [t] quota: per_day and per_month cannot be negative"},
+		{name: "Empty quota", store: QuotaStoreConfig{Path: "quota.db"}, apiKey: &QuotaConfig{}, expectErr: "at least one of"},
+		{name: "Daily exceeds monthly", store: QuotaStoreConfig{Path: "quota.db"}, apiKey: &QuotaConfig{PerDay: 100, PerMonth: 10}, expectErr: "cannot exceed"},
+		{name: "Invalid timezone", store: QuotaStoreConfig{Path: "quota.db", Timezone: "Mars/Base"}, expectErr: "invalid timezone"},
+		{name: "Negative flush interval", store: QuotaStoreConfig{FlushInterval: -1}, expectErr: "flush_interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Quota:        tt.store,
+				BearerTokens: []BearerConfig{{Name: "t", Token: "token-1234567890", Quota: tt.bearer}},
+				APIKeys:      []APIKeyConfig{{Name: "k", Key: "key-1234567890", Quota: tt.apiKey}},
+			}
+			err := validateQuotas(cfg)
+			if tt.expectErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if cfg.Quota.Location() == nil {
+					t.Error("expected location to be resolved")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("expected error containing %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExpandRoles 按 [roles] 继承关系展开角色集合
+// 保持原有角色的顺序，继承得到的角色依次追加在后面并去重；没有配置 [roles] 时原样返回
+func (c *Config) ExpandRoles(roles []string) []string {
+	if len(c.Roles) == 0 || len(roles) == 0 {
+		return roles
+	}
+
+	closure := c.roleClosure
+	if closure == nil {
+		// 未经 Validate 的配置（如测试中直接构造）按需计算；存在循环时不展开
+		computed, err := buildRoleClosure(c.Roles)
+		if err != nil {
+			return roles
+		}
+		closure = computed
+	}
+
+	expanded := make([]string, 0, len(roles))
+	seen := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		if seen[role] {
+			continue
+		}
+		seen[role] = true
+		expanded = append(expanded, role)
+	}
+	for _, role := range roles {
+		for _, inherited := range closure[role] {
+			if !seen[inherited] {
+				seen[inherited] = true
+				expanded = append(expanded, inherited)
+			}
+		}
+	}
+	return expanded
+}
+
+// buildRoleClosure 计算每个角色继承的全部角色（深度优先顺序，不含自身）
+// 存在循环继承时返回包含完整循环路径的错误
+func buildRoleClosure(roles map[string][]string) (map[string][]string, error) {
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names) // 保证错误信息稳定
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(roles))
+	closure := make(map[string][]string, len(roles))
+	var path []string
+
+	var visit func(role string) error
+	visit = func(role string) error {
+		switch state[role] {
+		case done:
+			return nil
+		case visiting:
+			start := 0
+			for i, r := range path {
+				if r == role {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string(nil), path[start:]...), role)
+			return fmt.Errorf("role inheritance cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[role] = visiting
+		path = append(path, role)
+
+		var inherited []string
+		seen := map[string]bool{role: true}
+		for _, child := range roles[role] {
+			if err := visit(child); err != nil {
+				return err
+			}
+			for _, r := range append([]string{child}, closure[child]...) {
+				if !seen[r] {
+					seen[r] = true
+					inherited = append(inherited, r)
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[role] = done
+		closure[role] = inherited
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return closure, nil
+}
+
+// validateRoles 验证角色继承配置，检测循环并预计算展开结果
+func validateRoles(cfg *Config) error {
+	for name, included := range cfg.Roles {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("role name cannot be empty")
+		}
+		for _, r := range included {
+			if strings.TrimSpace(r) == "" {
+				return fmt.Errorf("[%s] included role name cannot be empty", name)
+			}
+		}
+	}
+
+	closure, err := buildRoleClosure(cfg.Roles)
+	if err != nil {
+		return err
+	}
+	cfg.roleClosure = closure
+	return nil
+}
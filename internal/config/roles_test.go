@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestExpandRoles 测试角色继承展开
+func TestExpandRoles(t *testing.T) {
+	cfg := &Config{
+		Roles: map[string][]string{
+			"admin":  {"editor", "auditor"},
+			"editor": {"viewer"},
+			"ops":    {"viewer"},
+		},
+	}
+	if err := validateRoles(cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		roles []string
+		want  []string
+	}{
+		{"Transitive", []string{"admin"}, []string{"admin", "editor", "viewer", "auditor"}},
+		{"Shared descendants deduplicated", []string{"ops", "editor"}, []string{"ops", "editor", "viewer"}},
+		{"Unknown role kept", []string{"guest"}, []string{"guest"}},
+		{"Duplicates removed", []string{"viewer", "viewer"}, []string{"viewer"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.ExpandRoles(tt.roles); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandRoles(%v) = %v, want %v", tt.roles, got, tt.want)
+			}
+		})
+	}
+
+	// 未经 Validate 的配置按需计算
+	raw := &Config{Roles: cfg.Roles}
+	if got := raw.ExpandRoles([]string{"admin"}); len(got) != 4 {
+		t.Errorf("Expected lazily expanded roles, got %v", got)
+	}
+}
+
+// TestValidateRoles_Cycle 测试循环继承检测并报告完整路径
+func TestValidateRoles_Cycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles map[string][]string
+		want  string
+	}{
+		{"Self reference", map[string][]string{"admin": {"admin"}}, "admin -> admin"},
+		{"Three-role cycle", map[string][]string{
+			"admin":  {"editor"},
+			"editor": {"viewer"},
+			"viewer": {"admin"},
+		}, "admin -> editor -> viewer -> admin"},
+		{"Cycle below entry point", map[string][]string{
+			"a": {"b"},
+			"b": {"c"},
+			"c": {"b"},
+		}, "b -> c -> b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRoles(&Config{Roles: tt.roles})
+			if err == nil || !strings.Contains(err.Error(), "role inheritance cycle: "+tt.want) {
+				t.Errorf("Expected cycle %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
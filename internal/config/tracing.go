@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// validateTracing 验证追踪导出配置
+func validateTracing(cfg *TracingConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.Endpoint == "" {
+		return fmt.Errorf("endpoint cannot be empty when tracing is enabled")
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("endpoint %q must be an http:// or https:// URL", cfg.Endpoint)
+	}
+
+	if cfg.SampleRatio <= 0 || cfg.SampleRatio > 1 {
+		return fmt.Errorf("sample_ratio must be in (0, 1], got %g", cfg.SampleRatio)
+	}
+	if cfg.TimeoutMs < 0 {
+		return fmt.Errorf("timeout_ms cannot be negative")
+	}
+	if cfg.ServiceName == "" {
+		return fmt.Errorf("service_name cannot be empty")
+	}
+	for name := range cfg.Headers {
+		if name == "" {
+			return fmt.Errorf("headers cannot contain an empty name")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateTracing 测试追踪导出配置验证
+func TestValidateTracing(t *testing.T) {
+	valid := func(mutate func(*TracingConfig)) TracingConfig {
+		cfg := TracingConfig{
+			Enabled:     true,
+			Endpoint:    "http://otel-collector:4318",
+			ServiceName: "tiny-auth",
+			SampleRatio: 1,
+			TimeoutMs:   10000,
+		}
+		if mutate != nil {
+			mutate(&cfg)
+		}
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		cfg    TracingConfig
+		errMsg string
+	}{
+		{"Disabled", TracingConfig{Endpoint: "bad"}, ""},
+		{"Valid", valid(nil), ""},
+		{"HTTPS with path", valid(func(c *TracingConfig) { c.Endpoint = "https://collector.example.com/otlp/v1/traces" }), ""},
+		{"Sampled", valid(func(c *TracingConfig) { c.SampleRatio = 0.1 }), ""},
+		{"No endpoint", valid(func(c *TracingConfig) { c.Endpoint = "" }), "endpoint cannot be empty"},
+		{"No scheme", valid(func(c *TracingConfig) { c.Endpoint = "otel-collector:4318" }), "must be an http"},
+		{"gRPC scheme", valid(func(c *TracingConfig) { c.Endpoint = "grpc://otel-collector:4317" }), "must be an http"},
+		{"Ratio too large", valid(func(c *TracingConfig) { c.SampleRatio = 2 }), "sample_ratio"},
+		{"Negative ratio", valid(func(c *TracingConfig) { c.SampleRatio = -0.5 }), "sample_ratio"},
+		{"Negative timeout", valid(func(c *TracingConfig) { c.TimeoutMs = -1 }), "timeout_ms"},
+		{"Empty header name", valid(func(c *TracingConfig) { c.Headers = map[string]string{"": "x"} }), "empty name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTracing(&tt.cfg)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -1,17 +1,41 @@
 package config
 
+import (
+	"html/template"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/expr"
+	"github.com/nerdneilsfield/tiny-auth/internal/schedule"
+)
+
 // Config 是 tiny-auth 的主配置结构
 type Config struct {
-	Server        ServerConfig      `toml:"server"`
-	Headers       HeadersConfig     `toml:"headers"`
-	Logging       LoggingConfig     `toml:"logging"`
-	Audit         AuditConfig       `toml:"audit"`
-	RateLimit     RateLimitConfig   `toml:"rate_limit"`
-	BasicAuths    []BasicAuthConfig `toml:"basic_auth"`
-	BearerTokens  []BearerConfig    `toml:"bearer_token"`
-	APIKeys       []APIKeyConfig    `toml:"api_key"`
-	JWT           JWTConfig         `toml:"jwt"`
-	RoutePolicies []RoutePolicy     `toml:"route_policy"`
+	Server        ServerConfig        `toml:"server"`
+	Headers       HeadersConfig       `toml:"headers"`
+	Logging       LoggingConfig       `toml:"logging"`
+	Audit         AuditConfig         `toml:"audit"`
+	RateLimit     RateLimitConfig     `toml:"rate_limit"`
+	ErrorPages    ErrorPagesConfig    `toml:"error_pages"`
+	Quota         QuotaStoreConfig    `toml:"quota"`
+	Admin         AdminConfig         `toml:"admin"`
+	IPFilter      IPFilterConfig      `toml:"ip_filter"`
+	GeoIP         GeoIPConfig         `toml:"geoip"`
+	Metrics       MetricsConfig       `toml:"metrics"`
+	Tracing       TracingConfig       `toml:"tracing"`
+	BasicAuths    []BasicAuthConfig   `toml:"basic_auth"`
+	BearerTokens  []BearerConfig      `toml:"bearer_token"`
+	APIKeys       []APIKeyConfig      `toml:"api_key"`
+	JWT           JWTConfig           `toml:"jwt"`
+	RoutePolicies []RoutePolicy       `toml:"route_policy"`
+	Roles         map[string][]string `toml:"roles"`         // 角色继承：角色 → 包含的角色（如 admin = ["editor"]）
+	Groups        []GroupConfig       `toml:"group"`         // 用户组
+	GroupMappings []GroupMapping      `toml:"group_mapping"` // 外部身份（JWT claim）到组 / 角色的映射
+
+	// 加载时预计算的结果（不来自 TOML）
+	roleClosure    map[string][]string
+	errorTemplates *template.Template
 }
 
 // ServerConfig 服务器配置
@@ -32,6 +56,7 @@ type HeadersConfig struct {
 	MethodHeader       string   `toml:"method_header"`        // 认证方法 header
 	ExtraHeaders       []string `toml:"extra_headers"`        // 额外的 headers
 	IncludeJWTMetadata bool     `toml:"include_jwt_metadata"` // 是否包含 JWT 元数据
+	CountryHeader      string   `toml:"country_header"`       // 客户端国家代码 header（如 "X-Auth-Country"，需要 geoip.country_db，为空时不注入）
 }
 
 // LoggingConfig 日志配置
@@ -44,6 +69,13 @@ type LoggingConfig struct {
 type AuditConfig struct {
 	Enabled bool   `toml:"enabled"` // 是否启用审计日志
 	Output  string `toml:"output"`  // 输出位置: stdout/stderr 或文件路径
+
+	// 以下选项仅对文件输出有效
+	MaxSizeMB      int    `toml:"max_size_mb"`     // 文件达到该大小（MB）后轮转，0 表示不按大小轮转
+	RotateInterval string `toml:"rotate_interval"` // 按时间轮转: "hourly" / "daily"，空表示不按时间轮转
+	Compress       bool   `toml:"compress"`        // 是否 gzip 压缩轮转出的文件
+	MaxBackups     int    `toml:"max_backups"`     // 最多保留的轮转文件数，0 表示不限制
+	MaxAgeDays     int    `toml:"max_age_days"`    // 轮转文件的最长保留天数，0 表示不限制
 }
 
 // RateLimitConfig 速率限制配置
@@ -52,6 +84,107 @@ type RateLimitConfig struct {
 	MaxAttempts int  `toml:"max_attempts"` // 时间窗口内的最大尝试次数
 	WindowSecs  int  `toml:"window_secs"`  // 时间窗口（秒）
 	BanSecs     int  `toml:"ban_secs"`     // 封禁时长（秒）
+
+	StateFile string `toml:"state_file"` // 封禁状态快照文件（可选，关闭时写入、启动时恢复，包括策略级封禁）
+
+	Mode    string        `toml:"mode"`    // 限流方式: "ban"（默认，超过次数后封禁 ban_secs）或 "backoff"（每次失败后要求的等待时间指数增长）
+	Backoff BackoffConfig `toml:"backoff"` // backoff 模式参数
+
+	MaxEntries int `toml:"max_entries"` // 每个限流器在内存中最多保存的客户端记录数（默认 100000），超出后淘汰最久未使用的记录
+	IPv6Prefix int `toml:"ipv6_prefix"` // IPv6 客户端按此前缀长度聚合计数（默认 64，128 表示按单个地址）
+
+	Backend string      `toml:"backend"` // 计数和封禁后端: "memory"（默认，进程内）或 "redis"（多副本共享）
+	Redis   RedisConfig `toml:"redis"`   // Redis 后端配置
+}
+
+// BackoffConfig 渐进延迟（tarpit）参数：第 n 次失败后要求等待 base × factor^(n-1)，不超过 max_secs
+// 等待结束后 window_secs 内没有新的失败则清除记录
+type BackoffConfig struct {
+	BaseMs       int     `toml:"base_ms"`       // 第一次失败后的等待时间（毫秒，默认 1000）
+	Factor       float64 `toml:"factor"`        // 每次失败的增长倍数（默认 2）
+	MaxSecs      int     `toml:"max_secs"`      // 等待时间上限（秒，默认 300）
+	SuccessDecay float64 `toml:"success_decay"` // 每次认证成功抵消的失败次数（默认 1）
+	HoldSecs     int     `toml:"hold_secs"`     // 失败响应最多保持的时间（秒，默认 0 表示只返回 Retry-After 不保持）
+}
+
+// AdminConfig 管理 API 配置（/admin/ratelimit）
+type AdminConfig struct {
+	Enabled    bool     `toml:"enabled"`     // 是否启用管理 API
+	Roles      []string `toml:"roles"`       // 访问管理 API 需要的角色（满足任一即可，默认 ["admin"]）
+	AllowedIPs []string `toml:"allowed_ips"` // 允许访问的客户端 IP/CIDR（可选，为空时不限制来源）
+
+	allowedNets []*net.IPNet
+}
+
+// IPFilterConfig 全局 IP 允许 / 拒绝列表，在速率限制和认证之前检查（允许列表优先）
+// 列表文件每行一个 IP/CIDR（# 开头为注释），文件变化后自动重新加载
+type IPFilterConfig struct {
+	Allow           []string `toml:"allow"`            // 始终允许的 IP/CIDR（跳过拒绝列表和全局速率限制，仍需通过认证）
+	Deny            []string `toml:"deny"`             // 拒绝的 IP/CIDR
+	AllowFiles      []string `toml:"allow_files"`      // 允许列表文件
+	DenyFiles       []string `toml:"deny_files"`       // 拒绝列表文件
+	DecisionsFile   string   `toml:"decisions_file"`   // 封禁决策文件（CrowdSec JSON 或 fail2ban-client 输出），按决策中的时长过期
+	DecisionsFormat string   `toml:"decisions_format"` // 决策文件格式: "crowdsec"（默认）或 "fail2ban"
+	ReloadSecs      int      `toml:"reload_secs"`      // 文件变化检查间隔（秒，默认 10）
+}
+
+// MetricsConfig Prometheus 指标端点（修改后需要重启）
+type MetricsConfig struct {
+	Enabled bool   `toml:"enabled"` // 是否启用 /metrics
+	Path    string `toml:"path"`    // 指标端点路径（默认 /metrics）
+	Listen  string `toml:"listen"`  // 单独的监听地址（如 ":9100"）；为空时在主端口上提供
+}
+
+// TracingConfig OpenTelemetry 追踪（OTLP/HTTP 导出，修改后需要重启）
+type TracingConfig struct {
+	Enabled     bool              `toml:"enabled"`      // 是否启用追踪
+	Endpoint    string            `toml:"endpoint"`     // OTLP/HTTP collector 地址（如 http://otel-collector:4318，路径默认 /v1/traces）
+	Headers     map[string]string `toml:"headers"`      // 导出请求附加的 headers（值支持 env:VAR_NAME）
+	ServiceName string            `toml:"service_name"` // service.name（默认 tiny-auth）
+	SampleRatio float64           `toml:"sample_ratio"` // 上游没有采样决定时的采样比例（默认 1）
+	TimeoutMs   int               `toml:"timeout_ms"`   // 单次导出超时（默认 10000）
+}
+
+// GeoIPConfig 本地 MaxMind 数据库（.mmdb）和全局国家 / ASN 规则
+// 全局规则在 IP 过滤之后、速率限制之前检查；数据库文件变化后自动重新加载
+type GeoIPConfig struct {
+	CountryDB        string   `toml:"country_db"`        // 国家数据库（GeoLite2-Country / GeoIP2-City 等）
+	ASNDB            string   `toml:"asn_db"`            // ASN 数据库（GeoLite2-ASN 等），denied_asns 需要
+	ReloadSecs       int      `toml:"reload_secs"`       // 文件变化检查间隔（秒，默认 60）
+	AllowedCountries []string `toml:"allowed_countries"` // 只允许这些国家（ISO 3166-1 代码，查不到国家的地址也被拒绝）
+	DeniedCountries  []string `toml:"denied_countries"`  // 拒绝的国家
+	DeniedASNs       []uint   `toml:"denied_asns"`       // 拒绝的自治系统号
+}
+
+// RedisConfig Redis 连接配置
+type RedisConfig struct {
+	Addr      string `toml:"addr"`       // 地址（host:port）
+	Username  string `toml:"username"`   // ACL 用户名（可选）
+	Password  string `toml:"password"`   // 密码（支持 env:VAR 语法）
+	DB        int    `toml:"db"`         // 数据库编号
+	TLS       bool   `toml:"tls"`        // 是否使用 TLS（使用系统 CA）
+	KeyPrefix string `toml:"key_prefix"` // key 前缀（默认 "tiny-auth:"）
+	TimeoutMs int    `toml:"timeout_ms"` // 单次操作超时（毫秒，默认 100），超时后退回本地限流
+}
+
+// ErrorPagesConfig 错误页面配置（浏览器请求返回 HTML 时使用）
+type ErrorPagesConfig struct {
+	TemplateDir string `toml:"template_dir"` // html/template 模板目录（加载其中的 *.html，default.html 替换内置页面）
+}
+
+// QuotaStoreConfig 用量配额计数的持久化配置
+type QuotaStoreConfig struct {
+	Path          string `toml:"path"`           // bbolt 数据库文件路径（配置了凭证配额时必填）
+	Timezone      string `toml:"timezone"`       // 计算日 / 月周期边界的 IANA 时区（默认 UTC）
+	FlushInterval int    `toml:"flush_interval"` // 计数写入数据库的间隔（秒，默认 1）
+
+	location *time.Location // 加载时解析的时区
+}
+
+// QuotaConfig 凭证的用量配额（0 表示不限制）
+type QuotaConfig struct {
+	PerDay   int64 `toml:"per_day"`   // 每个自然日允许的请求数
+	PerMonth int64 `toml:"per_month"` // 每个自然月允许的请求数
 }
 
 // BasicAuthConfig Basic 认证配置
@@ -61,6 +194,8 @@ type BasicAuthConfig struct {
 	Pass     string   `toml:"pass"`      // 明文密码（支持 env:VAR 语法）
 	PassHash string   `toml:"pass_hash"` // bcrypt 哈希密码（推荐，与 pass 二选一，支持 env:VAR 语法）
 	Roles    []string `toml:"roles"`     // 关联的角色
+
+	Schedule *ScheduleConfig `toml:"schedule"` // 访问时间窗口（可选）
 }
 
 // BearerConfig Bearer Token 配置
@@ -68,6 +203,9 @@ type BearerConfig struct {
 	Name  string   `toml:"name"`  // 唯一标识符
 	Token string   `toml:"token"` // Token 值（支持 env:VAR 语法）
 	Roles []string `toml:"roles"` // 关联的角色
+
+	Schedule *ScheduleConfig `toml:"schedule"` // 访问时间窗口（可选）
+	Quota    *QuotaConfig    `toml:"quota"`    // 用量配额（可选）
 }
 
 // APIKeyConfig API Key 配置
@@ -75,6 +213,9 @@ type APIKeyConfig struct {
 	Name  string   `toml:"name"`  // 唯一标识符
 	Key   string   `toml:"key"`   // API Key 值（支持 env:VAR 语法）
 	Roles []string `toml:"roles"` // 关联的角色
+
+	Schedule *ScheduleConfig `toml:"schedule"` // 访问时间窗口（可选）
+	Quota    *QuotaConfig    `toml:"quota"`    // 用量配额（可选）
 }
 
 // JWTConfig JWT 配置
@@ -85,19 +226,119 @@ type JWTConfig struct {
 	UserClaimName string `toml:"user_claim_name"` // 用户标识的 claim 名称（默认为 "sub"，可配置为 "preferred_username" 等）
 }
 
+// GroupConfig 用户组配置
+type GroupConfig struct {
+	Name    string   `toml:"name"`    // 唯一标识符
+	Members []string `toml:"members"` // 成员：Basic Auth 用户名、JWT subject 或凭证名称
+	Roles   []string `toml:"roles"`   // 组成员获得的角色
+}
+
+// GroupMapping 外部身份映射规则：JWT claim 的值匹配时加入组并授予角色
+type GroupMapping struct {
+	Claim  string   `toml:"claim"`  // claim 名称（默认 "groups"，值可以是字符串或字符串数组）
+	Match  string   `toml:"match"`  // 匹配方式: "exact"（默认）、"prefix" 或 "regex"（匹配整个值）
+	Value  string   `toml:"value"`  // 匹配值
+	Groups []string `toml:"groups"` // 匹配时加入的组
+	Roles  []string `toml:"roles"`  // 匹配时授予的角色
+
+	// 加载时预编译的结果（不来自 TOML）
+	valueRegex *regexp.Regexp
+}
+
 // RoutePolicy 路由策略配置
 type RoutePolicy struct {
-	Name                string   `toml:"name"`                  // 唯一标识符
-	Priority            int      `toml:"priority"`              // 优先级（数字越大优先级越高，默认 0）
-	Host                string   `toml:"host"`                  // Host 匹配模式
-	PathPrefix          string   `toml:"path_prefix"`           // 路径前缀
-	Method              string   `toml:"method"`                // HTTP 方法
-	AllowAnonymous      bool     `toml:"allow_anonymous"`       // 是否允许匿名访问
-	AllowedBasicNames   []string `toml:"allowed_basic_names"`   // 允许的 Basic Auth 名称
-	AllowedBearerNames  []string `toml:"allowed_bearer_names"`  // 允许的 Bearer Token 名称
-	AllowedAPIKeyNames  []string `toml:"allowed_api_key_names"` // 允许的 API Key 名称
-	JWTOnly             bool     `toml:"jwt_only"`              // 仅允许 JWT
-	RequireAllRoles     []string `toml:"require_all_roles"`     // 必须拥有所有角色
-	RequireAnyRole      []string `toml:"require_any_role"`      // 必须拥有任意一个角色
-	InjectAuthorization string   `toml:"inject_authorization"`  // 注入的 Authorization header
+	Name                    string            `toml:"name"`                     // 唯一标识符
+	Priority                int               `toml:"priority"`                 // 优先级（数字越大优先级越高，默认 0）
+	Host                    string            `toml:"host"`                     // Host 匹配模式
+	PathPrefix              string            `toml:"path_prefix"`              // 路径前缀（按路径段匹配，/api 不匹配 /apiary）
+	PathExact               string            `toml:"path_exact"`               // 精确路径
+	PathGlob                string            `toml:"path_glob"`                // 路径通配（* 匹配单个路径段内字符，** 匹配任意多个路径段）
+	PathRegex               string            `toml:"path_regex"`               // 路径正则（匹配整个规范化后的路径）
+	Method                  string            `toml:"method"`                   // HTTP 方法
+	MatchHeaders            map[string]string `toml:"match_headers"`            // 请求 header 匹配（"*" 表示存在，"~" 前缀表示正则，其余为精确值）
+	MatchQuery              map[string]string `toml:"match_query"`              // query 参数匹配（语法同 match_headers）
+	SourceCIDRs             []string          `toml:"source_cidrs"`             // 客户端 IP/CIDR 白名单
+	AllowAnonymous          bool              `toml:"allow_anonymous"`          // 是否允许匿名访问
+	AllowedBasicNames       []string          `toml:"allowed_basic_names"`      // 允许的 Basic Auth 名称
+	AllowedBearerNames      []string          `toml:"allowed_bearer_names"`     // 允许的 Bearer Token 名称
+	AllowedAPIKeyNames      []string          `toml:"allowed_api_key_names"`    // 允许的 API Key 名称
+	JWTOnly                 bool              `toml:"jwt_only"`                 // 仅允许 JWT
+	RequireAllRoles         []string          `toml:"require_all_roles"`        // 必须拥有所有角色
+	RequireAnyRole          []string          `toml:"require_any_role"`         // 必须拥有任意一个角色
+	RequireAnyGroup         []string          `toml:"require_any_group"`        // 必须属于任意一个组
+	InjectAuthorization     string            `toml:"inject_authorization"`     // 注入的 Authorization header
+	Condition               string            `toml:"condition"`                // 条件表达式（见 internal/expr）
+	Effect                  string            `toml:"effect"`                   // 策略效果: "allow"（默认）或 "deny"；配置了 rule 时作为默认效果
+	DenyStatus              int               `toml:"deny_status"`              // 显式拒绝时返回的 HTTP 状态码（默认 403）
+	DenyResponse            string            `toml:"deny_response"`            // 授权失败的响应: "detailed"（默认，返回错误代码和原因）、"generic"（隐藏原因）、"not_found"（返回 404）
+	ErrorTemplate           string            `toml:"error_template"`           // HTML 错误页面模板（error_pages.template_dir 中的文件名，如 "corp.html"）
+	UnauthenticatedRedirect string            `toml:"unauthenticated_redirect"` // 未认证的浏览器请求重定向到此地址（附加 rd= 原始地址）
+	Realm                   string            `toml:"realm"`                    // WWW-Authenticate challenge 中的 realm（默认 "api"）
+	AllowedCountries        []string          `toml:"allowed_countries"`        // 只允许这些国家的客户端（需要 geoip.country_db）
+	DeniedCountries         []string          `toml:"denied_countries"`         // 拒绝这些国家的客户端
+	DeniedASNs              []uint            `toml:"denied_asns"`              // 拒绝这些自治系统的客户端（需要 geoip.asn_db）
+
+	Rules        []PolicyRule        `toml:"rule"`          // 有序的 allow/deny 规则（第一条匹配的规则决定结果）
+	AuthzWebhook *AuthzWebhookConfig `toml:"authz_webhook"` // 外部授权 Webhook（认证成功后调用）
+	Schedule     *ScheduleConfig     `toml:"schedule"`      // 访问时间窗口（窗口外拒绝访问）
+	RateLimit    *PolicyRateLimit    `toml:"rate_limit"`    // 策略级速率限制（独立于全局 [rate_limit]）
+
+	// 加载时预编译的结果（不来自 TOML）
+	condition     *expr.Program
+	pathRegex     *regexp.Regexp
+	headerMatches []ValueMatcher
+	queryMatches  []ValueMatcher
+	sourceNets    []*net.IPNet
+}
+
+// PolicyRule 策略内的 allow/deny 规则
+// 所有非空条件都满足时规则匹配；没有任何条件的规则匹配所有请求
+type PolicyRule struct {
+	Name        string   `toml:"name"`         // 规则名称（可选，用于审计和日志）
+	Effect      string   `toml:"effect"`       // "allow" 或 "deny"
+	Users       []string `toml:"users"`        // 用户名（任意一个匹配）
+	Roles       []string `toml:"roles"`        // 角色（拥有任意一个即匹配）
+	Groups      []string `toml:"groups"`       // 组（属于任意一个即匹配）
+	AuthMethods []string `toml:"auth_methods"` // 认证方式（basic / bearer / apikey / jwt / anonymous）
+	Names       []string `toml:"names"`        // 凭证配置名称（如 "admin-user"）
+	Methods     []string `toml:"methods"`      // HTTP 方法
+	Condition   string   `toml:"condition"`    // 条件表达式（变量同策略 condition）
+
+	// 加载时预编译的结果（不来自 TOML）
+	condition *expr.Program
+}
+
+// PolicyRateLimit 策略级速率限制
+// 认证失败限制按客户端 IP 计数并封禁；吞吐量限制使用令牌桶，只对通过的请求计数
+type PolicyRateLimit struct {
+	FailedAttempts   int `toml:"failed_attempts"`    // 时间窗口内允许的认证失败次数（0 表示不限制）
+	FailedWindowSecs int `toml:"failed_window_secs"` // 认证失败计数窗口（秒，默认 60）
+	FailedBanSecs    int `toml:"failed_ban_secs"`    // 封禁时长（秒，默认 300）
+
+	Requests   int    `toml:"requests"`    // 每个周期允许的请求数（0 表示不限制吞吐量）
+	PeriodSecs int    `toml:"period_secs"` // 周期（秒，默认 1）
+	Burst      int    `toml:"burst"`       // 令牌桶容量（默认等于 requests）
+	Key        string `toml:"key"`         // 吞吐量计数维度: "ip"（默认）、"credential"（凭证名称）或 "user"（用户名）
+}
+
+// ScheduleConfig 访问时间窗口配置（windows 和 cron 任意一条满足即可访问）
+type ScheduleConfig struct {
+	Timezone string   `toml:"timezone"` // IANA 时区（如 "Europe/Berlin"，默认 UTC）
+	Windows  []string `toml:"windows"`  // 星期 + 时间段，如 "mon-fri 09:00-18:00"、"sat 22:00-02:00"
+	Cron     []string `toml:"cron"`     // 5 段 cron（分 时 日 月 周），匹配的每一分钟都可访问，如 "* 9-17 * * mon-fri"
+
+	// 加载时预编译的结果（不来自 TOML）
+	compiled *schedule.Schedule
+}
+
+// AuthzWebhookConfig 外部授权 Webhook 配置
+type AuthzWebhookConfig struct {
+	URL          string `toml:"url"`            // Webhook 地址（接收 POST JSON）
+	TimeoutMs    int    `toml:"timeout_ms"`     // 请求超时（毫秒）
+	FailureMode  string `toml:"failure_mode"`   // 调用失败时的处理: "closed"（拒绝）或 "open"（放行）
+	CacheTTLSecs int    `toml:"cache_ttl_secs"` // 决策缓存时长（秒，0 表示不缓存）
+	Secret       string `toml:"secret"`         // 共享密钥，用于 HMAC 签名请求体（支持 env:VAR 语法）
+	TLSCertFile  string `toml:"tls_cert_file"`  // mTLS 客户端证书
+	TLSKeyFile   string `toml:"tls_key_file"`   // mTLS 客户端私钥
+	TLSCAFile    string `toml:"tls_ca_file"`    // 校验 Webhook 服务端证书的 CA（为空时使用系统 CA）
 }
@@ -2,9 +2,15 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
+	"unicode"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/expr"
+	"github.com/nerdneilsfield/tiny-auth/internal/schedule"
 )
 
 var headerNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
@@ -31,6 +37,36 @@ func Validate(cfg *Config) error {
 		return fmt.Errorf("audit: %w", err)
 	}
 
+	// 验证速率限制后端
+	if err := validateRateLimit(&cfg.RateLimit); err != nil {
+		return fmt.Errorf("rate_limit: %w", err)
+	}
+
+	// 验证管理 API
+	if err := validateAdmin(&cfg.Admin); err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
+
+	// 验证 IP 过滤
+	if err := validateIPFilter(&cfg.IPFilter); err != nil {
+		return fmt.Errorf("ip_filter: %w", err)
+	}
+
+	// 验证 GeoIP 数据库和全局国家 / ASN 规则
+	if err := validateGeoIP(cfg); err != nil {
+		return fmt.Errorf("geoip: %w", err)
+	}
+
+	// 验证指标端点
+	if err := validateMetrics(cfg); err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+
+	// 验证追踪导出
+	if err := validateTracing(&cfg.Tracing); err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+
 	// 验证 Basic Auth
 	if err := validateBasicAuths(cfg.BasicAuths); err != nil {
 		return fmt.Errorf("basic_auth: %w", err)
@@ -46,6 +82,26 @@ func Validate(cfg *Config) error {
 		return fmt.Errorf("api_key: %w", err)
 	}
 
+	// 验证角色继承（检测循环）
+	if err := validateRoles(cfg); err != nil {
+		return fmt.Errorf("roles: %w", err)
+	}
+
+	// 验证用户组和外部身份映射
+	if err := validateGroups(cfg); err != nil {
+		return err
+	}
+
+	// 编译凭证的访问时间窗口
+	if err := validateCredentialSchedules(cfg); err != nil {
+		return err
+	}
+
+	// 验证凭证用量配额
+	if err := validateQuotas(cfg); err != nil {
+		return err
+	}
+
 	// 验证 JWT
 	if err := validateJWT(&cfg.JWT); err != nil {
 		return fmt.Errorf("jwt: %w", err)
@@ -56,6 +112,11 @@ func Validate(cfg *Config) error {
 		return fmt.Errorf("route_policy: %w", err)
 	}
 
+	// 加载错误页面模板，验证策略的错误页面和重定向配置
+	if err := validateErrorPages(cfg); err != nil {
+		return err
+	}
+
 	// 高级验证：循环依赖检测
 	if err := validatePolicyDependencies(cfg); err != nil {
 		return fmt.Errorf("policy dependencies: %w", err)
@@ -100,7 +161,7 @@ func validateServer(cfg *ServerConfig) error {
 }
 
 func validateHeaders(cfg *HeadersConfig) error {
-	headers := []string{cfg.UserHeader, cfg.RoleHeader, cfg.MethodHeader}
+	headers := []string{cfg.UserHeader, cfg.RoleHeader, cfg.MethodHeader, cfg.CountryHeader}
 	headers = append(headers, cfg.ExtraHeaders...)
 
 	seen := make(map[string]bool)
@@ -153,6 +214,85 @@ func validateAudit(cfg *AuditConfig) error {
 		return fmt.Errorf("output cannot be empty when audit is enabled")
 	}
 
+	if cfg.MaxSizeMB < 0 {
+		return fmt.Errorf("max_size_mb cannot be negative")
+	}
+	if cfg.MaxBackups < 0 {
+		return fmt.Errorf("max_backups cannot be negative")
+	}
+	if cfg.MaxAgeDays < 0 {
+		return fmt.Errorf("max_age_days cannot be negative")
+	}
+	switch cfg.RotateInterval {
+	case "", "hourly", "daily":
+	default:
+		return fmt.Errorf("rotate_interval must be 'hourly', 'daily' or empty, got %q", cfg.RotateInterval)
+	}
+
+	// 轮转只对文件输出有效
+	rotation := cfg.MaxSizeMB > 0 || cfg.RotateInterval != "" || cfg.Compress || cfg.MaxBackups > 0 || cfg.MaxAgeDays > 0
+	if output := strings.TrimSpace(cfg.Output); rotation && (output == "stdout" || output == "stderr") {
+		return fmt.Errorf("rotation options require a file output, got %q", output)
+	}
+
+	return nil
+}
+
+func validateRateLimit(cfg *RateLimitConfig) error {
+	if cfg.MaxEntries < 0 {
+		return fmt.Errorf("max_entries cannot be negative")
+	}
+	if cfg.IPv6Prefix < 0 || cfg.IPv6Prefix > 128 {
+		return fmt.Errorf("ipv6_prefix must be between 1 and 128, got %d", cfg.IPv6Prefix)
+	}
+
+	switch cfg.Mode {
+	case "", "ban":
+	case "backoff":
+		if cfg.Backend == "redis" {
+			return fmt.Errorf("mode \"backoff\" is only supported with the memory backend")
+		}
+		if err := validateBackoff(&cfg.Backoff); err != nil {
+			return fmt.Errorf("backoff: %w", err)
+		}
+	default:
+		return fmt.Errorf("mode must be \"ban\" or \"backoff\", got %q", cfg.Mode)
+	}
+
+	switch cfg.Backend {
+	case "", "memory":
+		return nil
+	case "redis":
+	default:
+		return fmt.Errorf("backend must be \"memory\" or \"redis\", got %q", cfg.Backend)
+	}
+
+	if cfg.Redis.Addr == "" {
+		return fmt.Errorf("redis.addr is required when backend is \"redis\"")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Redis.Addr); err != nil {
+		return fmt.Errorf("redis.addr must be host:port, got %q", cfg.Redis.Addr)
+	}
+	if cfg.Redis.DB < 0 || cfg.Redis.TimeoutMs < 0 {
+		return fmt.Errorf("redis.db and redis.timeout_ms cannot be negative")
+	}
+	return nil
+}
+
+func validateBackoff(cfg *BackoffConfig) error {
+	if cfg.BaseMs < 0 || cfg.MaxSecs < 0 || cfg.SuccessDecay < 0 || cfg.HoldSecs < 0 {
+		return fmt.Errorf("base_ms, max_secs, success_decay and hold_secs cannot be negative")
+	}
+	if cfg.Factor != 0 && cfg.Factor < 1 {
+		return fmt.Errorf("factor must be at least 1, got %g", cfg.Factor)
+	}
+	if cfg.MaxSecs > 0 && cfg.BaseMs > cfg.MaxSecs*1000 {
+		return fmt.Errorf("base_ms (%d) cannot exceed max_secs (%d)", cfg.BaseMs, cfg.MaxSecs)
+	}
+	if cfg.HoldSecs > cfg.MaxSecs && cfg.MaxSecs > 0 {
+		fmt.Fprintf(os.Stderr, "⚠ Warning: rate_limit.backoff.hold_secs (%d) exceeds max_secs (%d); responses are held at most max_secs.\n",
+			cfg.HoldSecs, cfg.MaxSecs)
+	}
 	return nil
 }
 
@@ -269,6 +409,39 @@ func validateAPIKeys(configs []APIKeyConfig) error {
 	return validateSecretConfigs(configs, "key")
 }
 
+// validateCredentialSchedules 编译 Basic Auth / Bearer Token / API Key 的访问时间窗口
+func validateCredentialSchedules(cfg *Config) error {
+	for _, b := range cfg.BasicAuths {
+		if err := validateSchedule(b.Schedule); err != nil {
+			return fmt.Errorf("basic_auth: [%s] schedule: %w", b.Name, err)
+		}
+	}
+	for _, b := range cfg.BearerTokens {
+		if err := validateSchedule(b.Schedule); err != nil {
+			return fmt.Errorf("bearer_token: [%s] schedule: %w", b.Name, err)
+		}
+	}
+	for _, k := range cfg.APIKeys {
+		if err := validateSchedule(k.Schedule); err != nil {
+			return fmt.Errorf("api_key: [%s] schedule: %w", k.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateSchedule 编译时间窗口（时区、窗口和 cron 语法错误在加载时报告）
+func validateSchedule(cfg *ScheduleConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	compiled, err := schedule.Parse(cfg.Timezone, cfg.Windows, cfg.Cron)
+	if err != nil {
+		return err
+	}
+	cfg.compiled = compiled
+	return nil
+}
+
 func validateJWT(cfg *JWTConfig) error {
 	if cfg.Secret == "" {
 		return nil // JWT 是可选的
@@ -346,6 +519,239 @@ func validateRoutePolicies(policies []RoutePolicy, cfg *Config) error {
 		if policy.JWTOnly && (len(policy.AllowedBasicNames) > 0 || len(policy.AllowedBearerNames) > 0 || len(policy.AllowedAPIKeyNames) > 0) {
 			fmt.Fprintf(os.Stderr, "⚠ Warning: Policy [%s] is jwt_only but has other method restrictions (will be ignored)\n", policy.Name)
 		}
+
+		// 验证路径匹配方式（最多只能配置一种）
+		if err := validatePolicyPath(&policies[i]); err != nil {
+			return fmt.Errorf("[%s] %w", policy.Name, err)
+		}
+
+		// 编译 header / query / 来源网段匹配条件
+		if err := validatePolicyMatchers(&policies[i]); err != nil {
+			return fmt.Errorf("[%s] %w", policy.Name, err)
+		}
+
+		// 验证 allow/deny 规则
+		if err := validatePolicyRules(&policies[i]); err != nil {
+			return fmt.Errorf("[%s] %w", policy.Name, err)
+		}
+
+		// 验证国家 / ASN 限制
+		if err := validateGeoRules(policy.GeoRules(), &cfg.GeoIP); err != nil {
+			return fmt.Errorf("[%s] %w", policy.Name, err)
+		}
+
+		// 编译访问时间窗口
+		if err := validateSchedule(policy.Schedule); err != nil {
+			return fmt.Errorf("[%s] schedule: %w", policy.Name, err)
+		}
+
+		// 验证策略级速率限制
+		if policy.RateLimit != nil {
+			if err := validatePolicyRateLimit(policy.RateLimit); err != nil {
+				return fmt.Errorf("[%s] rate_limit: %w", policy.Name, err)
+			}
+			if policy.RateLimit.FailedAttempts == 0 && policy.RateLimit.Requests == 0 {
+				fmt.Fprintf(os.Stderr, "⚠ Warning: Policy [%s] has rate_limit without failed_attempts or requests (no limit applied)\n", policy.Name)
+			}
+		}
+
+		// 编译条件表达式（语法错误带有行号和列号）
+		if policy.Condition != "" {
+			prog, err := expr.Compile(policy.Condition, ConditionVariables...)
+			if err != nil {
+				return fmt.Errorf("[%s] condition: %w", policy.Name, err)
+			}
+			policies[i].condition = prog
+		}
+
+		// 验证外部授权 Webhook
+		if policy.AuthzWebhook != nil {
+			if err := validateAuthzWebhook(policy.AuthzWebhook); err != nil {
+				return fmt.Errorf("[%s] authz_webhook: %w", policy.Name, err)
+			}
+			if policy.AllowAnonymous {
+				fmt.Fprintf(os.Stderr, "⚠ Warning: Policy [%s] allows anonymous but has authz_webhook (webhook will be skipped)\n", policy.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePolicyRules 验证策略效果和 allow/deny 规则，并预编译规则条件
+func validatePolicyRules(policy *RoutePolicy) error {
+	if policy.Effect != "" && policy.Effect != "allow" && policy.Effect != "deny" {
+		return fmt.Errorf("effect must be \"allow\" or \"deny\", got %q", policy.Effect)
+	}
+	if policy.DenyStatus != 0 && (policy.DenyStatus < 400 || policy.DenyStatus > 599) {
+		return fmt.Errorf("deny_status must be between 400 and 599, got %d", policy.DenyStatus)
+	}
+	switch policy.DenyResponse {
+	case "", "detailed", "generic", "not_found":
+	default:
+		return fmt.Errorf("deny_response must be \"detailed\", \"generic\" or \"not_found\", got %q", policy.DenyResponse)
+	}
+
+	// realm 写入带引号的 WWW-Authenticate 参数，不允许引号、反斜杠和控制字符
+	if strings.ContainsAny(policy.Realm, "\"\\") || strings.IndexFunc(policy.Realm, unicode.IsControl) >= 0 {
+		return fmt.Errorf("realm must not contain quotes, backslashes or control characters, got %q", policy.Realm)
+	}
+
+	validAuthMethods := map[string]bool{"basic": true, "bearer": true, "apikey": true, "jwt": true, "anonymous": true}
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		label := fmt.Sprintf("rule[%d]", i)
+		if rule.Name != "" {
+			label = fmt.Sprintf("rule[%s]", rule.Name)
+		}
+
+		if rule.Effect != "allow" && rule.Effect != "deny" {
+			return fmt.Errorf("%s: effect must be \"allow\" or \"deny\", got %q", label, rule.Effect)
+		}
+		for _, m := range rule.AuthMethods {
+			if !validAuthMethods[m] {
+				return fmt.Errorf("%s: unknown auth method %q", label, m)
+			}
+		}
+		if rule.Condition != "" {
+			prog, err := expr.Compile(rule.Condition, ConditionVariables...)
+			if err != nil {
+				return fmt.Errorf("%s condition: %w", label, err)
+			}
+			rule.condition = prog
+		}
+	}
+
+	if policy.Effect == "deny" && len(policy.Rules) == 0 && policy.AllowAnonymous {
+		fmt.Fprintf(os.Stderr, "⚠ Warning: Policy [%s] has effect = \"deny\" without rules (allow_anonymous has no effect)\n", policy.Name)
+	}
+
+	return nil
+}
+
+// validatePolicyMatchers 验证并预编译 match_headers、match_query 和 source_cidrs
+func validatePolicyMatchers(policy *RoutePolicy) error {
+	if len(policy.MatchHeaders) > 0 {
+		matchers, err := compileValueMatchers(policy.MatchHeaders, true)
+		if err != nil {
+			return fmt.Errorf("match_headers: %w", err)
+		}
+		policy.headerMatches = matchers
+	}
+
+	if len(policy.MatchQuery) > 0 {
+		matchers, err := compileValueMatchers(policy.MatchQuery, false)
+		if err != nil {
+			return fmt.Errorf("match_query: %w", err)
+		}
+		policy.queryMatches = matchers
+	}
+
+	if len(policy.SourceCIDRs) > 0 {
+		nets, err := parseSourceCIDRs(policy.SourceCIDRs)
+		if err != nil {
+			return fmt.Errorf("source_cidrs: %w", err)
+		}
+		policy.sourceNets = nets
+	}
+
+	return nil
+}
+
+// validatePolicyPath 验证路径匹配配置，并预编译 path_regex
+func validatePolicyPath(policy *RoutePolicy) error {
+	count := 0
+	for _, v := range []string{policy.PathPrefix, policy.PathExact, policy.PathGlob, policy.PathRegex} {
+		if v != "" {
+			count++
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("only one of path_prefix, path_exact, path_glob and path_regex can be set")
+	}
+
+	if policy.PathPrefix != "" && !strings.HasPrefix(policy.PathPrefix, "/") {
+		return fmt.Errorf("path_prefix must start with /")
+	}
+	if policy.PathExact != "" && !strings.HasPrefix(policy.PathExact, "/") {
+		return fmt.Errorf("path_exact must start with /")
+	}
+
+	if policy.PathGlob != "" {
+		if err := validatePathGlob(policy.PathGlob); err != nil {
+			return fmt.Errorf("path_glob: %w", err)
+		}
+	}
+
+	if policy.PathRegex != "" {
+		re, err := compilePathRegex(policy.PathRegex)
+		if err != nil {
+			return fmt.Errorf("path_regex: %w", err)
+		}
+		policy.pathRegex = re
+	}
+
+	return nil
+}
+
+// validatePolicyRateLimit 验证策略级速率限制
+func validatePolicyRateLimit(cfg *PolicyRateLimit) error {
+	if cfg.FailedAttempts < 0 || cfg.FailedWindowSecs < 0 || cfg.FailedBanSecs < 0 {
+		return fmt.Errorf("failed_attempts, failed_window_secs and failed_ban_secs cannot be negative")
+	}
+	if cfg.Requests < 0 || cfg.PeriodSecs < 0 || cfg.Burst < 0 {
+		return fmt.Errorf("requests, period_secs and burst cannot be negative")
+	}
+	if cfg.Burst > 0 && cfg.Requests == 0 {
+		return fmt.Errorf("burst requires requests")
+	}
+	switch cfg.Key {
+	case "", "ip", "credential", "user":
+	default:
+		return fmt.Errorf("key must be \"ip\", \"credential\" or \"user\", got %q", cfg.Key)
+	}
+	return nil
+}
+
+func validateAuthzWebhook(cfg *AuthzWebhookConfig) error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url must be an absolute http(s) URL, got %q", cfg.URL)
+	}
+
+	if cfg.TimeoutMs <= 0 {
+		return fmt.Errorf("timeout_ms must be positive")
+	}
+
+	if cfg.FailureMode != "open" && cfg.FailureMode != "closed" {
+		return fmt.Errorf("failure_mode must be 'open' or 'closed', got %q", cfg.FailureMode)
+	}
+
+	if cfg.CacheTTLSecs < 0 {
+		return fmt.Errorf("cache_ttl_secs cannot be negative")
+	}
+
+	// mTLS 证书和私钥必须成对出现
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+
+	if u.Scheme != "https" && (cfg.TLSCertFile != "" || cfg.TLSCAFile != "") {
+		return fmt.Errorf("tls_* options require an https url")
+	}
+
+	for _, f := range []string{cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSCAFile} {
+		if f == "" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			return fmt.Errorf("cannot access %q: %w", f, err)
+		}
+	}
+
+	// 明文 HTTP 且无共享密钥时，Webhook 无法校验请求来源
+	if u.Scheme == "http" && cfg.Secret == "" {
+		fmt.Fprintf(os.Stderr, "⚠ Warning: authz_webhook %s uses plain http without a shared secret\n", cfg.URL)
 	}
 
 	return nil
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -24,6 +25,17 @@ func validatePolicyDependencies(cfg *Config) error {
 		availableNames[auth.Name] = true
 	}
 
+	// 可用的组：[[group]] 定义的组和 group_mapping 可能加入的组
+	availableGroups := make(map[string]bool)
+	for _, g := range cfg.Groups {
+		availableGroups[g.Name] = true
+	}
+	for _, m := range cfg.GroupMappings {
+		for _, g := range m.Groups {
+			availableGroups[g] = true
+		}
+	}
+
 	// 检查每个策略引用的名称是否存在
 	for i := range cfg.RoutePolicies {
 		policy := cfg.RoutePolicies[i]
@@ -47,6 +59,13 @@ func validatePolicyDependencies(cfg *Config) error {
 				return fmt.Errorf("policy [%s] references non-existent api_key name: %q", policy.Name, name)
 			}
 		}
+
+		// 检查 require_any_group
+		for _, name := range policy.RequireAnyGroup {
+			if !availableGroups[name] {
+				return fmt.Errorf("policy [%s] references non-existent group: %q", policy.Name, name)
+			}
+		}
 	}
 
 	return nil
@@ -63,9 +82,10 @@ func validatePolicyConflicts(policies []RoutePolicy) error {
 
 	// 记录所有策略的匹配规则
 	type policyKey struct {
-		host   string
-		path   string
-		method string
+		host     string
+		path     string
+		method   string
+		matchers string
 	}
 
 	conflicts := make(map[policyKey][]string)
@@ -78,10 +98,7 @@ func validatePolicyConflicts(policies []RoutePolicy) error {
 			hosts = []string{"*"} // 空表示匹配所有
 		}
 
-		paths := []string{policy.PathPrefix}
-		if policy.PathPrefix == "" {
-			paths = []string{"/"} // 空表示根路径
-		}
+		paths := []string{policyPathKey(&policy)}
 
 		methods := []string{policy.Method}
 		if policy.Method == "" {
@@ -93,9 +110,10 @@ func validatePolicyConflicts(policies []RoutePolicy) error {
 			for _, path := range paths {
 				for _, method := range methods {
 					key := policyKey{
-						host:   host,
-						path:   path,
-						method: method,
+						host:     host,
+						path:     path,
+						method:   method,
+						matchers: policyMatchersKey(&policy),
 					}
 					conflicts[key] = append(conflicts[key], policy.Name)
 				}
@@ -109,8 +127,8 @@ func validatePolicyConflicts(policies []RoutePolicy) error {
 		if len(policyNames) > 1 {
 			// 发现冲突，但不是致命错误，只发出警告
 			hasConflict = true
-			fmt.Fprintf(os.Stderr, "⚠ Warning: Multiple policies match [host=%s, path=%s, method=%s]: %v\n",
-				key.host, key.path, key.method, policyNames)
+			fmt.Fprintf(os.Stderr, "⚠ Warning: Multiple policies match [host=%s, path=%s, method=%s%s]: %v\n",
+				key.host, key.path, key.method, key.matchers, policyNames)
 			fmt.Fprintf(os.Stderr, "  → First matching policy will be used (order matters)\n")
 		}
 	}
@@ -123,6 +141,51 @@ func validatePolicyConflicts(policies []RoutePolicy) error {
 	return nil // 冲突不是错误，只是警告
 }
 
+// policyPathKey 返回策略路径条件的描述（用于冲突检测）
+func policyPathKey(policy *RoutePolicy) string {
+	switch {
+	case policy.PathExact != "":
+		return "exact:" + policy.PathExact
+	case policy.PathGlob != "":
+		return "glob:" + policy.PathGlob
+	case policy.PathRegex != "":
+		return "regex:" + policy.PathRegex
+	case policy.PathPrefix != "":
+		return policy.PathPrefix
+	}
+	return "/" // 空表示根路径
+}
+
+// policyMatchersKey 返回策略附加匹配条件的描述（用于冲突检测）
+// 附加条件不同的策略不视为冲突
+func policyMatchersKey(policy *RoutePolicy) string {
+	var parts []string
+	for _, name := range sortedKeys(policy.MatchHeaders) {
+		parts = append(parts, fmt.Sprintf("header %s=%s", strings.ToLower(name), policy.MatchHeaders[name]))
+	}
+	for _, name := range sortedKeys(policy.MatchQuery) {
+		parts = append(parts, fmt.Sprintf("query %s=%s", name, policy.MatchQuery[name]))
+	}
+	if len(policy.SourceCIDRs) > 0 {
+		cidrs := append([]string(nil), policy.SourceCIDRs...)
+		sort.Strings(cidrs)
+		parts = append(parts, "source="+strings.Join(cidrs, ","))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(parts, ", ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // validateJWTSecretStrength 验证 JWT Secret 强度
 // 检查密钥长度和熵值
 func validateJWTSecretStrength(jwt *JWTConfig) error {
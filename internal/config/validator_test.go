@@ -0,0 +1,382 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateAuthzWebhook 测试外部授权 Webhook 配置验证
+func TestValidateAuthzWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		webhook   AuthzWebhookConfig
+		expectErr bool
+		errMsg    string
+	}{
+		{
+			name:    "Valid https webhook",
+			webhook: AuthzWebhookConfig{URL: "https://authz.internal/decide", TimeoutMs: 500, FailureMode: "closed"},
+		},
+		{
+			name:    "Valid http webhook with secret",
+			webhook: AuthzWebhookConfig{URL: "http://authz:9000/decide", TimeoutMs: 500, FailureMode: "open", Secret: "s3cr3t"},
+		},
+		{
+			name:      "Relative URL",
+			webhook:   AuthzWebhookConfig{URL: "/decide", TimeoutMs: 500, FailureMode: "closed"},
+			expectErr: true,
+			errMsg:    "url must be an absolute http(s) URL",
+		},
+		{
+			name:      "Invalid failure mode",
+			webhook:   AuthzWebhookConfig{URL: "https://authz.internal", TimeoutMs: 500, FailureMode: "maybe"},
+			expectErr: true,
+			errMsg:    "failure_mode",
+		},
+		{
+			name:      "Non-positive timeout",
+			webhook:   AuthzWebhookConfig{URL: "https://authz.internal", FailureMode: "closed"},
+			expectErr: true,
+			errMsg:    "timeout_ms",
+		},
+		{
+			name:      "Client cert without key",
+			webhook:   AuthzWebhookConfig{URL: "https://authz.internal", TimeoutMs: 500, FailureMode: "closed", TLSCertFile: "client.pem"},
+			expectErr: true,
+			errMsg:    "must be set together",
+		},
+		{
+			name:      "TLS options with http URL",
+			webhook:   AuthzWebhookConfig{URL: "http://authz.internal", TimeoutMs: 500, FailureMode: "closed", TLSCAFile: "ca.pem"},
+			expectErr: true,
+			errMsg:    "require an https url",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAuthzWebhook(&tt.webhook)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.errMsg)
+				}
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// TestValidateRoutePolicies_Condition 测试条件表达式在加载时编译
+func TestValidateRoutePolicies_Condition(t *testing.T) {
+	cfg := &Config{
+		RoutePolicies: []RoutePolicy{
+			{Name: "ok", Condition: `"admin" in auth.roles`},
+		},
+	}
+	if err := validateRoutePolicies(cfg.RoutePolicies, cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.RoutePolicies[0].condition == nil {
+		t.Error("Expected condition to be precompiled")
+	}
+
+	cfg.RoutePolicies = []RoutePolicy{
+		{Name: "broken", Condition: "auth.user == \"a\" &&\n  (request.method == \"GET\""},
+	}
+	err := validateRoutePolicies(cfg.RoutePolicies, cfg)
+	if err == nil {
+		t.Fatal("Expected syntax error, got nil")
+	}
+	if want := "[broken] condition: line 2, column 27"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected error containing %q, got %v", want, err)
+	}
+}
+
+// TestValidatePolicyPath 测试路径匹配配置验证
+func TestValidatePolicyPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    RoutePolicy
+		expectErr bool
+		errMsg    string
+	}{
+		{name: "Prefix", policy: RoutePolicy{PathPrefix: "/api"}},
+		{name: "Glob", policy: RoutePolicy{PathGlob: "/static/**/*.css"}},
+		{name: "Regex", policy: RoutePolicy{PathRegex: `/v[0-9]+/.*`}},
+		{
+			name:      "Multiple path matchers",
+			policy:    RoutePolicy{PathPrefix: "/api", PathExact: "/api/login"},
+			expectErr: true,
+			errMsg:    "only one of",
+		},
+		{
+			name:      "Relative exact path",
+			policy:    RoutePolicy{PathExact: "login"},
+			expectErr: true,
+			errMsg:    "path_exact must start with /",
+		},
+		{
+			name:      "Partial double star",
+			policy:    RoutePolicy{PathGlob: "/static/a**"},
+			expectErr: true,
+			errMsg:    "** must be a whole path segment",
+		},
+		{
+			name:      "Bad glob class",
+			policy:    RoutePolicy{PathGlob: "/static/[a"},
+			expectErr: true,
+			errMsg:    "path_glob",
+		},
+		{
+			name:      "Bad regex",
+			policy:    RoutePolicy{PathRegex: "/v[0-9"},
+			expectErr: true,
+			errMsg:    "path_regex",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePolicyPath(&tt.policy)
+			if tt.expectErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.policy.PathRegex != "" && tt.policy.pathRegex == nil {
+				t.Error("Expected path_regex to be precompiled")
+			}
+		})
+	}
+}
+
+// TestValidatePolicyMatchers 测试 header / query / 来源网段匹配配置验证
+func TestValidatePolicyMatchers(t *testing.T) {
+	policy := RoutePolicy{
+		MatchHeaders: map[string]string{"X-Tenant": "acme", "User-Agent": "~curl"},
+		MatchQuery:   map[string]string{"access_token": "*"},
+		SourceCIDRs:  []string{"10.0.0.0/8", "2001:db8::1"},
+	}
+	if err := validatePolicyMatchers(&policy); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(policy.headerMatches) != 2 || policy.headerMatches[0].Name != "user-agent" {
+		t.Errorf("Expected sorted lowercase header matchers, got %+v", policy.headerMatches)
+	}
+	if len(policy.sourceNets) != 2 {
+		t.Errorf("Expected 2 source networks, got %d", len(policy.sourceNets))
+	}
+
+	tests := []struct {
+		name   string
+		policy RoutePolicy
+		errMsg string
+	}{
+		{"Bad header name", RoutePolicy{MatchHeaders: map[string]string{"X Tenant": "a"}}, "match_headers: invalid header name"},
+		{"Bad header regex", RoutePolicy{MatchHeaders: map[string]string{"User-Agent": "~("}}, "match_headers"},
+		{"Empty query name", RoutePolicy{MatchQuery: map[string]string{"": "*"}}, "match_query"},
+		{"Bad CIDR", RoutePolicy{SourceCIDRs: []string{"10.0.0.0/33"}}, "source_cidrs: invalid CIDR"},
+		{"Bad IP", RoutePolicy{SourceCIDRs: []string{"office"}}, "source_cidrs: invalid IP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePolicyMatchers(&tt.policy)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+// TestValidatePolicyRules 测试策略效果和 allow/deny 规则验证
+func TestValidatePolicyRules(t *testing.T) {
+	policy := RoutePolicy{
+		Effect: "deny",
+		Rules:  []PolicyRule{{Effect: "allow", Condition: `"ops" in auth.roles`}},
+	}
+	if err := validatePolicyRules(&policy); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if policy.Rules[0].condition == nil {
+		t.Error("Expected rule condition to be precompiled")
+	}
+
+	tests := []struct {
+		name   string
+		policy RoutePolicy
+		errMsg string
+	}{
+		{"Bad policy effect", RoutePolicy{Effect: "block"}, "effect must be"},
+		{"Bad deny status", RoutePolicy{DenyStatus: 302}, "deny_status must be between 400 and 599"},
+		{"Bad deny response", RoutePolicy{DenyResponse: "silent"}, "deny_response must be"},
+		{"Quoted realm", RoutePolicy{Realm: `my "realm"`}, "realm must not contain"},
+		{"Missing rule effect", RoutePolicy{Rules: []PolicyRule{{Name: "r"}}}, "rule[r]: effect must be"},
+		{"Unknown auth method", RoutePolicy{Rules: []PolicyRule{{Effect: "deny", AuthMethods: []string{"oauth"}}}}, `rule[0]: unknown auth method "oauth"`},
+		{"Bad rule condition", RoutePolicy{Rules: []PolicyRule{{Effect: "deny", Condition: "auth.user =="}}}, "rule[0] condition: line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePolicyRules(&tt.policy)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+// TestValidateGroups 测试用户组和外部身份映射验证
+func TestValidateGroups(t *testing.T) {
+	cfg := &Config{
+		Groups:        []GroupConfig{{Name: "platform", Members: []string{"alice"}, Roles: []string{"ops"}}},
+		GroupMappings: []GroupMapping{{Match: "regex", Value: "admins-.*", Roles: []string{"admin"}}},
+	}
+	if err := validateGroups(cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.GroupMappings[0].valueRegex == nil {
+		t.Error("Expected mapping regex to be precompiled")
+	}
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		errMsg string
+	}{
+		{"Duplicate group", Config{Groups: []GroupConfig{{Name: "a", Members: []string{"x"}}, {Name: "a", Members: []string{"y"}}}}, `duplicate name "a"`},
+		{"Empty mapping value", Config{GroupMappings: []GroupMapping{{Roles: []string{"r"}}}}, "value cannot be empty"},
+		{"Mapping without target", Config{GroupMappings: []GroupMapping{{Value: "eng"}}}, "at least one of groups or roles"},
+		{"Unknown match mode", Config{GroupMappings: []GroupMapping{{Match: "suffix", Value: "eng", Roles: []string{"r"}}}}, "match must be"},
+		{"Bad regex", Config{GroupMappings: []GroupMapping{{Match: "regex", Value: "(", Roles: []string{"r"}}}}, "invalid regex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateGroups(&tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+
+	// 策略引用未定义的组
+	cfg.RoutePolicies = []RoutePolicy{{Name: "p", RequireAnyGroup: []string{"missing"}}}
+	if err := validatePolicyDependencies(cfg); err == nil || !strings.Contains(err.Error(), `non-existent group: "missing"`) {
+		t.Errorf("Expected non-existent group error, got %v", err)
+	}
+}
+
+// TestValidatePolicyRateLimit 测试策略级速率限制验证
+func TestValidatePolicyRateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  PolicyRateLimit
+		errMsg string
+	}{
+		{"Valid", PolicyRateLimit{FailedAttempts: 5, Requests: 10, PeriodSecs: 1, Burst: 20, Key: "user"}, ""},
+		{"Negative attempts", PolicyRateLimit{FailedAttempts: -1}, "cannot be negative"},
+		{"Negative requests", PolicyRateLimit{Requests: -1}, "cannot be negative"},
+		{"Burst without requests", PolicyRateLimit{Burst: 5}, "burst requires requests"},
+		{"Unknown key", PolicyRateLimit{Requests: 1, Key: "session"}, "key must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePolicyRateLimit(&tt.limit)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+// TestValidateRateLimit 测试速率限制后端验证
+func TestValidateRateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    RateLimitConfig
+		errMsg string
+	}{
+		{"Memory", RateLimitConfig{Backend: "memory"}, ""},
+		{"Redis", RateLimitConfig{Backend: "redis", Redis: RedisConfig{Addr: "redis:6379"}}, ""},
+		{"Unknown backend", RateLimitConfig{Backend: "memcached"}, "backend must be"},
+		{"Redis without addr", RateLimitConfig{Backend: "redis"}, "redis.addr is required"},
+		{"Redis addr without port", RateLimitConfig{Backend: "redis", Redis: RedisConfig{Addr: "redis"}}, "host:port"},
+		{"IPv6 prefix", RateLimitConfig{Backend: "memory", IPv6Prefix: 48, MaxEntries: 1000}, ""},
+		{"IPv6 prefix too long", RateLimitConfig{IPv6Prefix: 129}, "ipv6_prefix must be between"},
+		{"Negative max entries", RateLimitConfig{MaxEntries: -1}, "max_entries cannot be negative"},
+		{"Backoff", RateLimitConfig{Mode: "backoff", Backoff: BackoffConfig{BaseMs: 500, Factor: 1.5, MaxSecs: 60}}, ""},
+		{"Unknown mode", RateLimitConfig{Mode: "tarpit"}, "mode must be"},
+		{"Backoff with redis", RateLimitConfig{Mode: "backoff", Backend: "redis", Redis: RedisConfig{Addr: "redis:6379"}}, "only supported with the memory backend"},
+		{"Backoff factor below 1", RateLimitConfig{Mode: "backoff", Backoff: BackoffConfig{Factor: 0.5}}, "factor must be at least 1"},
+		{"Backoff base above max", RateLimitConfig{Mode: "backoff", Backoff: BackoffConfig{BaseMs: 5000, MaxSecs: 1}}, "cannot exceed max_secs"},
+		{"Negative hold", RateLimitConfig{Mode: "backoff", Backoff: BackoffConfig{HoldSecs: -1}}, "cannot be negative"},
+		{"Negative timeout", RateLimitConfig{Backend: "redis", Redis: RedisConfig{Addr: "redis:6379", TimeoutMs: -1}}, "cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRateLimit(&tt.cfg)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+// TestValidateAudit 测试审计日志轮转配置验证
+func TestValidateAudit(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    AuditConfig
+		errMsg string
+	}{
+		{"Disabled", AuditConfig{MaxSizeMB: -1}, ""},
+		{"Stdout", AuditConfig{Enabled: true, Output: "stdout"}, ""},
+		{"Empty output", AuditConfig{Enabled: true, Output: " "}, "output cannot be empty"},
+		{"File rotation", AuditConfig{Enabled: true, Output: "/var/log/audit.log", MaxSizeMB: 100, RotateInterval: "daily", Compress: true, MaxBackups: 7, MaxAgeDays: 30}, ""},
+		{"Hourly", AuditConfig{Enabled: true, Output: "audit.log", RotateInterval: "hourly"}, ""},
+		{"Unknown interval", AuditConfig{Enabled: true, Output: "audit.log", RotateInterval: "weekly"}, "rotate_interval must be"},
+		{"Negative size", AuditConfig{Enabled: true, Output: "audit.log", MaxSizeMB: -1}, "max_size_mb cannot be negative"},
+		{"Negative backups", AuditConfig{Enabled: true, Output: "audit.log", MaxBackups: -1}, "max_backups cannot be negative"},
+		{"Negative age", AuditConfig{Enabled: true, Output: "audit.log", MaxAgeDays: -1}, "max_age_days cannot be negative"},
+		{"Rotation on stdout", AuditConfig{Enabled: true, Output: "stdout", MaxSizeMB: 10}, "require a file output"},
+		{"Compress on stderr", AuditConfig{Enabled: true, Output: "stderr", Compress: true}, "require a file output"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAudit(&tt.cfg)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -29,6 +29,8 @@ const (
 	ErrCodeAuthzInsufficientRoles ErrorCode = "AUTHZ_INSUFFICIENT_ROLES"
 	ErrCodeAuthzMethodNotAllowed  ErrorCode = "AUTHZ_METHOD_NOT_ALLOWED"
 	ErrCodeAuthzJWTRequired       ErrorCode = "AUTHZ_JWT_REQUIRED"
+	ErrCodeAuthzGroupRequired     ErrorCode = "AUTHZ_GROUP_REQUIRED"
+	ErrCodeAuthzDenied            ErrorCode = "AUTHZ_DENIED"
 
 	// Rate limiting errors
 	ErrCodeRateLimitExceeded ErrorCode = "RATE_LIMIT_EXCEEDED"
@@ -189,6 +191,22 @@ func AuthzJWTRequired() *AppError {
 	)
 }
 
+func AuthzGroupRequired(required, actual []string) *AppError {
+	return NewAppError(
+		ErrCodeAuthzGroupRequired,
+		"Group membership required for access",
+		nil,
+	).WithDetail("required", required).WithDetail("actual", actual)
+}
+
+func AuthzDenied(reason string) *AppError {
+	return NewAppError(
+		ErrCodeAuthzDenied,
+		"Access denied",
+		nil,
+	).WithDetail("reason", reason)
+}
+
 // Rate limiting error constructors
 
 func RateLimitExceeded(retryAfter string) *AppError {
@@ -250,7 +268,8 @@ func IsAuthzError(err error) bool {
 	var appErr *AppError
 	if errors.As(err, &appErr) {
 		switch appErr.Code {
-		case ErrCodeAuthzInsufficientRoles, ErrCodeAuthzMethodNotAllowed, ErrCodeAuthzJWTRequired:
+		case ErrCodeAuthzInsufficientRoles, ErrCodeAuthzMethodNotAllowed, ErrCodeAuthzJWTRequired,
+			ErrCodeAuthzGroupRequired, ErrCodeAuthzDenied:
 			return true
 		}
 	}
@@ -251,6 +251,16 @@ func TestIsAuthzError(t *testing.T) {
 			err:      AuthzJWTRequired(),
 			expected: true,
 		},
+		{
+			name:     "Group required",
+			err:      AuthzGroupRequired([]string{"sre"}, nil),
+			expected: true,
+		},
+		{
+			name:     "Denied",
+			err:      AuthzDenied("explicit_deny"),
+			expected: true,
+		},
 		{
 			name:     "Auth error",
 			err:      AuthFailed("reason"),
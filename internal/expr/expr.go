@@ -0,0 +1,438 @@
+// Package expr 实现用于策略条件的小型安全表达式语言
+//
+// 语法示例:
+//
+//	"admin" in auth.roles || (auth.user in ["alice", "bob"] && request.method == "GET")
+//	claims.email.endsWith("@corp.com")
+//	inCIDR(request.ip, "10.0.0.0/8") && now.hour >= 9
+//
+// 表达式只能读取求值时传入的变量，没有副作用，也不支持循环。
+package expr
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Program 编译后的表达式
+type Program struct {
+	src  string
+	root node
+}
+
+// Compile 编译表达式
+// vars 为允许使用的顶层变量名；为空时不检查变量名
+// 语法错误以 *SyntaxError 返回，包含行号和列号
+func Compile(src string, vars ...string) (*Program, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, errorf(Pos{Line: 1, Column: 1}, "empty expression")
+	}
+
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if len(vars) > 0 {
+		p.vars = make(map[string]bool, len(vars))
+		for _, v := range vars {
+			p.vars[v] = true
+		}
+	}
+
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, errorf(tok.pos, "unexpected %s", tok)
+	}
+
+	return &Program{src: src, root: root}, nil
+}
+
+// String 返回表达式源码
+func (p *Program) String() string {
+	return p.src
+}
+
+// Eval 使用给定变量对表达式求值，结果必须是布尔值
+func (p *Program) Eval(vars map[string]interface{}) (bool, error) {
+	v, err := eval(p.root, vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression result is %s, not bool", typeName(v))
+	}
+	return b, nil
+}
+
+func runtimeErr(n node, format string, args ...interface{}) error {
+	pos := n.position()
+	return fmt.Errorf("line %d, column %d: %s", pos.Line, pos.Column, fmt.Sprintf(format, args...))
+}
+
+//nolint:gocyclo // evaluator dispatch is intentionally flat
+func eval(n node, vars map[string]interface{}) (interface{}, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.val, nil
+
+	case *identNode:
+		return normalize(vars[n.name]), nil
+
+	case *memberNode:
+		obj, err := eval(n.obj, vars)
+		if err != nil {
+			return nil, err
+		}
+		v, _ := lookup(obj, n.name)
+		return v, nil
+
+	case *indexNode:
+		obj, err := eval(n.obj, vars)
+		if err != nil {
+			return nil, err
+		}
+		index, err := eval(n.index, vars)
+		if err != nil {
+			return nil, err
+		}
+		return evalIndex(obj, index), nil
+
+	case *listNode:
+		list := make([]interface{}, 0, len(n.elems))
+		for _, e := range n.elems {
+			v, err := eval(e, vars)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+
+	case *notNode:
+		v, err := evalBool(n.operand, vars)
+		if err != nil {
+			return nil, err
+		}
+		return !v, nil
+
+	case *binaryNode:
+		return evalBinary(n, vars)
+
+	case *callNode:
+		return evalCall(n, vars)
+	}
+
+	return nil, fmt.Errorf("unsupported expression node %T", n)
+}
+
+func evalBool(n node, vars map[string]interface{}) (bool, error) {
+	v, err := eval(n, vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, runtimeErr(n, "expected bool, got %s", typeName(v))
+	}
+	return b, nil
+}
+
+//nolint:gocyclo // operator dispatch is intentionally flat
+func evalBinary(n *binaryNode, vars map[string]interface{}) (interface{}, error) {
+	// 逻辑运算短路求值
+	switch n.op {
+	case tokAnd:
+		left, err := evalBool(n.left, vars)
+		if err != nil || !left {
+			return false, err
+		}
+		return evalBool(n.right, vars)
+	case tokOr:
+		left, err := evalBool(n.left, vars)
+		if err != nil || left {
+			return left, err
+		}
+		return evalBool(n.right, vars)
+	}
+
+	left, err := eval(n.left, vars)
+	if err != nil {
+		return nil, err
+	}
+	right, err := eval(n.right, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case tokEq:
+		return equal(left, right), nil
+	case tokNeq:
+		return !equal(left, right), nil
+	case tokIn:
+		return contains(right, left), nil
+	}
+
+	// 有序比较：仅支持同类型的数字或字符串，null 参与比较时结果为 false
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, runtimeErr(n, "cannot compare number with %s", typeName(right))
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, runtimeErr(n, "cannot compare string with %s", typeName(right))
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return nil, runtimeErr(n, "cannot order values of type %s", typeName(left))
+	}
+
+	switch n.op {
+	case tokLt:
+		return cmp < 0, nil
+	case tokLte:
+		return cmp <= 0, nil
+	case tokGt:
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+//nolint:gocognit,gocyclo // builtin dispatch is intentionally flat
+func evalCall(n *callNode, vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := eval(a, vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	// 全局函数
+	if n.recv == nil {
+		switch n.name {
+		case "size":
+			return size(n, args[0])
+		case "inCIDR":
+			ipStr, ok := args[0].(string)
+			if !ok {
+				return false, nil
+			}
+			ip := net.ParseIP(ipStr)
+			if ip == nil {
+				return false, nil
+			}
+			network := n.cidr
+			if network == nil {
+				cidr, ok := args[1].(string)
+				if !ok {
+					return nil, runtimeErr(n, "inCIDR expects a CIDR string")
+				}
+				_, parsed, err := net.ParseCIDR(cidr)
+				if err != nil {
+					return nil, runtimeErr(n, "invalid CIDR %q", cidr)
+				}
+				network = parsed
+			}
+			return network.Contains(ip), nil
+		}
+		return nil, runtimeErr(n, "unknown function %q", n.name)
+	}
+
+	recv, err := eval(n.recv, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.name {
+	case "size":
+		return size(n, recv)
+	case "contains":
+		if _, isString := recv.(string); !isString {
+			return contains(recv, args[0]), nil
+		}
+	}
+
+	// 其余方法仅支持字符串；null 接收者返回 false，便于处理缺失的 claim/header
+	s, ok := recv.(string)
+	if !ok {
+		if recv == nil {
+			return false, nil
+		}
+		return nil, runtimeErr(n, "%s() is not defined on %s", n.name, typeName(recv))
+	}
+
+	switch n.name {
+	case "lower":
+		return strings.ToLower(s), nil
+	case "upper":
+		return strings.ToUpper(s), nil
+	}
+
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, runtimeErr(n, "%s() expects a string argument, got %s", n.name, typeName(args[0]))
+	}
+
+	switch n.name {
+	case "startsWith":
+		return strings.HasPrefix(s, arg), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg), nil
+	case "contains":
+		return strings.Contains(s, arg), nil
+	case "matches":
+		re := n.re
+		if re == nil {
+			compiled, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, runtimeErr(n, "invalid regular expression: %v", err)
+			}
+			re = compiled
+		}
+		return re.MatchString(s), nil
+	}
+
+	return nil, runtimeErr(n, "unknown method %q", n.name)
+}
+
+func size(n node, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return float64(0), nil
+	case string:
+		return float64(len([]rune(v))), nil
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	}
+	return nil, runtimeErr(n, "size() is not defined on %s", typeName(v))
+}
+
+// normalize 将外部传入的值统一为求值器使用的类型
+// 数字 → float64，[]string → []interface{}，map[string]string → map[string]interface{}
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m
+	}
+	return v
+}
+
+// lookup 读取 map 字段，非 map 或字段不存在时返回 nil
+func lookup(obj interface{}, key string) (interface{}, bool) {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	v, ok := m[key]
+	return normalize(v), ok
+}
+
+func evalIndex(obj, index interface{}) interface{} {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil
+		}
+		v, _ := lookup(o, key)
+		return v
+	case []interface{}:
+		f, ok := index.(float64)
+		if !ok || f != math.Trunc(f) || f < 0 || int(f) >= len(o) {
+			return nil
+		}
+		return normalize(o[int(f)])
+	}
+	return nil
+}
+
+// contains 实现 in 运算：列表成员、map 键或子串
+func contains(container, item interface{}) bool {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, e := range c {
+			if equal(normalize(e), item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		if key, ok := item.(string); ok {
+			_, exists := c[key]
+			return exists
+		}
+	case string:
+		if s, ok := item.(string); ok {
+			return strings.Contains(c, s)
+		}
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,141 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+)
+
+func testVars() map[string]interface{} {
+	return map[string]interface{}{
+		"auth": map[string]interface{}{
+			"method": "jwt",
+			"user":   "alice",
+			"roles":  []string{"user", "team-x"},
+		},
+		"claims": map[string]interface{}{
+			"email":  "alice@corp.com",
+			"groups": []interface{}{"eng", "ops"},
+			"level":  float64(3),
+		},
+		"request": map[string]interface{}{
+			"host":    "api.example.com",
+			"path":    "/reports/2024",
+			"method":  "GET",
+			"ip":      "10.1.2.3",
+			"headers": map[string]string{"x-tenant": "acme"},
+		},
+		"now": map[string]interface{}{
+			"hour":    14,
+			"weekday": "tue",
+		},
+	}
+}
+
+// TestEval 测试表达式求值
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{"Role membership", `"team-x" in auth.roles`, true},
+		{"Missing role", `"admin" in auth.roles`, false},
+		{"Or with grouping", `"admin" in auth.roles || (auth.user in ["alice", "bob"] && request.method == "GET")`, true},
+		{"Keyword operators", `not ("admin" in auth.roles) and request.method == 'GET'`, true},
+		{"Claim suffix", `claims.email.endsWith("@corp.com")`, true},
+		{"Claim prefix", `claims.email.startsWith("bob")`, false},
+		{"Regex match", `request.path.matches("^/reports/[0-9]{4}$")`, true},
+		{"Header index", `request.headers["x-tenant"] == "acme"`, true},
+		{"Missing header is null", `request.headers["x-missing"] == null`, true},
+		{"Method on missing claim", `claims.missing.endsWith("x")`, false},
+		{"Number comparison", `claims.level >= 3 && now.hour < 18`, true},
+		{"String comparison", `now.weekday != "sun"`, true},
+		{"CIDR match", `inCIDR(request.ip, "10.0.0.0/8")`, true},
+		{"CIDR miss", `inCIDR(request.ip, "192.168.0.0/16")`, false},
+		{"List size", `size(claims.groups) == 2`, true},
+		{"Method size", `auth.roles.size() > 1`, true},
+		{"List contains method", `claims.groups.contains("ops")`, true},
+		{"Lowercase", `request.host.upper() == "API.EXAMPLE.COM"`, true},
+		{"Index into list", `claims.groups[0] == "eng"`, true},
+		{"Map key membership", `"x-tenant" in request.headers`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := Compile(tt.src, "auth", "claims", "request", "now")
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.src, err)
+			}
+			got, err := prog.Eval(testVars())
+			if err != nil {
+				t.Fatalf("Eval(%q) error = %v", tt.src, err)
+			}
+			if got != tt.want {
+				t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCompile_SyntaxErrors 测试语法错误的位置信息
+func TestCompile_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        string
+		wantLine   int
+		wantColumn int
+	}{
+		{"Empty", "   ", 1, 1},
+		{"Single equals", `auth.user = "alice"`, 1, 11},
+		{"Unclosed paren", `(auth.user == "a"`, 1, 18},
+		{"Unterminated string", `auth.user == "alice`, 1, 14},
+		{"Unknown variable", `user == "alice"`, 1, 1},
+		{"Unknown method", `auth.user.reverse()`, 1, 11},
+		{"Wrong arity", `inCIDR(request.ip)`, 1, 1},
+		{"Bad regex", `request.path.matches("(")`, 1, 22},
+		{"Bad CIDR", `inCIDR(request.ip, "10.0.0.0/33")`, 1, 20},
+		{"Trailing tokens", `auth.user == "a" "b"`, 1, 18},
+		{"Multi-line", "auth.user == \"a\" &&\n  request.method ==", 2, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.src, "auth", "claims", "request", "now")
+			if err == nil {
+				t.Fatalf("Compile(%q) expected error, got nil", tt.src)
+			}
+			var synErr *SyntaxError
+			if !errors.As(err, &synErr) {
+				t.Fatalf("Expected *SyntaxError, got %T: %v", err, err)
+			}
+			if synErr.Line != tt.wantLine || synErr.Column != tt.wantColumn {
+				t.Errorf("Compile(%q) error at %d:%d, want %d:%d (%v)",
+					tt.src, synErr.Line, synErr.Column, tt.wantLine, tt.wantColumn, err)
+			}
+		})
+	}
+}
+
+// TestEval_RuntimeErrors 测试运行时类型错误
+func TestEval_RuntimeErrors(t *testing.T) {
+	tests := []string{
+		`auth.user`,                  // 结果不是 bool
+		`auth.user && true`,          // && 操作数不是 bool
+		`!claims.level`,              // ! 操作数不是 bool
+		`claims.level > "3"`,         // 类型不匹配的比较
+		`auth.roles.endsWith("x")`,   // 列表上调用字符串方法
+		`claims.email.startsWith(1)`, // 参数类型错误
+	}
+
+	for _, src := range tests {
+		t.Run(src, func(t *testing.T) {
+			prog, err := Compile(src)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", src, err)
+			}
+			if _, err := prog.Eval(testVars()); err == nil {
+				t.Errorf("Eval(%q) expected error, got nil", src)
+			}
+		})
+	}
+}
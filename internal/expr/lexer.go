@@ -0,0 +1,294 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokDot
+	tokNot
+	tokAnd
+	tokOr
+	tokEq
+	tokNeq
+	tokLt
+	tokLte
+	tokGt
+	tokGte
+	tokIn
+	tokTrue
+	tokFalse
+	tokNull
+)
+
+// Pos 源码位置（行号和列号均从 1 开始，列按字符计算）
+type Pos struct {
+	Line   int
+	Column int
+}
+
+type token struct {
+	kind tokenKind
+	text string // 标识符名称或字符串字面量内容
+	num  float64
+	pos  Pos
+}
+
+// SyntaxError 表达式语法错误，带有出错位置
+type SyntaxError struct {
+	Pos
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+func errorf(pos Pos, format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// keywords 关键字（and/or/not 是 &&/||/! 的别名）
+var keywords = map[string]tokenKind{
+	"and":   tokAnd,
+	"or":    tokOr,
+	"not":   tokNot,
+	"in":    tokIn,
+	"true":  tokTrue,
+	"false": tokFalse,
+	"null":  tokNull,
+}
+
+type lexer struct {
+	src  string
+	off  int
+	line int
+	col  int
+}
+
+// tokenize 将表达式切分为词法单元
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: src, line: 1, col: 1}
+	var tokens []token
+
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) peek() rune {
+	if l.off >= len(l.src) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.off:])
+	return r
+}
+
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.off:])
+	l.off += size
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+//nolint:gocyclo // lexer switch is intentionally flat
+func (l *lexer) next() (token, error) {
+	for l.off < len(l.src) && unicode.IsSpace(l.peek()) {
+		l.advance()
+	}
+
+	pos := Pos{Line: l.line, Column: l.col}
+	if l.off >= len(l.src) {
+		return token{kind: tokEOF, pos: pos}, nil
+	}
+
+	r := l.peek()
+	switch {
+	case r == '_' || unicode.IsLetter(r):
+		return l.ident(pos), nil
+	case unicode.IsDigit(r):
+		return l.number(pos)
+	case r == '"' || r == '\'':
+		return l.str(pos)
+	}
+
+	l.advance()
+	two := func(next rune, matched, single tokenKind) token {
+		if l.peek() == next {
+			l.advance()
+			return token{kind: matched, pos: pos}
+		}
+		return token{kind: single, pos: pos}
+	}
+
+	switch r {
+	case '(':
+		return token{kind: tokLParen, pos: pos}, nil
+	case ')':
+		return token{kind: tokRParen, pos: pos}, nil
+	case '[':
+		return token{kind: tokLBracket, pos: pos}, nil
+	case ']':
+		return token{kind: tokRBracket, pos: pos}, nil
+	case ',':
+		return token{kind: tokComma, pos: pos}, nil
+	case '.':
+		return token{kind: tokDot, pos: pos}, nil
+	case '<':
+		return two('=', tokLte, tokLt), nil
+	case '>':
+		return two('=', tokGte, tokGt), nil
+	case '!':
+		return two('=', tokNeq, tokNot), nil
+	case '=':
+		if l.peek() == '=' {
+			l.advance()
+			return token{kind: tokEq, pos: pos}, nil
+		}
+		return token{}, errorf(pos, "unexpected '=' (use '==' for comparison)")
+	case '&':
+		if l.peek() == '&' {
+			l.advance()
+			return token{kind: tokAnd, pos: pos}, nil
+		}
+		return token{}, errorf(pos, "unexpected '&' (use '&&')")
+	case '|':
+		if l.peek() == '|' {
+			l.advance()
+			return token{kind: tokOr, pos: pos}, nil
+		}
+		return token{}, errorf(pos, "unexpected '|' (use '||')")
+	}
+
+	return token{}, errorf(pos, "unexpected character %q", r)
+}
+
+func (l *lexer) ident(pos Pos) token {
+	start := l.off
+	for l.off < len(l.src) {
+		r := l.peek()
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		l.advance()
+	}
+
+	text := l.src[start:l.off]
+	if kind, ok := keywords[text]; ok {
+		return token{kind: kind, text: text, pos: pos}
+	}
+	return token{kind: tokIdent, text: text, pos: pos}
+}
+
+func (l *lexer) number(pos Pos) (token, error) {
+	start := l.off
+	for l.off < len(l.src) && (unicode.IsDigit(l.peek()) || l.peek() == '.') {
+		l.advance()
+	}
+
+	text := l.src[start:l.off]
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return token{}, errorf(pos, "invalid number %q", text)
+	}
+	return token{kind: tokNumber, num: n, pos: pos}, nil
+}
+
+func (l *lexer) str(pos Pos) (token, error) {
+	quote := l.advance()
+	var b strings.Builder
+
+	for {
+		if l.off >= len(l.src) {
+			return token{}, errorf(pos, "unterminated string literal")
+		}
+		r := l.advance()
+		switch r {
+		case quote:
+			return token{kind: tokString, text: b.String(), pos: pos}, nil
+		case '\n':
+			return token{}, errorf(pos, "unterminated string literal")
+		case '\\':
+			if l.off >= len(l.src) {
+				return token{}, errorf(pos, "unterminated string literal")
+			}
+			escPos := Pos{Line: l.line, Column: l.col}
+			switch esc := l.advance(); esc {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case '\\', '"', '\'':
+				b.WriteRune(esc)
+			default:
+				return token{}, errorf(escPos, "unknown escape sequence \\%c", esc)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// String 返回词法单元的可读描述（用于错误信息）
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokIdent:
+		return fmt.Sprintf("identifier %q", t.text)
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	case tokNumber:
+		return fmt.Sprintf("number %v", t.num)
+	}
+	if name, ok := tokenNames[t.kind]; ok {
+		return fmt.Sprintf("%q", name)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var tokenNames = map[tokenKind]string{
+	tokLParen:   "(",
+	tokRParen:   ")",
+	tokLBracket: "[",
+	tokRBracket: "]",
+	tokComma:    ",",
+	tokDot:      ".",
+	tokNot:      "!",
+	tokAnd:      "&&",
+	tokOr:       "||",
+	tokEq:       "==",
+	tokNeq:      "!=",
+	tokLt:       "<",
+	tokLte:      "<=",
+	tokGt:       ">",
+	tokGte:      ">=",
+}
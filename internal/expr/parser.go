@@ -0,0 +1,368 @@
+package expr
+
+import (
+	"net"
+	"regexp"
+)
+
+// node 语法树节点
+type node interface {
+	position() Pos
+}
+
+type (
+	literalNode struct {
+		pos Pos
+		val interface{}
+	}
+
+	identNode struct {
+		pos  Pos
+		name string
+	}
+
+	memberNode struct {
+		pos  Pos
+		obj  node
+		name string
+	}
+
+	indexNode struct {
+		pos   Pos
+		obj   node
+		index node
+	}
+
+	listNode struct {
+		pos   Pos
+		elems []node
+	}
+
+	notNode struct {
+		pos     Pos
+		operand node
+	}
+
+	binaryNode struct {
+		pos   Pos
+		op    tokenKind
+		left  node
+		right node
+	}
+
+	// callNode 全局函数调用（如 size(x)）或方法调用（如 s.endsWith("x")）
+	callNode struct {
+		pos  Pos
+		recv node // 方法接收者，全局函数为 nil
+		name string
+		args []node
+
+		re   *regexp.Regexp // matches() 的预编译正则（参数为字面量时）
+		cidr *net.IPNet     // inCIDR() 的预解析网段（参数为字面量时）
+	}
+)
+
+func (n *literalNode) position() Pos { return n.pos }
+func (n *identNode) position() Pos   { return n.pos }
+func (n *memberNode) position() Pos  { return n.pos }
+func (n *indexNode) position() Pos   { return n.pos }
+func (n *listNode) position() Pos    { return n.pos }
+func (n *notNode) position() Pos     { return n.pos }
+func (n *binaryNode) position() Pos  { return n.pos }
+func (n *callNode) position() Pos    { return n.pos }
+
+// methods 支持的方法及参数个数
+var methods = map[string]int{
+	"startsWith": 1,
+	"endsWith":   1,
+	"contains":   1,
+	"matches":    1,
+	"lower":      0,
+	"upper":      0,
+	"size":       0,
+}
+
+// functions 支持的全局函数及参数个数
+var functions = map[string]int{
+	"size":   1,
+	"inCIDR": 2,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	vars   map[string]bool // 允许的顶层变量（为 nil 时不检查）
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, errorf(tok.pos, "expected %s, found %s", what, tok)
+	}
+	return tok, nil
+}
+
+// parseExpr: or
+func (p *parser) parseExpr() (node, error) {
+	return p.parseOr()
+}
+
+// parseOr: and ( "||" and )*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		op := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: op.pos, op: tokOr, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd: unary ( "&&" unary )*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		op := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: op.pos, op: tokAnd, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary: "!" unary | comparison
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().kind == tokNot {
+		op := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{pos: op.pos, operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison: postfix ( op postfix )?
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.peek().kind {
+	case tokEq, tokNeq, tokLt, tokLte, tokGt, tokGte, tokIn:
+		op := p.next()
+		right, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{pos: op.pos, op: op.kind, left: left, right: right}, nil
+	}
+
+	return left, nil
+}
+
+// parsePostfix: primary ( "." ident [ "(" args ")" ] | "[" expr "]" )*
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek().kind {
+		case tokDot:
+			p.next()
+			name, err := p.expect(tokIdent, "field or method name")
+			if err != nil {
+				return nil, err
+			}
+			if p.peek().kind == tokLParen {
+				args, err := p.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+				call, err := p.newCall(name, n, args, methods)
+				if err != nil {
+					return nil, err
+				}
+				n = call
+			} else {
+				n = &memberNode{pos: name.pos, obj: n, name: name.text}
+			}
+
+		case tokLBracket:
+			open := p.next()
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokRBracket, "']'"); err != nil {
+				return nil, err
+			}
+			n = &indexNode{pos: open.pos, obj: n, index: index}
+
+		default:
+			return n, nil
+		}
+	}
+}
+
+//nolint:gocyclo // primary expression dispatch is intentionally flat
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokString:
+		return &literalNode{pos: tok.pos, val: tok.text}, nil
+	case tokNumber:
+		return &literalNode{pos: tok.pos, val: tok.num}, nil
+	case tokTrue:
+		return &literalNode{pos: tok.pos, val: true}, nil
+	case tokFalse:
+		return &literalNode{pos: tok.pos, val: false}, nil
+	case tokNull:
+		return &literalNode{pos: tok.pos, val: nil}, nil
+
+	case tokIdent:
+		if p.peek().kind == tokLParen {
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return p.newCall(tok, nil, args, functions)
+		}
+		if p.vars != nil && !p.vars[tok.text] {
+			return nil, errorf(tok.pos, "unknown variable %q", tok.text)
+		}
+		return &identNode{pos: tok.pos, name: tok.text}, nil
+
+	case tokLParen:
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+
+	case tokLBracket:
+		list := &listNode{pos: tok.pos}
+		if p.peek().kind == tokRBracket {
+			p.next()
+			return list, nil
+		}
+		for {
+			elem, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			list.elems = append(list.elems, elem)
+			sep := p.next()
+			if sep.kind == tokRBracket {
+				return list, nil
+			}
+			if sep.kind != tokComma {
+				return nil, errorf(sep.pos, "expected ',' or ']', found %s", sep)
+			}
+		}
+	}
+
+	return nil, errorf(tok.pos, "unexpected %s", tok)
+}
+
+// parseArgs: "(" [ expr ( "," expr )* ] ")"
+func (p *parser) parseArgs() ([]node, error) {
+	p.next() // "("
+	var args []node
+	if p.peek().kind == tokRParen {
+		p.next()
+		return args, nil
+	}
+
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		sep := p.next()
+		if sep.kind == tokRParen {
+			return args, nil
+		}
+		if sep.kind != tokComma {
+			return nil, errorf(sep.pos, "expected ',' or ')', found %s", sep)
+		}
+	}
+}
+
+// newCall 创建函数/方法调用节点，并在编译期检查名称、参数个数和字面量参数
+func (p *parser) newCall(name token, recv node, args []node, table map[string]int) (*callNode, error) {
+	arity, ok := table[name.text]
+	if !ok {
+		if recv != nil {
+			return nil, errorf(name.pos, "unknown method %q", name.text)
+		}
+		return nil, errorf(name.pos, "unknown function %q", name.text)
+	}
+	if len(args) != arity {
+		return nil, errorf(name.pos, "%s expects %d argument(s), got %d", name.text, arity, len(args))
+	}
+
+	call := &callNode{pos: name.pos, recv: recv, name: name.text, args: args}
+
+	switch {
+	case recv != nil && name.text == "matches":
+		if lit, ok := args[0].(*literalNode); ok {
+			pattern, isString := lit.val.(string)
+			if !isString {
+				return nil, errorf(lit.pos, "matches expects a string pattern")
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, errorf(lit.pos, "invalid regular expression: %v", err)
+			}
+			call.re = re
+		}
+
+	case recv == nil && name.text == "inCIDR":
+		if lit, ok := args[1].(*literalNode); ok {
+			cidr, isString := lit.val.(string)
+			if !isString {
+				return nil, errorf(lit.pos, "inCIDR expects a CIDR string")
+			}
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, errorf(lit.pos, "invalid CIDR %q", cidr)
+			}
+			call.cidr = network
+		}
+	}
+
+	return call, nil
+}
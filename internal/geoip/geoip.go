@@ -0,0 +1,199 @@
+// Package geoip 从本地 MaxMind DB（.mmdb）文件查询客户端 IP 的国家和 ASN，并按国家 / ASN 规则限制访问
+// 数据库文件变化后自动重新加载（文件整体读入内存，替换文件不影响正在进行的查询）
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// DefaultReloadInterval 默认的数据库文件变化检查间隔
+const DefaultReloadInterval = time.Minute
+
+// 拒绝原因（与审计日志一致）
+const (
+	ReasonCountryDenied = "country_denied"
+	ReasonASNDenied     = "asn_denied"
+)
+
+// Options 数据库配置
+type Options struct {
+	CountryDB      string        // 国家数据库（GeoLite2-Country / GeoIP2-City 等）
+	ASNDB          string        // ASN 数据库（GeoLite2-ASN 等）；可以与 CountryDB 是同一个文件
+	ReloadInterval time.Duration // 文件变化检查间隔（<= 0 时使用 DefaultReloadInterval）
+}
+
+// Info 查询结果（数据库中没有的字段为零值）
+type Info struct {
+	Country      string // ISO 3166-1 国家代码（大写）
+	ASN          uint   // 自治系统号
+	Organization string // 自治系统组织名称
+}
+
+// record 数据库记录中用到的字段（国家库和 ASN 库使用同一结构解码）
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	ASN          uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// DB 国家 / ASN 数据库，查询不加锁，文件重新加载时整体替换读取器
+type DB struct {
+	sources []*source
+	onError func(path string, err error)
+
+	mu       sync.Mutex // 串行化文件检查
+	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// source 一个数据库文件，保留上一次成功加载的读取器
+type source struct {
+	path    string
+	modTime time.Time
+	size    int64
+	missing bool
+	reader  atomic.Pointer[maxminddb.Reader]
+}
+
+// Open 加载数据库文件，之后在后台定期检查文件变化
+// 首次加载失败时返回错误；之后的重新加载失败通过 onError 报告，并继续使用上一次加载的数据库
+func Open(opts Options, onError func(path string, err error)) (*DB, error) {
+	if onError == nil {
+		onError = func(string, error) {}
+	}
+	if opts.ReloadInterval <= 0 {
+		opts.ReloadInterval = DefaultReloadInterval
+	}
+
+	db := &DB{
+		onError:  onError,
+		interval: opts.ReloadInterval,
+		stop:     make(chan struct{}),
+	}
+	for _, path := range []string{opts.CountryDB, opts.ASNDB} {
+		if path == "" || (len(db.sources) > 0 && db.sources[0].path == path) {
+			continue
+		}
+		src := &source{path: path}
+		if err := src.load(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		db.sources = append(db.sources, src)
+	}
+	if len(db.sources) == 0 {
+		return nil, fmt.Errorf("no database configured")
+	}
+
+	go db.watch()
+	return db, nil
+}
+
+// Lookup 查询 ip 的国家和 ASN；无效的 IP 或数据库中没有记录时返回零值
+func (db *DB) Lookup(ip string) Info {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Info{}
+	}
+	netIP := net.IP(addr.Unmap().AsSlice())
+
+	var info Info
+	for _, src := range db.sources {
+		var rec record
+		if err := src.reader.Load().Lookup(netIP, &rec); err != nil {
+			continue
+		}
+		if info.Country == "" {
+			info.Country = rec.Country.ISOCode
+			if info.Country == "" {
+				info.Country = rec.RegisteredCountry.ISOCode
+			}
+		}
+		if info.ASN == 0 {
+			info.ASN = rec.ASN
+			info.Organization = rec.Organization
+		}
+	}
+	info.Country = strings.ToUpper(info.Country)
+	return info
+}
+
+// Refresh 检查所有数据库文件，有变化时重新加载
+func (db *DB) Refresh() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, src := range db.sources {
+		info, err := os.Stat(src.path)
+		if err != nil {
+			// 文件不存在时继续使用已加载的数据库，只报告一次
+			if !src.missing {
+				db.onError(src.path, err)
+				src.missing = true
+			}
+			continue
+		}
+		src.missing = false
+		if info.ModTime().Equal(src.modTime) && info.Size() == src.size {
+			continue
+		}
+		if err := src.load(); err != nil {
+			db.onError(src.path, err)
+		}
+	}
+}
+
+// load 读取并解析数据库文件；失败时保留原来的读取器，但记录文件状态避免重复报告同一个损坏的文件
+func (src *source) load() error {
+	info, err := os.Stat(src.path)
+	if err != nil {
+		return err
+	}
+	src.modTime = info.ModTime()
+	src.size = info.Size()
+
+	data, err := os.ReadFile(src.path)
+	if err != nil {
+		return err
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return err
+	}
+	src.reader.Store(reader)
+	return nil
+}
+
+// watch 定期检查文件变化
+func (db *DB) watch() {
+	ticker := time.NewTicker(db.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			db.Refresh()
+		case <-db.stop:
+			return
+		}
+	}
+}
+
+// Close 停止后台文件检查
+func (db *DB) Close() {
+	db.stopOnce.Do(func() { close(db.stop) })
+}
@@ -0,0 +1,126 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/geoip/geoiptest"
+)
+
+func writeDB(t *testing.T, path string, records []geoiptest.Record, modTime time.Time) {
+	t.Helper()
+	if err := geoiptest.Write(path, records); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLookup 测试国家库和 ASN 库的合并查询
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	countryDB := filepath.Join(dir, "country.mmdb")
+	asnDB := filepath.Join(dir, "asn.mmdb")
+	writeDB(t, countryDB, []geoiptest.Record{
+		{Network: "192.0.2.0/24", Country: "DE"},
+		{Network: "192.0.2.128/25", Country: "fr"},
+		{Network: "2001:db8::/32", Country: "US"},
+	}, time.Unix(1700000000, 0))
+	writeDB(t, asnDB, []geoiptest.Record{
+		{Network: "192.0.2.0/24", ASN: 64496, Organization: "Example Net"},
+	}, time.Unix(1700000000, 0))
+
+	db, err := Open(Options{CountryDB: countryDB, ASNDB: asnDB, ReloadInterval: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		ip   string
+		want Info
+	}{
+		{"192.0.2.1", Info{Country: "DE", ASN: 64496, Organization: "Example Net"}},
+		{"192.0.2.200", Info{Country: "FR", ASN: 64496, Organization: "Example Net"}},
+		{"::ffff:192.0.2.1", Info{Country: "DE", ASN: 64496, Organization: "Example Net"}},
+		{"2001:db8::1", Info{Country: "US"}},
+		{"198.51.100.1", Info{}},
+		{"unknown", Info{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := db.Lookup(tt.ip); got != tt.want {
+				t.Errorf("Lookup(%s) = %+v, want %+v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRefresh 测试文件变化后重新加载、损坏的文件保留旧数据
+func TestRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	writeDB(t, path, []geoiptest.Record{{Network: "192.0.2.0/24", Country: "DE"}}, time.Unix(1700000000, 0))
+
+	var errs []string
+	db, err := Open(Options{CountryDB: path, ASNDB: path, ReloadInterval: time.Hour},
+		func(p string, err error) { errs = append(errs, p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if len(db.sources) != 1 {
+		t.Errorf("expected the same file to be loaded once, got %d sources", len(db.sources))
+	}
+
+	writeDB(t, path, []geoiptest.Record{{Network: "192.0.2.0/24", Country: "NL", ASN: 64500}}, time.Unix(1700000100, 0))
+	db.Refresh()
+	if got := db.Lookup("192.0.2.1"); got.Country != "NL" || got.ASN != 64500 {
+		t.Errorf("expected reloaded data, got %+v", got)
+	}
+
+	if err := os.WriteFile(path, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db.Refresh()
+	db.Refresh()
+	if got := db.Lookup("192.0.2.1"); got.Country != "NL" {
+		t.Errorf("expected previous data to be kept, got %+v", got)
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected one reported error, got %v", errs)
+	}
+
+	if _, err := Open(Options{CountryDB: filepath.Join(t.TempDir(), "missing.mmdb")}, nil); err == nil {
+		t.Error("expected error for missing database")
+	}
+}
+
+// TestRules 测试国家 / ASN 规则
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		country string
+		asn     uint
+		want    string
+	}{
+		{"no rules", Rules{}, "", 0, ""},
+		{"allowed", Rules{AllowedCountries: []string{"de", "FR"}}, "DE", 0, ""},
+		{"not allowed", Rules{AllowedCountries: []string{"DE"}}, "US", 0, ReasonCountryDenied},
+		{"unknown country not allowed", Rules{AllowedCountries: []string{"DE"}}, "", 0, ReasonCountryDenied},
+		{"denied country", Rules{DeniedCountries: []string{"XX"}}, "XX", 0, ReasonCountryDenied},
+		{"unknown country not denied", Rules{DeniedCountries: []string{"XX"}}, "", 0, ""},
+		{"denied asn", Rules{DeniedASNs: []uint{64496}}, "DE", 64496, ReasonASNDenied},
+		{"other asn", Rules{DeniedASNs: []uint{64496}}, "DE", 64497, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Check(tt.country, tt.asn); got != tt.want {
+				t.Errorf("Check(%q, %d) = %q, want %q", tt.country, tt.asn, got, tt.want)
+			}
+		})
+	}
+}
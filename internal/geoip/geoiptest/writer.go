@@ -0,0 +1,258 @@
+// Package geoiptest 生成测试用的小型 MaxMind DB（.mmdb）文件
+// 只实现读取国家和 ASN 所需的数据类型（map、UTF-8 字符串、uint32），不用于生产数据
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+)
+
+// Record 一个网段的数据
+type Record struct {
+	Network      string // IP/CIDR
+	Country      string // ISO 3166-1 国家代码（写入 country.iso_code）
+	ASN          uint32 // 写入 autonomous_system_number
+	Organization string // 写入 autonomous_system_organization
+}
+
+// 数据段类型（MaxMind DB 格式规范）
+const (
+	typeString = 2
+	typeMap    = 7
+	typeUint16 = 5
+	typeUint32 = 6
+	typeArray  = 11
+	typeUint64 = 9
+)
+
+const recordSize = 32 // 每条记录 4 字节
+
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// node 搜索树节点：子节点为 *node、数据下标（int）或 nil（没有数据）
+type node struct {
+	children [2]any
+}
+
+// Write 把记录写入 path（IPv6 树，IPv4 网段位于 ::/96 下）
+// 后写入的更具体网段覆盖先写入的较大网段
+func Write(path string, records []Record) error {
+	data, err := Build(records)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Build 返回 .mmdb 文件内容
+func Build(records []Record) ([]byte, error) {
+	// 按前缀长度从短到长插入，保证更具体的网段覆盖较大的网段
+	sorted := make([]Record, len(records))
+	copy(sorted, records)
+	prefixes := make([]netip.Prefix, len(sorted))
+	for i := range sorted {
+		prefix, err := parsePrefix(sorted[i].Network)
+		if err != nil {
+			return nil, err
+		}
+		if bits(prefix) == 0 {
+			return nil, fmt.Errorf("network %q is too large", sorted[i].Network)
+		}
+		prefixes[i] = prefix
+	}
+	order := make([]int, len(sorted))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return bits(prefixes[order[a]]) < bits(prefixes[order[b]]) })
+
+	root := &node{}
+	for _, i := range order {
+		insert(root, prefixes[i], i)
+	}
+
+	// 编码数据段
+	var dataSection bytes.Buffer
+	offsets := make([]int, len(sorted))
+	for i := range sorted {
+		offsets[i] = dataSection.Len()
+		encodeRecord(&dataSection, &sorted[i])
+	}
+
+	// 给节点编号（广度优先）
+	var nodes []*node
+	ids := map[*node]int{}
+	queue := []*node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		ids[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, child := range n.children {
+			if c, ok := child.(*node); ok {
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	nodeCount := len(nodes)
+	var out bytes.Buffer
+	for _, n := range nodes {
+		for _, child := range n.children {
+			var value uint32
+			switch c := child.(type) {
+			case nil:
+				value = uint32(nodeCount) // 没有数据
+			case *node:
+				value = uint32(ids[c])
+			case int:
+				value = uint32(nodeCount + 16 + offsets[c])
+			}
+			_ = binary.Write(&out, binary.BigEndian, value)
+		}
+	}
+	out.Write(make([]byte, 16)) // 数据段分隔
+	out.Write(dataSection.Bytes())
+	out.Write(metadataMarker)
+	encodeMetadata(&out, nodeCount)
+	return out.Bytes(), nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid network %q", s)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// bits 返回网段在 IPv6 树中的深度
+func bits(prefix netip.Prefix) int {
+	if prefix.Addr().Is4() {
+		return prefix.Bits() + 96
+	}
+	return prefix.Bits()
+}
+
+// insert 插入网段；经过已有数据的位置时把数据下推到两个子节点
+func insert(root *node, prefix netip.Prefix, record int) {
+	var key [16]byte
+	if prefix.Addr().Is4() {
+		v4 := prefix.Addr().As4()
+		copy(key[12:], v4[:])
+	} else {
+		key = prefix.Addr().As16()
+	}
+
+	depth := bits(prefix)
+	n := root
+	for i := 0; i < depth-1; i++ {
+		b := (key[i/8] >> (7 - uint(i%8))) & 1
+		switch c := n.children[b].(type) {
+		case *node:
+			n = c
+		default:
+			next := &node{children: [2]any{c, c}}
+			n.children[b] = next
+			n = next
+		}
+	}
+	last := depth - 1
+	n.children[(key[last/8]>>(7-uint(last%8)))&1] = record
+}
+
+func encodeRecord(buf *bytes.Buffer, r *Record) {
+	fields := 0
+	for _, set := range []bool{r.Country != "", r.ASN != 0, r.Organization != ""} {
+		if set {
+			fields++
+		}
+	}
+
+	writeControl(buf, typeMap, fields)
+	if r.Country != "" {
+		writeString(buf, "country")
+		writeControl(buf, typeMap, 1)
+		writeString(buf, "iso_code")
+		writeString(buf, r.Country)
+	}
+	if r.ASN != 0 {
+		writeString(buf, "autonomous_system_number")
+		writeUint(buf, typeUint32, uint64(r.ASN))
+	}
+	if r.Organization != "" {
+		writeString(buf, "autonomous_system_organization")
+		writeString(buf, r.Organization)
+	}
+}
+
+func encodeMetadata(buf *bytes.Buffer, nodeCount int) {
+	writeControl(buf, typeMap, 9)
+	writeString(buf, "binary_format_major_version")
+	writeUint(buf, typeUint16, 2)
+	writeString(buf, "binary_format_minor_version")
+	writeUint(buf, typeUint16, 0)
+	writeString(buf, "build_epoch")
+	writeUint(buf, typeUint64, 1700000000)
+	writeString(buf, "database_type")
+	writeString(buf, "tiny-auth-test")
+	writeString(buf, "description")
+	writeControl(buf, typeMap, 1)
+	writeString(buf, "en")
+	writeString(buf, "tiny-auth test database")
+	writeString(buf, "ip_version")
+	writeUint(buf, typeUint16, 6)
+	writeString(buf, "languages")
+	writeControl(buf, typeArray, 1)
+	writeString(buf, "en")
+	writeString(buf, "node_count")
+	writeUint(buf, typeUint32, uint64(nodeCount))
+	writeString(buf, "record_size")
+	writeUint(buf, typeUint16, recordSize)
+}
+
+// writeControl 写入控制字节（类型 + 长度）；类型大于 7 时使用扩展类型字节
+func writeControl(buf *bytes.Buffer, typ, size int) {
+	first := byte(0)
+	if typ <= 7 {
+		first = byte(typ) << 5
+	}
+	var extra []byte
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 29+256:
+		first |= 29
+		extra = []byte{byte(size - 29)}
+	default:
+		first |= 30
+		n := size - 285
+		extra = []byte{byte(n >> 8), byte(n)}
+	}
+	buf.WriteByte(first)
+	if typ > 7 {
+		buf.WriteByte(byte(typ - 7))
+	}
+	buf.Write(extra)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeControl(buf, typeString, len(s))
+	buf.WriteString(s)
+}
+
+// writeUint 写入无符号整数（大端，去掉前导零字节）
+func writeUint(buf *bytes.Buffer, typ int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	trimmed := bytes.TrimLeft(b[:], "\x00")
+	writeControl(buf, typ, len(trimmed))
+	buf.Write(trimmed)
+}
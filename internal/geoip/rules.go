@@ -0,0 +1,45 @@
+package geoip
+
+import (
+	"slices"
+	"strings"
+)
+
+// Rules 按国家 / ASN 限制访问
+type Rules struct {
+	AllowedCountries []string // 非空时只允许这些国家（查不到国家的地址也被拒绝）
+	DeniedCountries  []string // 拒绝的国家
+	DeniedASNs       []uint   // 拒绝的自治系统号
+}
+
+// Empty 是否没有任何规则
+func (r *Rules) Empty() bool {
+	return len(r.AllowedCountries) == 0 && len(r.DeniedCountries) == 0 && len(r.DeniedASNs) == 0
+}
+
+// Check 返回拒绝原因（ReasonCountryDenied / ReasonASNDenied），允许时返回空
+// 国家代码不区分大小写
+func (r *Rules) Check(country string, asn uint) string {
+	if len(r.AllowedCountries) > 0 && !containsFold(r.AllowedCountries, country) {
+		return ReasonCountryDenied
+	}
+	if country != "" && containsFold(r.DeniedCountries, country) {
+		return ReasonCountryDenied
+	}
+	if asn != 0 && slices.Contains(r.DeniedASNs, asn) {
+		return ReasonASNDenied
+	}
+	return ""
+}
+
+func containsFold(list []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,303 @@
+// Package ipfilter 实现全局 IP 允许 / 拒绝列表：静态 CIDR、列表文件（每行一个 CIDR）
+// 以及 CrowdSec / fail2ban 导出的封禁决策文件。文件变化后自动重新加载。
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 决策结果
+const (
+	ActionAllow = "allow" // 命中允许列表
+	ActionDeny  = "deny"  // 命中拒绝列表
+)
+
+// 决策文件格式
+const (
+	FormatCrowdSec = "crowdsec"
+	FormatFail2ban = "fail2ban"
+)
+
+// DefaultReloadInterval 默认的文件变化检查间隔
+const DefaultReloadInterval = 10 * time.Second
+
+// Options 过滤器配置
+type Options struct {
+	Allow           []string      // 静态允许的 IP/CIDR
+	Deny            []string      // 静态拒绝的 IP/CIDR
+	AllowFiles      []string      // 允许列表文件（每行一个 IP/CIDR，# 开头为注释）
+	DenyFiles       []string      // 拒绝列表文件
+	DecisionsFile   string        // 封禁决策文件（可选）
+	DecisionsFormat string        // 决策文件格式: "crowdsec"（默认）或 "fail2ban"
+	ReloadInterval  time.Duration // 文件变化检查间隔（<= 0 时使用 DefaultReloadInterval）
+}
+
+// Decision 过滤结果
+type Decision struct {
+	Action string    // ActionAllow、ActionDeny 或空（未命中任何列表）
+	Source string    // 命中条目的来源
+	Until  time.Time // 命中条目的过期时间（零值表示不过期）
+}
+
+// Filter 全局 IP 过滤器：允许列表优先于拒绝列表
+// 查询使用不可变的前缀树快照，文件重新加载时整体替换，查询不加锁
+type Filter struct {
+	static  lists
+	sources []*source
+	trees   atomic.Pointer[trees]
+
+	now     func() time.Time
+	onError func(path string, err error)
+
+	mu       sync.Mutex // 串行化文件检查
+	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type lists struct {
+	allow []Entry
+	deny  []Entry
+}
+
+type trees struct {
+	allow Tree
+	deny  Tree
+}
+
+// source 一个列表或决策文件，保留上一次成功加载的条目
+type source struct {
+	path    string
+	kind    string // "allow_file"、"deny_file" 或决策文件格式
+	modTime time.Time
+	size    int64
+	loaded  bool
+	missing bool
+	entries []Entry
+}
+
+// ParsePrefix 解析 IP 或 CIDR（单个 IP 按 /32 或 /128 处理）
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", s)
+		}
+		// IPv4 映射网段按 IPv4 网段处理；短于 /96 的网段包含非映射地址，仍按 IPv6 处理
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP %q", s)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// New 创建过滤器并加载所有文件，之后在后台定期检查文件变化
+// 静态列表无效时返回错误；文件读取或解析失败通过 onError 报告（保留上一次加载的条目，首次加载失败时为空）
+func New(opts Options, onError func(path string, err error)) (*Filter, error) {
+	return newFilter(opts, onError, time.Now)
+}
+
+func newFilter(opts Options, onError func(path string, err error), now func() time.Time) (*Filter, error) {
+	if onError == nil {
+		onError = func(string, error) {}
+	}
+	if opts.ReloadInterval <= 0 {
+		opts.ReloadInterval = DefaultReloadInterval
+	}
+
+	f := &Filter{
+		now:      now,
+		onError:  onError,
+		interval: opts.ReloadInterval,
+		stop:     make(chan struct{}),
+	}
+
+	var err error
+	if f.static.allow, err = staticEntries(opts.Allow, ActionAllow); err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	if f.static.deny, err = staticEntries(opts.Deny, ActionDeny); err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+
+	for _, path := range opts.AllowFiles {
+		f.sources = append(f.sources, &source{path: path, kind: "allow_file"})
+	}
+	for _, path := range opts.DenyFiles {
+		f.sources = append(f.sources, &source{path: path, kind: "deny_file"})
+	}
+	if opts.DecisionsFile != "" {
+		format := opts.DecisionsFormat
+		if format == "" {
+			format = FormatCrowdSec
+		}
+		if format != FormatCrowdSec && format != FormatFail2ban {
+			return nil, fmt.Errorf("decisions_format: unsupported format %q", format)
+		}
+		f.sources = append(f.sources, &source{path: opts.DecisionsFile, kind: format})
+	}
+
+	f.Refresh()
+	if len(f.sources) > 0 {
+		go f.watch()
+	}
+	return f, nil
+}
+
+// staticEntries 解析静态列表
+func staticEntries(cidrs []string, label string) ([]Entry, error) {
+	entries := make([]Entry, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Prefix: prefix, Source: label})
+	}
+	return entries, nil
+}
+
+// Check 返回 ip 的过滤结果；无效的 IP 不命中任何列表
+func (f *Filter) Check(ip string) Decision {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Decision{}
+	}
+	addr = addr.Unmap().WithZone("")
+
+	now := f.now()
+	t := f.trees.Load()
+	if e, ok := t.allow.Lookup(addr, now); ok {
+		return Decision{Action: ActionAllow, Source: e.Source, Until: e.Until}
+	}
+	if e, ok := t.deny.Lookup(addr, now); ok {
+		return Decision{Action: ActionDeny, Source: e.Source, Until: e.Until}
+	}
+	return Decision{}
+}
+
+// Len 返回允许列表和拒绝列表的网段数量
+func (f *Filter) Len() (allow, deny int) {
+	t := f.trees.Load()
+	return t.allow.Len(), t.deny.Len()
+}
+
+// Refresh 检查所有文件，有变化时重新加载并替换前缀树
+func (f *Filter) Refresh() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	changed := f.trees.Load() == nil
+	for _, src := range f.sources {
+		if f.reload(src) {
+			changed = true
+		}
+	}
+	if changed {
+		f.rebuild()
+	}
+}
+
+// reload 文件的修改时间或大小变化时重新加载，返回条目是否更新
+// 读取或解析失败时保留上一次的条目并报告错误
+func (f *Filter) reload(src *source) bool {
+	info, err := os.Stat(src.path)
+	if err != nil {
+		// 文件不存在时保留上一次的条目，只报告一次
+		if !src.missing {
+			f.onError(src.path, err)
+			src.missing = true
+		}
+		return false
+	}
+	src.missing = false
+	if src.loaded && info.ModTime().Equal(src.modTime) && info.Size() == src.size {
+		return false
+	}
+
+	data, err := os.ReadFile(src.path)
+	if err == nil {
+		var entries []Entry
+		entries, err = parseSource(src, data, info.ModTime())
+		if err == nil {
+			src.entries = entries
+		}
+	}
+	src.modTime = info.ModTime()
+	src.size = info.Size()
+	src.loaded = true
+	if err != nil {
+		f.onError(src.path, err)
+		return false
+	}
+	return true
+}
+
+// parseSource 按文件类型解析内容；决策中的相对时长以文件修改时间为起点
+func parseSource(src *source, data []byte, modTime time.Time) ([]Entry, error) {
+	switch src.kind {
+	case FormatCrowdSec:
+		return parseCrowdSec(data, modTime)
+	case FormatFail2ban:
+		return parseFail2ban(data)
+	default:
+		return parseList(data, src.kind+":"+src.path)
+	}
+}
+
+// rebuild 用静态列表和所有文件的条目重新构建前缀树（跳过已过期的条目）
+func (f *Filter) rebuild() {
+	now := f.now()
+	t := &trees{}
+	insert := func(tree *Tree, entries []Entry) {
+		for _, e := range entries {
+			if !e.expired(now) {
+				tree.Insert(e)
+			}
+		}
+	}
+
+	insert(&t.allow, f.static.allow)
+	insert(&t.deny, f.static.deny)
+	for _, src := range f.sources {
+		if src.kind == "allow_file" {
+			insert(&t.allow, src.entries)
+		} else {
+			insert(&t.deny, src.entries)
+		}
+	}
+	f.trees.Store(t)
+}
+
+// watch 定期检查文件变化
+func (f *Filter) watch() {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.Refresh()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// Stop 停止后台文件检查
+func (f *Filter) Stop() {
+	f.stopOnce.Do(func() { close(f.stop) })
+}
@@ -0,0 +1,143 @@
+package ipfilter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestFilter_Static 测试静态列表和允许优先
+func TestFilter_Static(t *testing.T) {
+	f, err := New(Options{
+		Allow: []string{"10.0.0.5", "2001:db8:1::/48"},
+		Deny:  []string{"10.0.0.0/8", "2001:db8::/32"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Stop()
+
+	tests := []struct {
+		ip     string
+		action string
+		source string
+	}{
+		{"10.0.0.5", ActionAllow, "allow"},
+		{"::ffff:10.0.0.5", ActionAllow, "allow"},
+		{"10.0.0.6", ActionDeny, "deny"},
+		{"2001:db8:1::9", ActionAllow, "allow"},
+		{"2001:db8:2::9", ActionDeny, "deny"},
+		{"192.0.2.1", "", ""},
+		{"unknown", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			d := f.Check(tt.ip)
+			if d.Action != tt.action || d.Source != tt.source {
+				t.Errorf("Check(%s) = %+v, want %s/%s", tt.ip, d, tt.action, tt.source)
+			}
+		})
+	}
+
+	if _, err := New(Options{Deny: []string{"bad"}}, nil); err == nil {
+		t.Error("expected error for invalid static entry")
+	}
+	if _, err := New(Options{DecisionsFile: "x", DecisionsFormat: "csv"}, nil); err == nil {
+		t.Error("expected error for unsupported decisions format")
+	}
+}
+
+// TestFilter_Reload 测试文件变化后重新加载、解析失败时保留旧条目
+func TestFilter_Reload(t *testing.T) {
+	dir := t.TempDir()
+	denyFile := filepath.Join(dir, "deny.txt")
+	missing := filepath.Join(dir, "allow.txt")
+	writeFile(t, denyFile, "192.0.2.0/24\n", time.Unix(1700000000, 0))
+
+	var errs []string
+	f, err := newFilter(Options{
+		DenyFiles:      []string{denyFile},
+		AllowFiles:     []string{missing},
+		ReloadInterval: time.Hour,
+	}, func(path string, err error) { errs = append(errs, path) }, time.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Stop()
+
+	if d := f.Check("192.0.2.1"); d.Action != ActionDeny || d.Source != "deny_file:"+denyFile {
+		t.Fatalf("expected deny from file, got %+v", d)
+	}
+	if len(errs) != 1 || errs[0] != missing {
+		t.Fatalf("expected one error for the missing file, got %v", errs)
+	}
+
+	// 文件变化后重新加载
+	writeFile(t, denyFile, "198.51.100.0/24\n", time.Unix(1700000100, 0))
+	f.Refresh()
+	if d := f.Check("192.0.2.1"); d.Action != "" {
+		t.Errorf("expected removed entry to be gone, got %+v", d)
+	}
+	if d := f.Check("198.51.100.1"); d.Action != ActionDeny {
+		t.Errorf("expected new entry to be denied, got %+v", d)
+	}
+
+	// 解析失败：保留上一次的条目
+	writeFile(t, denyFile, "198.51.100.0/24\ngarbage\n", time.Unix(1700000200, 0))
+	f.Refresh()
+	if d := f.Check("198.51.100.1"); d.Action != ActionDeny {
+		t.Errorf("expected previous entries to be kept, got %+v", d)
+	}
+	if len(errs) != 2 || errs[1] != denyFile {
+		t.Errorf("expected parse error to be reported, got %v", errs)
+	}
+
+	// 缺失的文件出现后加载（不重复报告缺失）
+	writeFile(t, missing, "198.51.100.1\n", time.Unix(1700000300, 0))
+	f.Refresh()
+	if d := f.Check("198.51.100.1"); d.Action != ActionAllow {
+		t.Errorf("expected allow file to take precedence, got %+v", d)
+	}
+	if allow, deny := f.Len(); allow != 1 || deny != 1 {
+		t.Errorf("Len() = %d, %d", allow, deny)
+	}
+	if len(errs) != 2 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+// TestFilter_Decisions 测试决策文件的过期时间
+func TestFilter_Decisions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "decisions.json")
+	exported := time.Unix(1700000000, 0)
+	writeFile(t, path, `[{"scenario":"ssh-bf","scope":"Ip","type":"ban","value":"203.0.113.9","duration":"1h"}]`, exported)
+
+	now := exported.Add(30 * time.Minute)
+	f, err := newFilter(Options{DecisionsFile: path, ReloadInterval: time.Hour}, nil, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Stop()
+
+	d := f.Check("203.0.113.9")
+	if d.Action != ActionDeny || d.Source != "crowdsec:ssh-bf" || !d.Until.Equal(exported.Add(time.Hour)) {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+
+	now = exported.Add(2 * time.Hour)
+	if d := f.Check("203.0.113.9"); d.Action != "" {
+		t.Errorf("expected expired decision to be ignored, got %+v", d)
+	}
+}
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,167 @@
+package ipfilter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// fail2banTimeLayout fail2ban-client "banip --with-time" 输出的时间格式（本地时间）
+const fail2banTimeLayout = "2006-01-02 15:04:05"
+
+// parseList 解析列表文件：每行一个 IP/CIDR，忽略空行和 # 开头的注释（包括行尾注释）
+func parseList(data []byte, label string) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		prefix, err := ParsePrefix(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, Entry{Prefix: prefix, Source: label})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// crowdsecDecision CrowdSec 决策（LAPI /v1/decisions 或 cscli decisions list -o json 中的 decisions 元素）
+type crowdsecDecision struct {
+	Value    string `json:"value"`
+	Scope    string `json:"scope"`
+	Type     string `json:"type"`
+	Scenario string `json:"scenario"`
+	Duration string `json:"duration"` // 剩余时长（Go duration 格式，如 "3h59m50s"）
+	Until    string `json:"until"`    // 过期时间（RFC 3339，较新版本才有）
+}
+
+// crowdsecItem 决策数组或告警数组（cscli 输出的告警带有 decisions 字段）的元素
+type crowdsecItem struct {
+	crowdsecDecision
+	Decisions []crowdsecDecision `json:"decisions"`
+}
+
+// parseCrowdSec 解析 CrowdSec 导出的决策 JSON
+// 只使用 type 为 ban、scope 为 Ip 或 Range 的决策；duration 为相对 exported（文件修改时间）的剩余时长
+func parseCrowdSec(data []byte, exported time.Time) ([]Entry, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var items []crowdsecItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid CrowdSec decisions: %w", err)
+	}
+
+	var entries []Entry
+	add := func(d *crowdsecDecision) error {
+		if !strings.EqualFold(d.Type, "ban") {
+			return nil
+		}
+		if !strings.EqualFold(d.Scope, "ip") && !strings.EqualFold(d.Scope, "range") {
+			return nil
+		}
+		prefix, err := ParsePrefix(d.Value)
+		if err != nil {
+			return err
+		}
+		until, err := d.expiry(exported)
+		if err != nil {
+			return fmt.Errorf("decision for %s: %w", d.Value, err)
+		}
+		label := "crowdsec"
+		if d.Scenario != "" {
+			label += ":" + d.Scenario
+		}
+		entries = append(entries, Entry{Prefix: prefix, Source: label, Until: until})
+		return nil
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.Decisions == nil {
+			if err := add(&item.crowdsecDecision); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for j := range item.Decisions {
+			if err := add(&item.Decisions[j]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// expiry 返回决策的过期时间：优先使用 until，否则为 exported + duration；都没有时不过期
+func (d *crowdsecDecision) expiry(exported time.Time) (time.Time, error) {
+	if d.Until != "" {
+		until, err := time.Parse(time.RFC3339, d.Until)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid until %q", d.Until)
+		}
+		return until, nil
+	}
+	if d.Duration != "" {
+		duration, err := time.ParseDuration(d.Duration)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration %q", d.Duration)
+		}
+		return exported.Add(duration), nil
+	}
+	return time.Time{}, nil
+}
+
+// parseFail2ban 解析 fail2ban-client 的封禁列表输出：
+//   - "get <jail> banip"：以空格或逗号分隔的 IP（可以多行）
+//   - "get <jail> banip --with-time"：每行 "IP  2024-01-01 10:00:00 + 600 = 2024-01-01 10:10:00"，
+//     按等号后的结束时间（本地时间）过期
+func parseFail2ban(data []byte) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		if head, end, found := strings.Cut(text, "="); found {
+			fields := strings.Fields(head)
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: missing IP", line)
+			}
+			prefix, err := ParsePrefix(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			until, err := time.ParseInLocation(fail2banTimeLayout, strings.TrimSpace(end), time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid end time %q", line, strings.TrimSpace(end))
+			}
+			entries = append(entries, Entry{Prefix: prefix, Source: FormatFail2ban, Until: until})
+			continue
+		}
+
+		for _, field := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			prefix, err := ParsePrefix(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			entries = append(entries, Entry{Prefix: prefix, Source: FormatFail2ban})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
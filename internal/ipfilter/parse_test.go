@@ -0,0 +1,149 @@
+package ipfilter
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParsePrefix 测试 IP 和 CIDR 解析
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"192.0.2.1", "192.0.2.1/32", false},
+		{" 192.0.2.0/24 ", "192.0.2.0/24", false},
+		{"192.0.2.77/24", "192.0.2.0/24", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"::ffff:192.0.2.1", "192.0.2.1/32", false},
+		{"::ffff:192.0.2.0/120", "192.0.2.0/24", false},
+		{"::ffff:0:0/95", "::fffe:0:0/95", false},
+		{"office", "", true},
+		{"10.0.0.0/33", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePrefix(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil || got.String() != tt.want {
+				t.Errorf("ParsePrefix(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseList 测试列表文件解析
+func TestParseList(t *testing.T) {
+	data := "# monitoring\n10.0.0.0/8\n\n  192.0.2.1  # probe\n2001:db8::/32\n"
+	entries, err := parseList([]byte(data), "allow_file:list.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if entries[1].Prefix.String() != "192.0.2.1/32" || entries[1].Source != "allow_file:list.txt" {
+		t.Errorf("unexpected entry %+v", entries[1])
+	}
+
+	_, err = parseList([]byte("10.0.0.0/8\nnot-an-ip\n"), "deny_file:x")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error on line 2, got %v", err)
+	}
+}
+
+// TestParseCrowdSec 测试 CrowdSec 决策（LAPI 格式和 cscli 告警格式）
+func TestParseCrowdSec(t *testing.T) {
+	exported := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("decisions", func(t *testing.T) {
+		data := `[
+			{"id":1,"origin":"crowdsec","scenario":"crowdsecurity/ssh-bf","scope":"Ip","type":"ban","value":"192.0.2.1","duration":"3h59m"},
+			{"id":2,"origin":"cscli","scenario":"manual","scope":"Range","type":"ban","value":"198.51.100.0/24","until":"2024-01-02T00:00:00Z"},
+			{"id":3,"scope":"Ip","type":"captcha","value":"192.0.2.2","duration":"1h"},
+			{"id":4,"scope":"Country","type":"ban","value":"XX","duration":"1h"},
+			{"id":5,"scope":"Ip","type":"ban","value":"192.0.2.3"}
+		]`
+		entries, err := parseCrowdSec([]byte(data), exported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 3 {
+			t.Fatalf("expected 3 ban entries, got %d: %+v", len(entries), entries)
+		}
+		if entries[0].Source != "crowdsec:crowdsecurity/ssh-bf" || !entries[0].Until.Equal(exported.Add(3*time.Hour+59*time.Minute)) {
+			t.Errorf("unexpected entry %+v", entries[0])
+		}
+		if entries[1].Prefix.String() != "198.51.100.0/24" || !entries[1].Until.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected entry %+v", entries[1])
+		}
+		if entries[2].Source != "crowdsec" || !entries[2].Until.IsZero() {
+			t.Errorf("unexpected entry %+v", entries[2])
+		}
+	})
+
+	t.Run("alerts", func(t *testing.T) {
+		data := `[{"id":7,"scenario":"crowdsecurity/http-probing","decisions":[
+			{"scenario":"crowdsecurity/http-probing","scope":"Ip","type":"ban","value":"203.0.113.5","duration":"-5m"}
+		]}]`
+		entries, err := parseCrowdSec([]byte(data), exported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || !entries[0].expired(exported) {
+			t.Errorf("expected one expired entry, got %+v", entries)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		for _, data := range []string{"", "null", "[]"} {
+			if entries, err := parseCrowdSec([]byte(data), exported); err != nil || len(entries) != 0 {
+				t.Errorf("parseCrowdSec(%q) = %v, %v", data, entries, err)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, data := range []string{
+			`{"value":"192.0.2.1"}`,
+			`[{"scope":"Ip","type":"ban","value":"bad"}]`,
+			`[{"scope":"Ip","type":"ban","value":"192.0.2.1","duration":"soon"}]`,
+		} {
+			if _, err := parseCrowdSec([]byte(data), exported); err == nil {
+				t.Errorf("expected error for %s", data)
+			}
+		}
+	})
+}
+
+// TestParseFail2ban 测试 fail2ban-client 封禁列表解析
+func TestParseFail2ban(t *testing.T) {
+	data := "192.0.2.1 192.0.2.2,2001:db8::1\n" +
+		"198.51.100.7 \t2024-01-01 10:00:00 + 600 = 2024-01-01 10:10:00\n"
+	entries, err := parseFail2ban([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	want := time.Date(2024, 1, 1, 10, 10, 0, 0, time.Local)
+	if entries[3].Prefix.String() != "198.51.100.7/32" || !entries[3].Until.Equal(want) || entries[3].Source != "fail2ban" {
+		t.Errorf("unexpected entry %+v", entries[3])
+	}
+	if !entries[0].Until.IsZero() {
+		t.Errorf("expected no expiry without --with-time, got %v", entries[0].Until)
+	}
+
+	if _, err := parseFail2ban([]byte("192.0.2.1 = tomorrow\n")); err == nil {
+		t.Error("expected error for invalid end time")
+	}
+}
@@ -0,0 +1,102 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"time"
+)
+
+// Entry 列表中一个网段的来源和过期时间
+type Entry struct {
+	Prefix netip.Prefix
+	Source string    // 来源（如 "deny"、"deny_file:/etc/tiny-auth/bad.txt"、"crowdsec:ssh-bf"）
+	Until  time.Time // 过期时间（零值表示不过期）
+}
+
+// expired 条目在 now 是否已过期
+func (e *Entry) expired(now time.Time) bool {
+	return !e.Until.IsZero() && !now.Before(e.Until)
+}
+
+// Tree 按地址位建立的前缀树，查询为最长前缀匹配
+// IPv4 和 IPv6 分别存储在两棵树中：IPv6 网段（包括 ::/0 和 ::ffff:0:0/96）不会匹配 IPv4 地址。
+// 构建后只读，可以并发查询
+type Tree struct {
+	v4   node
+	v6   node
+	size int
+}
+
+type node struct {
+	children [2]*node
+	entry    *Entry
+}
+
+// Insert 插入网段；同一网段已存在时保留过期时间较晚的条目
+func (t *Tree) Insert(e Entry) {
+	prefix := e.Prefix.Masked()
+	key, n := t.root(prefix.Addr())
+
+	for i := 0; i < prefix.Bits(); i++ {
+		b := bit(&key, i)
+		if n.children[b] == nil {
+			n.children[b] = &node{}
+		}
+		n = n.children[b]
+	}
+
+	e.Prefix = prefix
+	switch {
+	case n.entry == nil:
+		t.size++
+		n.entry = &e
+	case n.entry.Until.IsZero():
+		// 已有不过期的条目
+	case e.Until.IsZero() || e.Until.After(n.entry.Until):
+		n.entry = &e
+	}
+}
+
+// Lookup 返回包含 addr 的最长未过期网段
+func (t *Tree) Lookup(addr netip.Addr, now time.Time) (*Entry, bool) {
+	if !addr.IsValid() {
+		return nil, false
+	}
+	// IPv4 映射的 IPv6 地址按 IPv4 匹配
+	addr = addr.Unmap()
+	key, n := t.root(addr)
+
+	var match *Entry
+	for i := 0; ; i++ {
+		if n.entry != nil && !n.entry.expired(now) {
+			match = n.entry
+		}
+		if i == addr.BitLen() {
+			break
+		}
+		if n = n.children[bit(&key, i)]; n == nil {
+			break
+		}
+	}
+	return match, match != nil
+}
+
+// Len 返回网段数量
+func (t *Tree) Len() int {
+	return t.size
+}
+
+// root 返回地址所属的树和按位比较用的地址字节（IPv4 只使用前 4 字节）
+func (t *Tree) root(addr netip.Addr) ([16]byte, *node) {
+	var key [16]byte
+	if addr.Is4() {
+		v4 := addr.As4()
+		copy(key[:], v4[:])
+		return key, &t.v4
+	}
+	return addr.As16(), &t.v6
+}
+
+// bit 返回地址的第 i 位（从最高位开始）
+func bit(key *[16]byte, i int) int {
+	return int(key[i/8]>>(7-uint(i%8))) & 1
+}
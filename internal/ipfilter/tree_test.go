@@ -0,0 +1,109 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// TestTree_Lookup 测试最长前缀匹配、IPv4/IPv6 和过期条目
+func TestTree_Lookup(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var tree Tree
+	for _, e := range []Entry{
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Source: "wide"},
+		{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Source: "narrow"},
+		{Prefix: netip.MustParsePrefix("10.1.2.3/32"), Source: "expired", Until: now.Add(-time.Second)},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), Source: "v6"},
+		{Prefix: netip.MustParsePrefix("0.0.0.0/0"), Source: "any v4"},
+	} {
+		tree.Insert(e)
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.2.3.4", "wide"},
+		{"10.1.9.9", "narrow"},
+		{"10.1.2.3", "narrow"}, // 最长的条目已过期，退回到较短的网段
+		{"::ffff:10.1.9.9", "narrow"},
+		{"2001:db8:1::1", "v6"},
+		{"192.0.2.1", "any v4"},
+		{"2001:db9::1", ""}, // IPv4 的 /0 不包含 IPv6 地址
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got := ""
+			if e, ok := tree.Lookup(netip.MustParseAddr(tt.ip), now); ok {
+				got = e.Source
+			}
+			if got != tt.want {
+				t.Errorf("Lookup(%s) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	if tree.Len() != 5 {
+		t.Errorf("Len() = %d, want 5", tree.Len())
+	}
+}
+
+// TestTree_IPv6PrefixesExcludeIPv4 测试 IPv6 网段（包括 ::/0 和 IPv4 映射网段）不匹配 IPv4 地址
+func TestTree_IPv6PrefixesExcludeIPv4(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var tree Tree
+	for _, e := range []Entry{
+		{Prefix: netip.MustParsePrefix("::/0"), Source: "any v6"},
+		{Prefix: netip.MustParsePrefix("::ffff:0:0/96"), Source: "v4-mapped"},
+	} {
+		tree.Insert(e)
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"192.0.2.1", ""},
+		{"::ffff:192.0.2.1", ""}, // IPv4 映射的 IPv6 地址按 IPv4 匹配
+		{"2001:db8::1", "any v6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got := ""
+			if e, ok := tree.Lookup(netip.MustParseAddr(tt.ip), now); ok {
+				got = e.Source
+			}
+			if got != tt.want {
+				t.Errorf("Lookup(%s) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTree_InsertDuplicate 测试同一网段重复插入时保留过期时间较晚的条目
+func TestTree_InsertDuplicate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	prefix := netip.MustParsePrefix("192.0.2.0/24")
+
+	var tree Tree
+	tree.Insert(Entry{Prefix: prefix, Source: "short", Until: now.Add(time.Minute)})
+	tree.Insert(Entry{Prefix: prefix, Source: "long", Until: now.Add(time.Hour)})
+	tree.Insert(Entry{Prefix: prefix, Source: "shorter", Until: now.Add(time.Second)})
+
+	e, ok := tree.Lookup(netip.MustParseAddr("192.0.2.1"), now)
+	if !ok || e.Source != "long" {
+		t.Fatalf("expected the later expiry to win, got %+v", e)
+	}
+
+	tree.Insert(Entry{Prefix: netip.MustParsePrefix("192.0.2.128/24"), Source: "permanent"})
+	e, _ = tree.Lookup(netip.MustParseAddr("192.0.2.1"), now.Add(2*time.Hour))
+	if e == nil || e.Source != "permanent" {
+		t.Fatalf("expected the permanent entry to win, got %+v", e)
+	}
+	if tree.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", tree.Len())
+	}
+}
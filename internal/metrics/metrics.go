@@ -0,0 +1,191 @@
+// Package metrics 以 Prometheus 文本格式导出 tiny-auth 的运行指标
+// 标签只使用取值有限的字段（结果、认证方式、策略名称、状态码），不包含用户名或 IP
+// 所有方法都可以在 nil *Metrics 上调用（未启用指标时不做任何事）
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tinyauth"
+
+// none 空标签值的占位（未匹配策略、未认证）
+const none = "none"
+
+// durationBuckets 请求延迟直方图的桶（秒）；认证通常在毫秒级完成，外部授权 Webhook 可能达到秒级
+var durationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// LimiterStats 一个限流器在采集时刻的状态
+type LimiterStats struct {
+	Scope   string // "global" 或 "policy:<name>"
+	Records int    // 进程内记录的客户端数（< 0 表示后端不提供，如 Redis）
+	Bans    int    // 处于封禁期的客户端数（< 0 表示查询失败）
+}
+
+// Metrics 指标注册表和采集器
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	auditErrors prometheus.Counter
+	reloads     *prometheus.CounterVec
+	lastReload  prometheus.Gauge
+	reloadOK    prometheus.Gauge
+	cache       *prometheus.CounterVec
+}
+
+// New 创建指标注册表；limiters 在每次采集时调用，返回当前限流器的状态（可以为 nil）
+func New(limiters func() []LimiterStats) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Forward-auth requests by result, auth method, policy and HTTP status.",
+		}, []string{"result", "auth_method", "policy", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time spent handling forward-auth requests.",
+			Buckets:   durationBuckets,
+		}, []string{"result"}),
+		auditErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "audit_write_errors_total",
+			Help:      "Audit events that could not be written.",
+		}),
+		reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_reloads_total",
+			Help:      "Configuration reloads by result.",
+		}, []string{"result"}),
+		lastReload: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_last_reload_timestamp_seconds",
+			Help:      "Unix time of the last configuration reload attempt.",
+		}),
+		reloadOK: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last configuration reload succeeded (1) or failed (0).",
+		}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Verification cache lookups by cache and result (hit or miss).",
+		}, []string{"cache", "result"}),
+	}
+
+	// 启动时的配置视为一次成功加载
+	m.reloadOK.Set(1)
+	m.lastReload.Set(float64(time.Now().Unix()))
+
+	m.registry.MustRegister(
+		m.requests, m.duration, m.auditErrors, m.reloads, m.lastReload, m.reloadOK, m.cache,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if limiters != nil {
+		m.registry.MustRegister(&limiterCollector{stats: limiters})
+	}
+	return m
+}
+
+// ObserveRequest 记录一次 forward-auth 请求
+// method、policy 为空时记为 "none"
+func (m *Metrics) ObserveRequest(result, method, policy string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	if method == "" {
+		method = none
+	}
+	if policy == "" {
+		policy = none
+	}
+	m.requests.WithLabelValues(result, method, policy, strconv.Itoa(status)).Inc()
+	m.duration.WithLabelValues(result).Observe(d.Seconds())
+}
+
+// AuditError 记录一次审计日志写入失败
+func (m *Metrics) AuditError() {
+	if m == nil {
+		return
+	}
+	m.auditErrors.Inc()
+}
+
+// ObserveReload 记录一次配置重载
+func (m *Metrics) ObserveReload(success bool, at time.Time) {
+	if m == nil {
+		return
+	}
+	m.lastReload.Set(float64(at.Unix()))
+	if success {
+		m.reloads.WithLabelValues("success").Inc()
+		m.reloadOK.Set(1)
+	} else {
+		m.reloads.WithLabelValues("failure").Inc()
+		m.reloadOK.Set(0)
+	}
+}
+
+// CacheLookup 记录一次缓存查询（cache 为缓存名称，如 "authz_webhook"）
+func (m *Metrics) CacheLookup(cache string, hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cache.WithLabelValues(cache, result).Inc()
+}
+
+// Handler 返回以 Prometheus 文本格式输出指标的 HTTP handler
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// limiterCollector 在采集时读取限流器的记录数和封禁数
+type limiterCollector struct {
+	stats func() []LimiterStats
+}
+
+var (
+	limiterRecordsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ratelimit", "records"),
+		"Clients currently tracked by the in-process rate limiter.",
+		[]string{"scope"}, nil,
+	)
+	limiterBansDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ratelimit", "active_bans"),
+		"Clients currently banned (or waiting, in backoff mode) by the rate limiter.",
+		[]string{"scope"}, nil,
+	)
+)
+
+// Describe 实现 prometheus.Collector
+func (c *limiterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- limiterRecordsDesc
+	ch <- limiterBansDesc
+}
+
+// Collect 实现 prometheus.Collector
+func (c *limiterCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.stats() {
+		if s.Records >= 0 {
+			ch <- prometheus.MustNewConstMetric(limiterRecordsDesc, prometheus.GaugeValue, float64(s.Records), s.Scope)
+		}
+		if s.Bans >= 0 {
+			ch <- prometheus.MustNewConstMetric(limiterBansDesc, prometheus.GaugeValue, float64(s.Bans), s.Scope)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMetrics_Handler 测试记录的指标以 Prometheus 文本格式输出
+func TestMetrics_Handler(t *testing.T) {
+	m := New(func() []LimiterStats {
+		return []LimiterStats{
+			{Scope: "global", Records: 3, Bans: 1},
+			{Scope: "policy:login", Records: -1, Bans: 2},
+		}
+	})
+
+	m.ObserveRequest("success", "basic", "api", 200, 3*time.Millisecond)
+	m.ObserveRequest("denied", "", "", 401, time.Millisecond)
+	m.ObserveRequest("denied", "", "", 401, time.Millisecond)
+	m.AuditError()
+	m.ObserveReload(true, time.Unix(1700000000, 0))
+	m.ObserveReload(false, time.Unix(1700000100, 0))
+	m.CacheLookup("authz_webhook", true)
+	m.CacheLookup("authz_webhook", false)
+	m.CacheLookup("authz_webhook", true)
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`tinyauth_requests_total{auth_method="basic",policy="api",result="success",status="200"} 1`,
+		`tinyauth_requests_total{auth_method="none",policy="none",result="denied",status="401"} 2`,
+		`tinyauth_request_duration_seconds_count{result="denied"} 2`,
+		`tinyauth_request_duration_seconds_bucket{result="success",le="0.005"} 1`,
+		`tinyauth_audit_write_errors_total 1`,
+		`tinyauth_config_reloads_total{result="success"} 1`,
+		`tinyauth_config_reloads_total{result="failure"} 1`,
+		`tinyauth_config_last_reload_timestamp_seconds 1.7000001e+09`,
+		`tinyauth_config_last_reload_successful 0`,
+		`tinyauth_cache_requests_total{cache="authz_webhook",result="hit"} 2`,
+		`tinyauth_cache_requests_total{cache="authz_webhook",result="miss"} 1`,
+		`tinyauth_ratelimit_records{scope="global"} 3`,
+		`tinyauth_ratelimit_active_bans{scope="global"} 1`,
+		`tinyauth_ratelimit_active_bans{scope="policy:login"} 2`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in output", want)
+		}
+	}
+	if strings.Contains(body, `tinyauth_ratelimit_records{scope="policy:login"}`) {
+		t.Error("records without a local count should be omitted")
+	}
+}
+
+// TestMetrics_Nil 测试未启用指标时可以安全调用
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+	m.ObserveRequest("success", "basic", "api", 200, time.Millisecond)
+	m.AuditError()
+	m.ObserveReload(true, time.Now())
+	m.CacheLookup("authz_webhook", true)
+}
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
@@ -1,27 +1,109 @@
 package policy
 
 import (
+	"strings"
+
 	"github.com/nerdneilsfield/tiny-auth/internal/auth"
 	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	apperrors "github.com/nerdneilsfield/tiny-auth/internal/errors"
+)
+
+// Reason 策略检查失败的原因类型
+type Reason string
+
+const (
+	ReasonJWTRequired       Reason = "jwt_required"       // jwt_only 策略收到非 JWT 认证
+	ReasonMethodNotAllowed  Reason = "method_not_allowed" // 凭证不在 allowed_*_names 白名单中
+	ReasonInsufficientRoles Reason = "insufficient_roles" // 不满足 require_all_roles / require_any_role
+	ReasonGroupRequired     Reason = "group_required"     // 不满足 require_any_group
 )
 
+// Violation 认证结果未满足的策略要求
+type Violation struct {
+	Reason      Reason
+	Requirement string   // 未满足的配置项（如 "require_all_roles"）
+	Missing     []string // 缺少的角色或组（require_any_* 时为候选列表）
+	Method      string   // 使用的认证方式
+	Actual      []string // 实际拥有的角色或组
+}
+
+// String 返回便于排查的描述（如 "jwt_only"、"require_all_roles: missing admin"）
+func (v *Violation) String() string {
+	switch {
+	case len(v.Missing) == 0:
+		return v.Requirement
+	case v.Requirement == "require_all_roles":
+		return v.Requirement + ": missing " + strings.Join(v.Missing, ", ")
+	default:
+		return v.Requirement + ": none of " + strings.Join(v.Missing, ", ")
+	}
+}
+
+// AppError 转换为返回给客户端的结构化错误
+func (v *Violation) AppError() *apperrors.AppError {
+	switch v.Reason {
+	case ReasonJWTRequired:
+		return apperrors.AuthzJWTRequired()
+	case ReasonMethodNotAllowed:
+		// 不返回白名单内容，避免泄露其他凭证名称
+		return apperrors.NewAppError(apperrors.ErrCodeAuthzMethodNotAllowed, "Authentication method not allowed", nil).
+			WithDetail("method", v.Method)
+	case ReasonGroupRequired:
+		return apperrors.AuthzGroupRequired(v.Missing, v.Actual).WithDetail("requirement", v.Requirement)
+	default:
+		return apperrors.AuthzInsufficientRoles(v.Missing, v.Actual).WithDetail("requirement", v.Requirement)
+	}
+}
+
 // CheckPolicy 检查认证结果是否满足策略要求
-func CheckPolicy(policy *config.RoutePolicy, result *auth.AuthResult, store *auth.AuthStore) bool {
+// 全部满足时返回 nil，否则返回第一个未满足的要求
+func CheckPolicy(policy *config.RoutePolicy, result *auth.AuthResult, store *auth.AuthStore) *Violation {
 	if policy == nil {
-		return true // 无策略，接受任何有效认证
+		return nil // 无策略，接受任何有效认证
 	}
 
 	// 检查认证方法白名单
 	if !checkMethodRestrictions(policy, result) {
-		return false
+		if policy.JWTOnly && result.Method != "jwt" {
+			return &Violation{Reason: ReasonJWTRequired, Requirement: "jwt_only", Method: result.Method}
+		}
+		requirement := map[string]string{
+			"basic":  "allowed_basic_names",
+			"bearer": "allowed_bearer_names",
+			"apikey": "allowed_api_key_names",
+		}[result.Method]
+		return &Violation{Reason: ReasonMethodNotAllowed, Requirement: requirement, Method: result.Method}
 	}
 
 	// 检查角色要求
 	if !checkRoleRequirements(policy, result) {
-		return false
+		v := &Violation{Reason: ReasonInsufficientRoles, Method: result.Method, Actual: result.Roles}
+		for _, required := range policy.RequireAllRoles {
+			if !contains(result.Roles, required) {
+				v.Missing = append(v.Missing, required)
+			}
+		}
+		if len(v.Missing) > 0 {
+			v.Requirement = "require_all_roles"
+		} else {
+			v.Requirement = "require_any_role"
+			v.Missing = policy.RequireAnyRole
+		}
+		return v
 	}
 
-	return true
+	// 检查组要求
+	if len(policy.RequireAnyGroup) > 0 && !containsAny(result.Groups, policy.RequireAnyGroup) {
+		return &Violation{
+			Reason:      ReasonGroupRequired,
+			Requirement: "require_any_group",
+			Missing:     policy.RequireAnyGroup,
+			Method:      result.Method,
+			Actual:      result.Groups,
+		}
+	}
+
+	return nil
 }
 
 // checkMethodRestrictions 检查认证方法限制
@@ -5,6 +5,7 @@ import (
 
 	"github.com/nerdneilsfield/tiny-auth/internal/auth"
 	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	apperrors "github.com/nerdneilsfield/tiny-auth/internal/errors"
 )
 
 // TestCheckMethodRestrictions_JWTOnly 测试 jwt_only 策略绕过漏洞的修复
@@ -280,8 +281,51 @@ func TestCheckPolicy(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := CheckPolicy(tt.policy, tt.result, nil)
-			if got != tt.expected {
-				t.Errorf("CheckPolicy() = %v, want %v", got, tt.expected)
+			if (got == nil) != tt.expected {
+				t.Errorf("CheckPolicy() = %v, want allowed=%v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCheckPolicy_Violation 测试未满足要求时返回的原因
+func TestCheckPolicy_Violation(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *config.RoutePolicy
+		result   *auth.AuthResult
+		reason   Reason
+		expected string
+		code     apperrors.ErrorCode
+	}{
+		{"jwt_only", &config.RoutePolicy{JWTOnly: true}, &auth.AuthResult{Method: "basic"},
+			ReasonJWTRequired, "jwt_only", apperrors.ErrCodeAuthzJWTRequired},
+		{"Basic 名称白名单", &config.RoutePolicy{AllowedBasicNames: []string{"admin"}}, &auth.AuthResult{Method: "basic", Name: "guest"},
+			ReasonMethodNotAllowed, "allowed_basic_names", apperrors.ErrCodeAuthzMethodNotAllowed},
+		{"API Key 名称白名单", &config.RoutePolicy{AllowedAPIKeyNames: []string{"ci"}}, &auth.AuthResult{Method: "apikey", Name: "other"},
+			ReasonMethodNotAllowed, "allowed_api_key_names", apperrors.ErrCodeAuthzMethodNotAllowed},
+		{"缺少必需角色", &config.RoutePolicy{RequireAllRoles: []string{"user", "admin", "ops"}}, &auth.AuthResult{Method: "jwt", Roles: []string{"user"}},
+			ReasonInsufficientRoles, "require_all_roles: missing admin, ops", apperrors.ErrCodeAuthzInsufficientRoles},
+		{"没有任意角色", &config.RoutePolicy{RequireAnyRole: []string{"admin", "ops"}}, &auth.AuthResult{Method: "jwt", Roles: []string{"user"}},
+			ReasonInsufficientRoles, "require_any_role: none of admin, ops", apperrors.ErrCodeAuthzInsufficientRoles},
+		{"不属于任何组", &config.RoutePolicy{RequireAnyGroup: []string{"sre"}}, &auth.AuthResult{Method: "jwt", Groups: []string{"dev"}},
+			ReasonGroupRequired, "require_any_group: none of sre", apperrors.ErrCodeAuthzGroupRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := CheckPolicy(tt.policy, tt.result, nil)
+			if v == nil {
+				t.Fatal("expected violation, got nil")
+			}
+			if v.Reason != tt.reason {
+				t.Errorf("Reason = %q, want %q", v.Reason, tt.reason)
+			}
+			if got := v.String(); got != tt.expected {
+				t.Errorf("String() = %q, want %q", got, tt.expected)
+			}
+			if code := v.AppError().Code; code != tt.code {
+				t.Errorf("AppError().Code = %q, want %q", code, tt.code)
 			}
 		})
 	}
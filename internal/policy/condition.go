@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// Request 策略评估使用的请求信息（来自可信代理的转发信息）
+type Request struct {
+	Host     string
+	URI      string
+	Method   string
+	ClientIP string
+	Headers  map[string]string // header 名称统一为小写
+	Time     time.Time
+	Country  string // 客户端国家代码（GeoIP，未配置或查不到时为空）
+	ASN      uint   // 客户端自治系统号（GeoIP，未配置或查不到时为 0）
+}
+
+// CheckCondition 对策略的条件表达式求值
+// 策略没有条件时返回 true；表达式无效或求值出错时返回 false 和错误（按拒绝处理）
+func CheckCondition(policy *config.RoutePolicy, result *auth.AuthResult, req *Request) (bool, error) {
+	if policy == nil || policy.Condition == "" {
+		return true, nil
+	}
+
+	prog, err := policy.ConditionProgram()
+	if err != nil {
+		return false, err
+	}
+
+	return prog.Eval(conditionVars(result, req))
+}
+
+// conditionVars 构建条件表达式可见的变量（与 config.ConditionVariables 对应）
+func conditionVars(result *auth.AuthResult, req *Request) map[string]interface{} {
+	headers := make(map[string]interface{}, len(req.Headers))
+	for k, v := range req.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+
+	claims := map[string]interface{}{}
+	if result.Claims != nil {
+		claims = result.Claims
+	}
+
+	t := req.Time.UTC()
+
+	return map[string]interface{}{
+		"auth": map[string]interface{}{
+			"method": result.Method,
+			"name":   result.Name,
+			"user":   result.User,
+			"roles":  result.Roles,
+			"groups": result.Groups,
+		},
+		"claims": claims,
+		"request": map[string]interface{}{
+			"host":    req.Host,
+			"uri":     req.URI,
+			"path":    NormalizePath(req.URI),
+			"method":  strings.ToUpper(req.Method),
+			"ip":      req.ClientIP,
+			"headers": headers,
+		},
+		"now": map[string]interface{}{
+			"unix":    t.Unix(),
+			"hour":    t.Hour(),
+			"minute":  t.Minute(),
+			"weekday": strings.ToLower(t.Weekday().String()[:3]),
+		},
+	}
+}
@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestCheckCondition 测试策略条件表达式
+func TestCheckCondition(t *testing.T) {
+	jwtUser := &auth.AuthResult{
+		Method: "jwt",
+		User:   "alice",
+		Roles:  []string{"user"},
+		Claims: map[string]interface{}{"email": "alice@corp.com"},
+	}
+	basicAdmin := &auth.AuthResult{
+		Method: "basic",
+		Name:   "admin-cred",
+		User:   "admin",
+		Roles:  []string{"admin"},
+	}
+
+	req := &Request{
+		Host:     "api.example.com",
+		URI:      "/reports?year=2024",
+		Method:   "get",
+		ClientIP: "10.0.0.5",
+		Headers:  map[string]string{"X-Tenant": "acme"},
+		Time:     time.Date(2024, 5, 7, 10, 30, 0, 0, time.UTC), // Tuesday
+	}
+
+	tests := []struct {
+		name      string
+		condition string
+		result    *auth.AuthResult
+		want      bool
+		wantErr   bool
+	}{
+		{"No condition", "", basicAdmin, true, false},
+		{"Admin or GET from team", `"admin" in auth.roles || (auth.user in ["bob"] && request.method == "GET")`, basicAdmin, true, false},
+		{"Admin or GET from team (no match)", `"admin" in auth.roles || (auth.user in ["bob"] && request.method == "GET")`, jwtUser, false, false},
+		{"Claim suffix", `claims.email.endsWith("@corp.com")`, jwtUser, true, false},
+		{"Claim missing for basic", `claims.email.endsWith("@corp.com")`, basicAdmin, false, false},
+		{"Path excludes query", `request.path == "/reports"`, jwtUser, true, false},
+		{"Header names are lowercase", `request.headers["x-tenant"] == "acme"`, jwtUser, true, false},
+		{"Client network", `inCIDR(request.ip, "10.0.0.0/8")`, jwtUser, true, false},
+		{"Time", `now.weekday == "tue" && now.hour >= 9 && now.hour < 17`, jwtUser, true, false},
+		{"Auth method", `auth.method == "basic" && auth.name == "admin-cred"`, basicAdmin, true, false},
+		{"Invalid expression", `auth.user ==`, jwtUser, false, true},
+		{"Runtime error", `auth.user && true`, jwtUser, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &config.RoutePolicy{Name: "test", Condition: tt.condition}
+			got, err := CheckCondition(p, tt.result, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CheckCondition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package policy
+
+import "github.com/nerdneilsfield/tiny-auth/internal/config"
+
+// CheckGeo 检查请求来源的国家和 ASN 是否满足策略的限制
+// 返回拒绝原因（country_denied / asn_denied），满足或策略没有限制时返回空
+func CheckGeo(policy *config.RoutePolicy, req *Request) string {
+	if policy == nil {
+		return ""
+	}
+	rules := policy.GeoRules()
+	return rules.Check(req.Country, req.ASN)
+}
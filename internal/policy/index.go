@@ -0,0 +1,309 @@
+package policy
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// Index 预编译的路由策略索引
+// 加载和热重载时构建一次，之后只读，可并发使用。匹配语义与 MatchRequest 一致：
+// 按优先级（相同时附加条件更多者优先，其余按配置顺序）返回第一个匹配的策略。
+//
+// 索引层次：精确 host → 通配符后缀 host（*.example.com）→ 任意 host，
+// 每个 host 桶内是按路径段组织的前缀树，树节点上的策略再按 HTTP 方法分桶。
+type Index struct {
+	policies []config.RoutePolicy // 按匹配顺序排序后的副本
+
+	exactHosts    map[string]*pathTrie // 小写 host → 路径树
+	wildcardHosts map[string]*pathTrie // 后缀（如 ".example.com"）→ 路径树
+	anyHost       *pathTrie            // 未配置 host 的策略
+}
+
+// NewIndex 编译路由策略索引
+func NewIndex(policies []config.RoutePolicy) *Index {
+	idx := &Index{
+		policies:      sortPolicies(policies),
+		exactHosts:    make(map[string]*pathTrie),
+		wildcardHosts: make(map[string]*pathTrie),
+	}
+
+	for rank := range idx.policies {
+		p := &idx.policies[rank]
+
+		var trie *pathTrie
+		switch {
+		case p.Host == "":
+			if idx.anyHost == nil {
+				idx.anyHost = newPathTrie()
+			}
+			trie = idx.anyHost
+		case strings.HasPrefix(p.Host, "*."):
+			suffix := p.Host[1:] // 与 matchHost 一致：后缀比较区分大小写
+			if idx.wildcardHosts[suffix] == nil {
+				idx.wildcardHosts[suffix] = newPathTrie()
+			}
+			trie = idx.wildcardHosts[suffix]
+		default:
+			host := strings.ToLower(p.Host)
+			if idx.exactHosts[host] == nil {
+				idx.exactHosts[host] = newPathTrie()
+			}
+			trie = idx.exactHosts[host]
+		}
+
+		trie.insert(&indexEntry{rank: rank, policy: p})
+	}
+
+	return idx
+}
+
+// Len 返回索引中的策略数量
+func (idx *Index) Len() int {
+	return len(idx.policies)
+}
+
+// Match 返回第一个匹配请求的策略，没有匹配时返回 nil
+func (idx *Index) Match(req *Request) *config.RoutePolicy {
+	if idx == nil || len(idx.policies) == 0 {
+		return nil
+	}
+
+	m := &indexMatch{
+		req:      req,
+		path:     NormalizePath(req.URI),
+		method:   strings.ToUpper(req.Method),
+		bestRank: len(idx.policies),
+	}
+	m.segments = pathSegments(m.path)
+
+	if trie := idx.exactHosts[strings.ToLower(req.Host)]; trie != nil {
+		trie.collect(m)
+	}
+	if len(idx.wildcardHosts) > 0 {
+		// 枚举 host 的所有 ".xxx" 后缀
+		for i := 0; i < len(req.Host); i++ {
+			if req.Host[i] != '.' {
+				continue
+			}
+			if trie := idx.wildcardHosts[req.Host[i:]]; trie != nil {
+				trie.collect(m)
+			}
+		}
+	}
+	if idx.anyHost != nil {
+		idx.anyHost.collect(m)
+	}
+
+	if m.best == nil {
+		return nil
+	}
+	return m.best.policy
+}
+
+// indexMatch 单次匹配的状态
+type indexMatch struct {
+	req      *Request
+	path     string
+	segments []string
+	method   string
+	query    url.Values // 按需解析
+
+	best     *indexEntry
+	bestRank int
+}
+
+// consider 检查候选策略列表（已按 rank 升序），更新最佳匹配
+func (m *indexMatch) consider(entries []*indexEntry) {
+	for _, e := range entries {
+		if e.rank >= m.bestRank {
+			return // 后面的候选优先级更低
+		}
+		if m.verify(e) {
+			m.best = e
+			m.bestRank = e.rank
+			return
+		}
+	}
+}
+
+// verify 检查索引无法覆盖的条件（glob / regex 路径、来源网段、header、query）
+func (m *indexMatch) verify(e *indexEntry) bool {
+	p := e.policy
+	if e.verifyPath && !matchPath(p, m.path) {
+		return false
+	}
+	if len(p.SourceCIDRs) > 0 && !matchSource(p, m.req.ClientIP) {
+		return false
+	}
+	if len(p.MatchHeaders) > 0 && !matchHeaders(p, m.req.Headers) {
+		return false
+	}
+	if len(p.MatchQuery) > 0 {
+		if m.query == nil {
+			m.query = parseQuery(m.req.URI)
+		}
+		if !matchQuery(p, m.query) {
+			return false
+		}
+	}
+	return true
+}
+
+// indexEntry 索引中的一条策略
+type indexEntry struct {
+	rank       int // 在匹配顺序中的位置（越小越优先）
+	policy     *config.RoutePolicy
+	verifyPath bool // 路径需要在候选阶段后完整校验（glob / regex）
+}
+
+// methodBuckets 按 HTTP 方法分桶的候选列表
+type methodBuckets struct {
+	byMethod map[string][]*indexEntry // 大写方法 → 候选
+	any      []*indexEntry            // 未限制方法的候选
+}
+
+func (b *methodBuckets) add(e *indexEntry) {
+	if e.policy.Method == "" {
+		b.any = append(b.any, e)
+		return
+	}
+	if b.byMethod == nil {
+		b.byMethod = make(map[string][]*indexEntry)
+	}
+	method := strings.ToUpper(e.policy.Method)
+	b.byMethod[method] = append(b.byMethod[method], e)
+}
+
+func (b *methodBuckets) collect(m *indexMatch) {
+	if b == nil {
+		return
+	}
+	if entries := b.byMethod[m.method]; entries != nil {
+		m.consider(entries)
+	}
+	m.consider(b.any)
+}
+
+// pathTrie 按路径段组织的前缀树
+type pathTrie struct {
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[string]*trieNode
+
+	prefix       *methodBuckets // 前缀匹配：路径以该节点开头即可（包括未配置路径的策略）
+	prefixStrict *methodBuckets // 以 / 结尾的前缀：路径在该节点之后还需要至少一个路径段
+	exact        *methodBuckets // 精确匹配：路径恰好结束于该节点
+}
+
+func newPathTrie() *pathTrie {
+	return &pathTrie{root: &trieNode{}}
+}
+
+// insert 按策略的路径条件放入对应节点
+// 策略按 rank 升序插入，因此每个候选列表天然有序
+func (t *pathTrie) insert(e *indexEntry) {
+	p := e.policy
+	switch {
+	case p.PathExact != "":
+		n := t.node(pathSegments(p.PathExact))
+		n.bucket(&n.exact).add(e)
+
+	case p.PathPrefix != "":
+		prefix := p.PathPrefix
+		if strings.HasSuffix(prefix, "/") {
+			n := t.node(prefixSegments(prefix[:len(prefix)-1]))
+			n.bucket(&n.prefixStrict).add(e)
+		} else {
+			n := t.node(prefixSegments(prefix))
+			n.bucket(&n.prefix).add(e)
+		}
+
+	case p.PathGlob != "":
+		// 放在通配符之前的字面量路径段对应的节点上，候选阶段后再完整匹配
+		e.verifyPath = true
+		n := t.node(globLiteralSegments(p.PathGlob))
+		n.bucket(&n.prefix).add(e)
+
+	case p.PathRegex != "":
+		e.verifyPath = true
+		t.root.bucket(&t.root.prefix).add(e)
+
+	default:
+		t.root.bucket(&t.root.prefix).add(e)
+	}
+}
+
+// node 返回（必要时创建）路径段序列对应的节点
+func (t *pathTrie) node(segments []string) *trieNode {
+	n := t.root
+	for _, seg := range segments {
+		if n.children == nil {
+			n.children = make(map[string]*trieNode)
+		}
+		child := n.children[seg]
+		if child == nil {
+			child = &trieNode{}
+			n.children[seg] = child
+		}
+		n = child
+	}
+	return n
+}
+
+func (n *trieNode) bucket(b **methodBuckets) *methodBuckets {
+	if *b == nil {
+		*b = &methodBuckets{}
+	}
+	return *b
+}
+
+// collect 沿请求路径遍历前缀树，收集所有可能匹配的候选
+func (t *pathTrie) collect(m *indexMatch) {
+	n := t.root
+	for depth := 0; ; depth++ {
+		n.prefix.collect(m)
+		if depth < len(m.segments) {
+			n.prefixStrict.collect(m)
+		}
+		if depth == len(m.segments) {
+			n.exact.collect(m)
+			return
+		}
+		next := n.children[m.segments[depth]]
+		if next == nil {
+			return
+		}
+		n = next
+	}
+}
+
+// pathSegments 拆分以 / 开头的路径："/" → [""]，"/a/b/" → ["a", "b", ""]
+func pathSegments(p string) []string {
+	return strings.Split(strings.TrimPrefix(p, "/"), "/")
+}
+
+// prefixSegments 拆分前缀（不含末尾的 /）："" → []，"/api" → ["api"]
+func prefixSegments(prefix string) []string {
+	if prefix == "" {
+		return nil
+	}
+	return pathSegments(prefix)
+}
+
+// globLiteralSegments 返回 glob 模式开头不含通配符的路径段
+// 不含通配符的段只能匹配完全相同的路径段，因此这些段可以用于索引
+func globLiteralSegments(pattern string) []string {
+	segs := pathSegments(pattern)
+	var literal []string
+	for i, seg := range segs {
+		if i == len(segs)-1 || strings.ContainsAny(seg, `*?[\`) {
+			break // 最后一段可能是空段（以 / 结尾），保守处理
+		}
+		literal = append(literal, seg)
+	}
+	return literal
+}
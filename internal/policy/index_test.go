@@ -0,0 +1,238 @@
+package policy
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestIndex_Match 测试预编译索引的匹配结果
+func TestIndex_Match(t *testing.T) {
+	policies := []config.RoutePolicy{
+		{Name: "catch-all", Priority: -1},
+		{Name: "api", Host: "api.example.com", PathPrefix: "/api"},
+		{Name: "api-dir", Host: "api.example.com", PathPrefix: "/api/v1/", Priority: 1},
+		{Name: "login", Host: "API.example.com", PathExact: "/api/login", Method: "post", Priority: 10},
+		{Name: "deep-wildcard", Host: "*.internal.example.com", PathPrefix: "/admin"},
+		{Name: "css", Host: "static.example.com", PathGlob: "/assets/**/*.css"},
+		{Name: "regex", Host: "static.example.com", PathRegex: `/v[0-9]+/.*`},
+		{Name: "root", Host: "root.example.com", PathPrefix: "/"},
+		{Name: "tenant", Host: "api.example.com", PathPrefix: "/api", MatchHeaders: map[string]string{"X-Tenant": "acme"}},
+		{Name: "wildcard", Host: "*.example.com"},
+	}
+
+	tests := []struct {
+		name     string
+		req      Request
+		expected string
+	}{
+		{"Exact host, path prefix", Request{Host: "api.example.com", URI: "/api/users", Method: "GET"}, "api"},
+		{"Prefix is segment aware", Request{Host: "api.example.com", URI: "/apiary", Method: "GET"}, "wildcard"},
+		{"Host is case insensitive", Request{Host: "Api.Example.COM", URI: "/api", Method: "GET"}, "api"},
+		{"Trailing slash prefix needs child", Request{Host: "api.example.com", URI: "/api/v1", Method: "GET"}, "api"},
+		{"Trailing slash prefix", Request{Host: "api.example.com", URI: "/api/v1/x", Method: "GET"}, "api-dir"},
+		{"Exact path and method bucket", Request{Host: "api.example.com", URI: "/api/login", Method: "POST"}, "login"},
+		{"Method mismatch falls back", Request{Host: "api.example.com", URI: "/api/login", Method: "GET"}, "api"},
+		{"Normalized path", Request{Host: "api.example.com", URI: "/x/../api/login?next=/", Method: "post"}, "login"},
+		{"Header matcher wins on tie", Request{Host: "api.example.com", URI: "/api", Headers: map[string]string{"x-tenant": "acme"}}, "tenant"},
+		{"Wildcard host", Request{Host: "www.example.com", URI: "/"}, "wildcard"},
+		{"Nested wildcard host", Request{Host: "a.b.internal.example.com", URI: "/admin/x"}, "deep-wildcard"},
+		{"Wildcard does not match apex", Request{Host: "example.com", URI: "/"}, "catch-all"},
+		{"Glob", Request{Host: "static.example.com", URI: "/assets/a/b/site.css"}, "css"},
+		{"Glob mismatch", Request{Host: "static.example.com", URI: "/assets/a/site.js"}, "wildcard"},
+		{"Regex", Request{Host: "static.example.com", URI: "/v2/app.js"}, "regex"},
+		{"Root prefix", Request{Host: "root.example.com", URI: "/"}, "root"},
+		{"Unknown host", Request{Host: "other.test", URI: "/"}, "catch-all"},
+	}
+
+	idx := NewIndex(policies)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := idx.Match(&tt.req)
+			if got == nil {
+				t.Fatalf("expected policy %q, got nil", tt.expected)
+			}
+			if got.Name != tt.expected {
+				t.Errorf("expected policy %q, got %q", tt.expected, got.Name)
+			}
+			if legacy := MatchRequest(policies, &tt.req); legacy == nil || legacy.Name != got.Name {
+				t.Errorf("index and MatchRequest disagree: %q vs %v", got.Name, legacy)
+			}
+		})
+	}
+}
+
+// TestIndex_Empty 测试空索引
+func TestIndex_Empty(t *testing.T) {
+	var nilIndex *Index
+	if nilIndex.Match(&Request{Host: "a", URI: "/"}) != nil {
+		t.Error("nil index should not match")
+	}
+	if NewIndex(nil).Match(&Request{Host: "a", URI: "/"}) != nil {
+		t.Error("empty index should not match")
+	}
+	if got := NewIndex([]config.RoutePolicy{{Name: "a", Host: "a.test"}}).Match(&Request{Host: "b.test", URI: "/"}); got != nil {
+		t.Errorf("expected no match, got %q", got.Name)
+	}
+}
+
+// TestIndex_MatchesLegacy 随机生成策略和请求，确认索引与 MatchRequest 的结果一致
+func TestIndex_MatchesLegacy(t *testing.T) {
+	rng := rand.New(rand.NewSource(34))
+
+	for round := 0; round < 20; round++ {
+		policies := randomPolicies(rng, 60)
+		idx := NewIndex(policies)
+
+		for i := 0; i < 500; i++ {
+			req := randomRequest(rng)
+			want := MatchRequest(policies, req)
+			got := idx.Match(req)
+
+			switch {
+			case want == nil && got == nil:
+			case want == nil || got == nil || want.Name != got.Name:
+				t.Fatalf("round %d: request %+v: MatchRequest=%v, Index=%v", round, req, policyName(want), policyName(got))
+			}
+		}
+	}
+}
+
+var (
+	testHosts   = []string{"", "api.example.com", "API.example.com", "*.example.com", "*.b.example.com", "www.example.com", "other.test"}
+	testPaths   = []string{"/", "/api", "/api/", "/api/v1", "/api/v1/users", "/static", "/static/app.css", "/admin"}
+	testGlobs   = []string{"/static/*.css", "/api/**", "/**/users", "/api/*/users", "/*"}
+	testRegexes = []string{`/api/v[0-9]+.*`, `.*\.css`, `/admin(/.*)?`}
+	testMethods = []string{"", "GET", "post", "DELETE"}
+)
+
+func randomPolicies(rng *rand.Rand, n int) []config.RoutePolicy {
+	policies := make([]config.RoutePolicy, n)
+	for i := range policies {
+		p := config.RoutePolicy{
+			Name:     fmt.Sprintf("p%d", i),
+			Host:     testHosts[rng.Intn(len(testHosts))],
+			Method:   testMethods[rng.Intn(len(testMethods))],
+			Priority: rng.Intn(3),
+		}
+		switch rng.Intn(5) {
+		case 0:
+			p.PathPrefix = testPaths[rng.Intn(len(testPaths))]
+		case 1:
+			p.PathExact = testPaths[rng.Intn(len(testPaths))]
+		case 2:
+			p.PathGlob = testGlobs[rng.Intn(len(testGlobs))]
+		case 3:
+			p.PathRegex = testRegexes[rng.Intn(len(testRegexes))]
+		}
+		if rng.Intn(4) == 0 {
+			p.MatchHeaders = map[string]string{"X-Env": "prod"}
+		}
+		if rng.Intn(6) == 0 {
+			p.SourceCIDRs = []string{"10.0.0.0/8"}
+		}
+		policies[i] = p
+	}
+	return policies
+}
+
+func randomRequest(rng *rand.Rand) *Request {
+	hosts := []string{"api.example.com", "Api.Example.com", "www.example.com", "a.b.example.com", "example.com", "other.test"}
+	methods := []string{"GET", "POST", "delete", "PUT"}
+	req := &Request{
+		Host:     hosts[rng.Intn(len(hosts))],
+		URI:      testPaths[rng.Intn(len(testPaths))],
+		Method:   methods[rng.Intn(len(methods))],
+		ClientIP: "192.168.1.1",
+		Headers:  map[string]string{},
+	}
+	if rng.Intn(2) == 0 {
+		req.URI += "/extra"
+	}
+	if rng.Intn(2) == 0 {
+		req.ClientIP = "10.1.2.3"
+	}
+	if rng.Intn(2) == 0 {
+		req.Headers["x-env"] = "prod"
+	}
+	return req
+}
+
+func policyName(p *config.RoutePolicy) string {
+	if p == nil {
+		return "<nil>"
+	}
+	return p.Name
+}
+
+// benchmarkPolicies 生成 n 个分布在不同 host 和路径上的策略
+func benchmarkPolicies(n int) []config.RoutePolicy {
+	policies := make([]config.RoutePolicy, 0, n)
+	for i := 0; i < n; i++ {
+		p := config.RoutePolicy{
+			Name:     fmt.Sprintf("policy-%d", i),
+			Priority: i % 5,
+		}
+		switch i % 4 {
+		case 0:
+			p.Host = fmt.Sprintf("svc%d.example.com", i)
+			p.PathPrefix = "/api"
+		case 1:
+			p.Host = fmt.Sprintf("*.zone%d.example.com", i)
+		case 2:
+			p.Host = fmt.Sprintf("svc%d.example.com", i-2)
+			p.PathExact = fmt.Sprintf("/api/v1/items/%d", i)
+			p.Method = "POST"
+		case 3:
+			p.Host = fmt.Sprintf("app%d.example.com", i)
+			p.PathGlob = "/static/**/*.js"
+		}
+		policies = append(policies, p)
+	}
+	return policies
+}
+
+var benchmarkRequest = &Request{
+	Host:   "svc296.example.com",
+	URI:    "/api/v1/items/298",
+	Method: "POST",
+}
+
+func BenchmarkMatchRequest_300Policies(b *testing.B) {
+	policies := benchmarkPolicies(300)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		MatchRequest(policies, benchmarkRequest)
+	}
+}
+
+func BenchmarkIndexMatch_300Policies(b *testing.B) {
+	idx := NewIndex(benchmarkPolicies(300))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Match(benchmarkRequest)
+	}
+}
+
+func BenchmarkIndexMatch_300Policies_NoMatch(b *testing.B) {
+	idx := NewIndex(benchmarkPolicies(300))
+	req := &Request{Host: "unknown.test", URI: "/", Method: "GET"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Match(req)
+	}
+}
+
+func BenchmarkNewIndex_300Policies(b *testing.B) {
+	policies := benchmarkPolicies(300)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewIndex(policies)
+	}
+}
@@ -1,49 +1,77 @@
 package policy
 
 import (
+	"net"
+	"net/url"
 	"sort"
 	"strings"
 
 	"github.com/nerdneilsfield/tiny-auth/internal/config"
 )
 
-// MatchPolicy 匹配路由策略
-// 策略按优先级排序（priority 越大越优先），优先级相同时按配置顺序
-// 返回第一个匹配的策略，如果没有匹配则返回 nil
+// MatchPolicy 按 host、路径和方法匹配路由策略
+// 不携带 header、query 和客户端 IP，配置了 match_headers / match_query / source_cidrs 的策略只在满足条件时匹配
 func MatchPolicy(policies []config.RoutePolicy, host, uri, method string) *config.RoutePolicy {
+	return MatchRequest(policies, &Request{Host: host, URI: uri, Method: method})
+}
+
+// MatchRequest 匹配路由策略
+// 每次调用都会复制并排序策略，请求路径上应使用预编译的 Index
+// 策略按优先级排序（priority 越大越优先）；优先级相同时，附加匹配条件（header / query / 来源网段）更多的策略优先，
+// 其余按配置顺序。返回第一个匹配的策略，如果没有匹配则返回 nil
+func MatchRequest(policies []config.RoutePolicy, req *Request) *config.RoutePolicy {
 	if len(policies) == 0 {
 		return nil
 	}
 
 	// 创建策略副本并按优先级排序
-	sortedPolicies := make([]config.RoutePolicy, len(policies))
-	copy(sortedPolicies, policies)
+	sortedPolicies := sortPolicies(policies)
 
-	sort.SliceStable(sortedPolicies, func(i, j int) bool {
-		// 按 priority 降序排序（数字越大越优先）
-		// 优先级相同时保持原有顺序（StableSort）
-		return sortedPolicies[i].Priority > sortedPolicies[j].Priority
-	})
+	// 规范化路径（去除 query、解码、解析 ..），防止编码绕过
+	normalizedPath := NormalizePath(req.URI)
+
+	// query 参数按需解析（只有配置了 match_query 的策略才需要）
+	var query url.Values
 
 	// 遍历排序后的策略
 	for i := range sortedPolicies {
 		p := &sortedPolicies[i]
 
 		// 匹配 host
-		if !matchHost(p.Host, host) {
+		if !matchHost(p.Host, req.Host) {
 			continue
 		}
 
-		// 匹配 path prefix
-		if p.PathPrefix != "" && !strings.HasPrefix(uri, p.PathPrefix) {
+		// 匹配路径（prefix / exact / glob / regex）
+		if !matchPath(p, normalizedPath) {
 			continue
 		}
 
 		// 匹配 method
-		if p.Method != "" && !strings.EqualFold(p.Method, method) {
+		if p.Method != "" && !strings.EqualFold(p.Method, req.Method) {
+			continue
+		}
+
+		// 匹配客户端网段
+		if len(p.SourceCIDRs) > 0 && !matchSource(p, req.ClientIP) {
+			continue
+		}
+
+		// 匹配 header
+		if len(p.MatchHeaders) > 0 && !matchHeaders(p, req.Headers) {
 			continue
 		}
 
+		// 匹配 query 参数
+		if len(p.MatchQuery) > 0 {
+			if query == nil {
+				query = parseQuery(req.URI)
+			}
+			if !matchQuery(p, query) {
+				continue
+			}
+		}
+
 		// 所有条件都匹配，返回这个策略
 		return p
 	}
@@ -52,6 +80,104 @@ func MatchPolicy(policies []config.RoutePolicy, host, uri, method string) *confi
 	return nil
 }
 
+// sortPolicies 复制并按匹配顺序排序策略（与 MatchRequest 一致）
+func sortPolicies(policies []config.RoutePolicy) []config.RoutePolicy {
+	sorted := make([]config.RoutePolicy, len(policies))
+	copy(sorted, policies)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		// 按 priority 降序排序（数字越大越优先）
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		// 优先级相同时更具体的策略优先，其余保持原有顺序（StableSort）
+		return matcherCount(&sorted[i]) > matcherCount(&sorted[j])
+	})
+	return sorted
+}
+
+// matcherCount 返回策略附加匹配条件的数量（用于同优先级排序）
+func matcherCount(p *config.RoutePolicy) int {
+	n := len(p.MatchHeaders) + len(p.MatchQuery)
+	if len(p.SourceCIDRs) > 0 {
+		n++
+	}
+	return n
+}
+
+// matchSource 检查客户端 IP 是否在 source_cidrs 中
+func matchSource(p *config.RoutePolicy, clientIP string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	nets, err := p.SourceNetworks()
+	if err != nil {
+		return false // 无效配置不匹配任何请求（已在 validator 中检查）
+	}
+	for _, network := range nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHeaders 检查请求 header 是否满足所有 match_headers 条件
+func matchHeaders(p *config.RoutePolicy, headers map[string]string) bool {
+	matchers, err := p.HeaderMatchers()
+	if err != nil {
+		return false
+	}
+	for i := range matchers {
+		value, present := headers[matchers[i].Name]
+		if !matchers[i].Match(value, present) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchQuery 检查 query 参数是否满足所有 match_query 条件
+// 同名参数出现多次时，任意一个值满足即可
+func matchQuery(p *config.RoutePolicy, query url.Values) bool {
+	matchers, err := p.QueryMatchers()
+	if err != nil {
+		return false
+	}
+	for i := range matchers {
+		values, present := query[matchers[i].Name]
+		if !present {
+			return false
+		}
+		matched := false
+		for _, v := range values {
+			if matchers[i].Match(v, true) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// parseQuery 解析 URI 中的 query 参数（忽略 fragment 和非法编码）
+func parseQuery(uri string) url.Values {
+	i := strings.IndexByte(uri, '?')
+	if i < 0 {
+		return url.Values{}
+	}
+	raw := uri[i+1:]
+	if j := strings.IndexByte(raw, '#'); j >= 0 {
+		raw = raw[:j]
+	}
+	query, _ := url.ParseQuery(raw) // 出错时返回已成功解析的部分
+	return query
+}
+
 // matchHost 匹配 host 模式
 // 支持精确匹配和通配符（*.example.com）
 func matchHost(pattern, host string) bool {
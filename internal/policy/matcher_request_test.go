@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestMatchRequest_Matchers 测试 header、query 和来源网段匹配
+func TestMatchRequest_Matchers(t *testing.T) {
+	policies := []config.RoutePolicy{
+		{Name: "default", Host: "api.example.com"},
+		{Name: "curl", Host: "api.example.com", MatchHeaders: map[string]string{"User-Agent": "~^curl/"}},
+		{Name: "tenant", Host: "api.example.com", MatchHeaders: map[string]string{"X-Tenant": "acme"}},
+		{Name: "token", Host: "api.example.com", MatchQuery: map[string]string{"access_token": "*"}},
+		{Name: "office", Host: "api.example.com", SourceCIDRs: []string{"10.0.0.0/8", "192.168.1.5"}},
+	}
+
+	tests := []struct {
+		name     string
+		req      Request
+		expected string
+	}{
+		{
+			name:     "No extra attributes",
+			req:      Request{Host: "api.example.com", URI: "/", ClientIP: "203.0.113.1"},
+			expected: "default",
+		},
+		{
+			name:     "User-Agent regex",
+			req:      Request{Host: "api.example.com", URI: "/", Headers: map[string]string{"user-agent": "curl/8.4.0"}},
+			expected: "curl",
+		},
+		{
+			name:     "Header exact value",
+			req:      Request{Host: "api.example.com", URI: "/", Headers: map[string]string{"x-tenant": "acme"}},
+			expected: "tenant",
+		},
+		{
+			name:     "Header value mismatch",
+			req:      Request{Host: "api.example.com", URI: "/", Headers: map[string]string{"x-tenant": "other"}},
+			expected: "default",
+		},
+		{
+			name:     "Query parameter present",
+			req:      Request{Host: "api.example.com", URI: "/data?format=json&access_token="},
+			expected: "token",
+		},
+		{
+			name:     "Query parameter in fragment is ignored",
+			req:      Request{Host: "api.example.com", URI: "/data#access_token=x"},
+			expected: "default",
+		},
+		{
+			name:     "Client in office CIDR",
+			req:      Request{Host: "api.example.com", URI: "/", ClientIP: "10.20.30.40"},
+			expected: "office",
+		},
+		{
+			name:     "Client single IP",
+			req:      Request{Host: "api.example.com", URI: "/", ClientIP: "192.168.1.5"},
+			expected: "office",
+		},
+		{
+			name:     "Client outside CIDRs",
+			req:      Request{Host: "api.example.com", URI: "/", ClientIP: "192.168.1.6"},
+			expected: "default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := MatchRequest(policies, &tt.req)
+			if p == nil || p.Name != tt.expected {
+				t.Errorf("Expected %q, got %v", tt.expected, p)
+			}
+		})
+	}
+}
+
+// TestMatchRequest_Specificity 测试同优先级时附加条件更多的策略优先
+func TestMatchRequest_Specificity(t *testing.T) {
+	policies := []config.RoutePolicy{
+		{Name: "api", PathPrefix: "/api"},
+		{Name: "api-tenant", PathPrefix: "/api", MatchHeaders: map[string]string{"X-Tenant": "*"}},
+		{Name: "api-tenant-office", PathPrefix: "/api", MatchHeaders: map[string]string{"X-Tenant": "*"}, SourceCIDRs: []string{"10.0.0.0/8"}},
+		{Name: "urgent", Priority: 10, PathPrefix: "/api", MatchQuery: map[string]string{"urgent": "true"}},
+	}
+
+	req := &Request{URI: "/api/x", ClientIP: "10.1.1.1", Headers: map[string]string{"x-tenant": "acme"}}
+	if p := MatchRequest(policies, req); p == nil || p.Name != "api-tenant-office" {
+		t.Errorf("Expected most specific policy, got %v", p)
+	}
+
+	req.ClientIP = "172.16.0.1"
+	if p := MatchRequest(policies, req); p == nil || p.Name != "api-tenant" {
+		t.Errorf("Expected api-tenant, got %v", p)
+	}
+
+	// 优先级仍然优先于具体程度
+	req.URI = "/api/x?urgent=false&urgent=true"
+	if p := MatchRequest(policies, req); p == nil || p.Name != "urgent" {
+		t.Errorf("Expected higher priority policy, got %v", p)
+	}
+}
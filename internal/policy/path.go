@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// NormalizePath 规范化请求 URI 用于策略匹配
+// 去除 query/fragment，百分号解码（仅一次），合并重复的 /，解析 . 和 ..
+// 保留末尾的 /，结果总是以 / 开头
+func NormalizePath(uri string) string {
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+
+	// 解码失败（非法的 % 序列）时使用原始路径，避免因解码错误跳过匹配
+	if decoded, err := url.PathUnescape(uri); err == nil {
+		uri = decoded
+	}
+
+	if uri == "" {
+		return "/"
+	}
+
+	trailingSlash := strings.HasSuffix(uri, "/")
+	cleaned := path.Clean("/" + uri)
+	if trailingSlash && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
+
+// matchPath 检查规范化后的路径是否满足策略的路径条件
+func matchPath(p *config.RoutePolicy, normalized string) bool {
+	switch {
+	case p.PathExact != "":
+		return normalized == p.PathExact
+
+	case p.PathGlob != "":
+		return matchGlob(p.PathGlob, normalized)
+
+	case p.PathRegex != "":
+		re, err := p.PathRegexp()
+		if err != nil {
+			return false // 无效正则不匹配任何路径（已在 validator 中检查）
+		}
+		return re.MatchString(normalized)
+
+	case p.PathPrefix != "":
+		return matchPathPrefix(p.PathPrefix, normalized)
+	}
+
+	return true
+}
+
+// matchPathPrefix 按路径段匹配前缀：/api 匹配 /api 和 /api/x，但不匹配 /apiary
+func matchPathPrefix(prefix, normalized string) bool {
+	if !strings.HasPrefix(normalized, prefix) {
+		return false
+	}
+	if len(normalized) == len(prefix) || strings.HasSuffix(prefix, "/") {
+		return true
+	}
+	return normalized[len(prefix)] == '/'
+}
+
+// matchGlob 匹配路径通配模式
+// * / ? / [...] 在单个路径段内匹配（path.Match 语法），** 匹配零个或多个路径段
+func matchGlob(pattern, normalized string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(normalized, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// 合并连续的 **
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range segments {
+				if matchSegments(pattern, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+
+	return len(segments) == 0
+}
@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestNormalizePath 测试路径规范化
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"/api/users", "/api/users"},
+		{"/api/users?id=1", "/api/users"},
+		{"/api/users#frag", "/api/users"},
+		{"", "/"},
+		{"/", "/"},
+		{"//api///users", "/api/users"},
+		{"/api/./users", "/api/users"},
+		{"/public/../admin", "/admin"},
+		{"/public/%2e%2e/admin", "/admin"},
+		{"/public/%2E%2E%2Fadmin", "/admin"},
+		{"/../../etc/passwd", "/etc/passwd"},
+		{"/api/users/", "/api/users/"},
+		{"/api%20docs", "/api docs"},
+		{"/bad%zzescape", "/bad%zzescape"},
+		{"relative/path", "/relative/path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			if got := NormalizePath(tt.uri); got != tt.want {
+				t.Errorf("NormalizePath(%q) = %q, want %q", tt.uri, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMatchPath 测试各种路径匹配方式
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy config.RoutePolicy
+		uri    string
+		want   bool
+	}{
+		{"Prefix matches itself", config.RoutePolicy{PathPrefix: "/api"}, "/api", true},
+		{"Prefix matches subpath", config.RoutePolicy{PathPrefix: "/api"}, "/api/v1", true},
+		{"Prefix is segment-aware", config.RoutePolicy{PathPrefix: "/api"}, "/apiary", false},
+		{"Prefix with trailing slash", config.RoutePolicy{PathPrefix: "/api/"}, "/api/v1", true},
+		{"Root prefix", config.RoutePolicy{PathPrefix: "/"}, "/anything", true},
+		{"Prefix ignores query", config.RoutePolicy{PathPrefix: "/api"}, "/api?x=/admin", true},
+		{"Encoded traversal escapes prefix", config.RoutePolicy{PathPrefix: "/public"}, "/public/%2e%2e/admin", false},
+		{"Encoded traversal reaches admin", config.RoutePolicy{PathPrefix: "/admin"}, "/public/%2e%2e/admin/users", true},
+		{"Exact match", config.RoutePolicy{PathExact: "/login"}, "/login?next=/", true},
+		{"Exact no subpath", config.RoutePolicy{PathExact: "/login"}, "/login/x", false},
+		{"Exact after collapsing slashes", config.RoutePolicy{PathExact: "/a/b"}, "//a//b", true},
+		{"Glob single segment", config.RoutePolicy{PathGlob: "/users/*/profile"}, "/users/42/profile", true},
+		{"Glob star does not cross segments", config.RoutePolicy{PathGlob: "/users/*/profile"}, "/users/4/2/profile", false},
+		{"Glob double star", config.RoutePolicy{PathGlob: "/static/**"}, "/static/css/site.css", true},
+		{"Glob double star matches base", config.RoutePolicy{PathGlob: "/static/**"}, "/static", true},
+		{"Glob double star in middle", config.RoutePolicy{PathGlob: "/api/**/export"}, "/api/v1/reports/export", true},
+		{"Glob double star zero segments", config.RoutePolicy{PathGlob: "/api/**/export"}, "/api/export", true},
+		{"Glob extension", config.RoutePolicy{PathGlob: "/**/*.json"}, "/a/b/c.json", true},
+		{"Glob extension miss", config.RoutePolicy{PathGlob: "/**/*.json"}, "/a/b/c.xml", false},
+		{"Regex full match", config.RoutePolicy{PathRegex: `/v[0-9]+/items`}, "/v2/items", true},
+		{"Regex is anchored", config.RoutePolicy{PathRegex: `/v[0-9]+/items`}, "/x/v2/items/1", false},
+		{"Regex sees decoded path", config.RoutePolicy{PathRegex: `/files/[^/]+`}, "/files/a%2Fb", false},
+		{"No path condition", config.RoutePolicy{}, "/anything", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchPath(&tt.policy, NormalizePath(tt.uri)); got != tt.want {
+				t.Errorf("matchPath(%+v, %q) = %v, want %v", tt.policy, tt.uri, got, tt.want)
+			}
+		})
+	}
+}
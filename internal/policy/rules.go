@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// DefaultRule 未匹配任何规则、由策略默认效果决定时的规则名称
+const DefaultRule = "default"
+
+// RuleDecision 策略 allow/deny 规则的评估结果
+type RuleDecision struct {
+	Allow bool
+	Rule  string // 决定结果的规则（规则名称，未命名时为 "rule[i]"；使用策略默认效果时为 DefaultRule）
+}
+
+// EvaluateRules 按顺序评估策略的 allow/deny 规则，第一条匹配的规则决定结果
+// 没有规则匹配时使用策略的 effect（默认 allow）
+// 规则条件求值出错时按拒绝处理，并返回错误
+func EvaluateRules(policy *config.RoutePolicy, result *auth.AuthResult, req *Request) (RuleDecision, error) {
+	if policy == nil {
+		return RuleDecision{Allow: true, Rule: DefaultRule}, nil
+	}
+
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		matched, err := matchRule(rule, result, req)
+		if err != nil {
+			return RuleDecision{Allow: false, Rule: ruleName(rule, i)}, err
+		}
+		if matched {
+			return RuleDecision{Allow: rule.Effect == "allow", Rule: ruleName(rule, i)}, nil
+		}
+	}
+
+	return RuleDecision{Allow: policy.Effect != "deny", Rule: DefaultRule}, nil
+}
+
+// matchRule 检查规则的所有非空条件是否满足
+func matchRule(rule *config.PolicyRule, result *auth.AuthResult, req *Request) (bool, error) {
+	if len(rule.Users) > 0 && !contains(rule.Users, result.User) {
+		return false, nil
+	}
+	if len(rule.Roles) > 0 && !containsAny(result.Roles, rule.Roles) {
+		return false, nil
+	}
+	if len(rule.Groups) > 0 && !containsAny(result.Groups, rule.Groups) {
+		return false, nil
+	}
+	if len(rule.AuthMethods) > 0 && !contains(rule.AuthMethods, result.Method) {
+		return false, nil
+	}
+	if len(rule.Names) > 0 && !contains(rule.Names, result.Name) {
+		return false, nil
+	}
+	if len(rule.Methods) > 0 && !containsFold(rule.Methods, req.Method) {
+		return false, nil
+	}
+
+	if rule.Condition != "" {
+		prog, err := rule.ConditionProgram()
+		if err != nil {
+			return false, err
+		}
+		return prog.Eval(conditionVars(result, req))
+	}
+
+	return true, nil
+}
+
+func ruleName(rule *config.PolicyRule, index int) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return fmt.Sprintf("rule[%d]", index)
+}
+
+// containsAny 检查 have 中是否包含 want 的任意一个元素
+func containsAny(have, want []string) bool {
+	for _, r := range want {
+		if contains(have, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(slice []string, item string) bool {
+	for _, s := range slice {
+		if strings.EqualFold(s, item) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestEvaluateRules 测试 allow/deny 规则按顺序评估并报告决定结果的规则
+func TestEvaluateRules(t *testing.T) {
+	adminOnly := &config.RoutePolicy{
+		Name:   "admin",
+		Effect: "deny",
+		Rules: []config.PolicyRule{
+			{Name: "block-writes-from-keys", Effect: "deny", AuthMethods: []string{"apikey"}, Methods: []string{"POST", "DELETE"}},
+			{Name: "ops", Effect: "allow", Roles: []string{"ops"}},
+			{Effect: "allow", Users: []string{"alice"}, Condition: `request.method == "GET"`},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		policy    *config.RoutePolicy
+		result    *auth.AuthResult
+		method    string
+		wantAllow bool
+		wantRule  string
+	}{
+		{"Allowed by role", adminOnly, &auth.AuthResult{Method: "basic", User: "bob", Roles: []string{"ops"}}, "POST", true, "ops"},
+		{"Earlier deny rule wins", adminOnly, &auth.AuthResult{Method: "apikey", Name: "ci", Roles: []string{"ops"}}, "delete", false, "block-writes-from-keys"},
+		{"Unnamed rule reported by index", adminOnly, &auth.AuthResult{Method: "jwt", User: "alice"}, "GET", true, "rule[2]"},
+		{"Rule condition not met falls to default", adminOnly, &auth.AuthResult{Method: "jwt", User: "alice"}, "PUT", false, DefaultRule},
+		{"Default deny", adminOnly, &auth.AuthResult{Method: "basic", User: "eve", Roles: []string{"user"}}, "GET", false, DefaultRule},
+		{"Default allow without rules", &config.RoutePolicy{Name: "open"}, &auth.AuthResult{Method: "basic"}, "GET", true, DefaultRule},
+		{"Deny effect without rules", &config.RoutePolicy{Name: "closed", Effect: "deny"}, &auth.AuthResult{Method: "basic"}, "GET", false, DefaultRule},
+		{"No policy", nil, &auth.AuthResult{Method: "basic"}, "GET", true, DefaultRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := EvaluateRules(tt.policy, tt.result, &Request{Method: tt.method})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if decision.Allow != tt.wantAllow || decision.Rule != tt.wantRule {
+				t.Errorf("EvaluateRules() = %+v, want allow=%v rule=%q", decision, tt.wantAllow, tt.wantRule)
+			}
+		})
+	}
+}
+
+// TestEvaluateRules_ConditionError 测试规则条件求值出错时按拒绝处理
+func TestEvaluateRules_ConditionError(t *testing.T) {
+	p := &config.RoutePolicy{
+		Name:  "broken",
+		Rules: []config.PolicyRule{{Name: "bad", Effect: "allow", Condition: `auth.user`}},
+	}
+
+	decision, err := EvaluateRules(p, &auth.AuthResult{Method: "basic", User: "alice"}, &Request{})
+	if err == nil {
+		t.Fatal("Expected evaluation error, got nil")
+	}
+	if decision.Allow || decision.Rule != "bad" {
+		t.Errorf("Expected deny by rule 'bad', got %+v", decision)
+	}
+}
@@ -0,0 +1,43 @@
+package policy
+
+import (
+	"time"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// InSchedule 检查请求时间是否在策略的访问时间窗口内
+// 策略没有配置 schedule 时返回 true；配置无效时按窗口外处理
+func InSchedule(policy *config.RoutePolicy, now time.Time) bool {
+	if policy == nil {
+		return true
+	}
+	return scheduleActive(policy.Schedule, now)
+}
+
+// CredentialInSchedule 检查请求时间是否在凭证（Basic Auth / Bearer Token / API Key）的访问时间窗口内
+// 凭证没有配置 schedule 时返回 true
+func CredentialInSchedule(result *auth.AuthResult, store *auth.AuthStore, now time.Time) bool {
+	var sched *config.ScheduleConfig
+	switch result.Method {
+	case "basic":
+		sched = store.BasicByName[result.Name].Schedule
+	case "bearer":
+		sched = store.BearerByName[result.Name].Schedule
+	case "apikey":
+		sched = store.APIKeyByName[result.Name].Schedule
+	}
+	return scheduleActive(sched, now)
+}
+
+func scheduleActive(sched *config.ScheduleConfig, now time.Time) bool {
+	if sched == nil {
+		return true
+	}
+	compiled, err := sched.Compiled()
+	if err != nil {
+		return false // 无效配置按窗口外处理（已在 validator 中检查）
+	}
+	return compiled.Active(now)
+}
@@ -0,0 +1,406 @@
+// Package quota 实现凭证的用量配额（按自然日 / 自然月计数，持久化到本地 bbolt 数据库）
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// usageBucket 数据库中保存用量记录的 bucket 名称
+var usageBucket = []byte("usage")
+
+// openTimeout 打开数据库时等待文件锁的最长时间（CLI 和服务器可能同时访问）
+const openTimeout = 5 * time.Second
+
+// Limits 凭证的配额限制（0 表示不限制）
+type Limits struct {
+	PerDay   int64
+	PerMonth int64
+}
+
+// Usage 凭证在当前周期的用量
+type Usage struct {
+	Key        string `json:"key"`         // 配额计数 key（如 "apikey:partner"）
+	Day        string `json:"day"`         // 日周期（YYYY-MM-DD）
+	DayCount   int64  `json:"day_count"`   // 当日请求数
+	Month      string `json:"month"`       // 月周期（YYYY-MM）
+	MonthCount int64  `json:"month_count"` // 当月请求数
+}
+
+// Result 一次 Consume 的结果
+type Result struct {
+	Allowed        bool
+	Exceeded       string        // 超出的周期: "day" / "month"（允许时为空）
+	RemainingDay   int64         // 当日剩余次数（-1 表示不限制）
+	RemainingMonth int64         // 当月剩余次数（-1 表示不限制）
+	Reset          time.Duration // 超出配额时，距离该周期重置的时间
+}
+
+// Remaining 返回所有已配置周期中最小的剩余次数（-1 表示不限制）
+func (r Result) Remaining() int64 {
+	switch {
+	case r.RemainingDay < 0:
+		return r.RemainingMonth
+	case r.RemainingMonth < 0:
+		return r.RemainingDay
+	default:
+		return min(r.RemainingDay, r.RemainingMonth)
+	}
+}
+
+// Key 返回凭证的配额计数 key（如 "apikey:partner"）
+func Key(method, name string) string {
+	return method + ":" + name
+}
+
+// LimitsFromConfig 收集配置了配额的 Bearer Token / API Key（按 Key 索引）
+func LimitsFromConfig(cfg *config.Config) map[string]Limits {
+	limits := make(map[string]Limits)
+	for _, t := range cfg.BearerTokens {
+		if t.Quota != nil {
+			limits[Key("bearer", t.Name)] = Limits{PerDay: t.Quota.PerDay, PerMonth: t.Quota.PerMonth}
+		}
+	}
+	for _, k := range cfg.APIKeys {
+		if k.Quota != nil {
+			limits[Key("apikey", k.Name)] = Limits{PerDay: k.Quota.PerDay, PerMonth: k.Quota.PerMonth}
+		}
+	}
+	return limits
+}
+
+// Store 用量计数存储
+// 计数在内存中累加，每隔 flushInterval 合并写入数据库并重新读取（可以看到 CLI 的重置操作）。
+// 数据库只在写入期间打开，因此 CLI 可以在服务器运行时查看和重置用量。
+type Store struct {
+	path string
+	loc  *time.Location
+	now  func() time.Time
+
+	mu      sync.Mutex
+	usage   map[string]*Usage // 当前用量（数据库中的值 + 尚未写入的增量）
+	pending map[string]int64  // 尚未写入数据库的请求数
+
+	flushMu sync.Mutex  // 串行化写入
+	onError func(error) // 后台写入失败时调用（可选）
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open 打开（必要时创建）配额数据库并加载当前用量
+// onError 在后台写入失败时调用（可以为 nil），失败的增量会在下一次写入时重试
+func Open(path string, loc *time.Location, flushInterval time.Duration, onError func(error)) (*Store, error) {
+	return open(path, loc, flushInterval, onError, time.Now)
+}
+
+func open(path string, loc *time.Location, flushInterval time.Duration, onError func(error), now func() time.Time) (*Store, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	s := &Store{
+		path:    path,
+		loc:     loc,
+		now:     now,
+		usage:   make(map[string]*Usage),
+		pending: make(map[string]int64),
+		onError: onError,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+
+	go s.flushLoop(flushInterval)
+	return s, nil
+}
+
+// Consume 检查配额并计入一次请求（超出配额的请求不计数）
+func (s *Store) Consume(key string, limits Limits) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().In(s.loc)
+	u, exists := s.usage[key]
+	if !exists {
+		u = &Usage{Key: key}
+		s.usage[key] = u
+	}
+	u.roll(now)
+
+	r := Result{RemainingDay: -1, RemainingMonth: -1}
+	switch {
+	case limits.PerDay > 0 && u.DayCount >= limits.PerDay:
+		r.Exceeded = "day"
+		r.Reset = nextDay(now).Sub(now)
+	case limits.PerMonth > 0 && u.MonthCount >= limits.PerMonth:
+		r.Exceeded = "month"
+		r.Reset = nextMonth(now).Sub(now)
+	default:
+		r.Allowed = true
+		u.DayCount++
+		u.MonthCount++
+		s.pending[key]++
+	}
+
+	if limits.PerDay > 0 {
+		r.RemainingDay = max(limits.PerDay-u.DayCount, 0)
+	}
+	if limits.PerMonth > 0 {
+		r.RemainingMonth = max(limits.PerMonth-u.MonthCount, 0)
+	}
+	return r
+}
+
+// Flush 将内存中的增量写入数据库，并用数据库中的值刷新内存用量
+func (s *Store) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]int64)
+	s.mu.Unlock()
+
+	stored, err := s.merge(pending)
+	if err != nil {
+		// 写入失败：增量放回，下次重试
+		s.mu.Lock()
+		for key, n := range pending {
+			s.pending[key] += n
+		}
+		s.mu.Unlock()
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 合并期间产生的新增量已经计入旧的内存用量，需要叠加到数据库的值上
+	for key, n := range s.pending {
+		u, exists := stored[key]
+		if !exists {
+			u = &Usage{Key: key}
+			stored[key] = u
+		}
+		u.add(s.now().In(s.loc), n)
+	}
+	s.usage = stored
+	return nil
+}
+
+// merge 在一个事务中写入增量并读取全部用量
+func (s *Store) merge(pending map[string]int64) (map[string]*Usage, error) {
+	db, err := openDB(s.path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	now := s.now().In(s.loc)
+	stored := make(map[string]*Usage)
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(usageBucket)
+		if err != nil {
+			return err
+		}
+		for key, n := range pending {
+			u, err := getUsage(b, key)
+			if err != nil {
+				return err
+			}
+			u.add(now, n)
+			if err := putUsage(b, u); err != nil {
+				return err
+			}
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var u Usage
+			if err := json.Unmarshal(v, &u); err != nil {
+				return fmt.Errorf("quota: corrupt record %q: %w", k, err)
+			}
+			u.roll(now)
+			stored[u.Key] = &u
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// flushLoop 定期写入数据库
+func (s *Store) flushLoop(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil && s.onError != nil {
+				s.onError(err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close 停止后台任务并写入剩余的增量
+func (s *Store) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.Flush()
+}
+
+// List 读取数据库中的全部用量（按 key 排序，周期已按当前时间滚动）
+// 服务器尚未写入的增量（最多 flush_interval）不包含在内
+func List(path string, loc *time.Location) ([]Usage, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	db, err := openDB(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	now := time.Now().In(loc)
+	var usage []Usage
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usageBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var u Usage
+			if err := json.Unmarshal(v, &u); err != nil {
+				return fmt.Errorf("quota: corrupt record %q: %w", k, err)
+			}
+			u.roll(now)
+			usage = append(usage, u)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Key < usage[j].Key })
+	return usage, nil
+}
+
+// Reset 删除指定 key 的用量（不指定 key 时删除全部），返回删除的记录数
+// 运行中的服务器在下一次写入时看到重置结果
+func Reset(path string, keys ...string) (int, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	db, err := openDB(path, false)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	removed := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usageBucket)
+		if b == nil {
+			return nil
+		}
+		if len(keys) == 0 {
+			removed = b.Stats().KeyN
+			return tx.DeleteBucket(usageBucket)
+		}
+		for _, key := range keys {
+			if b.Get([]byte(key)) == nil {
+				continue
+			}
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// openDB 打开数据库文件（等待其他进程释放文件锁）
+func openDB(path string, readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout, ReadOnly: readOnly})
+	if err != nil {
+		return nil, fmt.Errorf("quota: failed to open %s: %w", path, err)
+	}
+	return db, nil
+}
+
+func getUsage(b *bolt.Bucket, key string) (*Usage, error) {
+	u := &Usage{Key: key}
+	if v := b.Get([]byte(key)); v != nil {
+		if err := json.Unmarshal(v, u); err != nil {
+			return nil, fmt.Errorf("quota: corrupt record %q: %w", key, err)
+		}
+	}
+	return u, nil
+}
+
+func putUsage(b *bolt.Bucket, u *Usage) error {
+	v, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(u.Key), v)
+}
+
+// roll 进入新的日 / 月周期时清零对应计数
+func (u *Usage) roll(now time.Time) {
+	if day := now.Format("2006-01-02"); u.Day != day {
+		u.Day = day
+		u.DayCount = 0
+	}
+	if month := now.Format("2006-01"); u.Month != month {
+		u.Month = month
+		u.MonthCount = 0
+	}
+}
+
+// add 在当前周期计入 n 次请求
+func (u *Usage) add(now time.Time, n int64) {
+	u.roll(now)
+	u.DayCount += n
+	u.MonthCount += n
+}
+
+// nextDay 返回下一个自然日的开始时间
+func nextDay(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+}
+
+// nextMonth 返回下一个自然月的开始时间
+func nextMonth(now time.Time) time.Time {
+	y, m, _ := now.Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, now.Location())
+}
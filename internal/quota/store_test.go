@@ -0,0 +1,159 @@
+package quota
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func openTestStore(t *testing.T, path string, clock *fakeClock) *Store {
+	t.Helper()
+	s, err := open(path, time.UTC, time.Hour, nil, clock.now)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return s
+}
+
+// TestStore_Consume 测试日 / 月配额计数、拒绝和周期重置
+func TestStore_Consume(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)}
+	s := openTestStore(t, filepath.Join(t.TempDir(), "quota.db"), clock)
+	defer s.Close()
+
+	limits := Limits{PerDay: 2, PerMonth: 3}
+	for i := 0; i < 2; i++ {
+		if r := s.Consume("apikey:k", limits); !r.Allowed {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+
+	r := s.Consume("apikey:k", limits)
+	if r.Allowed || r.Exceeded != "day" || r.Reset != time.Hour {
+		t.Fatalf("expected daily quota exceeded with 1h reset, got %+v", r)
+	}
+	if r.RemainingDay != 0 || r.RemainingMonth != 1 || r.Remaining() != 0 {
+		t.Errorf("unexpected remaining: %+v", r)
+	}
+
+	// 其他 key 独立计数，未配置的周期不限制
+	if r := s.Consume("bearer:t", Limits{PerMonth: 5}); !r.Allowed || r.RemainingDay != -1 || r.Remaining() != 4 {
+		t.Errorf("unexpected result for other key: %+v", r)
+	}
+
+	// 新的月份：日 / 月计数都清零
+	clock.advance(2 * time.Hour)
+	if r := s.Consume("apikey:k", limits); !r.Allowed || r.RemainingDay != 1 || r.RemainingMonth != 2 {
+		t.Errorf("expected counters reset in new month, got %+v", r)
+	}
+}
+
+// TestStore_MonthlyLimit 测试跨日累计的月配额
+func TestStore_MonthlyLimit(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)}
+	s := openTestStore(t, filepath.Join(t.TempDir(), "quota.db"), clock)
+	defer s.Close()
+
+	limits := Limits{PerDay: 2, PerMonth: 3}
+	s.Consume("k", limits)
+	s.Consume("k", limits)
+	clock.advance(24 * time.Hour)
+	s.Consume("k", limits)
+
+	r := s.Consume("k", limits)
+	if r.Allowed || r.Exceeded != "month" || r.Reset != 12*time.Hour {
+		t.Errorf("expected monthly quota exceeded with 12h reset, got %+v", r)
+	}
+}
+
+// TestStore_Persistence 测试计数在重新打开后保留，并能看到外部重置
+func TestStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.db")
+	clock := &fakeClock{t: time.Date(2026, 5, 10, 8, 0, 0, 0, time.UTC)}
+	limits := Limits{PerDay: 10}
+
+	s := openTestStore(t, path, clock)
+	for i := 0; i < 3; i++ {
+		s.Consume("apikey:a", limits)
+	}
+	s.Consume("apikey:b", limits)
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// 重新打开（模拟重启）
+	s = openTestStore(t, path, clock)
+	defer s.Close()
+	if r := s.Consume("apikey:a", limits); r.RemainingDay != 6 {
+		t.Fatalf("expected counters to survive reopen, got %+v", r)
+	}
+
+	// 两个实例共用同一个文件时（如热重载期间），增量合并而不是覆盖
+	other := openTestStore(t, path, clock)
+	other.Consume("apikey:a", limits)
+	if err := other.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if r := s.Consume("apikey:a", limits); r.RemainingDay != 4 {
+		t.Errorf("expected merged counters, got %+v", r)
+	}
+
+	// 外部重置（CLI）在下一次写入后生效
+	if err := s.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if n, err := Reset(path, "apikey:a", "apikey:missing"); err != nil || n != 1 {
+		t.Fatalf("Reset() = %d, %v", n, err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if r := s.Consume("apikey:a", limits); r.RemainingDay != 9 {
+		t.Errorf("expected reset counters, got %+v", r)
+	}
+}
+
+// TestListAndReset 测试 CLI 使用的 List / Reset
+func TestListAndReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.db")
+
+	// 数据库不存在时返回空结果
+	if usage, err := List(path, time.UTC); err != nil || len(usage) != 0 {
+		t.Fatalf("List() on missing file = %v, %v", usage, err)
+	}
+
+	s, err := Open(path, time.UTC, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	s.Consume("bearer:t", Limits{PerDay: 5})
+	s.Consume("apikey:k", Limits{PerDay: 5})
+	s.Consume("apikey:k", Limits{PerDay: 5})
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	usage, err := List(path, time.UTC)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(usage) != 2 || usage[0].Key != "apikey:k" || usage[0].DayCount != 2 || usage[1].MonthCount != 1 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	if n, err := Reset(path); err != nil || n != 2 {
+		t.Fatalf("Reset() all = %d, %v", n, err)
+	}
+	if usage, err := List(path, time.UTC); err != nil || len(usage) != 0 {
+		t.Errorf("expected empty usage after reset, got %v, %v", usage, err)
+	}
+}
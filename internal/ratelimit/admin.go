@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Status 单个 key 的限流状态（管理 API 使用）
+type Status struct {
+	Attempts   int           // 时间窗口内的尝试 / 失败次数（backoff 模式为失败次数，向上取整）
+	Banned     bool          // 是否处于封禁期（backoff 模式为是否仍需等待）
+	RetryAfter time.Duration // 剩余封禁 / 等待时间
+}
+
+// Manager 支持管理操作的后端（/admin/ratelimit）
+type Manager interface {
+	// Lookup 返回 key 的当前状态
+	Lookup(key string) (Status, error)
+	// ListBans 返回当前所有处于封禁期的 key
+	ListBans() ([]Ban, error)
+	// Ban 手动封禁 key d（不受配置的封禁时长限制）
+	Ban(key string, d time.Duration) error
+	// Unban 清除 key 的尝试记录和封禁（与 Backend.Reset 不同，失败时返回错误）
+	Unban(key string) error
+	// Clear 清除所有记录和封禁
+	Clear() error
+}
+
+var (
+	_ Manager = (*Limiter)(nil)
+	_ Manager = (*Backoff)(nil)
+	_ Manager = (*RedisLimiter)(nil)
+)
+
+// Lookup 返回 key 的当前状态
+func (l *Limiter) Lookup(key string) (Status, error) {
+	attempts, banned, retryAfter := l.GetStats(key)
+	return Status{Attempts: attempts, Banned: banned, RetryAfter: retryAfter}, nil
+}
+
+// ListBans 返回当前所有处于封禁期的 key
+func (l *Limiter) ListBans() ([]Ban, error) {
+	return l.Bans(), nil
+}
+
+// Ban 手动封禁 key d
+func (l *Limiter) Ban(key string, d time.Duration) error {
+	l.ban(key, time.Now().Add(d).UnixNano())
+	return nil
+}
+
+// Unban 清除 key 的尝试记录和封禁
+func (l *Limiter) Unban(key string) error {
+	l.Reset(key)
+	return nil
+}
+
+// Clear 清除所有记录和封禁
+func (l *Limiter) Clear() error {
+	l.clear()
+	return nil
+}
+
+// Lookup 返回 key 的失败次数和剩余等待时间
+func (b *Backoff) Lookup(key string) (Status, error) {
+	now := b.now().UnixNano()
+	s := b.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, exists := s.records[key]
+	if !exists || b.forgotten(rec, now) {
+		return Status{}, nil
+	}
+	status := Status{Attempts: int(math.Ceil(rec.curr))}
+	if now < rec.bannedUntil {
+		status.Banned = true
+		status.RetryAfter = time.Duration(rec.bannedUntil - now)
+	}
+	return status, nil
+}
+
+// ListBans 返回当前仍需等待的 key
+func (b *Backoff) ListBans() ([]Ban, error) {
+	return b.Bans(), nil
+}
+
+// Ban 手动要求 key 等待 d（失败次数不变）
+func (b *Backoff) Ban(key string, d time.Duration) error {
+	b.ban(key, b.now().Add(d).UnixNano())
+	return nil
+}
+
+// Unban 清除 key 的失败记录和等待时间
+func (b *Backoff) Unban(key string) error {
+	b.Reset(key)
+	return nil
+}
+
+// Clear 清除所有记录
+func (b *Backoff) Clear() error {
+	b.clear()
+	return nil
+}
+
+// ban 将 key 的封禁截止时间设置为 until（UnixNano）
+func (t *table) ban(key string, until int64) {
+	s := t.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.getOrCreate(key, time.Now().UnixNano())
+	rec.bannedUntil = until
+}
+
+// clear 清除所有记录
+func (t *table) clear() {
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.mu.Lock()
+		s.records = make(map[string]*record)
+		s.head, s.tail = nil, nil
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// testManager 对各后端执行相同的管理操作检查
+func testManager(t *testing.T, m Manager, allow func(key string) bool) {
+	t.Helper()
+
+	// 手动封禁不受配置的封禁时长限制
+	if err := m.Ban("10.0.0.1", time.Hour); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	status, err := m.Lookup("10.0.0.1")
+	if err != nil || !status.Banned || status.RetryAfter <= 59*time.Minute {
+		t.Fatalf("expected 1h ban, got %+v, %v", status, err)
+	}
+	if allow("10.0.0.1") {
+		t.Error("manually banned key should be denied")
+	}
+
+	allow("10.0.0.2")
+	if status, _ := m.Lookup("10.0.0.2"); status.Banned || status.Attempts != 1 {
+		t.Errorf("expected 1 attempt without ban, got %+v", status)
+	}
+
+	bans, err := m.ListBans()
+	if err != nil || len(bans) != 1 || bans[0].Key != "10.0.0.1" {
+		t.Fatalf("expected one ban for 10.0.0.1, got %v, %v", bans, err)
+	}
+
+	if err := m.Unban("10.0.0.1"); err != nil {
+		t.Fatalf("Unban: %v", err)
+	}
+	if status, _ := m.Lookup("10.0.0.1"); status.Banned {
+		t.Errorf("expected no ban after unban, got %+v", status)
+	}
+	if !allow("10.0.0.1") {
+		t.Error("unbanned key should be allowed")
+	}
+	if err := m.Ban("10.0.0.1", time.Hour); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+
+	if err := m.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if bans, _ := m.ListBans(); len(bans) != 0 {
+		t.Errorf("expected no bans after clear, got %v", bans)
+	}
+	if status, _ := m.Lookup("10.0.0.2"); status != (Status{}) {
+		t.Errorf("expected no record after clear, got %+v", status)
+	}
+}
+
+// TestLimiter_Manager 测试内存后端的管理操作
+func TestLimiter_Manager(t *testing.T) {
+	l := NewLimiter(3, time.Minute, time.Minute)
+	defer l.Stop()
+	testManager(t, l, func(key string) bool {
+		allowed, _ := l.Allow(key)
+		return allowed
+	})
+}
+
+// TestRedisLimiter_Manager 测试 Redis 后端的管理操作
+func TestRedisLimiter_Manager(t *testing.T) {
+	mr, client := newTestRedis(t)
+	l := NewRedisLimiter(client, 3, time.Minute, time.Minute, RedisOptions{Prefix: "test:global:"})
+	defer l.Stop()
+
+	// 其他限流器的 key 不受影响
+	mr.Set("test:policy:login:ban:10.0.0.9", "1")
+
+	testManager(t, l, func(key string) bool {
+		allowed, _ := l.Allow(key)
+		return allowed
+	})
+	if !mr.Exists("test:policy:login:ban:10.0.0.9") {
+		t.Error("Clear should only delete keys of its own limiter")
+	}
+
+	// 策略名称是另一个策略名称的前缀时（login 和 login:admin），清除其中一个不影响另一个
+	login := NewRedisLimiter(client, 3, time.Minute, time.Minute, RedisOptions{Prefix: "test:policy:login:"})
+	defer login.Stop()
+	mr.Set("test:policy:login:ban:10.0.0.8", "1")
+	mr.Set("test:policy:login:admin:attempts:10.0.0.9", "1")
+	mr.Set("test:policy:login:admin:ban:10.0.0.9", "1")
+	if err := login.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("test:policy:login:ban:10.0.0.8") {
+		t.Error("Clear should delete keys of its own limiter")
+	}
+	if !mr.Exists("test:policy:login:admin:attempts:10.0.0.9") || !mr.Exists("test:policy:login:admin:ban:10.0.0.9") {
+		t.Error("Clear should not delete keys of a policy whose name starts with its own")
+	}
+
+	// Redis 不可用时返回错误，而不是像 Reset 那样静默失败
+	mr.Close()
+	if err := l.Unban("10.0.0.1"); err == nil {
+		t.Error("expected Unban to fail when Redis is unavailable")
+	}
+}
+
+// TestBackoff_Manager 测试 backoff 后端的管理操作
+func TestBackoff_Manager(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newTestBackoff(clock)
+	defer b.Stop()
+
+	if err := b.Ban("k", time.Hour); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if status, _ := b.Lookup("k"); !status.Banned || status.RetryAfter != time.Hour {
+		t.Errorf("expected 1h wait, got %+v", status)
+	}
+	b.RecordFailure("other")
+	if status, _ := b.Lookup("other"); status.Attempts != 1 || status.RetryAfter != time.Second {
+		t.Errorf("expected 1 failure with 1s wait, got %+v", status)
+	}
+	if err := b.Clear(); err != nil || b.len() != 0 {
+		t.Errorf("expected empty limiter after clear, got %d records, %v", b.len(), err)
+	}
+}
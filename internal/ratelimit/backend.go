@@ -0,0 +1,40 @@
+package ratelimit
+
+import "time"
+
+// Backend 滑动窗口计数和封禁的存储后端
+// 内存后端（Limiter）只在单个进程内生效；Redis 后端（RedisLimiter）在多个副本之间共享计数和封禁
+type Backend interface {
+	// Allow 记录一次尝试，时间窗口内的尝试次数超过上限时开始封禁
+	Allow(key string) (bool, time.Duration)
+	// Banned 检查 key 是否处于封禁期（不记录尝试）
+	Banned(key string) (bool, time.Duration)
+	// RecordFailure 记录一次失败，时间窗口内失败次数达到上限时开始封禁
+	RecordFailure(key string) (bool, time.Duration)
+	// Reset 清除 key 的尝试记录和封禁
+	Reset(key string)
+	// Stop 停止后台任务
+	Stop()
+}
+
+// BanStore 支持导出和恢复封禁的后端（用于 rate_limit.state_file 快照）
+// Redis 后端的状态保存在 Redis 中，不需要快照
+type BanStore interface {
+	Bans() []Ban
+	RestoreBans(bans []Ban) int
+}
+
+// Progressive 按失败次数计算等待时间的后端（rate_limit.mode = "backoff"）
+// Allow 只检查等待时间是否已过，不计数；认证失败由 RecordFailure 计入，认证成功由 Succeed 衰减（而不是 Reset 清除）
+type Progressive interface {
+	Backend
+	Succeed(key string)
+}
+
+var (
+	_ Backend     = (*Limiter)(nil)
+	_ BanStore    = (*Limiter)(nil)
+	_ Backend     = (*RedisLimiter)(nil)
+	_ Progressive = (*Backoff)(nil)
+	_ BanStore    = (*Backoff)(nil)
+)
@@ -0,0 +1,245 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// BackoffOptions 渐进延迟参数
+type BackoffOptions struct {
+	Base         time.Duration // 第一次失败后要求的等待时间
+	Factor       float64       // 每次失败后等待时间的增长倍数（>= 1）
+	Max          time.Duration // 等待时间上限
+	SuccessDecay float64       // 每次成功抵消的失败次数
+	Forget       time.Duration // 等待结束后多久没有新的失败就清除记录
+	MaxEntries   int           // 最多保存的记录数（<= 0 时使用 DefaultMaxEntries）
+}
+
+// Backoff 渐进延迟限流器（tarpit）：不封禁，每次认证失败后要求客户端等待的时间按指数增长（有上限），
+// 认证成功后逐步衰减。Allow 只检查等待时间是否已过，不计数；失败由 RecordFailure 计入，成功由 Succeed 衰减。
+// 记录中 curr 为失败次数（可能因衰减出现小数），bannedUntil 为等待结束时间
+type Backoff struct {
+	table
+
+	opts BackoffOptions
+	now  func() time.Time
+
+	cleanupInterval time.Duration
+	stopCleanup     chan struct{}
+}
+
+// NewBackoff 创建渐进延迟限流器
+func NewBackoff(opts BackoffOptions) *Backoff {
+	return newBackoff(opts, time.Now, time.Minute*5)
+}
+
+func newBackoff(opts BackoffOptions, now func() time.Time, cleanupInterval time.Duration) *Backoff {
+	if opts.Factor < 1 {
+		opts.Factor = 1
+	}
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute * 5
+	}
+
+	b := &Backoff{
+		table:           newTable(opts.MaxEntries),
+		opts:            opts,
+		now:             now,
+		cleanupInterval: cleanupInterval,
+		stopCleanup:     make(chan struct{}),
+	}
+	go b.startCleanup()
+	return b
+}
+
+// Delay 返回累计 failures 次失败后要求的等待时间：Base × Factor^(failures-1)，不超过 Max
+func (b *Backoff) Delay(failures float64) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	d := float64(b.opts.Base) * math.Pow(b.opts.Factor, failures-1)
+	if d >= float64(b.opts.Max) {
+		return b.opts.Max
+	}
+	return time.Duration(d)
+}
+
+// Allow 检查等待时间是否已过（不计数）
+func (b *Backoff) Allow(key string) (bool, time.Duration) {
+	banned, retryAfter := b.Banned(key)
+	return !banned, retryAfter
+}
+
+// Banned 检查 key 是否仍需等待，返回剩余等待时间
+func (b *Backoff) Banned(key string) (bool, time.Duration) {
+	now := b.now().UnixNano()
+	s := b.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, exists := s.records[key]
+	if !exists {
+		return false, 0
+	}
+	if now < rec.bannedUntil {
+		return true, time.Duration(rec.bannedUntil - now)
+	}
+	if b.forgotten(rec, now) {
+		s.remove(rec)
+	}
+	return false, 0
+}
+
+// RecordFailure 记录一次失败，返回下一次尝试前需要等待的时间
+func (b *Backoff) RecordFailure(key string) (bool, time.Duration) {
+	now := b.now().UnixNano()
+	s := b.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.getOrCreate(key, now)
+	if b.forgotten(rec, now) {
+		rec.restart(now)
+	}
+	rec.curr++
+	delay := b.Delay(rec.curr)
+	rec.bannedUntil = now + int64(delay)
+	return delay > 0, delay
+}
+
+// Succeed 记录一次成功：失败次数减少 SuccessDecay，减到 0 时清除记录
+func (b *Backoff) Succeed(key string) {
+	s := b.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, exists := s.records[key]
+	if !exists {
+		return
+	}
+	rec.curr -= b.opts.SuccessDecay
+	if rec.curr <= 0 {
+		s.remove(rec)
+	}
+}
+
+// Reset 清除 key 的失败记录
+func (b *Backoff) Reset(key string) {
+	s := b.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, exists := s.records[key]; exists {
+		s.remove(rec)
+	}
+}
+
+// forgotten 等待结束后超过 Forget 没有新的失败
+func (b *Backoff) forgotten(rec *record, now int64) bool {
+	return now >= rec.bannedUntil+int64(b.opts.Forget)
+}
+
+// Inherit 从旧的限流器复制失败次数和等待时间（用于配置重载），剩余等待时间不超过新的上限
+func (b *Backoff) Inherit(old *Backoff) {
+	if old == nil || old == b {
+		return
+	}
+
+	now := b.now().UnixNano()
+	maxUntil := now + int64(b.opts.Max)
+	for i := range old.shards {
+		src := &old.shards[i]
+		src.mu.Lock()
+		for rec := src.tail; rec != nil; rec = rec.newer {
+			if old.forgotten(rec, now) {
+				continue
+			}
+			s := b.shardFor(rec.key)
+			s.mu.Lock()
+			inherited := s.getOrCreate(rec.key, now)
+			inherited.curr = rec.curr
+			inherited.bannedUntil = min(rec.bannedUntil, maxUntil)
+			s.mu.Unlock()
+		}
+		src.mu.Unlock()
+	}
+}
+
+// Bans 返回当前仍需等待的 key
+func (b *Backoff) Bans() []Ban {
+	now := b.now().UnixNano()
+	var bans []Ban
+	for i := range b.shards {
+		s := &b.shards[i]
+		s.mu.Lock()
+		for key, rec := range s.records {
+			if now < rec.bannedUntil {
+				bans = append(bans, Ban{Key: key, Until: time.Unix(0, rec.bannedUntil)})
+			}
+		}
+		s.mu.Unlock()
+	}
+	return bans
+}
+
+// RestoreBans 从快照恢复等待时间（快照不包含失败次数，按一次失败计），返回恢复的数量
+func (b *Backoff) RestoreBans(bans []Ban) int {
+	now := b.now().UnixNano()
+	maxUntil := now + int64(b.opts.Max)
+	restored := 0
+	for _, ban := range bans {
+		until := ban.Until.UnixNano()
+		if until <= now {
+			continue
+		}
+
+		s := b.shardFor(ban.Key)
+		s.mu.Lock()
+		rec := s.getOrCreate(ban.Key, now)
+		rec.curr = max(rec.curr, 1)
+		rec.bannedUntil = max(rec.bannedUntil, min(until, maxUntil))
+		s.mu.Unlock()
+		restored++
+	}
+	return restored
+}
+
+// startCleanup 启动后台清理任务
+func (b *Backoff) startCleanup() {
+	ticker := time.NewTicker(b.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.cleanup()
+		case <-b.stopCleanup:
+			return
+		}
+	}
+}
+
+// cleanup 清除已经遗忘的记录
+func (b *Backoff) cleanup() {
+	now := b.now().UnixNano()
+	for i := range b.shards {
+		s := &b.shards[i]
+		s.mu.Lock()
+		for _, rec := range s.records {
+			if b.forgotten(rec, now) {
+				s.remove(rec)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Stop 停止后台清理任务
+func (b *Backoff) Stop() {
+	close(b.stopCleanup)
+}
+
+// GetTotalRecords 获取当前记录总数（用于监控）
+func (b *Backoff) GetTotalRecords() int {
+	return b.len()
+}
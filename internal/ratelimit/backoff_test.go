@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestBackoff(clock *fakeClock) *Backoff {
+	return newBackoff(BackoffOptions{
+		Base:         time.Second,
+		Factor:       2,
+		Max:          10 * time.Second,
+		SuccessDecay: 1,
+		Forget:       time.Minute,
+	}, clock.now, time.Hour)
+}
+
+// TestBackoff_Delay 测试等待时间的增长曲线和上限
+func TestBackoff_Delay(t *testing.T) {
+	b := newTestBackoff(&fakeClock{t: time.Unix(1700000000, 0)})
+	defer b.Stop()
+
+	tests := []struct {
+		failures float64
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second}, // 16s 超过上限
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := b.Delay(tt.failures); got != tt.want {
+			t.Errorf("Delay(%v) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// TestBackoff_Flow 测试失败增长、等待期拒绝、成功衰减和遗忘
+func TestBackoff_Flow(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newTestBackoff(clock)
+	defer b.Stop()
+
+	// 没有失败记录时不需要等待
+	if allowed, _ := b.Allow("k"); !allowed {
+		t.Fatal("expected first attempt to be allowed")
+	}
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if _, delay := b.RecordFailure("k"); delay != want {
+			t.Fatalf("failure %d: expected delay %v, got %v", i+1, want, delay)
+		}
+		clock.advance(want)
+	}
+
+	// 等待期内拒绝，Allow 不增加失败次数
+	b.RecordFailure("k") // 第 4 次失败：8s
+	clock.advance(3 * time.Second)
+	if allowed, retryAfter := b.Allow("k"); allowed || retryAfter != 5*time.Second {
+		t.Fatalf("expected 5s remaining, got %v %v", allowed, retryAfter)
+	}
+	clock.advance(5 * time.Second)
+	if allowed, _ := b.Allow("k"); !allowed {
+		t.Fatal("expected attempt to be allowed after the delay")
+	}
+
+	// 成功抵消一次失败：下一次失败回到 8s 而不是 16s
+	b.Succeed("k")
+	if _, delay := b.RecordFailure("k"); delay != 8*time.Second {
+		t.Errorf("expected delay 8s after success decay, got %v", delay)
+	}
+
+	// 等待结束后超过 Forget 没有失败：重新开始
+	clock.advance(8*time.Second + time.Minute)
+	if _, delay := b.RecordFailure("k"); delay != time.Second {
+		t.Errorf("expected delay reset after forget period, got %v", delay)
+	}
+
+	// 成功次数足够时清除记录
+	b.Succeed("k")
+	if total := b.len(); total != 0 {
+		t.Errorf("expected record removed after decay, got %d records", total)
+	}
+}
+
+// TestBackoff_Inherit 测试重载时保留失败次数，剩余等待时间不超过新的上限
+func TestBackoff_Inherit(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	old := newTestBackoff(clock)
+	defer old.Stop()
+	for i := 0; i < 4; i++ {
+		old.RecordFailure("k") // 8s
+	}
+
+	b := newBackoff(BackoffOptions{Base: time.Second, Factor: 3, Max: 5 * time.Second, Forget: time.Minute}, clock.now, time.Hour)
+	defer b.Stop()
+	b.Inherit(old)
+
+	if banned, retryAfter := b.Banned("k"); !banned || retryAfter != 5*time.Second {
+		t.Errorf("expected wait capped at 5s, got %v %v", banned, retryAfter)
+	}
+	clock.advance(5 * time.Second)
+	if _, delay := b.RecordFailure("k"); delay != 5*time.Second {
+		t.Errorf("expected inherited failure count with new curve, got %v", delay)
+	}
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// TokenBucket 令牌桶限流器（每个 key 一个桶，用于吞吐量限制）
+// 桶容量为 burst，每 period 补充 requests 个令牌；每个请求消耗一个令牌。
+// 桶与 Limiter 一样按 key 分片保存，总数不超过 MaxEntries（按 LRU 淘汰，被淘汰的桶相当于重新装满）。
+// 记录中 curr 为上次更新时的令牌数，windowStart 为上次更新时间
+type TokenBucket struct {
+	table
+
+	// 配置
+	requests int           // 每个周期补充的令牌数
+	period   time.Duration // 补充周期
+	burst    int           // 桶容量
+	rate     float64       // 每秒补充的令牌数
+
+	now func() time.Time
+
+	// 清理器
+	cleanupInterval time.Duration
+	stopCleanup     chan struct{}
+}
+
+// Quota 一次取令牌的结果（对应 IETF RateLimit header 草案的字段）
+type Quota struct {
+	Allowed    bool
+	Limit      int           // 桶容量（RateLimit-Limit）
+	Remaining  int           // 剩余令牌数（RateLimit-Remaining）
+	Reset      time.Duration // 桶重新装满所需时间（RateLimit-Reset）
+	RetryAfter time.Duration // 被拒绝时，下一个令牌可用前的等待时间
+	Requests   int           // 每个周期补充的令牌数（RateLimit-Policy）
+	Period     time.Duration // 补充周期（RateLimit-Policy 的 w 参数）
+}
+
+// NewTokenBucket 创建令牌桶限流器
+// burst <= 0 时桶容量等于 requests
+func NewTokenBucket(requests int, period time.Duration, burst int) *TokenBucket {
+	return NewTokenBucketWithOptions(requests, period, burst, Options{})
+}
+
+// NewTokenBucketWithOptions 使用指定选项创建令牌桶限流器
+func NewTokenBucketWithOptions(requests int, period time.Duration, burst int, opts Options) *TokenBucket {
+	return newTokenBucket(requests, period, burst, time.Now, time.Minute*5, opts)
+}
+
+func newTokenBucket(requests int, period time.Duration, burst int, now func() time.Time, cleanupInterval time.Duration, opts Options) *TokenBucket {
+	if burst <= 0 {
+		burst = requests
+	}
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute * 5
+	}
+
+	b := &TokenBucket{
+		table:           newTable(opts.MaxEntries),
+		requests:        requests,
+		period:          period,
+		burst:           burst,
+		rate:            float64(requests) / period.Seconds(),
+		now:             now,
+		cleanupInterval: cleanupInterval,
+		stopCleanup:     make(chan struct{}),
+	}
+
+	// 启动后台清理任务
+	go b.startCleanup()
+
+	return b
+}
+
+// Take 为 key 消耗一个令牌
+func (b *TokenBucket) Take(key string) Quota {
+	now := b.now().UnixNano()
+	s := b.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.records[key]
+	rec := s.getOrCreate(key, now)
+	if !exists {
+		rec.curr = float64(b.burst)
+	}
+	b.refill(rec, now)
+
+	q := Quota{Limit: b.burst, Requests: b.requests, Period: b.period}
+	if rec.curr >= 1 {
+		rec.curr--
+		q.Allowed = true
+	} else {
+		q.RetryAfter = b.durationFor(1 - rec.curr)
+	}
+	q.Remaining = int(math.Floor(rec.curr))
+	q.Reset = b.durationFor(float64(b.burst) - rec.curr)
+	return q
+}
+
+// refill 按经过的时间补充令牌（不超过桶容量）
+func (b *TokenBucket) refill(rec *record, now int64) {
+	if elapsed := time.Duration(now - rec.windowStart).Seconds(); elapsed > 0 {
+		rec.curr = math.Min(float64(b.burst), rec.curr+elapsed*b.rate)
+		rec.windowStart = now
+	}
+}
+
+// durationFor 返回补充指定数量令牌所需的时间
+func (b *TokenBucket) durationFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / b.rate * float64(time.Second))
+}
+
+// startCleanup 启动后台清理任务
+func (b *TokenBucket) startCleanup() {
+	ticker := time.NewTicker(b.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.cleanup()
+		case <-b.stopCleanup:
+			return
+		}
+	}
+}
+
+// cleanup 清理已经重新装满的桶（与新建的桶等价）
+func (b *TokenBucket) cleanup() {
+	now := b.now().UnixNano()
+	for i := range b.shards {
+		s := &b.shards[i]
+		s.mu.Lock()
+		for _, rec := range s.records {
+			b.refill(rec, now)
+			if rec.curr >= float64(b.burst) {
+				s.remove(rec)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Stop 停止限流器（清理后台任务）
+func (b *TokenBucket) Stop() {
+	close(b.stopCleanup)
+}
+
+// GetTotalBuckets 获取当前桶总数（用于监控）
+func (b *TokenBucket) GetTotalBuckets() int {
+	return b.len()
+}
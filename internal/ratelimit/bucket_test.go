@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// TestTokenBucket_Take 测试令牌消耗、拒绝和补充
+func TestTokenBucket_Take(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newTokenBucket(2, time.Second, 4, clock.now, time.Hour, Options{})
+	defer b.Stop()
+
+	// 初始满桶：允许 burst 个请求
+	for i := 0; i < 4; i++ {
+		q := b.Take("k")
+		if !q.Allowed {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+		if q.Remaining != 3-i {
+			t.Errorf("request %d: expected remaining %d, got %d", i+1, 3-i, q.Remaining)
+		}
+	}
+
+	q := b.Take("k")
+	if q.Allowed {
+		t.Fatal("request beyond burst should be denied")
+	}
+	if q.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected retry after 500ms, got %v", q.RetryAfter)
+	}
+	if q.Reset != 2*time.Second {
+		t.Errorf("expected reset 2s, got %v", q.Reset)
+	}
+	if q.Limit != 4 || q.Requests != 2 || q.Period != time.Second {
+		t.Errorf("unexpected quota policy: %+v", q)
+	}
+
+	// 其他 key 独立计数
+	if !b.Take("other").Allowed {
+		t.Error("other key should be allowed")
+	}
+
+	// 0.5 秒后补充一个令牌
+	clock.advance(500 * time.Millisecond)
+	if q := b.Take("k"); !q.Allowed || q.Remaining != 0 {
+		t.Errorf("expected one refilled token, got %+v", q)
+	}
+
+	// 长时间空闲后不超过桶容量
+	clock.advance(time.Hour)
+	if q := b.Take("k"); q.Remaining != 3 {
+		t.Errorf("expected bucket capped at burst, got remaining %d", q.Remaining)
+	}
+}
+
+// TestTokenBucket_DefaultBurst 测试 burst 默认等于 requests
+func TestTokenBucket_DefaultBurst(t *testing.T) {
+	b := NewTokenBucket(10, time.Minute, 0)
+	defer b.Stop()
+
+	if q := b.Take("k"); q.Limit != 10 || q.Remaining != 9 {
+		t.Errorf("expected limit 10 remaining 9, got %+v", q)
+	}
+}
+
+// TestTokenBucket_Cleanup 测试清理已装满的桶
+func TestTokenBucket_Cleanup(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newTokenBucket(1, time.Second, 1, clock.now, time.Hour, Options{})
+	defer b.Stop()
+
+	b.Take("a")
+	b.Take("b")
+	clock.advance(500 * time.Millisecond)
+	b.cleanup()
+	if n := b.GetTotalBuckets(); n != 2 {
+		t.Fatalf("expected 2 buckets before refill, got %d", n)
+	}
+
+	clock.advance(time.Second)
+	b.cleanup()
+	if n := b.GetTotalBuckets(); n != 0 {
+		t.Errorf("expected 0 buckets after refill, got %d", n)
+	}
+}
+
+// TestRecordFailure 测试认证失败计数和封禁
+func TestRecordFailure(t *testing.T) {
+	limiter := NewLimiter(3, time.Minute, time.Minute)
+	defer limiter.Stop()
+
+	for i := 0; i < 2; i++ {
+		if banned, _ := limiter.RecordFailure("ip"); banned {
+			t.Fatalf("failure %d should not ban", i+1)
+		}
+	}
+	if banned, _ := limiter.Banned("ip"); banned {
+		t.Fatal("should not be banned before reaching the limit")
+	}
+
+	banned, retryAfter := limiter.RecordFailure("ip")
+	if !banned || retryAfter != time.Minute {
+		t.Fatalf("third failure should ban for 1m, got %v %v", banned, retryAfter)
+	}
+	if banned, retryAfter := limiter.Banned("ip"); !banned || retryAfter <= 0 {
+		t.Errorf("expected ban, got %v %v", banned, retryAfter)
+	}
+	if banned, _ := limiter.Banned("other"); banned {
+		t.Error("other key should not be banned")
+	}
+}
+
+// TestTokenBucket_MaxEntries 测试桶数量上限：超出后淘汰最久未使用的桶
+func TestTokenBucket_MaxEntries(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newTokenBucket(1, time.Hour, 2, clock.now, time.Hour, Options{MaxEntries: 16})
+	defer b.Stop()
+
+	for i := 0; i < 16; i++ {
+		b.Take(fmt.Sprintf("k%d", i))
+	}
+	b.Take("k0") // k0 最近使用过，k1 最久未使用
+	for i := 16; i < 20; i++ {
+		b.Take(fmt.Sprintf("k%d", i))
+	}
+
+	if n := b.GetTotalBuckets(); n != 16 {
+		t.Errorf("expected 16 buckets, got %d", n)
+	}
+	if q := b.Take("k0"); q.Allowed {
+		t.Error("recently used bucket should be kept")
+	}
+	if q := b.Take("k1"); !q.Allowed || q.Remaining != 1 {
+		t.Errorf("evicted bucket should start full, got %+v", q)
+	}
+}
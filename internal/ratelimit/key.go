@@ -0,0 +1,28 @@
+package ratelimit
+
+import "net/netip"
+
+// DefaultIPv6Prefix 默认的 IPv6 聚合前缀长度（通常一个用户 / 站点分配到一个 /64）
+const DefaultIPv6Prefix = 64
+
+// ClientKey 返回客户端 IP 的限流 key
+// IPv4（包括 IPv4 映射的 IPv6 地址）按单个地址计数；IPv6 按前 ipv6Prefix 位聚合（如 "2001:db8:1:2::/64"），
+// 避免攻击者轮换同一前缀下的地址绕过限流。ipv6Prefix 不在 1-127 之间或无法解析的地址原样返回
+func ClientKey(ip string, ipv6Prefix int) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	if addr.Is4In6() {
+		return addr.Unmap().String()
+	}
+	if !addr.Is6() || ipv6Prefix <= 0 || ipv6Prefix >= 128 {
+		return ip
+	}
+
+	prefix, err := addr.WithZone("").Prefix(ipv6Prefix)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import "testing"
+
+// TestClientKey 测试 IPv6 按前缀聚合
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		ip     string
+		prefix int
+		want   string
+	}{
+		{"ipv4", "192.168.1.1", 64, "192.168.1.1"},
+		{"ipv4-mapped ipv6", "::ffff:192.168.1.1", 64, "192.168.1.1"},
+		{"ipv6 /64", "2001:db8:1:2:3:4:5:6", 64, "2001:db8:1:2::/64"},
+		{"ipv6 /48", "2001:db8:1:2:3:4:5:6", 48, "2001:db8:1::/48"},
+		{"ipv6 with zone", "fe80::1%eth0", 64, "fe80::/64"},
+		{"ipv6 per address", "2001:db8::1", 128, "2001:db8::1"},
+		{"prefix disabled", "2001:db8::1", 0, "2001:db8::1"},
+		{"not an ip", "unknown", 64, "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClientKey(tt.ip, tt.prefix); got != tt.want {
+				t.Errorf("ClientKey(%q, %d) = %q, want %q", tt.ip, tt.prefix, got, tt.want)
+			}
+		})
+	}
+
+	// 同一 /64 内的地址共享 key
+	if ClientKey("2001:db8::1", 64) != ClientKey("2001:db8::ffff:1", 64) {
+		t.Error("expected addresses in the same /64 to share a key")
+	}
+}
@@ -1,15 +1,31 @@
 package ratelimit
 
 import (
+	"math"
 	"sync"
 	"time"
 )
 
-// Limiter 速率限制器（基于滑动窗口算法）
+const (
+	// DefaultMaxEntries 默认最多保存的记录数
+	DefaultMaxEntries = 100000
+
+	maxShards      = 32 // 分片数上限（必须是 2 的幂）
+	minShardSize   = 16 // 每个分片至少容纳的记录数（记录数上限较小时减少分片）
+	evictScanLimit = 8  // 淘汰时从 LRU 尾部最多检查的记录数（优先淘汰未封禁的记录）
+)
+
+// Options 限流器选项
+type Options struct {
+	MaxEntries int // 最多保存的记录数（<= 0 时使用 DefaultMaxEntries），超出后按 LRU 淘汰
+}
+
+// Limiter 速率限制器（近似滑动窗口计数 + 封禁）
+// 记录按 key 的哈希分布到多个分片，每个分片有独立的锁和 LRU 链表，总记录数不超过 MaxEntries。
+// 每条记录只保存当前和上一个固定窗口的计数，按上一个窗口剩余的比例加权估算滑动窗口内的次数，
+// 内存占用与尝试次数无关。
 type Limiter struct {
-	// 每个 IP 的请求记录
-	records map[string]*record
-	mu      sync.RWMutex
+	table
 
 	// 配置
 	maxAttempts int           // 时间窗口内的最大尝试次数
@@ -21,31 +37,58 @@ type Limiter struct {
 	stopCleanup     chan struct{}
 }
 
-// record 记录单个 IP 的请求历史
+// table 按 key 哈希分片的记录表，总记录数有上限（按 LRU 淘汰）
+type table struct {
+	shards []shard
+	mask   uint32
+}
+
+// shard 一个分片：记录表 + LRU 链表（head 为最近使用）
+type shard struct {
+	mu       sync.Mutex
+	records  map[string]*record
+	head     *record
+	tail     *record
+	capacity int
+}
+
+// record 单个 key 的计数和封禁状态
 type record struct {
-	attempts    []time.Time // 请求时间戳列表
-	bannedUntil time.Time   // 封禁截止时间
+	key         string
+	windowStart int64   // 当前固定窗口的开始时间（UnixNano）
+	curr        float64 // 当前窗口的尝试次数
+	prev        float64 // 上一个窗口的尝试次数
+	bannedUntil int64   // 封禁截止时间（UnixNano，0 表示未封禁过）
+
+	newer, older *record // LRU 链表
 }
 
 // NewLimiter 创建新的速率限制器
 func NewLimiter(maxAttempts int, window, banDuration time.Duration) *Limiter {
-	return newLimiter(maxAttempts, window, banDuration, time.Minute*5)
+	return NewLimiterWithOptions(maxAttempts, window, banDuration, Options{})
 }
 
-func newLimiter(maxAttempts int, window, banDuration, cleanupInterval time.Duration) *Limiter {
+// NewLimiterWithOptions 使用指定选项创建速率限制器
+func NewLimiterWithOptions(maxAttempts int, window, banDuration time.Duration, opts Options) *Limiter {
+	return newLimiter(maxAttempts, window, banDuration, time.Minute*5, opts)
+}
+
+func newLimiter(maxAttempts int, window, banDuration, cleanupInterval time.Duration, opts Options) *Limiter {
 	if cleanupInterval <= 0 {
 		cleanupInterval = time.Minute * 5
 	}
+	if window <= 0 {
+		window = time.Nanosecond
+	}
 
 	l := &Limiter{
-		records:         make(map[string]*record),
+		table:           newTable(opts.MaxEntries),
 		maxAttempts:     maxAttempts,
 		window:          window,
 		banDuration:     banDuration,
 		cleanupInterval: cleanupInterval,
 		stopCleanup:     make(chan struct{}),
 	}
-
 	// 启动后台清理任务
 	go l.startCleanup()
 
@@ -55,89 +98,258 @@ func newLimiter(maxAttempts int, window, banDuration, cleanupInterval time.Durat
 // Allow 检查 IP 是否允许继续尝试
 // 返回 (allowed bool, retryAfter time.Duration)
 func (l *Limiter) Allow(ip string) (bool, time.Duration) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	now := time.Now()
+	now := time.Now().UnixNano()
+	s := l.shardFor(ip)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// 获取或创建记录
-	rec, exists := l.records[ip]
-	if !exists {
-		rec = &record{
-			attempts: make([]time.Time, 0),
-		}
-		l.records[ip] = rec
-	}
+	rec := s.getOrCreate(ip, now)
 
 	// 检查是否在封禁期内
-	if now.Before(rec.bannedUntil) {
-		return false, time.Until(rec.bannedUntil)
+	if now < rec.bannedUntil {
+		return false, time.Duration(rec.bannedUntil - now)
 	}
 
 	// 封禁已过期，清空尝试记录重新开始
-	if !rec.bannedUntil.IsZero() && now.After(rec.bannedUntil) {
-		rec.attempts = make([]time.Time, 0)
-		rec.bannedUntil = time.Time{} // 重置封禁时间
+	if rec.bannedUntil != 0 {
+		rec.restart(now)
 	}
 
-	// 移除时间窗口外的旧记录
-	cutoff := now.Add(-l.window)
-	validAttempts := make([]time.Time, 0, len(rec.attempts))
-	for _, t := range rec.attempts {
-		if t.After(cutoff) {
-			validAttempts = append(validAttempts, t)
-		}
-	}
-	rec.attempts = validAttempts
-
 	// 检查是否超过限制
-	if len(rec.attempts) >= l.maxAttempts {
+	if l.estimate(rec, now) >= float64(l.maxAttempts) {
 		// 触发封禁
-		rec.bannedUntil = now.Add(l.banDuration)
+		rec.bannedUntil = now + int64(l.banDuration)
 		return false, l.banDuration
 	}
 
 	// 记录本次尝试
-	rec.attempts = append(rec.attempts, now)
+	rec.curr++
 
 	return true, 0
 }
 
+// Banned 检查 key 是否处于封禁期（不记录尝试）
+func (l *Limiter) Banned(key string) (bool, time.Duration) {
+	now := time.Now().UnixNano()
+	s := l.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, exists := s.records[key]
+	if !exists || now >= rec.bannedUntil {
+		return false, 0
+	}
+	return true, time.Duration(rec.bannedUntil - now)
+}
+
+// RecordFailure 记录一次失败（如认证失败），时间窗口内失败次数达到上限时开始封禁
+// 返回本次记录后是否被封禁以及封禁时长
+func (l *Limiter) RecordFailure(key string) (bool, time.Duration) {
+	now := time.Now().UnixNano()
+	s := l.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.getOrCreate(key, now)
+	if now < rec.bannedUntil {
+		return true, time.Duration(rec.bannedUntil - now)
+	}
+
+	// 封禁已过期，重新开始计数
+	if rec.bannedUntil != 0 {
+		rec.restart(now)
+	}
+
+	l.advance(rec, now)
+	rec.curr++
+	if l.estimate(rec, now) >= float64(l.maxAttempts) {
+		rec.bannedUntil = now + int64(l.banDuration)
+		return true, l.banDuration
+	}
+	return false, 0
+}
+
 // Reset 重置指定 IP 的限制（用于成功认证后）
 func (l *Limiter) Reset(ip string) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	s := l.shardFor(ip)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	delete(l.records, ip)
+	if rec, exists := s.records[ip]; exists {
+		s.remove(rec)
+	}
 }
 
 // GetStats 获取指定 IP 的统计信息
 func (l *Limiter) GetStats(ip string) (attempts int, isBanned bool, retryAfter time.Duration) {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+	now := time.Now().UnixNano()
+	s := l.shardFor(ip)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	rec, exists := l.records[ip]
+	rec, exists := s.records[ip]
 	if !exists {
 		return 0, false, 0
 	}
 
-	now := time.Now()
+	attempts = int(math.Ceil(l.estimate(rec, now)))
 
 	// 检查封禁状态
-	if now.Before(rec.bannedUntil) {
-		return len(rec.attempts), true, time.Until(rec.bannedUntil)
+	if now < rec.bannedUntil {
+		return attempts, true, time.Duration(rec.bannedUntil - now)
 	}
 
-	// 统计有效尝试次数
-	cutoff := now.Add(-l.window)
-	count := 0
-	for _, t := range rec.attempts {
-		if t.After(cutoff) {
-			count++
+	return attempts, false, 0
+}
+
+// advance 将记录的固定窗口推进到当前时间
+func (l *Limiter) advance(rec *record, now int64) {
+	window := int64(l.window)
+	elapsed := now - rec.windowStart
+	if elapsed < window {
+		return
+	}
+	if elapsed < 2*window {
+		rec.prev = rec.curr
+		rec.windowStart += window
+	} else {
+		rec.prev = 0
+		rec.windowStart = now
+	}
+	rec.curr = 0
+}
+
+// estimate 估算滑动窗口内的尝试次数：上一个窗口按仍在滑动窗口内的比例加权
+func (l *Limiter) estimate(rec *record, now int64) float64 {
+	l.advance(rec, now)
+	if rec.prev == 0 {
+		return rec.curr
+	}
+	weight := 1 - float64(now-rec.windowStart)/float64(l.window)
+	return rec.prev*weight + rec.curr
+}
+
+// restart 清空计数和封禁（封禁结束后重新开始）
+func (rec *record) restart(now int64) {
+	rec.curr = 0
+	rec.prev = 0
+	rec.windowStart = now
+	rec.bannedUntil = 0
+}
+
+// newTable 创建记录表（maxEntries <= 0 时使用 DefaultMaxEntries）
+func newTable(maxEntries int) table {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+
+	shardCount := maxShards
+	for shardCount > 1 && maxEntries/shardCount < minShardSize {
+		shardCount /= 2
+	}
+	perShard := (maxEntries + shardCount - 1) / shardCount
+
+	t := table{
+		shards: make([]shard, shardCount),
+		mask:   uint32(shardCount - 1),
+	}
+	for i := range t.shards {
+		t.shards[i].records = make(map[string]*record)
+		t.shards[i].capacity = perShard
+	}
+	return t
+}
+
+// shardFor 按 key 的 FNV-1a 哈希选择分片
+func (t *table) shardFor(key string) *shard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &t.shards[h&t.mask]
+}
+
+// len 返回记录总数
+func (t *table) len() int {
+	total := 0
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.mu.Lock()
+		total += len(s.records)
+		s.mu.Unlock()
+	}
+	return total
+}
+
+// getOrCreate 获取记录并标记为最近使用；不存在时创建（分片已满时先淘汰）
+func (s *shard) getOrCreate(key string, now int64) *record {
+	if rec, exists := s.records[key]; exists {
+		s.moveToFront(rec)
+		return rec
+	}
+
+	if len(s.records) >= s.capacity {
+		s.evict(now)
+	}
+	rec := &record{key: key, windowStart: now}
+	s.records[key] = rec
+	s.pushFront(rec)
+	return rec
+}
+
+// evict 淘汰一条记录：从 LRU 尾部开始优先淘汰未封禁的记录，
+// 避免攻击者用大量新 key 把自己的封禁挤出去；检查范围内都处于封禁期时淘汰最久未使用的记录
+func (s *shard) evict(now int64) {
+	victim := s.tail
+	for rec, i := s.tail, 0; rec != nil && i < evictScanLimit; rec, i = rec.newer, i+1 {
+		if now >= rec.bannedUntil {
+			victim = rec
+			break
 		}
 	}
+	if victim != nil {
+		s.remove(victim)
+	}
+}
 
-	return count, false, 0
+func (s *shard) remove(rec *record) {
+	delete(s.records, rec.key)
+	s.unlink(rec)
+}
+
+func (s *shard) pushFront(rec *record) {
+	rec.older = s.head
+	rec.newer = nil
+	if s.head != nil {
+		s.head.newer = rec
+	}
+	s.head = rec
+	if s.tail == nil {
+		s.tail = rec
+	}
+}
+
+func (s *shard) unlink(rec *record) {
+	if rec.newer != nil {
+		rec.newer.older = rec.older
+	} else {
+		s.head = rec.older
+	}
+	if rec.older != nil {
+		rec.older.newer = rec.newer
+	} else {
+		s.tail = rec.newer
+	}
+	rec.newer, rec.older = nil, nil
+}
+
+func (s *shard) moveToFront(rec *record) {
+	if s.head == rec {
+		return
+	}
+	s.unlink(rec)
+	s.pushFront(rec)
 }
 
 // startCleanup 启动后台清理任务
@@ -155,28 +367,18 @@ func (l *Limiter) startCleanup() {
 	}
 }
 
-// cleanup 清理过期的记录
+// cleanup 清理过期的记录（不在封禁期且滑动窗口内没有尝试）
 func (l *Limiter) cleanup() {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	now := time.Now()
-	cutoff := now.Add(-l.window)
-
-	for ip, rec := range l.records {
-		// 如果封禁已过期且没有有效尝试记录，删除该 IP
-		if now.After(rec.bannedUntil) {
-			hasValidAttempts := false
-			for _, t := range rec.attempts {
-				if t.After(cutoff) {
-					hasValidAttempts = true
-					break
-				}
-			}
-			if !hasValidAttempts {
-				delete(l.records, ip)
+	now := time.Now().UnixNano()
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.mu.Lock()
+		for _, rec := range s.records {
+			if now >= rec.bannedUntil && l.estimate(rec, now) == 0 {
+				s.remove(rec)
 			}
 		}
+		s.mu.Unlock()
 	}
 }
 
@@ -187,7 +389,5 @@ func (l *Limiter) Stop() {
 
 // GetTotalRecords 获取当前记录总数（用于监控）
 func (l *Limiter) GetTotalRecords() int {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-	return len(l.records)
+	return l.len()
 }
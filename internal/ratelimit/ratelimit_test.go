@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"strconv"
 	"testing"
 	"time"
 )
@@ -218,7 +219,7 @@ func TestGetStats(t *testing.T) {
 // TestCleanup 测试清理功能
 func TestCleanup(t *testing.T) {
 	// 使用短窗口和清理间隔
-	limiter := newLimiter(5, time.Millisecond*50, time.Millisecond*50, time.Millisecond*100)
+	limiter := newLimiter(5, time.Millisecond*50, time.Millisecond*50, time.Millisecond*100, Options{})
 	defer limiter.Stop()
 
 	// 添加一些记录
@@ -338,3 +339,100 @@ func BenchmarkAllowParallel(b *testing.B) {
 		}
 	})
 }
+
+// TestMaxEntries 测试记录数上限和 LRU 淘汰（优先保留处于封禁期的记录）
+func TestMaxEntries(t *testing.T) {
+	limiter := NewLimiterWithOptions(1, time.Minute, time.Minute, Options{MaxEntries: 4})
+	defer limiter.Stop()
+
+	// 封禁 "attacker"，然后用大量新 key 填满
+	limiter.Allow("attacker")
+	limiter.Allow("attacker")
+	for i := 0; i < 100; i++ {
+		limiter.Allow("10.0.0." + strconv.Itoa(i))
+	}
+
+	if total := limiter.GetTotalRecords(); total != 4 {
+		t.Errorf("Expected records capped at 4, got %d", total)
+	}
+	if banned, _ := limiter.Banned("attacker"); !banned {
+		t.Error("Expected banned record to survive eviction")
+	}
+	// 最近使用的记录保留，最久未使用的被淘汰
+	if attempts, _, _ := limiter.GetStats("10.0.0.99"); attempts != 1 {
+		t.Errorf("Expected most recent record to be kept, got %d attempts", attempts)
+	}
+	if attempts, _, _ := limiter.GetStats("10.0.0.0"); attempts != 0 {
+		t.Errorf("Expected least recently used record to be evicted, got %d attempts", attempts)
+	}
+}
+
+// TestEstimate 测试近似滑动窗口：上一个窗口的计数按剩余比例计入
+func TestEstimate(t *testing.T) {
+	limiter := NewLimiter(10, time.Minute, time.Minute)
+	defer limiter.Stop()
+
+	start := time.Now().UnixNano()
+	minute := int64(time.Minute)
+	tests := []struct {
+		name    string
+		elapsed int64 // 距离记录窗口开始的时间
+		want    float64
+	}{
+		{"current window", 30 * int64(time.Second), 6},
+		{"next window start", minute, 6},
+		{"quarter into next window", minute + minute/4, 4.5},
+		{"two windows later", 2 * minute, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &record{windowStart: start, curr: 6}
+			if got := limiter.estimate(rec, start+tt.elapsed); got != tt.want {
+				t.Errorf("estimate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkAllowParallelManyKeys 基准测试：多个 key 并发 Allow（分片锁竞争）
+func BenchmarkAllowParallelManyKeys(b *testing.B) {
+	limiter := NewLimiter(1000, time.Minute, time.Minute)
+	defer limiter.Stop()
+
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			limiter.Allow(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+// BenchmarkAllowParallelEviction 基准测试：记录数达到上限后持续出现新 key（LRU 淘汰）
+func BenchmarkAllowParallelEviction(b *testing.B) {
+	limiter := NewLimiterWithOptions(1000, time.Minute, time.Minute, Options{MaxEntries: 4096})
+	defer limiter.Stop()
+
+	keys := make([]string, 65536)
+	for i := range keys {
+		keys[i] = "2001:db8::" + strconv.FormatInt(int64(i), 16)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			limiter.Allow(keys[i%len(keys)])
+			i++
+		}
+	})
+}
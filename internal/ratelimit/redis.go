@@ -0,0 +1,372 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// allowScript 滑动窗口计数 + 封禁（与 Limiter.Allow 语义相同：先检查次数，再记录本次尝试）
+// KEYS[1] 尝试记录（sorted set，score 为毫秒时间戳），KEYS[2] 封禁标记（带 TTL）
+// ARGV: now_ms, window_ms, max_attempts, ban_ms, member
+// 返回 {allowed(0/1), retry_after_ms}
+var allowScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl > 0 then
+	return {0, ttl}
+end
+local now = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - tonumber(ARGV[2]))
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	redis.call('SET', KEYS[2], '1', 'PX', ARGV[4])
+	redis.call('DEL', KEYS[1])
+	return {0, tonumber(ARGV[4])}
+end
+redis.call('ZADD', KEYS[1], now, ARGV[5])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return {1, 0}
+`)
+
+// failureScript 记录一次失败，达到上限时封禁（与 Limiter.RecordFailure 语义相同）
+// 参数同 allowScript，返回 {banned(0/1), retry_after_ms}
+var failureScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl > 0 then
+	return {1, ttl}
+end
+local now = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - tonumber(ARGV[2]))
+redis.call('ZADD', KEYS[1], now, ARGV[5])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	redis.call('SET', KEYS[2], '1', 'PX', ARGV[4])
+	redis.call('DEL', KEYS[1])
+	return {1, tonumber(ARGV[4])}
+end
+return {0, 0}
+`)
+
+const (
+	adminTimeout = 5 * time.Second // 管理操作（SCAN 全部 key）的超时
+	scanCount    = 500             // 每次 SCAN / DEL 的 key 数
+)
+
+var (
+	errUnavailable     = errors.New("redis unavailable")
+	errUnexpectedReply = errors.New("unexpected redis script reply")
+)
+
+// RedisOptions Redis 后端选项
+type RedisOptions struct {
+	Prefix        string        // key 前缀（不同的限流器需要使用不同的前缀）
+	Timeout       time.Duration // 单次操作超时（默认 100ms）
+	RetryInterval time.Duration // Redis 不可用后，多久之后再次尝试（默认 5s）
+	MaxEntries    int           // Redis 不可用时本地限流器的记录数上限（默认 DefaultMaxEntries）
+
+	// OnStatus 在 Redis 变为不可用（err != nil）和恢复（err == nil）时调用（可选）
+	OnStatus func(err error)
+}
+
+// RedisLimiter 基于 Redis 的速率限制器，多个副本共享计数和封禁
+// Redis 不可用时退回到进程内的 Limiter（各副本独立计数），恢复后自动切回
+type RedisLimiter struct {
+	client redis.UniversalClient
+	opts   RedisOptions
+
+	maxAttempts int
+	window      time.Duration
+	banDuration time.Duration
+
+	local *Limiter // Redis 不可用时使用的本地限流器
+
+	mu          sync.Mutex
+	retryAt     time.Time // 不可用期间，在此时间之前不再尝试 Redis
+	unavailable bool
+
+	seq atomic.Uint64 // 保证同一毫秒内多次尝试的 member 唯一
+	id  string        // 实例标识（区分不同副本写入的 member）
+}
+
+// NewRedisLimiter 创建 Redis 速率限制器
+func NewRedisLimiter(client redis.UniversalClient, maxAttempts int, window, banDuration time.Duration, opts RedisOptions) *RedisLimiter {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 100 * time.Millisecond
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = 5 * time.Second
+	}
+
+	return &RedisLimiter{
+		client:      client,
+		opts:        opts,
+		maxAttempts: maxAttempts,
+		window:      window,
+		banDuration: banDuration,
+		local:       NewLimiterWithOptions(maxAttempts, window, banDuration, Options{MaxEntries: opts.MaxEntries}),
+		id:          strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// Allow 记录一次尝试，时间窗口内的尝试次数超过上限时开始封禁
+func (l *RedisLimiter) Allow(key string) (bool, time.Duration) {
+	result, err := l.run(allowScript, key)
+	if err != nil {
+		return l.local.Allow(key)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond
+}
+
+// RecordFailure 记录一次失败，时间窗口内失败次数达到上限时开始封禁
+func (l *RedisLimiter) RecordFailure(key string) (bool, time.Duration) {
+	result, err := l.run(failureScript, key)
+	if err != nil {
+		return l.local.RecordFailure(key)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond
+}
+
+// Banned 检查 key 是否处于封禁期（不记录尝试）
+func (l *RedisLimiter) Banned(key string) (bool, time.Duration) {
+	if !l.available() {
+		return l.local.Banned(key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.Timeout)
+	defer cancel()
+
+	ttl, err := l.client.PTTL(ctx, l.banKey(key)).Result()
+	if err != nil {
+		l.fail(err)
+		return l.local.Banned(key)
+	}
+	l.succeed()
+	if ttl > 0 {
+		return true, ttl
+	}
+	return false, 0
+}
+
+// Reset 清除 key 的尝试记录和封禁（同时清除本地记录）
+func (l *RedisLimiter) Reset(key string) {
+	l.local.Reset(key)
+	if !l.available() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.Timeout)
+	defer cancel()
+
+	if err := l.client.Del(ctx, l.attemptsKey(key), l.banKey(key)).Err(); err != nil {
+		l.fail(err)
+		return
+	}
+	l.succeed()
+}
+
+// Stop 停止本地限流器的后台任务（Redis 客户端由调用方关闭）
+func (l *RedisLimiter) Stop() {
+	l.local.Stop()
+}
+
+// run 执行计数脚本，返回 {结果, 毫秒}；Redis 不可用时返回错误
+func (l *RedisLimiter) run(script *redis.Script, key string) ([2]int64, error) {
+	var result [2]int64
+	if !l.available() {
+		return result, errUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.Timeout)
+	defer cancel()
+
+	now := time.Now()
+	member := strconv.FormatInt(now.UnixNano(), 36) + "-" + l.id + "-" + strconv.FormatUint(l.seq.Add(1), 36)
+	values, err := script.Run(ctx, l.client,
+		[]string{l.attemptsKey(key), l.banKey(key)},
+		now.UnixMilli(), l.window.Milliseconds(), l.maxAttempts, l.banDuration.Milliseconds(), member,
+	).Int64Slice()
+	if err == nil && len(values) != 2 {
+		err = errUnexpectedReply
+	}
+	if err != nil {
+		l.fail(err)
+		return result, err
+	}
+
+	l.succeed()
+	result[0], result[1] = values[0], values[1]
+	return result, nil
+}
+
+// available Redis 是否可用（不可用期间每隔 RetryInterval 重试一次）
+func (l *RedisLimiter) available() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !l.unavailable || !time.Now().Before(l.retryAt)
+}
+
+// fail 标记 Redis 不可用
+func (l *RedisLimiter) fail(err error) {
+	l.mu.Lock()
+	changed := !l.unavailable
+	l.unavailable = true
+	l.retryAt = time.Now().Add(l.opts.RetryInterval)
+	l.mu.Unlock()
+
+	if changed && l.opts.OnStatus != nil {
+		l.opts.OnStatus(err)
+	}
+}
+
+// succeed 标记 Redis 恢复
+func (l *RedisLimiter) succeed() {
+	l.mu.Lock()
+	changed := l.unavailable
+	l.unavailable = false
+	l.mu.Unlock()
+
+	if changed && l.opts.OnStatus != nil {
+		l.opts.OnStatus(nil)
+	}
+}
+
+// Degraded Redis 当前是否不可用（正在使用本地限流）
+func (l *RedisLimiter) Degraded() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.unavailable
+}
+
+func (l *RedisLimiter) attemptsKey(key string) string { return l.opts.Prefix + "attempts:" + key }
+func (l *RedisLimiter) banKey(key string) string      { return l.opts.Prefix + "ban:" + key }
+
+// Lookup 返回 key 的当前状态（Redis 不可用时返回本地记录）
+func (l *RedisLimiter) Lookup(key string) (Status, error) {
+	if !l.available() {
+		return l.local.Lookup(key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.Timeout)
+	defer cancel()
+
+	now := time.Now()
+	pipe := l.client.Pipeline()
+	count := pipe.ZCount(ctx, l.attemptsKey(key), strconv.FormatInt(now.Add(-l.window).UnixMilli(), 10), "+inf")
+	ttl := pipe.PTTL(ctx, l.banKey(key))
+	if _, err := pipe.Exec(ctx); err != nil {
+		l.fail(err)
+		return Status{}, err
+	}
+	l.succeed()
+
+	status := Status{Attempts: int(count.Val())}
+	if ttl.Val() > 0 {
+		status.Banned = true
+		status.RetryAfter = ttl.Val()
+	}
+	return status, nil
+}
+
+// ListBans 返回 Redis 中当前所有处于封禁期的 key
+func (l *RedisLimiter) ListBans() ([]Ban, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	prefix := l.opts.Prefix + "ban:"
+	keys, err := l.scan(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := l.client.Pipeline()
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, k := range keys {
+		ttls[i] = pipe.PTTL(ctx, k)
+	}
+	if len(keys) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	bans := make([]Ban, 0, len(keys))
+	for i, k := range keys {
+		if ttl := ttls[i].Val(); ttl > 0 {
+			bans = append(bans, Ban{Key: strings.TrimPrefix(k, prefix), Until: now.Add(ttl)})
+		}
+	}
+	return bans, nil
+}
+
+// Ban 手动封禁 key d（同时写入本地记录，Redis 不可用时也生效）
+func (l *RedisLimiter) Ban(key string, d time.Duration) error {
+	_ = l.local.Ban(key, d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.Timeout)
+	defer cancel()
+	return l.client.Set(ctx, l.banKey(key), "1", d).Err()
+}
+
+// Unban 删除 Redis 中 key 的尝试记录和封禁，并清除本地记录（Redis 不可用时返回错误）
+func (l *RedisLimiter) Unban(key string) error {
+	l.local.Reset(key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.Timeout)
+	defer cancel()
+	return l.client.Del(ctx, l.attemptsKey(key), l.banKey(key)).Err()
+}
+
+// Clear 删除 Redis 中该限流器的所有记录和封禁，并清除本地记录
+func (l *RedisLimiter) Clear() error {
+	_ = l.local.Clear()
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	// 只删除本限流器的尝试和封禁记录：直接扫描 Prefix 会匹配到名称以它开头的其他策略
+	// （如清除策略 login 时删除 login:admin 的记录）
+	var keys []string
+	for _, prefix := range []string{l.attemptsKey(""), l.banKey("")} {
+		found, err := l.scan(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, found...)
+	}
+	for start := 0; start < len(keys); start += scanCount {
+		end := min(start+scanCount, len(keys))
+		if err := l.client.Del(ctx, keys[start:end]...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan 返回所有以 prefix 开头的 key
+func (l *RedisLimiter) scan(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	iter := l.client.Scan(ctx, 0, escapeGlob(prefix)+"*", scanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// escapeGlob 转义 Redis SCAN MATCH 模式中的特殊字符
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
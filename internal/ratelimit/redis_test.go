@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+// TestRedisLimiter_SharedAcrossReplicas 测试多个副本共享计数和封禁
+func TestRedisLimiter_SharedAcrossReplicas(t *testing.T) {
+	mr, client := newTestRedis(t)
+	opts := RedisOptions{Prefix: "test:global:"}
+	a := NewRedisLimiter(client, 3, time.Minute, 5*time.Minute, opts)
+	b := NewRedisLimiter(client, 3, time.Minute, 5*time.Minute, opts)
+	defer a.Stop()
+	defer b.Stop()
+
+	// 两个副本交替尝试，共计 3 次后封禁
+	for i, l := range []*RedisLimiter{a, b, a} {
+		if allowed, _ := l.Allow("10.0.0.1"); !allowed {
+			t.Fatalf("attempt %d should be allowed", i+1)
+		}
+	}
+	allowed, retryAfter := b.Allow("10.0.0.1")
+	if allowed || retryAfter != 5*time.Minute {
+		t.Fatalf("expected 5m ban, got %v %v", allowed, retryAfter)
+	}
+	if banned, _ := a.Banned("10.0.0.1"); !banned {
+		t.Error("ban should be visible on the other replica")
+	}
+	if banned, _ := a.Banned("10.0.0.2"); banned {
+		t.Error("other keys should not be banned")
+	}
+
+	// 封禁过期后重新计数
+	mr.FastForward(5 * time.Minute)
+	if allowed, _ := a.Allow("10.0.0.1"); !allowed {
+		t.Error("expected attempts to restart after ban expires")
+	}
+
+	// Reset 清除计数和封禁
+	b.Reset("10.0.0.1")
+	if mr.Exists("test:global:attempts:10.0.0.1") {
+		t.Error("expected attempts key to be deleted by Reset")
+	}
+}
+
+// TestRedisLimiter_RecordFailure 测试认证失败计数
+func TestRedisLimiter_RecordFailure(t *testing.T) {
+	_, client := newTestRedis(t)
+	l := NewRedisLimiter(client, 2, time.Minute, time.Minute, RedisOptions{Prefix: "test:policy:login:"})
+	defer l.Stop()
+
+	if banned, _ := l.RecordFailure("ip"); banned {
+		t.Fatal("first failure should not ban")
+	}
+	banned, retryAfter := l.RecordFailure("ip")
+	if !banned || retryAfter != time.Minute {
+		t.Fatalf("second failure should ban for 1m, got %v %v", banned, retryAfter)
+	}
+	if banned, retryAfter := l.Banned("ip"); !banned || retryAfter <= 0 {
+		t.Errorf("expected ban, got %v %v", banned, retryAfter)
+	}
+}
+
+// TestRedisLimiter_ConcurrentAttempts 测试并发尝试的原子计数（同一毫秒内的尝试不会合并）
+func TestRedisLimiter_ConcurrentAttempts(t *testing.T) {
+	_, client := newTestRedis(t)
+	l := NewRedisLimiter(client, 20, time.Minute, time.Minute, RedisOptions{Prefix: "test:"})
+	defer l.Stop()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := l.Allow("k"); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 20 {
+		t.Errorf("expected exactly 20 allowed attempts, got %d", allowed)
+	}
+}
+
+// TestRedisLimiter_Degrade 测试 Redis 不可用时退回本地限流，恢复后切回
+func TestRedisLimiter_Degrade(t *testing.T) {
+	mr, client := newTestRedis(t)
+
+	var mu sync.Mutex
+	var statuses []error
+	l := NewRedisLimiter(client, 2, time.Minute, time.Minute, RedisOptions{
+		Prefix:        "test:",
+		Timeout:       50 * time.Millisecond,
+		RetryInterval: 10 * time.Millisecond,
+		OnStatus: func(err error) {
+			mu.Lock()
+			statuses = append(statuses, err)
+			mu.Unlock()
+		},
+	})
+	defer l.Stop()
+
+	mr.Close()
+
+	// 本地限流仍然生效
+	for i := 0; i < 2; i++ {
+		if allowed, _ := l.Allow("ip"); !allowed {
+			t.Fatalf("attempt %d should be allowed by the local limiter", i+1)
+		}
+	}
+	if allowed, _ := l.Allow("ip"); allowed {
+		t.Fatal("local limiter should ban after max attempts")
+	}
+	if !l.Degraded() {
+		t.Error("expected limiter to report degraded state")
+	}
+
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("failed to restart redis: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if allowed, _ := l.Allow("other"); !allowed {
+		t.Fatal("expected Redis to be used again after recovery")
+	}
+	if l.Degraded() || !mr.Exists("test:attempts:other") {
+		t.Error("expected limiter to switch back to Redis")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(statuses) != 2 || statuses[0] == nil || statuses[1] != nil {
+		t.Errorf("expected one unavailable and one recovered status, got %v", statuses)
+	}
+}
@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Ban 一个处于封禁期的 key（用于快照）
+type Ban struct {
+	Key   string    `json:"key"`
+	Until time.Time `json:"until"`
+}
+
+// Snapshot 封禁状态快照（关闭时写入磁盘，启动时恢复）
+type Snapshot struct {
+	SavedAt  time.Time        `json:"saved_at"`
+	Global   []Ban            `json:"global,omitempty"`   // 全局 [rate_limit] 的封禁
+	Policies map[string][]Ban `json:"policies,omitempty"` // 策略级认证失败封禁（按策略名称索引）
+}
+
+// Inherit 从旧的限流器复制记录（用于配置重载），并应用当前限流器的参数：
+// 保留旧时间窗口内的估算尝试次数，封禁从原来的开始时间按新的封禁时长重新计算，已经结束的封禁被丢弃。
+// 记录按最近使用的顺序复制，超出新的记录数上限时淘汰最久未使用的记录
+func (l *Limiter) Inherit(old *Limiter) {
+	if old == nil || old == l {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	shift := int64(l.banDuration - old.banDuration)
+	for i := range old.shards {
+		src := &old.shards[i]
+		src.mu.Lock()
+		for rec := src.tail; rec != nil; rec = rec.newer {
+			attempts := old.estimate(rec, now)
+			var bannedUntil int64
+			if rec.bannedUntil != 0 {
+				if until := rec.bannedUntil + shift; until > now {
+					bannedUntil = until
+				} else {
+					// 按新的封禁时长已经解封：与 Allow 中封禁过期的处理一致，重新开始计数
+					attempts = 0
+				}
+			}
+			if attempts == 0 && bannedUntil == 0 {
+				continue
+			}
+
+			s := l.shardFor(rec.key)
+			s.mu.Lock()
+			inherited := s.getOrCreate(rec.key, now)
+			inherited.restart(now)
+			inherited.curr = attempts
+			inherited.bannedUntil = bannedUntil
+			s.mu.Unlock()
+		}
+		src.mu.Unlock()
+	}
+}
+
+// Bans 返回当前所有处于封禁期的 key
+func (l *Limiter) Bans() []Ban {
+	now := time.Now().UnixNano()
+	var bans []Ban
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.mu.Lock()
+		for key, rec := range s.records {
+			if now < rec.bannedUntil {
+				bans = append(bans, Ban{Key: key, Until: time.Unix(0, rec.bannedUntil)})
+			}
+		}
+		s.mu.Unlock()
+	}
+	return bans
+}
+
+// RestoreBans 恢复快照中的封禁，返回恢复的数量
+// 已过期的封禁被丢弃；剩余时长不超过当前配置的封禁时长
+func (l *Limiter) RestoreBans(bans []Ban) int {
+	now := time.Now().UnixNano()
+	maxUntil := now + int64(l.banDuration)
+	restored := 0
+	for _, ban := range bans {
+		until := ban.Until.UnixNano()
+		if until <= now {
+			continue
+		}
+		until = min(until, maxUntil)
+
+		s := l.shardFor(ban.Key)
+		s.mu.Lock()
+		rec := s.getOrCreate(ban.Key, now)
+		rec.bannedUntil = max(rec.bannedUntil, until)
+		s.mu.Unlock()
+		restored++
+	}
+	return restored
+}
+
+// Inherit 从旧的令牌桶复制剩余令牌（用于配置重载），令牌数不超过新的桶容量
+func (b *TokenBucket) Inherit(old *TokenBucket) {
+	if old == nil || old == b {
+		return
+	}
+
+	now := b.now().UnixNano()
+	for i := range old.shards {
+		src := &old.shards[i]
+		src.mu.Lock()
+		for rec := src.tail; rec != nil; rec = rec.newer {
+			old.refill(rec, now)
+			if rec.curr >= float64(b.burst) {
+				continue // 与新建的桶等价
+			}
+
+			s := b.shardFor(rec.key)
+			s.mu.Lock()
+			inherited := s.getOrCreate(rec.key, now)
+			inherited.curr = rec.curr
+			inherited.windowStart = now
+			s.mu.Unlock()
+		}
+		src.mu.Unlock()
+	}
+}
+
+// LoadSnapshot 读取封禁快照（文件不存在时返回空快照）
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid rate limit snapshot %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Save 写入封禁快照（先写临时文件再重命名，避免中途退出留下不完整的文件）
+func (s *Snapshot) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLimiter_Inherit 测试配置重载时复制记录并应用新的参数
+func TestLimiter_Inherit(t *testing.T) {
+	old := NewLimiter(3, time.Minute, 10*time.Minute)
+	defer old.Stop()
+
+	now := time.Now()
+	putRecord(old, "banned", 3, now.Add(8*time.Minute)) // 2 分钟前开始封禁
+	putRecord(old, "short", 3, now.Add(2*time.Minute))  // 8 分钟前开始封禁
+	old.Allow("attempts")
+
+	l := NewLimiter(1, 30*time.Second, 5*time.Minute)
+	defer l.Stop()
+	l.Inherit(old)
+
+	// 新的封禁时长 5 分钟：2 分钟前开始的封禁还剩约 3 分钟
+	if banned, retryAfter := l.Banned("banned"); !banned || retryAfter > 3*time.Minute || retryAfter < 2*time.Minute {
+		t.Errorf("expected ban shortened to ~3m, got %v %v", banned, retryAfter)
+	}
+	// 8 分钟前开始的封禁按新时长已结束
+	if banned, _ := l.Banned("short"); banned {
+		t.Error("expected ban to end under the new ban duration")
+	}
+	if attempts, _, _ := l.GetStats("short"); attempts != 0 {
+		t.Errorf("expected attempts cleared after ban ended, got %d", attempts)
+	}
+	// 尝试次数保留，并按新的阈值立即触发封禁
+	if attempts, _, _ := l.GetStats("attempts"); attempts != 1 {
+		t.Errorf("expected 1 inherited attempt, got %d", attempts)
+	}
+	if allowed, _ := l.Allow("attempts"); allowed {
+		t.Error("expected new max_attempts to apply to inherited attempts")
+	}
+}
+
+// putRecord 直接设置记录的尝试次数和封禁截止时间
+func putRecord(l *Limiter, key string, attempts float64, bannedUntil time.Time) {
+	s := l.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.getOrCreate(key, time.Now().UnixNano())
+	rec.curr = attempts
+	rec.bannedUntil = bannedUntil.UnixNano()
+}
+
+// TestLimiter_RestoreBans 测试从快照恢复封禁
+func TestLimiter_RestoreBans(t *testing.T) {
+	l := NewLimiter(3, time.Minute, 5*time.Minute)
+	defer l.Stop()
+
+	now := time.Now()
+	restored := l.RestoreBans([]Ban{
+		{Key: "active", Until: now.Add(time.Minute)},
+		{Key: "expired", Until: now.Add(-time.Second)},
+		{Key: "too-long", Until: now.Add(time.Hour)},
+	})
+	if restored != 2 {
+		t.Fatalf("expected 2 restored bans, got %d", restored)
+	}
+	if banned, _ := l.Banned("active"); !banned {
+		t.Error("expected active ban to be restored")
+	}
+	if banned, _ := l.Banned("expired"); banned {
+		t.Error("expected expired ban to be discarded")
+	}
+	if _, retryAfter := l.Banned("too-long"); retryAfter > 5*time.Minute {
+		t.Errorf("expected ban capped at ban duration, got %v", retryAfter)
+	}
+
+	if bans := l.Bans(); len(bans) != 2 {
+		t.Errorf("expected 2 active bans, got %v", bans)
+	}
+}
+
+// TestTokenBucket_Inherit 测试重载时保留剩余令牌
+func TestTokenBucket_Inherit(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	old := newTokenBucket(10, time.Minute, 10, clock.now, time.Hour, Options{})
+	defer old.Stop()
+	for i := 0; i < 8; i++ {
+		old.Take("k")
+	}
+	old.Take("full")
+	old.cleanup()
+
+	b := newTokenBucket(5, time.Minute, 5, clock.now, time.Hour, Options{})
+	defer b.Stop()
+	b.Inherit(old)
+
+	if q := b.Take("k"); q.Remaining != 1 {
+		t.Errorf("expected 2 inherited tokens, got remaining %d", q.Remaining)
+	}
+	if q := b.Take("full"); q.Remaining != 4 {
+		t.Errorf("expected tokens capped at new burst, got remaining %d", q.Remaining)
+	}
+}
+
+// TestSnapshot_SaveLoad 测试快照写入和读取
+func TestSnapshot_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+
+	s, err := LoadSnapshot(path)
+	if err != nil || len(s.Global) != 0 {
+		t.Fatalf("expected empty snapshot for missing file, got %+v, %v", s, err)
+	}
+
+	until := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+	s = &Snapshot{
+		SavedAt:  time.Now().UTC(),
+		Global:   []Ban{{Key: "10.0.0.1", Until: until}},
+		Policies: map[string][]Ban{"login": {{Key: "10.0.0.2", Until: until}}},
+	}
+	if err := s.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected snapshot with 0600 permissions, got %v %v", info.Mode().Perm(), err)
+	}
+
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if len(loaded.Global) != 1 || !loaded.Global[0].Until.Equal(until) || loaded.Policies["login"][0].Key != "10.0.0.2" {
+		t.Errorf("unexpected snapshot: %+v", loaded)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSnapshot(path); err == nil {
+		t.Error("expected error for corrupt snapshot")
+	}
+}
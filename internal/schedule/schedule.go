@@ -0,0 +1,204 @@
+// Package schedule 实现基于时间窗口的访问控制
+//
+// 支持两种写法（可混用，任意一条满足即视为在时间窗口内）:
+//
+//	windows = ["mon-fri 09:00-18:00", "sat 22:00-02:00"]  # 星期 + 时间段，结束时间早于开始时间表示跨午夜
+//	cron    = ["* 9-17 * * mon-fri"]                        # 5 段 cron（分 时 日 月 周），匹配的每一分钟都在窗口内
+//
+// 所有时间按配置的 IANA 时区解释（默认 UTC）。
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule 编译后的时间窗口
+type Schedule struct {
+	loc     *time.Location
+	windows []window
+	crons   []*cronSpec
+}
+
+// Parse 编译时间窗口配置
+// timezone 为空时使用 UTC；windows 和 crons 至少需要一条
+func Parse(timezone string, windows, crons []string) (*Schedule, error) {
+	if len(windows) == 0 && len(crons) == 0 {
+		return nil, fmt.Errorf("at least one window or cron expression is required")
+	}
+
+	loc := time.UTC
+	if timezone != "" {
+		l, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		loc = l
+	}
+
+	s := &Schedule{loc: loc}
+	for i, w := range windows {
+		parsed, err := parseWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("windows[%d]: %w", i, err)
+		}
+		s.windows = append(s.windows, parsed)
+	}
+	for i, c := range crons {
+		parsed, err := parseCron(c)
+		if err != nil {
+			return nil, fmt.Errorf("cron[%d]: %w", i, err)
+		}
+		s.crons = append(s.crons, parsed)
+	}
+
+	return s, nil
+}
+
+// Location 返回时间窗口使用的时区
+func (s *Schedule) Location() *time.Location {
+	return s.loc
+}
+
+// Active 检查给定时间是否在任意一个时间窗口内
+func (s *Schedule) Active(t time.Time) bool {
+	local := t.In(s.loc)
+	for i := range s.windows {
+		if s.windows[i].contains(local) {
+			return true
+		}
+	}
+	for _, c := range s.crons {
+		if c.matches(local) {
+			return true
+		}
+	}
+	return false
+}
+
+// window 星期 + 时间段（分钟，左闭右开）
+type window struct {
+	days       [7]bool // 按 time.Weekday 索引
+	start, end int     // 一天中的分钟数，end 可为 1440（24:00）
+}
+
+// contains 检查本地时间是否在窗口内
+// 跨午夜的窗口（start >= end）属于开始那一天，午夜之后的部分落在次日
+func (w *window) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	wd := t.Weekday()
+
+	if w.start < w.end {
+		return w.days[wd] && minute >= w.start && minute < w.end
+	}
+	if w.days[wd] && minute >= w.start {
+		return true
+	}
+	return w.days[(wd+6)%7] && minute < w.end
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseWindow 解析 "[星期] HH:MM-HH:MM"
+// 星期支持 "*"、单个名称（mon）、范围（mon-fri，可跨周末如 fri-mon）和逗号列表；省略时表示每天
+func parseWindow(s string) (window, error) {
+	var w window
+
+	fields := strings.Fields(s)
+	var daySpec, timeSpec string
+	switch len(fields) {
+	case 1:
+		daySpec, timeSpec = "*", fields[0]
+	case 2:
+		daySpec, timeSpec = fields[0], fields[1]
+	default:
+		return w, fmt.Errorf("invalid window %q (expected \"[days] HH:MM-HH:MM\")", s)
+	}
+
+	days, err := parseDays(daySpec)
+	if err != nil {
+		return w, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	w.days = days
+
+	startStr, endStr, ok := strings.Cut(timeSpec, "-")
+	if !ok {
+		return w, fmt.Errorf("invalid window %q: time range must be HH:MM-HH:MM", s)
+	}
+	if w.start, err = parseClock(startStr, false); err != nil {
+		return w, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.end, err = parseClock(endStr, true); err != nil {
+		return w, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.start == w.end {
+		return w, fmt.Errorf("invalid window %q: start and end are equal", s)
+	}
+
+	return w, nil
+}
+
+func parseDays(spec string) ([7]bool, error) {
+	var days [7]bool
+	if spec == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return days, nil
+	}
+
+	for _, item := range strings.Split(strings.ToLower(spec), ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		start, ok := dayNames[from]
+		if !ok {
+			return days, fmt.Errorf("unknown day %q", from)
+		}
+		end := start
+		if isRange {
+			if end, ok = dayNames[to]; !ok {
+				return days, fmt.Errorf("unknown day %q", to)
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseClock 解析 HH:MM，返回一天中的分钟数；allowEndOfDay 时接受 24:00
+func parseClock(s string, allowEndOfDay bool) (int, error) {
+	if len(s) != 5 || s[2] != ':' || !isDigits(s[:2]) || !isDigits(s[3:]) {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	h := int(s[0]-'0')*10 + int(s[1]-'0')
+	m := int(s[3]-'0')*10 + int(s[4]-'0')
+	if allowEndOfDay && h == 24 && m == 0 {
+		return 24 * 60, nil
+	}
+	if h > 23 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestActive_Windows 测试星期 + 时间段窗口
+func TestActive_Windows(t *testing.T) {
+	s, err := Parse("Europe/Berlin", []string{"mon-fri 09:00-18:00", "sat 22:00-02:00"}, nil)
+	if err != nil {
+		t.Fatalf("Parse error = %v", err)
+	}
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"Weekday start inclusive", time.Date(2024, 6, 10, 9, 0, 0, 0, berlin), true},
+		{"Weekday end exclusive", time.Date(2024, 6, 14, 18, 0, 0, 0, berlin), false},
+		{"Weekday before hours", time.Date(2024, 6, 12, 8, 59, 0, 0, berlin), false},
+		{"UTC converted to local", time.Date(2024, 6, 10, 7, 30, 0, 0, time.UTC), true}, // 柏林 09:30
+		{"Saturday late night", time.Date(2024, 6, 15, 23, 0, 0, 0, berlin), true},
+		{"Past midnight belongs to Saturday window", time.Date(2024, 6, 16, 1, 59, 0, 0, berlin), true},
+		{"Sunday after window", time.Date(2024, 6, 16, 2, 0, 0, 0, berlin), false},
+		{"Saturday daytime", time.Date(2024, 6, 15, 12, 0, 0, 0, berlin), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Active(tt.t); got != tt.want {
+				t.Errorf("Active(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestActive_Cron 测试 cron 表达式
+func TestActive_Cron(t *testing.T) {
+	tests := []struct {
+		name string
+		cron string
+		t    time.Time
+		want bool
+	}{
+		{"Business hours", "* 9-17 * * mon-fri", time.Date(2024, 6, 10, 17, 59, 0, 0, time.UTC), true},
+		{"Business hours weekend", "* 9-17 * * mon-fri", time.Date(2024, 6, 9, 12, 0, 0, 0, time.UTC), false},
+		{"Step minutes", "*/15 * * * *", time.Date(2024, 6, 10, 3, 45, 0, 0, time.UTC), true},
+		{"Step minutes miss", "*/15 * * * *", time.Date(2024, 6, 10, 3, 46, 0, 0, time.UTC), false},
+		{"Sunday as 7", "* * * * 7", time.Date(2024, 6, 9, 0, 0, 0, 0, time.UTC), true},
+		{"Month names", "* * * jun-aug *", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), true},
+		{"Day or weekday", "* * 1 * mon", time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC), true},
+		{"Day or weekday miss", "* * 1 * mon", time.Date(2024, 6, 11, 0, 0, 0, 0, time.UTC), false},
+		// 以 * 开头的日字段（*/2）不受限：日和周按“且”匹配（奇数日且为周一）
+		{"Step day and weekday", "* 9 */2 * mon", time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC), true},
+		{"Step day and weekday even day", "* 9 */2 * mon", time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC), false},
+		{"Step day and weekday not monday", "* 9 */2 * mon", time.Date(2024, 6, 11, 9, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse("", nil, []string{tt.cron})
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.cron, err)
+			}
+			if got := s.Active(tt.t); got != tt.want {
+				t.Errorf("Active(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParse_Errors 测试配置错误
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		windows  []string
+		cron     []string
+		errMsg   string
+	}{
+		{"Empty", "", nil, nil, "at least one"},
+		{"Bad timezone", "Mars/Olympus", []string{"09:00-17:00"}, nil, "invalid timezone"},
+		{"Bad day", "", []string{"mon-fry 09:00-17:00"}, nil, `unknown day "fry"`},
+		{"Bad time", "", []string{"mon 9:00-17:00"}, nil, "expected HH:MM"},
+		{"Hour out of range", "", []string{"mon 09:00-25:00"}, nil, "invalid time"},
+		{"Empty window", "", []string{"mon 09:00-09:00"}, nil, "start and end are equal"},
+		{"Too many cron fields", "", nil, []string{"* * * * * *"}, "expected 5 fields"},
+		{"Cron out of range", "", nil, []string{"* 24 * * *"}, "hour: value 24 out of range"},
+		{"Cron bad step", "", nil, []string{"*/0 * * * *"}, "invalid step"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.timezone, tt.windows, tt.cron)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/audit"
+	"github.com/nerdneilsfield/tiny-auth/internal/geoip"
+	"github.com/nerdneilsfield/tiny-auth/internal/policy"
+	"github.com/nerdneilsfield/tiny-auth/internal/ratelimit"
+)
+
+// adminLimiter 管理 API 可以操作的一个限流器
+type adminLimiter struct {
+	scope   string // "global" 或 "policy:<name>"
+	manager ratelimit.Manager
+}
+
+// adminBan 封禁列表中的一项
+type adminBan struct {
+	Key        string    `json:"key"`
+	Until      time.Time `json:"until"`
+	RetryAfter int64     `json:"retry_after"` // 剩余秒数
+}
+
+// adminBanRequest POST /admin/ratelimit/:ip/ban 的请求体
+type adminBanRequest struct {
+	DurationSecs int    `json:"duration_secs"`
+	Scope        string `json:"scope"` // 默认 "global"
+}
+
+// registerAdminRoutes 注册管理 API（未启用时返回 404，热重载启用 / 禁用后立即生效）
+func (s *Server) registerAdminRoutes(app *fiber.App) {
+	admin := app.Group("/admin/ratelimit", s.adminAuth)
+	admin.Get("/", s.handleAdminListBans)
+	admin.Delete("/", s.handleAdminClear)
+	admin.Get("/:ip", s.handleAdminLookup)
+	admin.Delete("/:ip", s.handleAdminUnban)
+	admin.Post("/:ip/ban", s.handleAdminBan)
+}
+
+// adminAuth 管理 API 认证：检查来源 IP（admin.allowed_ips 和全局 IP 过滤 / 国家 / ASN 规则），
+// 并要求凭证拥有 admin.roles 中的任一角色且在凭证的访问时间窗口内
+// 认证失败计入全局速率限制（与 ForwardAuth 共用封禁）
+func (s *Server) adminAuth(c *fiber.Ctx) error {
+	s.mu.RLock()
+	cfg := s.Config
+	store := s.Store
+	trustedCIDRs := s.trustedCIDRs
+	pipeline := s.pipeline
+	rateLimiter := s.RateLimiter
+	ipFilter := s.ipFilter
+	geoDB := s.geoDB
+	// 请求结束前不关闭取得的审计日志（重载时替换下的记录器等待计数归零后关闭）
+	setRequestAudit(c, s.Audit)
+	inflight := s.inflight
+	inflight.Add(1)
+	s.mu.RUnlock()
+	defer inflight.Done()
+
+	if !cfg.Admin.Enabled {
+		return adminError(c, fiber.StatusNotFound, "Not Found")
+	}
+
+	clientIP := getClientIP(c, cfg, trustedCIDRs)
+	var geo geoip.Info
+	if geoDB != nil {
+		geo = geoDB.Lookup(clientIP)
+	}
+	event := audit.Event{
+		RequestID:    getRequestID(c),
+		ClientIP:     clientIP,
+		DirectIP:     c.IP(),
+		TrustedProxy: isTrustedProxy(c.IP(), trustedCIDRs),
+		Country:      geo.Country,
+		ASN:          geo.ASN,
+		URI:          c.OriginalURL(),
+		Method:       c.Method(),
+	}
+
+	// allowed_ips 无效时拒绝所有请求，不会因为配置错误而放开来源限制
+	nets, err := cfg.Admin.AllowedNetworks()
+	if err != nil {
+		s.Logger.Error("invalid admin.allowed_ips, rejecting admin request", zap.Error(err))
+		s.adminAudit(c, &event, "denied", "invalid_allowed_ips", fiber.StatusInternalServerError)
+		return adminError(c, fiber.StatusInternalServerError, "Invalid admin configuration")
+	}
+	if len(nets) > 0 && !inNetworks(clientIP, nets) {
+		s.adminAudit(c, &event, "denied", "ip_not_allowed", fiber.StatusForbidden)
+		return adminError(c, fiber.StatusForbidden, "Client IP not allowed")
+	}
+	// 全局 IP 过滤和国家 / ASN 规则同样适用于管理 API
+	if screened, _ := pipeline.ScreenClient(ipFilter, clientIP, geo); screened != nil {
+		event.Rule = screened.Rule
+		s.adminAudit(c, &event, "blocked", screened.Reason, screened.Status)
+		return adminError(c, screened.Status, "Client IP not allowed")
+	}
+
+	limitKey := ratelimit.ClientKey(clientIP, cfg.RateLimit.IPv6Prefix)
+	if rateLimiter != nil {
+		if banned, retryAfter := rateLimiter.Banned(limitKey); banned {
+			s.adminAudit(c, &event, "rate_limited", "rate_limit_exceeded", fiber.StatusTooManyRequests)
+			c.Set("Retry-After", formatSeconds(retryAfter))
+			return adminError(c, fiber.StatusTooManyRequests, "Too many authentication attempts")
+		}
+	}
+
+	authorization, apiKey := c.Get("Authorization"), c.Get("X-Api-Key")
+	result := pipeline.Authenticate(&AuthRequest{Authorization: authorization, APIKey: apiKey})
+	if result == nil {
+		if rateLimiter != nil && (authorization != "" || apiKey != "") {
+			rateLimiter.RecordFailure(limitKey)
+		}
+		s.adminAudit(c, &event, "denied", "invalid_credentials", fiber.StatusUnauthorized)
+		c.Set("WWW-Authenticate", `Basic realm="tiny-auth admin"`)
+		return adminError(c, fiber.StatusUnauthorized, "Unauthorized")
+	}
+
+	event.AuthMethod = result.Method
+	event.AuthName = result.Name
+	event.User = result.User
+	event.Roles = result.Roles
+	if !slices.ContainsFunc(cfg.Admin.Roles, func(role string) bool { return slices.Contains(result.Roles, role) }) {
+		s.adminAudit(c, &event, "denied", "policy_requirements_not_met", fiber.StatusForbidden)
+		return adminError(c, fiber.StatusForbidden, "Admin role required")
+	}
+	if !policy.CredentialInSchedule(result, store, s.now()) {
+		s.adminAudit(c, &event, "denied", "outside_schedule", fiber.StatusForbidden)
+		return adminError(c, fiber.StatusForbidden, "Access not allowed at this time")
+	}
+
+	c.Locals(adminEventKey, event)
+	return c.Next()
+}
+
+// adminEventKey 保存已认证管理员审计事件的 Locals key
+const adminEventKey = "admin_event"
+
+// handleAdminListBans GET /admin/ratelimit：列出各限流器处于封禁期的 key
+func (s *Server) handleAdminListBans(c *fiber.Ctx) error {
+	limiters, err := s.adminLimiters(c.Query("scope"))
+	if err != nil {
+		return adminError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	now := time.Now()
+	result := make([]fiber.Map, 0, len(limiters))
+	for _, l := range limiters {
+		bans, err := l.manager.ListBans()
+		if err != nil {
+			s.Logger.Error("admin: failed to list bans", zap.String("scope", l.scope), zap.Error(err))
+			return adminError(c, fiber.StatusServiceUnavailable, "Failed to list bans: "+err.Error())
+		}
+		sort.Slice(bans, func(i, j int) bool { return bans[i].Key < bans[j].Key })
+
+		items := make([]adminBan, 0, len(bans))
+		for _, b := range bans {
+			items = append(items, adminBan{Key: b.Key, Until: b.Until.UTC(), RetryAfter: ceilSeconds(b.Until.Sub(now))})
+		}
+		result = append(result, fiber.Map{"scope": l.scope, "bans": items})
+	}
+	return c.JSON(fiber.Map{"limiters": result})
+}
+
+// handleAdminLookup GET /admin/ratelimit/:ip：查询 IP 在各限流器中的状态
+func (s *Server) handleAdminLookup(c *fiber.Ctx) error {
+	key, err := s.adminKey(c)
+	if err != nil {
+		return adminError(c, fiber.StatusBadRequest, err.Error())
+	}
+	limiters, err := s.adminLimiters(c.Query("scope"))
+	if err != nil {
+		return adminError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	result := make([]fiber.Map, 0, len(limiters))
+	for _, l := range limiters {
+		status, err := l.manager.Lookup(key)
+		if err != nil {
+			s.Logger.Error("admin: failed to look up key", zap.String("scope", l.scope), zap.Error(err))
+			return adminError(c, fiber.StatusServiceUnavailable, "Failed to look up key: "+err.Error())
+		}
+		result = append(result, fiber.Map{
+			"scope":       l.scope,
+			"attempts":    status.Attempts,
+			"banned":      status.Banned,
+			"retry_after": ceilSeconds(status.RetryAfter),
+		})
+	}
+	return c.JSON(fiber.Map{"ip": c.Params("ip"), "key": key, "limiters": result})
+}
+
+// handleAdminUnban DELETE /admin/ratelimit/:ip：清除 IP 的尝试记录和封禁（默认所有限流器）
+func (s *Server) handleAdminUnban(c *fiber.Ctx) error {
+	key, err := s.adminKey(c)
+	if err != nil {
+		return adminError(c, fiber.StatusBadRequest, err.Error())
+	}
+	limiters, err := s.adminLimiters(c.Query("scope"))
+	if err != nil {
+		return adminError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	scopes := make([]string, 0, len(limiters))
+	for _, l := range limiters {
+		if err := l.manager.Unban(key); err != nil {
+			s.Logger.Error("admin: failed to unban key", zap.String("scope", l.scope), zap.Error(err))
+			return adminError(c, fiber.StatusServiceUnavailable, "Failed to unban "+l.scope+": "+err.Error())
+		}
+		scopes = append(scopes, l.scope)
+	}
+	s.adminAction(c, "ratelimit_unban", key, scopes)
+	return c.JSON(fiber.Map{"key": key, "scopes": scopes})
+}
+
+// handleAdminBan POST /admin/ratelimit/:ip/ban：手动封禁 IP 指定时长
+func (s *Server) handleAdminBan(c *fiber.Ctx) error {
+	key, err := s.adminKey(c)
+	if err != nil {
+		return adminError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	var req adminBanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return adminError(c, fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if req.DurationSecs <= 0 {
+		return adminError(c, fiber.StatusBadRequest, "duration_secs must be positive")
+	}
+	if req.Scope == "" {
+		req.Scope = "global"
+	}
+	limiters, err := s.adminLimiters(req.Scope)
+	if err != nil {
+		return adminError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	duration := time.Duration(req.DurationSecs) * time.Second
+	if err := limiters[0].manager.Ban(key, duration); err != nil {
+		s.Logger.Error("admin: failed to ban key", zap.String("scope", req.Scope), zap.Error(err))
+		return adminError(c, fiber.StatusServiceUnavailable, "Failed to ban key: "+err.Error())
+	}
+	s.adminAction(c, "ratelimit_ban", key, []string{req.Scope}, zap.Duration("duration", duration))
+	return c.JSON(fiber.Map{
+		"key":   key,
+		"scope": req.Scope,
+		"until": time.Now().Add(duration).UTC(),
+	})
+}
+
+// handleAdminClear DELETE /admin/ratelimit：清除所有记录和封禁（可用 ?scope= 限定限流器）
+func (s *Server) handleAdminClear(c *fiber.Ctx) error {
+	limiters, err := s.adminLimiters(c.Query("scope"))
+	if err != nil {
+		return adminError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	scopes := make([]string, 0, len(limiters))
+	for _, l := range limiters {
+		if err := l.manager.Clear(); err != nil {
+			s.Logger.Error("admin: failed to clear limiter", zap.String("scope", l.scope), zap.Error(err))
+			return adminError(c, fiber.StatusServiceUnavailable, "Failed to clear "+l.scope+": "+err.Error())
+		}
+		scopes = append(scopes, l.scope)
+	}
+	s.adminAction(c, "ratelimit_clear", "*", scopes)
+	return c.JSON(fiber.Map{"scopes": scopes})
+}
+
+// adminLimiters 返回 scope 对应的限流器（scope 为空时返回全部：全局 + 按名称排序的策略认证失败限流器）
+func (s *Server) adminLimiters(scope string) ([]adminLimiter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var limiters []adminLimiter
+	add := func(name string, b ratelimit.Backend) {
+		if m, ok := b.(ratelimit.Manager); ok {
+			limiters = append(limiters, adminLimiter{scope: name, manager: m})
+		}
+	}
+
+	if s.RateLimiter != nil {
+		add("global", s.RateLimiter)
+	}
+	names := make([]string, 0, len(s.policyLimiters))
+	for name, l := range s.policyLimiters {
+		if l.failures != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add("policy:"+name, s.policyLimiters[name].failures)
+	}
+
+	if scope == "" {
+		return limiters, nil
+	}
+	for _, l := range limiters {
+		if l.scope == scope {
+			return []adminLimiter{l}, nil
+		}
+	}
+	return nil, errors.New("unknown scope " + scope + " (expected global or policy:<name> with failure limits)")
+}
+
+// adminKey 将路径中的 IP 转换为限流 key（IPv6 按 rate_limit.ipv6_prefix 聚合）
+func (s *Server) adminKey(c *fiber.Ctx) (string, error) {
+	ip := strings.Clone(c.Params("ip")) // Fiber 的参数引用请求缓冲区，保存为 key 前需要复制
+	if net.ParseIP(strings.SplitN(ip, "%", 2)[0]) == nil {
+		return "", errors.New("invalid IP address " + ip)
+	}
+	return ratelimit.ClientKey(ip, s.GetConfig().RateLimit.IPv6Prefix), nil
+}
+
+// adminAction 记录管理操作（审计日志 + 应用日志）
+func (s *Server) adminAction(c *fiber.Ctx, action, target string, scopes []string, fields ...zap.Field) {
+	event, _ := c.Locals(adminEventKey).(audit.Event)
+	event.Target = target
+	event.Scope = strings.Join(scopes, ",")
+	s.adminAudit(c, &event, "admin", action, fiber.StatusOK)
+
+	s.Logger.Info("admin action",
+		append([]zap.Field{
+			zap.String("action", action),
+			zap.String("target", target),
+			zap.Strings("scopes", scopes),
+			zap.String("admin", event.AuthName),
+			zap.String("client_ip", event.ClientIP),
+		}, fields...)...,
+	)
+}
+
+// adminAudit 写入管理 API 的审计事件
+func (s *Server) adminAudit(c *fiber.Ctx, event *audit.Event, result, reason string, status int) {
+	event.Timestamp = time.Now().UTC()
+	event.Result = result
+	event.Reason = reason
+	event.Status = status
+	if event.RequestID == "" {
+		event.RequestID = getRequestID(c)
+	}
+	s.writeAudit(c, event)
+}
+
+// adminError 返回管理 API 的 JSON 错误响应
+func adminError(c *fiber.Ctx, status int, message string) error {
+	c.Set("Cache-Control", "no-store")
+	return c.Status(status).JSON(fiber.Map{
+		"error":      message,
+		"request_id": getRequestID(c),
+	})
+}
+
+// inNetworks 检查 IP 是否在任一网段内
+func inNetworks(ip string, nets []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSeconds 将时长格式化为 Retry-After 秒数
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatInt(retryAfterSeconds(d), 10)
+}
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestAdminAPI 测试管理 API：认证、查询、手动封禁、解封、清除和审计日志
+func TestAdminAPI(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Port:         "3000",
+			AuthPath:     "/auth",
+			ReadTimeout:  30,
+			WriteTimeout: 30,
+		},
+		Audit: config.AuditConfig{Enabled: true, Output: auditPath},
+		Admin: config.AdminConfig{Enabled: true, Roles: []string{"admin"}},
+		BasicAuths: []config.BasicAuthConfig{
+			{Name: "ops", User: "ops", Pass: "opspass", Roles: []string{"admin"}},
+			{Name: "user1", User: "user1", Pass: "pass1", Roles: []string{"user"}},
+		},
+		RateLimit: config.RateLimitConfig{Enabled: true, MaxAttempts: 5, WindowSecs: 60, BanSecs: 60},
+		RoutePolicies: []config.RoutePolicy{
+			{
+				Name:       "login",
+				PathPrefix: "/login",
+				RateLimit:  &config.PolicyRateLimit{FailedAttempts: 3, FailedWindowSecs: 60, FailedBanSecs: 60, Key: "ip"},
+			},
+		},
+	}
+
+	srv := createTestServer(t, cfg)
+	admin := "Basic b3BzOm9wc3Bhc3M=" // ops:opspass
+	send := func(method, path, authHeader, body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		}
+		resp, err := srv.App.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		var out map[string]interface{}
+		_ = json.Unmarshal(data, &out)
+		return resp.StatusCode, out
+	}
+
+	t.Run("Authentication", func(t *testing.T) {
+		if status, _ := send("GET", "/admin/ratelimit", "", ""); status != 401 {
+			t.Errorf("expected 401 without credentials, got %d", status)
+		}
+		if status, _ := send("GET", "/admin/ratelimit", "Basic dXNlcjE6cGFzczE=", ""); status != 403 {
+			t.Errorf("expected 403 without admin role, got %d", status)
+		}
+		if status, _ := send("GET", "/admin/ratelimit", admin, ""); status != 200 {
+			t.Errorf("expected 200 for admin, got %d", status)
+		}
+	})
+
+	t.Run("Ban, lookup and unban", func(t *testing.T) {
+		status, body := send("POST", "/admin/ratelimit/10.0.0.5/ban", admin, `{"duration_secs": 3600}`)
+		if status != 200 || body["scope"] != "global" {
+			t.Fatalf("ban: expected 200 for global scope, got %d %v", status, body)
+		}
+		if banned, retryAfter := srv.RateLimiter.Banned("10.0.0.5"); !banned || retryAfter.Minutes() < 59 {
+			t.Fatalf("expected 1h manual ban, got %v %v", banned, retryAfter)
+		}
+		if status, _ := send("POST", "/admin/ratelimit/10.0.0.6/ban", admin, `{"duration_secs": 60, "scope": "policy:login"}`); status != 200 {
+			t.Fatalf("policy ban: expected 200, got %d", status)
+		}
+
+		_, body = send("GET", "/admin/ratelimit", admin, "")
+		limiters := body["limiters"].([]interface{})
+		if len(limiters) != 2 {
+			t.Fatalf("expected global and policy limiters, got %v", limiters)
+		}
+		global := limiters[0].(map[string]interface{})
+		bans := global["bans"].([]interface{})
+		if global["scope"] != "global" || len(bans) != 1 || bans[0].(map[string]interface{})["key"] != "10.0.0.5" {
+			t.Errorf("unexpected global bans: %v", global)
+		}
+
+		_, body = send("GET", "/admin/ratelimit/10.0.0.5", admin, "")
+		entry := body["limiters"].([]interface{})[0].(map[string]interface{})
+		if entry["banned"] != true || entry["retry_after"].(float64) < 3500 {
+			t.Errorf("unexpected lookup result: %v", body)
+		}
+
+		if status, _ := send("DELETE", "/admin/ratelimit/10.0.0.5", admin, ""); status != 200 {
+			t.Fatalf("unban: expected 200, got %d", status)
+		}
+		if banned, _ := srv.RateLimiter.Banned("10.0.0.5"); banned {
+			t.Error("expected ban to be lifted")
+		}
+	})
+
+	t.Run("Invalid requests", func(t *testing.T) {
+		if status, _ := send("GET", "/admin/ratelimit/not-an-ip", admin, ""); status != 400 {
+			t.Errorf("expected 400 for invalid IP, got %d", status)
+		}
+		if status, _ := send("POST", "/admin/ratelimit/10.0.0.5/ban", admin, `{"duration_secs": 0}`); status != 400 {
+			t.Errorf("expected 400 for missing duration, got %d", status)
+		}
+		if status, _ := send("DELETE", "/admin/ratelimit?scope=policy:unknown", admin, ""); status != 400 {
+			t.Errorf("expected 400 for unknown scope, got %d", status)
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		status, body := send("DELETE", "/admin/ratelimit", admin, "")
+		if status != 200 || len(body["scopes"].([]interface{})) != 2 {
+			t.Fatalf("clear: expected 200 for all scopes, got %d %v", status, body)
+		}
+		if banned, _ := srv.policyLimiters["login"].failures.Banned("10.0.0.6"); banned {
+			t.Error("expected policy ban to be cleared")
+		}
+	})
+
+	t.Run("Audit log", func(t *testing.T) {
+		data, err := os.ReadFile(auditPath)
+		if err != nil {
+			t.Fatalf("read audit log: %v", err)
+		}
+		log := string(data)
+		for _, want := range []string{
+			`"reason":"ratelimit_ban","status":200`,
+			`"target":"10.0.0.5","scope":"global"`,
+			`"reason":"ratelimit_unban"`,
+			`"reason":"ratelimit_clear"`,
+			`"auth_name":"ops"`,
+			`"result":"denied","reason":"invalid_credentials"`,
+		} {
+			if !strings.Contains(log, want) {
+				t.Errorf("audit log missing %s:\n%s", want, log)
+			}
+		}
+	})
+
+	t.Run("Allowed IPs and disabled API", func(t *testing.T) {
+		srv.Config.Admin.AllowedIPs = []string{"10.0.0.0/8"}
+		if status, _ := send("GET", "/admin/ratelimit", admin, ""); status != 403 {
+			t.Errorf("expected 403 from a disallowed IP, got %d", status)
+		}
+		// 未经验证的无效 allowed_ips 不能放开来源限制
+		srv.Config.Admin.AllowedIPs = []string{"not-a-network"}
+		if status, _ := send("GET", "/admin/ratelimit", admin, ""); status != 500 {
+			t.Errorf("expected 500 for invalid allowed_ips, got %d", status)
+		}
+		srv.Config.Admin.Enabled = false
+		if status, _ := send("GET", "/admin/ratelimit", admin, ""); status != 404 {
+			t.Errorf("expected 404 when disabled, got %d", status)
+		}
+	})
+}
+
+// TestAdminAPI_FailuresRateLimited 测试管理 API 的认证失败计入全局速率限制
+func TestAdminAPI_FailuresRateLimited(t *testing.T) {
+	cfg := &config.Config{
+		Server:     config.ServerConfig{Port: "3000", AuthPath: "/auth", ReadTimeout: 30, WriteTimeout: 30},
+		Admin:      config.AdminConfig{Enabled: true, Roles: []string{"admin"}},
+		BasicAuths: []config.BasicAuthConfig{{Name: "ops", User: "ops", Pass: "opspass", Roles: []string{"admin"}}},
+		RateLimit:  config.RateLimitConfig{Enabled: true, MaxAttempts: 2, WindowSecs: 60, BanSecs: 60},
+	}
+	srv := createTestServer(t, cfg)
+
+	statuses := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/admin/ratelimit", http.NoBody)
+		req.Header.Set("Authorization", "Basic b3BzOndyb25n") // ops:wrong
+		resp, err := srv.App.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		statuses = append(statuses, resp.StatusCode)
+	}
+	if statuses[0] != 401 || statuses[2] != 429 {
+		t.Errorf("expected 401 then 429 after repeated failures, got %v", statuses)
+	}
+}
+
+// TestAdminAPI_ClientChecks 测试管理 API 同样应用全局 IP 过滤和凭证的访问时间窗口
+func TestAdminAPI_ClientChecks(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{Port: "3000", AuthPath: "/auth", ReadTimeout: 30, WriteTimeout: 30},
+		Admin:  config.AdminConfig{Enabled: true, Roles: []string{"admin"}},
+		BasicAuths: []config.BasicAuthConfig{
+			{Name: "ops", User: "ops", Pass: "opspass", Roles: []string{"admin"}},
+			{
+				Name: "oncall", User: "oncall", Pass: "oncallpass", Roles: []string{"admin"},
+				Schedule: &config.ScheduleConfig{Windows: []string{"mon-fri 09:00-17:00"}},
+			},
+		},
+		IPFilter: config.IPFilterConfig{Deny: []string{"10.0.0.0/8"}},
+	}
+	srv := createTestServer(t, cfg)
+	// 2024-06-10 是周一，UTC 22:00 在 oncall 的时间窗口外
+	srv.now = func() time.Time { return time.Date(2024, 6, 10, 22, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name       string
+		clientIP   string
+		authHeader string
+		wantStatus int
+	}{
+		{"Allowed client", "192.0.2.1", "Basic b3BzOm9wc3Bhc3M=", 200},
+		{"Denied by ip filter", "10.0.0.9", "Basic b3BzOm9wc3Bhc3M=", 403},
+		{"Outside credential schedule", "192.0.2.1", "Basic b25jYWxsOm9uY2FsbHBhc3M=", 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/ratelimit", http.NoBody)
+			req.Header.Set("X-Forwarded-For", tt.clientIP)
+			req.Header.Set("Authorization", tt.authHeader)
+			resp, err := srv.App.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+// TestAdminAPI_UnbanRedisUnavailable 测试 Redis 不可用时解封返回 503，且不记录成功的审计事件
+func TestAdminAPI_UnbanRedisUnavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Port:         "3000",
+			AuthPath:     "/auth",
+			ReadTimeout:  30,
+			WriteTimeout: 30,
+		},
+		Audit: config.AuditConfig{Enabled: true, Output: auditPath},
+		Admin: config.AdminConfig{Enabled: true, Roles: []string{"admin"}},
+		BasicAuths: []config.BasicAuthConfig{
+			{Name: "ops", User: "ops", Pass: "opspass", Roles: []string{"admin"}},
+		},
+		RateLimit: config.RateLimitConfig{
+			Enabled: true, MaxAttempts: 5, WindowSecs: 60, BanSecs: 60,
+			Backend: "redis",
+			Redis:   config.RedisConfig{Addr: mr.Addr(), KeyPrefix: "tiny-auth:", TimeoutMs: 100},
+		},
+	}
+	srv := createTestServer(t, cfg)
+	mr.Close()
+
+	req := httptest.NewRequest("DELETE", "/admin/ratelimit/10.0.0.5", http.NoBody)
+	req.Header.Set("Authorization", "Basic b3BzOm9wc3Bhc3M=") // ops:opspass
+	resp, err := srv.App.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when Redis is unavailable, got %d", resp.StatusCode)
+	}
+
+	logData, _ := os.ReadFile(auditPath)
+	if strings.Contains(string(logData), "ratelimit_unban") {
+		t.Errorf("failed unban should not be audited as done, got %s", logData)
+	}
+}
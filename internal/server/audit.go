@@ -0,0 +1,49 @@
+package server
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/audit"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// newAuditLogger 创建审计日志记录器，轮转、压缩和清理旧文件的错误记录到服务日志
+func newAuditLogger(cfg config.AuditConfig, logger *zap.Logger) (*audit.Logger, error) {
+	return audit.NewLogger(cfg, func(err error) {
+		logger.Error("audit log rotation failed", zap.Error(err))
+	})
+}
+
+// ReopenAudit 重新打开审计日志文件（外部轮转工具移走文件后通过 SIGUSR1 通知）
+// 与请求一样计入 in-flight 计数，重载替换下的记录器不会在重新打开期间关闭
+func (s *Server) ReopenAudit() error {
+	s.mu.RLock()
+	logger := s.Audit
+	inflight := s.inflight
+	inflight.Add(1)
+	s.mu.RUnlock()
+	defer inflight.Done()
+
+	return logger.Reopen()
+}
+
+// auditLoggerKey 保存请求开始时取得的审计日志记录器的 Locals key
+const auditLoggerKey = "audit_logger"
+
+// requestAudit Locals 中保存的审计日志记录器
+// 不能直接保存 *audit.Logger：fasthttp 在请求结束时会关闭实现了 io.Closer 的 Locals 值
+type requestAudit struct {
+	logger *audit.Logger
+}
+
+// setRequestAudit 保存处理请求期间使用的审计日志记录器
+func setRequestAudit(c *fiber.Ctx, logger *audit.Logger) {
+	c.Locals(auditLoggerKey, requestAudit{logger: logger})
+}
+
+// requestAuditLogger 返回请求开始时取得的审计日志记录器（未设置时为 nil）
+func requestAuditLogger(c *fiber.Ctx) *audit.Logger {
+	ref, _ := c.Locals(auditLoggerKey).(requestAudit)
+	return ref.logger
+}
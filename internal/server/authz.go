@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/authz"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+var webhookHeaderNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+// buildWebhooks 为配置了 authz_webhook 的策略创建 Webhook 客户端
+// 创建失败的策略不会出现在结果中，请求时按 failure_mode 处理
+func buildWebhooks(cfg *config.Config, logger *zap.Logger) map[string]*authz.Webhook {
+	webhooks := make(map[string]*authz.Webhook)
+
+	for i := range cfg.RoutePolicies {
+		p := &cfg.RoutePolicies[i]
+		if p.AuthzWebhook == nil {
+			continue
+		}
+
+		webhook, err := authz.NewWebhook(p.AuthzWebhook)
+		if err != nil {
+			logger.Error("failed to initialize authz webhook",
+				zap.String("policy", p.Name),
+				zap.Error(err),
+			)
+			continue
+		}
+		webhooks[p.Name] = webhook
+	}
+
+	return webhooks
+}
+
+// checkAuthzWebhook 调用策略的外部授权 Webhook
+// 返回 Webhook 决策（未配置或失败放行时为 nil）、决策缓存的查询结果（"hit" / "miss"，未启用缓存时为空）
+// 以及拒绝原因（放行时为空）
+func (p *Pipeline) checkAuthzWebhook(
+	ctx context.Context,
+	matchedPolicy *config.RoutePolicy,
+	result *auth.AuthResult,
+	info authz.RequestInfo,
+) (decision *authz.Decision, cache, denyReason string) {
+	if matchedPolicy == nil || matchedPolicy.AuthzWebhook == nil {
+		return nil, "", ""
+	}
+
+	failOpen := matchedPolicy.AuthzWebhook.FailureMode == "open"
+
+	webhook, ok := p.webhooks[matchedPolicy.Name]
+	if !ok {
+		p.logger.Error("authz webhook unavailable",
+			zap.String("policy", matchedPolicy.Name),
+			zap.Bool("fail_open", failOpen),
+		)
+		if failOpen {
+			return nil, "", ""
+		}
+		return nil, "", "authz_webhook_error"
+	}
+
+	req := authz.NewRequest(result, info.Host, info.URI, info.Method, info.ClientIP)
+	decision, cached, err := webhook.Decide(ctx, req)
+	if webhook.Caching() {
+		cache = "miss"
+		if cached {
+			cache = "hit"
+		}
+	}
+	if err != nil {
+		p.logger.Warn("authz webhook call failed",
+			zap.String("policy", matchedPolicy.Name),
+			zap.Bool("fail_open", failOpen),
+			zap.Error(err),
+		)
+		if failOpen {
+			return nil, cache, ""
+		}
+		return nil, cache, "authz_webhook_error"
+	}
+
+	if !decision.Allow {
+		return decision, cache, "authz_webhook_denied"
+	}
+
+	return decision, cache, ""
+}
+
+// applyAuthzDecision 合并 Webhook 授予的额外角色，返回新的认证结果
+func applyAuthzDecision(result *auth.AuthResult, decision *authz.Decision) *auth.AuthResult {
+	if decision == nil || len(decision.Roles) == 0 {
+		return result
+	}
+
+	merged := *result
+	merged.Roles = make([]string, 0, len(result.Roles)+len(decision.Roles))
+	seen := make(map[string]bool, len(result.Roles)+len(decision.Roles))
+	for _, roles := range [][]string{result.Roles, decision.Roles} {
+		for _, r := range roles {
+			if r == "" || seen[r] {
+				continue
+			}
+			seen[r] = true
+			merged.Roles = append(merged.Roles, r)
+		}
+	}
+
+	return &merged
+}
+
+// authzHeaders 返回 Webhook 要求注入的额外 headers（过滤非法名称和保留 header）
+func authzHeaders(decision *authz.Decision) map[string]string {
+	headers := make(map[string]string)
+	if decision == nil {
+		return headers
+	}
+
+	for name, value := range decision.Headers {
+		if !webhookHeaderNameRegex.MatchString(name) || isReservedResponseHeader(name) {
+			continue
+		}
+		headers[name] = sanitizeHeaderValue(value)
+	}
+	return headers
+}
+
+// isReservedResponseHeader 检查是否为不允许 Webhook 设置的 header
+func isReservedResponseHeader(name string) bool {
+	switch strings.ToLower(name) {
+	case "host", "content-length", "transfer-encoding", "connection", "set-cookie":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestHandleAuth_AuthzWebhook 测试外部授权 Webhook 决策
+func TestHandleAuth_AuthzWebhook(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.Contains(string(body), `"uri":"/tenants/1"`):
+			_, _ = io.WriteString(w, `{"allow": true, "roles": ["tenant-admin"], "headers": {"X-Tenant": "1", "X-Auth-User": "mallory"}}`)
+		case strings.Contains(string(body), `"uri":"/tenants/2"`):
+			_, _ = io.WriteString(w, `{"allow": false, "reason": "not a member"}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer webhook.Close()
+
+	newConfig := func(failureMode string) *config.Config {
+		return &config.Config{
+			Server: config.ServerConfig{
+				Port:         "3000",
+				AuthPath:     "/auth",
+				ReadTimeout:  30,
+				WriteTimeout: 30,
+			},
+			BasicAuths: []config.BasicAuthConfig{
+				{Name: "user1", User: "user1", Pass: "pass1", Roles: []string{"user"}},
+			},
+			// Webhook 授予的父角色按继承关系展开
+			Roles: map[string][]string{"tenant-admin": {"tenant-editor"}, "tenant-editor": {"tenant-viewer"}},
+			RoutePolicies: []config.RoutePolicy{
+				{
+					Name:       "tenants",
+					PathPrefix: "/tenants",
+					AuthzWebhook: &config.AuthzWebhookConfig{
+						URL:         webhook.URL,
+						TimeoutMs:   1000,
+						FailureMode: failureMode,
+					},
+				},
+			},
+			Headers: config.HeadersConfig{
+				UserHeader: "X-Auth-User",
+				RoleHeader: "X-Auth-Role",
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		failureMode string
+		path        string
+		wantStatus  int
+		wantRoles   string
+		wantTenant  string
+	}{
+		{
+			name:        "Webhook allows and adds roles and headers",
+			failureMode: "closed",
+			path:        "/tenants/1",
+			wantStatus:  200,
+			wantRoles:   "user,tenant-admin,tenant-editor,tenant-viewer",
+			wantTenant:  "1",
+		},
+		{
+			name:        "Webhook denies",
+			failureMode: "open",
+			path:        "/tenants/2",
+			wantStatus:  403,
+		},
+		{
+			name:        "Webhook error with fail-closed",
+			failureMode: "closed",
+			path:        "/tenants/3",
+			wantStatus:  403,
+		},
+		{
+			name:        "Webhook error with fail-open",
+			failureMode: "open",
+			path:        "/tenants/3",
+			wantStatus:  200,
+			wantRoles:   "user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := createTestServer(t, newConfig(tt.failureMode))
+
+			req := httptest.NewRequest("GET", "/auth", http.NoBody)
+			req.Header.Set("Authorization", "Basic dXNlcjE6cGFzczE=") // user1:pass1
+			req.Header.Set("X-Forwarded-Host", "api.example.com")
+			req.Header.Set("X-Forwarded-Uri", tt.path)
+
+			resp, err := srv.App.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+
+			if got := resp.Header.Get("X-Auth-Role"); got != tt.wantRoles {
+				t.Errorf("Expected X-Auth-Role=%q, got %q", tt.wantRoles, got)
+			}
+			if got := resp.Header.Get("X-Tenant"); got != tt.wantTenant {
+				t.Errorf("Expected X-Tenant=%q, got %q", tt.wantTenant, got)
+			}
+			// Webhook 不能覆盖身份 headers
+			if got := resp.Header.Get("X-Auth-User"); got != "user1" {
+				t.Errorf("Expected X-Auth-User=user1, got %q", got)
+			}
+		})
+	}
+}
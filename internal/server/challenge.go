@@ -0,0 +1,96 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/policy"
+)
+
+// Bearer challenge 的错误代码（RFC 6750 第 3.1 节）
+const (
+	bearerErrorInvalidToken      = "invalid_token"
+	bearerErrorInsufficientScope = "insufficient_scope"
+)
+
+// realmFor 返回策略的 realm（没有匹配策略或未设置时为默认值）
+func realmFor(matchedPolicy *config.RoutePolicy) string {
+	if matchedPolicy != nil && matchedPolicy.Realm != "" {
+		return matchedPolicy.Realm
+	}
+	return config.DefaultRealm
+}
+
+// authChallenges 返回 401 响应的 WWW-Authenticate challenges，只包含匹配策略接受的认证方式
+// bearerError 非空时（客户端提供的 Bearer token 无效）附加在 Bearer challenge 上
+func authChallenges(cfg *config.Config, matchedPolicy *config.RoutePolicy, bearerError string) []string {
+	realm := realmFor(matchedPolicy)
+	jwtOnly := matchedPolicy != nil && matchedPolicy.JWTOnly
+	var challenges []string
+
+	if len(cfg.BasicAuths) > 0 && !jwtOnly {
+		challenges = append(challenges, `Basic realm="`+realm+`", charset="UTF-8"`)
+	}
+
+	if (len(cfg.BearerTokens) > 0 && !jwtOnly) || cfg.JWT.Secret != "" {
+		challenges = append(challenges, bearerChallenge(realm, bearerError, nil))
+	}
+
+	if len(cfg.APIKeys) > 0 && !jwtOnly {
+		challenges = append(challenges, `ApiKey realm="`+realm+`"`)
+	}
+
+	return challenges
+}
+
+// bearerChallenge 构建 Bearer challenge（RFC 6750）
+// scope 为满足策略所需的角色或组（仅用于 insufficient_scope）
+func bearerChallenge(realm, bearerError string, scope []string) string {
+	var b strings.Builder
+	b.WriteString(`Bearer realm="`)
+	b.WriteString(realm)
+	b.WriteString(`"`)
+	if bearerError != "" {
+		b.WriteString(`, error="`)
+		b.WriteString(bearerError)
+		b.WriteString(`"`)
+	}
+	if len(scope) > 0 {
+		// scope-token 不允许空格、引号和反斜杠，这里清理后用空格分隔
+		tokens := make([]string, 0, len(scope))
+		for _, s := range scope {
+			if s = strings.Map(scopeChar, s); s != "" {
+				tokens = append(tokens, s)
+			}
+		}
+		if len(tokens) > 0 {
+			b.WriteString(`, scope="`)
+			b.WriteString(strings.Join(tokens, " "))
+			b.WriteString(`"`)
+		}
+	}
+	return b.String()
+}
+
+// scopeChar 过滤 scope-token 不允许的字符（%x21 / %x23-5B / %x5D-7E）
+func scopeChar(r rune) rune {
+	if r < 0x21 || r > 0x7e || r == '"' || r == '\\' {
+		return -1
+	}
+	return r
+}
+
+// scopeChallenges 返回 Bearer 凭证角色或组不足时 403 响应的 insufficient_scope challenge
+// 其他认证方式、其他失败原因或策略隐藏拒绝原因（deny_response 不是 detailed）时返回 nil
+func scopeChallenges(matchedPolicy *config.RoutePolicy, method string, violation *policy.Violation) []string {
+	if method != "jwt" && method != "bearer" {
+		return nil
+	}
+	if violation.Reason != policy.ReasonInsufficientRoles && violation.Reason != policy.ReasonGroupRequired {
+		return nil
+	}
+	if matchedPolicy != nil && matchedPolicy.DenyResponse != "" && matchedPolicy.DenyResponse != "detailed" {
+		return nil
+	}
+	return []string{bearerChallenge(realmFor(matchedPolicy), bearerErrorInsufficientScope, violation.Missing)}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/policy"
+)
+
+// TestAuthChallenges 测试 challenges 只包含匹配策略接受的认证方式
+func TestAuthChallenges(t *testing.T) {
+	full := &config.Config{
+		BasicAuths:   []config.BasicAuthConfig{{Name: "u", User: "u", Pass: "p"}},
+		BearerTokens: []config.BearerConfig{{Name: "t", Token: "token"}},
+		APIKeys:      []config.APIKeyConfig{{Name: "k", Key: "key"}},
+		JWT:          config.JWTConfig{Secret: "secret"},
+	}
+	basicOnly := &config.Config{BasicAuths: full.BasicAuths}
+
+	tests := []struct {
+		name        string
+		cfg         *config.Config
+		policy      *config.RoutePolicy
+		bearerError string
+		want        []string
+	}{
+		{
+			name: "No policy",
+			cfg:  full,
+			want: []string{`Basic realm="api", charset="UTF-8"`, `Bearer realm="api"`, `ApiKey realm="api"`},
+		},
+		{
+			name:   "Policy realm",
+			cfg:    basicOnly,
+			policy: &config.RoutePolicy{Name: "admin", Realm: "Admin Area"},
+			want:   []string{`Basic realm="Admin Area", charset="UTF-8"`},
+		},
+		{
+			name:        "JWT only",
+			cfg:         full,
+			policy:      &config.RoutePolicy{Name: "internal", JWTOnly: true, Realm: "internal"},
+			bearerError: bearerErrorInvalidToken,
+			want:        []string{`Bearer realm="internal", error="invalid_token"`},
+		},
+		{
+			name:   "JWT only without JWT configured",
+			cfg:    basicOnly,
+			policy: &config.RoutePolicy{Name: "internal", JWTOnly: true},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := authChallenges(tt.cfg, tt.policy, tt.bearerError)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("authChallenges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBearerChallenge_Scope 测试 insufficient_scope 的 scope 参数
+func TestBearerChallenge_Scope(t *testing.T) {
+	got := bearerChallenge("api", bearerErrorInsufficientScope, []string{"admin", `bad "role"`, "ops"})
+	want := `Bearer realm="api", error="insufficient_scope", scope="admin badrole ops"`
+	if got != want {
+		t.Errorf("bearerChallenge() = %q, want %q", got, want)
+	}
+}
+
+// TestPipeline_Challenges 测试流水线为 401 和 Bearer 凭证 403 生成的 challenges
+func TestPipeline_Challenges(t *testing.T) {
+	cfg := &config.Config{
+		BasicAuths:   []config.BasicAuthConfig{{Name: "u", User: "u", Pass: "p"}},
+		BearerTokens: []config.BearerConfig{{Name: "ci", Token: "ci-token-1234567890", Roles: []string{"service"}}},
+		RoutePolicies: []config.RoutePolicy{
+			{Name: "admin", Host: "admin.example.com", RequireAllRoles: []string{"admin"}, Realm: "admin"},
+			{Name: "hidden", Host: "hidden.example.com", RequireAnyRole: []string{"admin"}, DenyResponse: "generic"},
+		},
+	}
+	p := NewPipeline(cfg, auth.BuildStore(cfg), zap.NewNop())
+
+	tests := []struct {
+		name          string
+		host          string
+		authorization string
+		status        int
+		want          []string
+	}{
+		{"Missing credentials", "admin.example.com", "", 401,
+			[]string{`Basic realm="admin", charset="UTF-8"`, `Bearer realm="admin"`}},
+		{"Invalid bearer token", "admin.example.com", "Bearer wrong", 401,
+			[]string{`Basic realm="admin", charset="UTF-8"`, `Bearer realm="admin", error="invalid_token"`}},
+		{"Bearer token lacks role", "admin.example.com", "Bearer ci-token-1234567890", 403,
+			[]string{`Bearer realm="admin", error="insufficient_scope", scope="admin"`}},
+		{"Hidden reason has no scope", "hidden.example.com", "Bearer ci-token-1234567890", 403, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := p.Evaluate(context.Background(), &AuthRequest{
+				Request:       policy.Request{Host: tt.host, URI: "/", Time: time.Now()},
+				Authorization: tt.authorization,
+			})
+			if out.Status != tt.status {
+				t.Fatalf("expected status %d, got %d (%s)", tt.status, out.Status, out.Reason)
+			}
+			if !reflect.DeepEqual(out.Challenges, tt.want) {
+				t.Errorf("challenges = %q, want %q", out.Challenges, tt.want)
+			}
+		})
+	}
+}
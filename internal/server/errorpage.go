@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// errorPage 错误响应的内容（JSON、HTML 模板和纯文本共用同一份数据）
+type errorPage struct {
+	Status     int                    // HTTP 状态码
+	Title      string                 // 状态文本（如 "Unauthorized"）
+	Message    string                 // 错误描述
+	Code       string                 // 错误代码（如 AUTHZ_INSUFFICIENT_ROLES，可为空）
+	Details    map[string]interface{} // 错误详情（可为空）
+	RetryAfter int64                  // 限流时的重试等待秒数（可为 0）
+	RequestID  string                 // 请求 ID（与审计日志一致）
+	Timestamp  int64                  // Unix 时间戳
+}
+
+// newErrorPage 创建错误响应内容
+func newErrorPage(c *fiber.Ctx, status int, message string) *errorPage {
+	return &errorPage{
+		Status:    status,
+		Title:     http.StatusText(status),
+		Message:   message,
+		RequestID: getRequestID(c),
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// defaultErrorTemplate 内置的 HTML 错误页面（模板目录中的 default.html 可替换它）
+var defaultErrorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Status}} {{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; color: #222; max-width: 36rem; margin: 10vh auto; padding: 0 1rem; }
+h1 { font-size: 1.5rem; }
+.meta { color: #777; font-size: .85rem; }
+</style>
+</head>
+<body>
+<h1>{{.Status}} {{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .RetryAfter}}<p>Please try again in {{.RetryAfter}} seconds.</p>{{end}}
+<p class="meta">{{if .Code}}Code: {{.Code}}<br>{{end}}{{if .RequestID}}Request ID: {{.RequestID}}{{end}}</p>
+</body>
+</html>
+`))
+
+// Accept 协商得到的错误响应格式
+const (
+	formatJSON = "json"
+	formatHTML = "html"
+	formatText = "text"
+)
+
+// negotiateFormat 根据 Accept 选择错误响应格式
+// 浏览器返回 HTML，API 客户端（包括未发送 Accept 或接受 */* 的客户端）返回 JSON，其余返回纯文本
+func negotiateFormat(c *fiber.Ctx) string {
+	switch c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML, fiber.MIMETextPlain) {
+	case fiber.MIMEApplicationJSON:
+		return formatJSON
+	case fiber.MIMETextHTML:
+		return formatHTML
+	default:
+		return formatText
+	}
+}
+
+// sendErrorPage 按协商的格式写入错误响应
+func sendErrorPage(c *fiber.Ctx, cfg *config.Config, policy *config.RoutePolicy, page *errorPage) error {
+	c.Status(page.Status)
+
+	switch negotiateFormat(c) {
+	case formatJSON:
+		body := fiber.Map{
+			"error":     page.Message,
+			"timestamp": page.Timestamp,
+		}
+		if page.Code != "" {
+			body["code"] = page.Code
+		}
+		if len(page.Details) > 0 {
+			body["details"] = page.Details
+		}
+		if page.RetryAfter > 0 {
+			body["retry_after"] = page.RetryAfter
+		}
+		if page.RequestID != "" {
+			body["request_id"] = page.RequestID
+		}
+		return c.JSON(body)
+
+	case formatHTML:
+		var buf bytes.Buffer
+		if err := errorTemplate(cfg, policy).Execute(&buf, page); err != nil {
+			// 自定义模板执行失败时退回内置页面，避免返回半截 HTML
+			buf.Reset()
+			if err := defaultErrorTemplate.Execute(&buf, page); err != nil {
+				return err
+			}
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(buf.Bytes())
+
+	default:
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d %s: %s\n", page.Status, page.Title, page.Message)
+		if page.Code != "" {
+			fmt.Fprintf(&b, "Code: %s\n", page.Code)
+		}
+		if page.RetryAfter > 0 {
+			fmt.Fprintf(&b, "Retry after: %ds\n", page.RetryAfter)
+		}
+		if page.RequestID != "" {
+			fmt.Fprintf(&b, "Request ID: %s\n", page.RequestID)
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendString(b.String())
+	}
+}
+
+// errorTemplate 选择 HTML 错误页面模板
+// 优先使用策略的 error_template，其次是模板目录中的 default.html，最后是内置页面
+func errorTemplate(cfg *config.Config, policy *config.RoutePolicy) *template.Template {
+	if cfg == nil {
+		return defaultErrorTemplate
+	}
+	templates, err := cfg.ErrorTemplates()
+	if err != nil || templates == nil {
+		return defaultErrorTemplate
+	}
+	if policy != nil && policy.ErrorTemplate != "" {
+		if tmpl := templates.Lookup(policy.ErrorTemplate); tmpl != nil {
+			return tmpl
+		}
+	}
+	if tmpl := templates.Lookup(config.DefaultErrorTemplate); tmpl != nil {
+		return tmpl
+	}
+	return defaultErrorTemplate
+}
+
+// redirectLocation 构建未认证重定向地址，原始请求地址作为 rd 参数（覆盖已有的 rd）
+// returnURL 为空（请求不是来自可信代理）时不附加 rd
+func redirectLocation(target, returnURL string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	if returnURL == "" {
+		return u.String()
+	}
+	q := u.Query()
+	q.Set("rd", returnURL)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// getRequestID 返回当前请求的 ID（requestid 中间件沿用客户端的 X-Request-ID 或生成新的 ID）
+func getRequestID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("requestid").(string); ok && id != "" {
+		return id
+	}
+	return c.Get(fiber.HeaderXRequestID)
+}
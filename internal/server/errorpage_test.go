@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	apperrors "github.com/nerdneilsfield/tiny-auth/internal/errors"
+)
+
+// TestErrorResponse_Negotiation 测试错误响应按 Accept 协商格式，且都包含请求 ID
+func TestErrorResponse_Negotiation(t *testing.T) {
+	cfg := &config.Config{BasicAuths: []config.BasicAuthConfig{{Name: "test", User: "test", Pass: "test"}}}
+
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Get("/", func(c *fiber.Ctx) error {
+		return UnauthorizedResponse(c, cfg, nil, authChallenges(cfg, nil, ""), "Invalid credentials", "")
+	})
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		contains    []string
+	}{
+		{"No Accept", "", "application/json", []string{`"error":"Invalid credentials"`, `"request_id":"req-1"`}},
+		{"Wildcard", "*/*", "application/json", []string{`"request_id":"req-1"`}},
+		{"API client", "application/json", "application/json", []string{`"request_id":"req-1"`}},
+		{"Browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html", []string{"<h1>401 Unauthorized</h1>", "Request ID: req-1"}},
+		{"Plain text", "text/plain", "text/plain", []string{"401 Unauthorized: Invalid credentials", "Request ID: req-1"}},
+		{"Unsupported type", "image/png", "text/plain", []string{"Request ID: req-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			req.Header.Set("X-Request-ID", "req-1")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != 401 {
+				t.Errorf("Expected status 401, got %d", resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("Expected Content-Type %s, got %s", tt.contentType, ct)
+			}
+			if resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header")
+			}
+			body, _ := io.ReadAll(resp.Body)
+			for _, s := range tt.contains {
+				if !strings.Contains(string(body), s) {
+					t.Errorf("Expected body to contain %q, got %s", s, body)
+				}
+			}
+		})
+	}
+}
+
+// TestErrorResponse_Templates 测试策略模板、default.html 和内置页面的选择
+func TestErrorResponse_Templates(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"default.html": `site default {{.Status}} {{.RequestID}}`,
+		"corp.html":    `corp page: {{.Message}} ({{.Code}})`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{ErrorPages: config.ErrorPagesConfig{TemplateDir: dir}}
+	corp := &config.RoutePolicy{Name: "corp", ErrorTemplate: "corp.html"}
+	other := &config.RoutePolicy{Name: "other"}
+	appErr := apperrors.AuthzInsufficientRoles([]string{"admin"}, []string{"user"})
+
+	tests := []struct {
+		name     string
+		cfg      *config.Config
+		policy   *config.RoutePolicy
+		expected string
+	}{
+		{"Policy template", cfg, corp, "corp page: Insufficient roles for access (AUTHZ_INSUFFICIENT_ROLES)"},
+		{"Directory default", cfg, other, "site default 403 req-2"},
+		{"Built-in page", &config.Config{}, other, "<h1>403 Forbidden</h1>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return ForbiddenResponse(c, tt.cfg, tt.policy, nil, fiber.StatusForbidden, appErr)
+			})
+
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			req.Header.Set("Accept", "text/html")
+			req.Header.Set("X-Request-ID", "req-2")
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), tt.expected) {
+				t.Errorf("Expected body to contain %q, got %s", tt.expected, body)
+			}
+		})
+	}
+}
+
+// TestUnauthorizedResponse_Redirect 测试浏览器请求重定向到 unauthenticated_redirect
+func TestUnauthorizedResponse_Redirect(t *testing.T) {
+	cfg := &config.Config{}
+	policy := &config.RoutePolicy{Name: "app", UnauthenticatedRedirect: "https://login.example.com/start?client=app"}
+
+	tests := []struct {
+		name      string
+		accept    string
+		returnURL string
+		status    int
+		location  string
+	}{
+		{"Browser", "text/html", "https://app.example.com/dash?tab=1", 302, "https://login.example.com/start?client=app&rd=https%3A%2F%2Fapp.example.com%2Fdash%3Ftab%3D1"},
+		{"Untrusted request has no rd", "text/html", "", 302, "https://login.example.com/start?client=app"},
+		{"API client gets 401", "application/json", "https://app.example.com/", 401, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return UnauthorizedResponse(c, cfg, policy, nil, "Authentication required", tt.returnURL)
+			})
+
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			req.Header.Set("Accept", tt.accept)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if loc := resp.Header.Get("Location"); loc != tt.location {
+				t.Errorf("Expected Location %q, got %q", tt.location, loc)
+			}
+		})
+	}
+}
+
+// TestTooManyRequestsResponse 测试限流响应包含 retry_after 和请求 ID
+func TestTooManyRequestsResponse(t *testing.T) {
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Get("/", func(c *fiber.Ctx) error {
+		return TooManyRequestsResponse(c, &config.Config{}, nil, "Too many authentication attempts", 30)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", http.NoBody), -1)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != 429 || resp.Header.Get("Retry-After") != "30" {
+		t.Fatalf("Expected 429 with Retry-After 30, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if body["retry_after"] != float64(30) {
+		t.Errorf("Expected retry_after=30, got %v", body["retry_after"])
+	}
+	// 未携带 X-Request-ID 时使用中间件生成的 ID
+	if id, _ := body["request_id"].(string); id == "" || id != resp.Header.Get("X-Request-ID") {
+		t.Errorf("Expected request_id to match X-Request-ID header %q, got %v", resp.Header.Get("X-Request-ID"), body["request_id"])
+	}
+}
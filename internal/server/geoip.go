@@ -0,0 +1,40 @@
+package server
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/geoip"
+)
+
+// openGeoIP 加载 GeoIP 数据库（没有配置数据库时返回 nil）
+// 之后的重新加载失败只记录日志，继续使用上一次加载的数据库
+func openGeoIP(cfg config.GeoIPConfig, logger *zap.Logger) (*geoip.DB, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	db, err := geoip.Open(geoip.Options{
+		CountryDB:      cfg.CountryDB,
+		ASNDB:          cfg.ASNDB,
+		ReloadInterval: time.Duration(cfg.ReloadSecs) * time.Second,
+	}, func(path string, err error) {
+		logger.Error("failed to reload geoip database", zap.String("path", path), zap.Error(err))
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("geoip database loaded",
+		zap.String("country_db", cfg.CountryDB),
+		zap.String("asn_db", cfg.ASNDB),
+	)
+	return db, nil
+}
+
+// closeGeoIP 停止 GeoIP 数据库的文件检查（nil 时忽略）
+func closeGeoIP(db *geoip.DB) {
+	if db != nil {
+		db.Close()
+	}
+}
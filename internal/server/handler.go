@@ -1,7 +1,6 @@
 package server
 
 import (
-	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -10,8 +9,10 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/nerdneilsfield/tiny-auth/internal/audit"
-	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/geoip"
 	"github.com/nerdneilsfield/tiny-auth/internal/policy"
+	"github.com/nerdneilsfield/tiny-auth/internal/ratelimit"
 )
 
 // HandleAuth 处理 ForwardAuth 请求
@@ -22,50 +23,77 @@ func (s *Server) HandleAuth(c *fiber.Ctx) error {
 
 	// 获取当前配置和存储（线程安全）
 	cfg := s.GetConfig()
-	store := s.GetStore()
 
 	// 1. 安全地提取请求信息（验证可信代理）
 	s.mu.RLock()
 	trustedCIDRs := s.trustedCIDRs
 	rateLimiter := s.RateLimiter
+	pipeline := s.pipeline
+	policyLimiters := s.policyLimiters
+	quotas := s.quotas
+	quotaLimits := s.quotaLimits
+	ipFilter := s.ipFilter
+	geoDB := s.geoDB
+	// 请求结束前不关闭上面取得的配额存储和审计日志（重载时替换下的资源等待计数归零后关闭）
+	setRequestAudit(c, s.Audit)
+	inflight := s.inflight
+	inflight.Add(1)
 	s.mu.RUnlock()
+	defer inflight.Done()
+
+	// 追踪（可选）：延续可信代理传入的 traceparent，span 通过 UserContext 传给流水线
+	if s.tracer != nil {
+		span := s.startAuthSpan(c, isTrustedProxy(c.IP(), trustedCIDRs))
+		defer span.End()
+	}
 
 	// 获取真实客户端 IP（只信任来自可信代理的 X-Forwarded-For）
 	clientIP := getClientIP(c, cfg, trustedCIDRs)
-	requestID := c.Get("X-Request-ID")
+	requestID := getRequestID(c)
+	// 限流计数 key（IPv6 按 rate_limit.ipv6_prefix 聚合）
+	limitKey := ratelimit.ClientKey(clientIP, cfg.RateLimit.IPv6Prefix)
+
+	// 客户端的国家和 ASN（配置了 GeoIP 数据库时）
+	var geo geoip.Info
+	if geoDB != nil {
+		geo = geoDB.Lookup(clientIP)
+	}
+
+	// 在解析转发信息之前拒绝的请求（IP 过滤、国家 / ASN、全局速率限制）使用的审计事件
+	earlyAudit := audit.Event{
+		RequestID:    requestID,
+		ClientIP:     clientIP,
+		DirectIP:     c.IP(),
+		TrustedProxy: isTrustedProxy(c.IP(), trustedCIDRs),
+		Country:      geo.Country,
+		ASN:          geo.ASN,
+	}
 
-	// 2. 速率限制检查
-	if rateLimiter != nil {
-		allowed, retryAfter := rateLimiter.Allow(clientIP)
+	// 2. 全局 IP 过滤和国家 / ASN 规则：被拒绝的客户端不再进入速率限制和认证，
+	// 允许列表中的客户端跳过全局国家 / ASN 规则和全局速率限制
+	screened, ipAllowed := pipeline.ScreenClient(ipFilter, clientIP, geo)
+	if screened != nil {
+		s.Logger.Warn("client ip blocked",
+			zap.String("client_ip", clientIP),
+			zap.String("reason", screened.Reason),
+			zap.String("source", screened.Rule),
+			zap.String("country", geo.Country),
+			zap.Uint("asn", geo.ASN),
+		)
+		return s.blocked(c, cfg, &earlyAudit, startTime, screened)
+	}
+
+	// 3. 速率限制检查
+	if rateLimiter != nil && !ipAllowed {
+		allowed, retryAfter := rateLimiter.Allow(limitKey)
 		if !allowed {
-			retryAfterSeconds := int64(math.Ceil(retryAfter.Seconds()))
-			if retryAfterSeconds < 1 {
-				retryAfterSeconds = 1
-			}
-			auditEvent := audit.Event{
-				RequestID:    requestID,
-				ClientIP:     clientIP,
-				DirectIP:     c.IP(),
-				TrustedProxy: isTrustedProxy(c.IP(), trustedCIDRs),
-			}
-			auditEvent.Timestamp = time.Now().UTC()
-			auditEvent.Result = "rate_limited"
-			auditEvent.Reason = "rate_limit_exceeded"
-			auditEvent.Status = fiber.StatusTooManyRequests
-			auditEvent.LatencyMs = time.Since(startTime).Milliseconds()
-			if err := s.Audit.Log(&auditEvent); err != nil {
-				s.Logger.Error("audit log failed", zap.Error(err))
-			}
+			auditEvent := earlyAudit
 			s.Logger.Warn("rate limit exceeded",
 				zap.String("client_ip", clientIP),
 				zap.Duration("retry_after", retryAfter),
 			)
-			c.Set("Retry-After", strconv.FormatInt(retryAfterSeconds, 10))
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error":       "Too many authentication attempts",
-				"retry_after": retryAfterSeconds,
-				"timestamp":   time.Now().Unix(),
-			})
+			return s.rateLimited(c, cfg, nil, &auditEvent, startTime, "rate_limit_exceeded",
+				"Too many authentication attempts", retryAfter)
 		}
 	}
 
@@ -91,6 +119,8 @@ func (s *Server) HandleAuth(c *fiber.Ctx) error {
 		Host:         originalHost,
 		URI:          originalURI,
 		Method:       originalMethod,
+		Country:      geo.Country,
+		ASN:          geo.ASN,
 	}
 
 	// 如果不是来自可信代理，记录警告
@@ -100,162 +130,254 @@ func (s *Server) HandleAuth(c *fiber.Ctx) error {
 		)
 	}
 
-	// 2. 匹配路由策略
-	matchedPolicy := policy.MatchPolicy(cfg.RoutePolicies, originalHost, originalURI, originalMethod)
-
-	// 3. 检查是否允许匿名访问
-	if matchedPolicy != nil && matchedPolicy.AllowAnonymous {
-		auditEvent := baseAudit
-		auditEvent.Timestamp = time.Now().UTC()
-		auditEvent.AuthMethod = "anonymous"
-		auditEvent.Roles = []string{"anonymous"}
-		auditEvent.Policy = matchedPolicy.Name
-		auditEvent.Result = "success"
-		auditEvent.Status = fiber.StatusOK
-		auditEvent.LatencyMs = time.Since(startTime).Milliseconds()
-		if err := s.Audit.Log(&auditEvent); err != nil {
-			s.Logger.Error("audit log failed", zap.Error(err))
-		}
-
-		s.Logger.Info("auth success - anonymous",
-			append(logFields,
-				zap.String("auth_method", "anonymous"),
-				zap.String("policy", matchedPolicy.Name),
-				zap.Duration("latency", time.Since(startTime)),
-			)...,
-		)
-		return SuccessResponse(c, cfg, &auth.AuthResult{
-			Method: "anonymous",
-			Roles:  []string{"anonymous"},
-		}, matchedPolicy)
+	policyReq := policy.Request{
+		Host:     originalHost,
+		URI:      originalURI,
+		Method:   originalMethod,
+		ClientIP: clientIP,
+		Headers:  requestHeaders(c),
+		Time:     s.now(),
+		Country:  geo.Country,
+		ASN:      geo.ASN,
 	}
 
-	// 4. 尝试各种认证方式（按优先级）
-	authHeader := c.Get("Authorization")
-	authScheme, authToken := auth.ParseAuthHeader(authHeader)
-	var result *auth.AuthResult
-
-	// 优先级 1: JWT（如果配置了且看起来像 JWT）
-	if cfg.JWT.Secret != "" && strings.EqualFold(authScheme, "Bearer") {
-		if auth.IsJWT(authToken) {
-			result = auth.TryJWT(authToken, &cfg.JWT)
+	// 策略级认证失败封禁（在认证之前检查，被封禁的客户端不再消耗认证开销）
+	var limiter *policyLimiter
+	matched := pipeline.Match(c.UserContext(), &policyReq)
+	if matched != nil {
+		limiter = policyLimiters[matched.Name]
+	}
+	if limiter != nil && limiter.failures != nil {
+		if banned, retryAfter := limiter.failures.Banned(limitKey); banned {
+			auditEvent := baseAudit
+			auditEvent.Policy = matched.Name
+			s.Logger.Warn("policy auth failure limit exceeded",
+				append(logFields, zap.String("policy", matched.Name), zap.Duration("retry_after", retryAfter))...,
+			)
+			return s.rateLimited(c, cfg, matched, &auditEvent, startTime, "auth_failures_exceeded",
+				"Too many authentication attempts", retryAfter)
 		}
 	}
 
-	// 优先级 2: Bearer Token（静态 token）
-	if result == nil && strings.EqualFold(authScheme, "Bearer") {
-		result = auth.TryBearer(authHeader, store)
-	}
+	// 4. 执行认证决策（使用上面已匹配的策略：匿名访问、认证、策略检查、外部授权）
+	out := pipeline.Evaluate(c.UserContext(), &AuthRequest{
+		Request:       policyReq,
+		Authorization: c.Get("Authorization"),
+		APIKey:        c.Get("X-Api-Key"),
+		Matched:       true,
+		Policy:        matched,
+	})
 
-	// 优先级 3: Basic Auth
-	if result == nil && strings.EqualFold(authScheme, "Basic") {
-		result = auth.TryBasic(authHeader, store)
+	if out.AuthzCache != "" {
+		s.metrics.CacheLookup(cacheAuthzWebhook, out.AuthzCache == "hit")
 	}
 
-	// 优先级 4: API Key (Authorization: ApiKey xxx)
-	if result == nil && strings.EqualFold(authScheme, "ApiKey") {
-		result = auth.TryAPIKeyAuth(authHeader, store)
+	// backoff 模式：提供了凭证但认证失败时增加下一次尝试前的等待时间（未提供凭证的请求不计数）
+	progressive, _ := rateLimiter.(ratelimit.Progressive)
+	var backoffDelay time.Duration
+	if progressive != nil && !ipAllowed && out.Result == nil && len(out.Authenticators) > 0 {
+		_, backoffDelay = progressive.RecordFailure(limitKey)
 	}
 
-	// 优先级 5: API Key (X-Api-Key header)
-	if result == nil {
-		apiKeyHeader := c.Get("X-Api-Key")
-		if apiKeyHeader != "" {
-			result = auth.TryAPIKeyHeader(apiKeyHeader, store)
+	if limiter != nil {
+		// 提供了凭证但认证失败：计入策略的认证失败次数（未提供凭证的请求不计数）
+		if limiter.failures != nil && out.Result == nil && len(out.Authenticators) > 0 {
+			limiter.failures.RecordFailure(limitKey)
 		}
-	}
-
-	// 5. 检查策略约束
-	if result != nil {
-		if policy.CheckPolicy(matchedPolicy, result, store) {
-			auditEvent := baseAudit
-			auditEvent.Timestamp = time.Now().UTC()
-			auditEvent.AuthMethod = result.Method
-			auditEvent.AuthName = result.Name
-			auditEvent.User = result.User
-			auditEvent.Roles = result.Roles
-			if matchedPolicy != nil {
-				auditEvent.Policy = matchedPolicy.Name
-			}
-			auditEvent.Result = "success"
-			auditEvent.Status = fiber.StatusOK
-			auditEvent.LatencyMs = time.Since(startTime).Milliseconds()
-			if err := s.Audit.Log(&auditEvent); err != nil {
-				s.Logger.Error("audit log failed", zap.Error(err))
-			}
 
-			// 认证成功，重置速率限制
-			if rateLimiter != nil {
-				rateLimiter.Reset(clientIP)
-			}
-
-			policyName := ""
-			if matchedPolicy != nil {
-				policyName = matchedPolicy.Name
-			}
-			s.Logger.Info("auth success",
-				append(logFields,
-					zap.String("auth_method", result.Method),
-					zap.String("user", result.User),
-					zap.Strings("roles", result.Roles),
-					zap.String("policy", policyName),
-					zap.Duration("latency", time.Since(startTime)),
-				)...,
-			)
-			return SuccessResponse(c, cfg, result, matchedPolicy)
-		} else {
-			auditEvent := baseAudit
-			auditEvent.Timestamp = time.Now().UTC()
-			auditEvent.AuthMethod = result.Method
-			auditEvent.AuthName = result.Name
-			auditEvent.User = result.User
-			auditEvent.Roles = result.Roles
-			if matchedPolicy != nil {
-				auditEvent.Policy = matchedPolicy.Name
-			}
-			auditEvent.Result = "denied"
-			auditEvent.Reason = "policy_requirements_not_met"
-			auditEvent.Status = fiber.StatusUnauthorized
-			auditEvent.LatencyMs = time.Since(startTime).Milliseconds()
-			if err := s.Audit.Log(&auditEvent); err != nil {
-				s.Logger.Error("audit log failed", zap.Error(err))
+		// 吞吐量限制：只对通过的请求计数
+		if limiter.throughput != nil && out.Allowed {
+			quota := limiter.throughput.Take(limiter.throughputKey(limitKey, out.Result))
+			setRateLimitHeaders(c, quota)
+			if !quota.Allowed {
+				auditEvent := identityAudit(baseAudit, out)
+				s.Logger.Warn("policy throughput limit exceeded",
+					append(logFields, zap.String("policy", out.PolicyName()), zap.Duration("retry_after", quota.RetryAfter))...,
+				)
+				return s.rateLimited(c, cfg, out.Policy, &auditEvent, startTime, "throughput_limit_exceeded",
+					"Too many requests", quota.RetryAfter)
 			}
+		}
+	}
 
-			// 认证成功但不满足策略要求
-			policyName := ""
-			if matchedPolicy != nil {
-				policyName = matchedPolicy.Name
+	// 凭证用量配额：只对通过的请求计数
+	if out.Allowed && quotas != nil {
+		if key := quotaKey(out.Result); key != "" {
+			if limits, ok := quotaLimits[key]; ok {
+				usage := quotas.Consume(key, limits)
+				setQuotaHeaders(c, usage)
+				if !usage.Allowed {
+					auditEvent := identityAudit(baseAudit, out)
+					s.Logger.Warn("usage quota exceeded",
+						append(logFields,
+							zap.String("policy", out.PolicyName()),
+							zap.String("credential", key),
+							zap.String("period", usage.Exceeded),
+							zap.Duration("retry_after", usage.Reset),
+						)...,
+					)
+					return s.rateLimited(c, cfg, out.Policy, &auditEvent, startTime, "quota_exceeded",
+						"Usage quota exceeded", usage.Reset)
+				}
 			}
-			s.Logger.Warn("auth denied - policy check failed",
-				append(logFields,
-					zap.String("auth_method", result.Method),
-					zap.String("user", result.User),
-					zap.Strings("roles", result.Roles),
-					zap.String("policy", policyName),
-					zap.String("reason", "policy_requirements_not_met"),
-					zap.Duration("latency", time.Since(startTime)),
-				)...,
-			)
-			return UnauthorizedResponse(c, cfg, "Policy requirements not met")
 		}
 	}
 
-	// 6. 认证失败
+	// 5. 记录审计日志
 	auditEvent := baseAudit
 	auditEvent.Timestamp = time.Now().UTC()
-	auditEvent.Result = "denied"
-	auditEvent.Reason = "invalid_credentials"
-	auditEvent.Status = fiber.StatusUnauthorized
-	auditEvent.LatencyMs = time.Since(startTime).Milliseconds()
-	if err := s.Audit.Log(&auditEvent); err != nil {
-		s.Logger.Error("audit log failed", zap.Error(err))
+	auditEvent.Policy = out.PolicyName()
+	auditEvent.Rule = out.Rule
+	auditEvent.Status = out.Status
+	if out.Result != nil {
+		auditEvent.AuthMethod = out.Result.Method
+		auditEvent.AuthName = out.Result.Name
+		auditEvent.User = out.Result.User
+		auditEvent.Roles = out.Result.Roles
+		auditEvent.Groups = out.Result.Groups
+	}
+	if out.Allowed {
+		auditEvent.Result = "success"
+	} else {
+		auditEvent.Result = "denied"
+		auditEvent.Reason = out.Reason
+	}
+	s.finishAuth(c, &auditEvent, startTime)
+
+	logFields = append(logFields, zap.String("policy", out.PolicyName()))
+	if out.Result != nil {
+		logFields = append(logFields,
+			zap.String("auth_method", out.Result.Method),
+			zap.String("user", out.Result.User),
+			zap.Strings("roles", out.Result.Roles),
+		)
+	}
+
+	// 6. 返回响应
+	if out.Allowed {
+		anonymous := out.Result.Method == "anonymous"
+		// 认证成功，重置速率限制（backoff 模式逐步衰减；匿名访问不重置）
+		switch {
+		case anonymous || rateLimiter == nil:
+		case progressive != nil:
+			progressive.Succeed(limitKey)
+		default:
+			rateLimiter.Reset(limitKey)
+		}
+
+		message := "auth success"
+		if anonymous {
+			message = "auth success - anonymous"
+		}
+		s.Logger.Info(message,
+			append(logFields, zap.Duration("latency", time.Since(startTime)))...,
+		)
+
+		// Webhook headers 先写入，身份相关的 headers 不会被覆盖
+		for name, value := range authzHeaders(out.Authz) {
+			c.Set(name, value)
+		}
+		if cfg.Headers.CountryHeader != "" && geo.Country != "" {
+			c.Set(cfg.Headers.CountryHeader, geo.Country)
+		}
+		return SuccessResponse(c, cfg, out.Result, out.Policy)
 	}
 
-	s.Logger.Warn("auth denied - no valid authentication",
+	webhookReason := ""
+	if out.Authz != nil {
+		webhookReason = out.Authz.Reason
+	}
+	s.Logger.Warn(denyLogMessage(out),
 		append(logFields,
-			zap.String("reason", "invalid_credentials"),
+			zap.String("reason", out.Reason),
+			zap.String("requirement", out.Requirement),
+			zap.String("rule", out.Rule),
+			zap.String("webhook_reason", webhookReason),
 			zap.Duration("latency", time.Since(startTime)),
+			zap.Duration("backoff", backoffDelay),
 		)...,
 	)
-	return UnauthorizedResponse(c, cfg, "Unauthorized")
+	if backoffDelay > 0 {
+		c.Set("Retry-After", strconv.FormatInt(retryAfterSeconds(backoffDelay), 10))
+		holdResponse(c, min(backoffDelay, time.Duration(cfg.RateLimit.Backoff.HoldSecs)*time.Second))
+	}
+	if out.Error != nil {
+		return ForbiddenResponse(c, cfg, out.Policy, out.Challenges, out.Status, out.Error)
+	}
+	return UnauthorizedResponse(c, cfg, out.Policy, out.Challenges, out.Message, getForwardedURL(c, trustedCIDRs))
+}
+
+// rateLimited 记录审计日志并返回 429 响应
+func (s *Server) rateLimited(c *fiber.Ctx, cfg *config.Config, matchedPolicy *config.RoutePolicy, event *audit.Event,
+	startTime time.Time, reason, message string, retryAfter time.Duration,
+) error {
+	event.Timestamp = time.Now().UTC()
+	event.Result = "rate_limited"
+	event.Reason = reason
+	event.Status = fiber.StatusTooManyRequests
+	s.finishAuth(c, event, startTime)
+	return TooManyRequestsResponse(c, cfg, matchedPolicy, message, retryAfterSeconds(retryAfter))
+}
+
+// blocked 记录审计日志并返回 403 响应（客户端被 IP 过滤或全局国家 / ASN 规则拒绝）
+func (s *Server) blocked(c *fiber.Ctx, cfg *config.Config, event *audit.Event, startTime time.Time, out *Outcome) error {
+	event.Timestamp = time.Now().UTC()
+	event.Result = "blocked"
+	event.Reason = out.Reason
+	event.Rule = out.Rule
+	event.Status = out.Status
+	s.finishAuth(c, event, startTime)
+	return ForbiddenResponse(c, cfg, nil, nil, out.Status, out.Error)
+}
+
+// holdResponse 保持失败响应 d（backoff 模式的 tarpit），服务器关闭时提前返回
+func holdResponse(c *fiber.Ctx, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-c.Context().Done():
+	}
+}
+
+// identityAudit 返回带有策略和认证身份的审计事件（用于认证之后的限流拒绝）
+func identityAudit(base audit.Event, out *Outcome) audit.Event {
+	base.Policy = out.PolicyName()
+	if out.Result != nil {
+		base.AuthMethod = out.Result.Method
+		base.AuthName = out.Result.Name
+		base.User = out.Result.User
+	}
+	return base
+}
+
+// denyLogMessage 返回拒绝请求时的日志消息
+func denyLogMessage(out *Outcome) string {
+	switch {
+	case out.Reason == "outside_schedule":
+		return "auth denied - outside schedule"
+	case out.Result == nil && (out.Reason == geoip.ReasonCountryDenied || out.Reason == geoip.ReasonASNDenied):
+		return "auth denied - geo restriction"
+	case out.Result == nil:
+		return "auth denied - no valid authentication"
+	case out.Result.Method == "anonymous":
+		return "auth denied - explicit deny"
+	default:
+		return "auth denied - policy check failed"
+	}
+}
+
+// requestHeaders 收集请求 headers（名称为小写，同名 header 取第一个值）
+func requestHeaders(c *fiber.Ctx) map[string]string {
+	headers := make(map[string]string)
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		name := strings.ToLower(string(key))
+		if _, exists := headers[name]; !exists {
+			headers[name] = string(value)
+		}
+	})
+	return headers
 }
@@ -2,6 +2,9 @@ package server
 
 import (
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
 )
 
 // HandleHealth 处理健康检查请求
@@ -56,5 +59,55 @@ func (s *Server) HandleDebug(c *fiber.Ctx) error {
 			"jwt_enabled":   cfg.JWT.Secret != "",
 		},
 		"policies": policyNames,
+		"groups":   debugGroups(cfg, s.GetStore()),
 	})
 }
+
+// debugGroups 构建组和有效成员关系摘要
+// memberships 按凭证列出解析组和角色继承后的有效组与角色（JWT 身份在运行时根据 claims 解析）
+func debugGroups(cfg *config.Config, store *auth.AuthStore) fiber.Map {
+	groups := make([]fiber.Map, 0, len(cfg.Groups))
+	for _, g := range cfg.Groups {
+		groups = append(groups, fiber.Map{
+			"name":    g.Name,
+			"members": g.Members,
+			"roles":   cfg.ExpandRoles(g.Roles),
+		})
+	}
+
+	mappings := make([]fiber.Map, 0, len(cfg.GroupMappings))
+	for i := range cfg.GroupMappings {
+		m := &cfg.GroupMappings[i]
+		mappings = append(mappings, fiber.Map{
+			"claim":  m.ClaimName(),
+			"match":  m.Match,
+			"value":  m.Value,
+			"groups": m.Groups,
+			"roles":  m.Roles,
+		})
+	}
+
+	memberships := make(map[string]fiber.Map)
+	addMembership := func(result *auth.AuthResult) {
+		resolved := expandRoles(cfg, auth.ResolveGroups(result, store))
+		memberships[result.Method+":"+result.Name] = fiber.Map{
+			"groups": resolved.Groups,
+			"roles":  resolved.Roles,
+		}
+	}
+	for _, b := range cfg.BasicAuths {
+		addMembership(&auth.AuthResult{Method: "basic", Name: b.Name, User: b.User, Roles: b.Roles})
+	}
+	for _, b := range cfg.BearerTokens {
+		addMembership(&auth.AuthResult{Method: "bearer", Name: b.Name, Roles: b.Roles})
+	}
+	for _, k := range cfg.APIKeys {
+		addMembership(&auth.AuthResult{Method: "apikey", Name: k.Name, Roles: k.Roles})
+	}
+
+	return fiber.Map{
+		"definitions": groups,
+		"mappings":    mappings,
+		"memberships": memberships,
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/ipfilter"
+)
+
+// NewIPFilter 创建全局 IP 过滤器（没有配置任何来源时返回 nil）
+// 文件读取或解析失败只记录日志：保留上一次加载的条目，文件修复后自动重新加载
+func NewIPFilter(cfg config.IPFilterConfig, logger *zap.Logger) (*ipfilter.Filter, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	return ipfilter.New(ipfilter.Options{
+		Allow:           cfg.Allow,
+		Deny:            cfg.Deny,
+		AllowFiles:      cfg.AllowFiles,
+		DenyFiles:       cfg.DenyFiles,
+		DecisionsFile:   cfg.DecisionsFile,
+		DecisionsFormat: cfg.DecisionsFormat,
+		ReloadInterval:  time.Duration(cfg.ReloadSecs) * time.Second,
+	}, func(path string, err error) {
+		logger.Error("failed to load ip filter file", zap.String("path", path), zap.Error(err))
+	})
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/audit"
+	"github.com/nerdneilsfield/tiny-auth/internal/metrics"
+	"github.com/nerdneilsfield/tiny-auth/internal/ratelimit"
+)
+
+// cacheAuthzWebhook 外部授权 Webhook 决策缓存在指标中的名称
+const cacheAuthzWebhook = "authz_webhook"
+
+// setupMetrics 按 [metrics] 创建指标注册表（未启用时不做任何事）
+// metrics.listen 为空时在主端口上注册端点，否则创建单独的 HTTP 服务器（在 Start 中启动）
+func (s *Server) setupMetrics(app *fiber.App) {
+	cfg := s.Config.Metrics
+	if !cfg.Enabled {
+		return
+	}
+
+	s.metrics = metrics.New(s.limiterStats)
+	if cfg.Listen == "" {
+		app.Get(cfg.Path, adaptor.HTTPHandler(s.metrics.Handler()))
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Path, s.metrics.Handler())
+	s.metricsServer = &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// startMetricsServer 在后台启动单独的指标监听器
+func (s *Server) startMetricsServer() {
+	if s.metricsServer == nil {
+		return
+	}
+	s.Logger.Info("metrics listener starting",
+		zap.String("addr", s.metricsServer.Addr),
+		zap.String("path", s.Config.Metrics.Path),
+	)
+	go func() {
+		if err := s.metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.Logger.Error("metrics listener failed", zap.Error(err))
+		}
+	}()
+}
+
+// limiterStats 返回全局和策略级限流器的记录数和封禁数（采集指标时调用）
+func (s *Server) limiterStats() []metrics.LimiterStats {
+	s.mu.RLock()
+	rateLimiter := s.RateLimiter
+	policyLimiters := s.policyLimiters
+	s.mu.RUnlock()
+
+	var stats []metrics.LimiterStats
+	if rateLimiter != nil {
+		stats = append(stats, backendStats("global", rateLimiter))
+	}
+	for name, l := range policyLimiters {
+		if l.failures != nil {
+			stats = append(stats, backendStats("policy:"+name, l.failures))
+		}
+	}
+	return stats
+}
+
+// backendStats 读取一个进程内限流后端的状态
+// Redis 后端不提供记录数和封禁数：列出封禁需要 SCAN 全部 key，不应在每次采集指标时访问 Redis
+func backendStats(scope string, backend ratelimit.Backend) metrics.LimiterStats {
+	stats := metrics.LimiterStats{Scope: scope, Records: -1, Bans: -1}
+	counter, ok := backend.(interface{ GetTotalRecords() int })
+	if !ok {
+		return stats
+	}
+	stats.Records = counter.GetTotalRecords()
+	if manager, ok := backend.(ratelimit.Manager); ok {
+		if bans, err := manager.ListBans(); err == nil {
+			stats.Bans = len(bans)
+		}
+	}
+	return stats
+}
+
+// writeAudit 用请求开始时取得的审计日志记录器写入审计事件，失败时记录日志和指标
+func (s *Server) writeAudit(c *fiber.Ctx, event *audit.Event) {
+	if err := requestAuditLogger(c).Log(event); err != nil {
+		s.metrics.AuditError()
+		s.Logger.Error("audit log failed", zap.Error(err))
+	}
+}
+
+// finishAuth 记录 forward-auth 请求的延迟，写入审计事件并更新请求指标和 span
+func (s *Server) finishAuth(c *fiber.Ctx, event *audit.Event, startTime time.Time) {
+	latency := time.Since(startTime)
+	event.LatencyMs = latency.Milliseconds()
+	s.writeAudit(c, event)
+	s.metrics.ObserveRequest(event.Result, event.AuthMethod, event.Policy, event.Status, latency)
+	annotateAuthSpan(c, event)
+}
+
+// ObserveReload 记录一次配置重载的结果（err 为 nil 表示成功）
+func (s *Server) ObserveReload(err error) {
+	s.metrics.ObserveReload(err == nil, time.Now())
+}
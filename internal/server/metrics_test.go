@@ -0,0 +1,168 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+)
+
+// TestMetricsEndpoint 测试请求、缓存、限流和重载指标
+func TestMetricsEndpoint(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"allow": true}`)
+	}))
+	defer webhook.Close()
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Port:         "3000",
+			AuthPath:     "/auth",
+			HealthPath:   "/health",
+			ReadTimeout:  30,
+			WriteTimeout: 30,
+		},
+		Metrics: config.MetricsConfig{Enabled: true, Path: "/metrics"},
+		BasicAuths: []config.BasicAuthConfig{
+			{Name: "user1", User: "user1", Pass: "pass1", Roles: []string{"user"}},
+		},
+		RateLimit: config.RateLimitConfig{Enabled: true, MaxAttempts: 2, WindowSecs: 60, BanSecs: 60},
+		RoutePolicies: []config.RoutePolicy{
+			{
+				Name:         "tenants",
+				PathPrefix:   "/tenants",
+				AuthzWebhook: &config.AuthzWebhookConfig{URL: webhook.URL, TimeoutMs: 1000, FailureMode: "closed", CacheTTLSecs: 60},
+			},
+		},
+	}
+
+	srv := createTestServer(t, cfg)
+	send := func(uri, authHeader, clientIP string) int {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/auth", http.NoBody)
+		req.Header.Set("X-Forwarded-Uri", uri)
+		req.Header.Set("X-Forwarded-For", clientIP)
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		}
+		resp, err := srv.App.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	user := "Basic dXNlcjE6cGFzczE=" // user1:pass1
+	for i := 0; i < 2; i++ {
+		if status := send("/tenants/1", user, "192.0.2.1"); status != http.StatusOK {
+			t.Fatalf("expected 200, got %d", status)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		send("/tenants/1", "Basic d3Jvbmc6d3Jvbmc=", "198.51.100.7")
+	}
+	srv.ObserveReload(errors.New("bad config"))
+
+	req := httptest.NewRequest("GET", "/metrics", http.NoBody)
+	resp, err := srv.App.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from /metrics, got %d", resp.StatusCode)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	body := string(data)
+
+	for _, want := range []string{
+		`tinyauth_requests_total{auth_method="basic",policy="tenants",result="success",status="200"} 2`,
+		`tinyauth_requests_total{auth_method="none",policy="tenants",result="denied",status="401"} 2`,
+		`tinyauth_requests_total{auth_method="none",policy="none",result="rate_limited",status="429"} 1`,
+		`tinyauth_request_duration_seconds_count{result="success"} 2`,
+		`tinyauth_cache_requests_total{cache="authz_webhook",result="hit"} 1`,
+		`tinyauth_cache_requests_total{cache="authz_webhook",result="miss"} 1`,
+		`tinyauth_ratelimit_records{scope="global"} 1`,
+		`tinyauth_ratelimit_active_bans{scope="global"} 1`,
+		`tinyauth_config_reloads_total{result="failure"} 1`,
+		`tinyauth_config_last_reload_successful 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in metrics output", want)
+		}
+	}
+	for _, leaked := range []string{"user1", "198.51.100.7", "192.0.2.1"} {
+		if strings.Contains(body, leaked) {
+			t.Errorf("metrics output must not contain %q", leaked)
+		}
+	}
+}
+
+// TestMetricsEndpoint_SeparateListener 测试单独监听时主端口不提供指标
+func TestMetricsEndpoint_SeparateListener(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Port:         "3000",
+			AuthPath:     "/auth",
+			ReadTimeout:  30,
+			WriteTimeout: 30,
+		},
+		Metrics: config.MetricsConfig{Enabled: true, Path: "/metrics", Listen: "127.0.0.1:9100"},
+	}
+
+	srv := createTestServer(t, cfg)
+	resp, err := srv.App.Test(httptest.NewRequest("GET", "/metrics", http.NoBody), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		t.Error("metrics should not be served on the main port")
+	}
+
+	if srv.metricsServer == nil {
+		t.Fatal("expected a separate metrics server")
+	}
+	rec := httptest.NewRecorder()
+	srv.metricsServer.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", http.NoBody))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "tinyauth_config_last_reload_successful 1") {
+		t.Errorf("unexpected metrics listener response %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestMetricsEndpoint_RedisBackend 测试采集指标时不访问 Redis（不 SCAN 封禁 key）
+func TestMetricsEndpoint_RedisBackend(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Port:         "3000",
+			AuthPath:     "/auth",
+			ReadTimeout:  30,
+			WriteTimeout: 30,
+		},
+		Metrics: config.MetricsConfig{Enabled: true, Path: "/metrics"},
+		RateLimit: config.RateLimitConfig{
+			Enabled: true, MaxAttempts: 2, WindowSecs: 60, BanSecs: 60,
+			Backend: "redis",
+			Redis:   config.RedisConfig{Addr: mr.Addr(), KeyPrefix: "tiny-auth:", TimeoutMs: 100},
+		},
+	}
+	srv := createTestServer(t, cfg)
+
+	before := mr.CommandCount()
+	resp, err := srv.App.Test(httptest.NewRequest("GET", "/metrics", http.NoBody), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := mr.CommandCount() - before; n != 0 {
+		t.Errorf("scrape sent %d commands to Redis", n)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if strings.Contains(string(data), `tinyauth_ratelimit_active_bans{scope="global"}`) {
+		t.Error("ban count should not be reported for the redis backend")
+	}
+}
@@ -0,0 +1,412 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/authz"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	apperrors "github.com/nerdneilsfield/tiny-auth/internal/errors"
+	"github.com/nerdneilsfield/tiny-auth/internal/geoip"
+	"github.com/nerdneilsfield/tiny-auth/internal/ipfilter"
+	"github.com/nerdneilsfield/tiny-auth/internal/policy"
+	"github.com/nerdneilsfield/tiny-auth/internal/tracing"
+)
+
+// Pipeline 认证决策流水线：客户端过滤 → 策略匹配 → 匿名访问 → 认证 → 策略检查 → 外部授权
+// HandleAuth 和 check 命令共用同一条流水线，保证模拟结果与线上行为一致
+// 构建后只读，热重载时整体替换
+type Pipeline struct {
+	config   *config.Config
+	store    *auth.AuthStore
+	index    *policy.Index
+	webhooks map[string]*authz.Webhook
+	logger   *zap.Logger
+}
+
+// NewPipeline 根据配置构建认证决策流水线（编译策略索引、创建 Webhook 客户端）
+func NewPipeline(cfg *config.Config, store *auth.AuthStore, logger *zap.Logger) *Pipeline {
+	return &Pipeline{
+		config:   cfg,
+		store:    store,
+		index:    policy.NewIndex(cfg.RoutePolicies),
+		webhooks: buildWebhooks(cfg, logger),
+		logger:   logger,
+	}
+}
+
+// AuthRequest 待评估的请求（转发信息已按可信代理规则解析）
+type AuthRequest struct {
+	policy.Request
+	Authorization string // Authorization header
+	APIKey        string // X-Api-Key header
+	SkipWebhook   bool   // 不调用外部授权 Webhook（check 命令默认跳过）
+
+	// 调用方已经用 Match 匹配过策略时设置（HandleAuth 在策略级封禁检查时匹配），Evaluate 不再查询策略索引
+	Matched bool
+	Policy  *config.RoutePolicy // 匹配的策略（Matched 为 true 时有效，可能为 nil）
+}
+
+// Outcome 认证决策结果
+type Outcome struct {
+	Policy         *config.RoutePolicy // 匹配的策略（可能为 nil）
+	Authenticators []string            // 按顺序尝试过的认证方式
+	Result         *auth.AuthResult    // 认证得到的身份（已展开组和角色；凭证无效时为 nil）
+	Allowed        bool
+	Status         int                 // 返回给代理的 HTTP 状态码
+	Reason         string              // 拒绝原因（与审计日志一致）
+	Message        string              // 返回给客户端的错误信息
+	Requirement    string              // 未满足的策略要求（reason 为 policy_requirements_not_met 时）
+	Error          *apperrors.AppError // 授权失败（凭证有效但无权访问）时返回给客户端的结构化错误
+	Rule           string              // 决定结果的 allow/deny 规则（策略没有规则时为空）
+	Authz          *authz.Decision
+	WebhookSkipped bool     // 策略配置了 Webhook 但本次评估跳过了调用
+	AuthzCache     string   // Webhook 决策缓存的查询结果: "hit" / "miss"（未启用缓存或未调用时为空）
+	Challenges     []string // WWW-Authenticate challenges（401，以及 Bearer 凭证 insufficient_scope 的 403）
+}
+
+// PolicyName 返回匹配的策略名称（没有匹配时为空）
+func (o *Outcome) PolicyName() string {
+	if o.Policy == nil {
+		return ""
+	}
+	return o.Policy.Name
+}
+
+// Match 返回请求匹配的路由策略（没有匹配时为 nil）
+func (p *Pipeline) Match(ctx context.Context, req *policy.Request) *config.RoutePolicy {
+	_, span := tracing.Start(ctx, "policy.match")
+	matched := p.index.Match(req)
+	if span.IsRecording() {
+		name := ""
+		if matched != nil {
+			name = matched.Name
+		}
+		span.SetAttributes(attribute.String("tinyauth.policy", name))
+	}
+	span.End()
+	return matched
+}
+
+// ScreenClient 全局客户端过滤（在速率限制和认证之前）：IP 允许 / 拒绝列表，然后是全局国家 / ASN 规则
+// 客户端被拒绝时返回 403 的决策结果，否则返回 nil；filter 为 nil 表示未配置 IP 过滤
+// ipAllowed 表示客户端命中 IP 允许列表（跳过全局国家 / ASN 规则和全局速率限制）
+func (p *Pipeline) ScreenClient(filter *ipfilter.Filter, clientIP string, geo geoip.Info) (out *Outcome, ipAllowed bool) {
+	if filter != nil {
+		switch decision := filter.Check(clientIP); decision.Action {
+		case ipfilter.ActionDeny:
+			out = &Outcome{Rule: decision.Source}
+			return out.forbid(fiber.StatusForbidden, "ip_denied", apperrors.AuthzDenied("ip_denied")), false
+		case ipfilter.ActionAllow:
+			return nil, true
+		}
+	}
+
+	rules := p.config.GeoIP.Rules()
+	if reason := rules.Check(geo.Country, geo.ASN); reason != "" {
+		out = &Outcome{}
+		return out.forbid(fiber.StatusForbidden, reason, apperrors.AuthzDenied(reason)), false
+	}
+	return nil, false
+}
+
+// Evaluate 对请求执行完整的认证决策（不包括速率限制）
+func (p *Pipeline) Evaluate(ctx context.Context, req *AuthRequest) *Outcome {
+	out := p.evaluate(ctx, req)
+	if out.Status == fiber.StatusUnauthorized {
+		// 只声明匹配策略接受的认证方式；提供了无效的 Bearer token 时附加 invalid_token
+		bearerError := ""
+		if out.bearerFailed() {
+			bearerError = bearerErrorInvalidToken
+		}
+		out.Challenges = authChallenges(p.config, out.Policy, bearerError)
+	}
+	return out
+}
+
+//nolint:gocognit,gocyclo // auth flow intentionally aggregates multiple checks
+func (p *Pipeline) evaluate(ctx context.Context, req *AuthRequest) *Outcome {
+	cfg := p.config
+	store := p.store
+	policyReq := &req.Request
+
+	// 1. 匹配路由策略（host / 路径 / 方法 / header / query / 来源网段）
+	matchedPolicy := req.Policy
+	if !req.Matched {
+		matchedPolicy = p.Match(ctx, policyReq)
+	}
+	out := &Outcome{Policy: matchedPolicy}
+
+	// 检查策略的访问时间窗口（窗口外直接拒绝，不再尝试认证；返回 403，提供凭证也无济于事）
+	if !policy.InSchedule(matchedPolicy, policyReq.Time) {
+		return out.forbid(fiber.StatusForbidden, "outside_schedule", apperrors.AuthzDenied("outside_schedule"))
+	}
+
+	// 检查策略的国家 / ASN 限制（在认证之前拒绝）
+	if reason := policy.CheckGeo(matchedPolicy, policyReq); reason != "" {
+		return out.forbid(fiber.StatusForbidden, reason, apperrors.AuthzDenied(reason))
+	}
+
+	// 2. 检查是否允许匿名访问（条件不满足或被 deny 规则拒绝时，继续要求认证）
+	anonymous := expandRoles(cfg, anonymousResult())
+	anonymousAllowed := matchedPolicy != nil && matchedPolicy.AllowAnonymous &&
+		p.checkCondition(matchedPolicy, anonymous, policyReq)
+	var anonymousDecision policy.RuleDecision
+	if anonymousAllowed {
+		anonymousDecision = p.evaluateRules(matchedPolicy, anonymous, policyReq)
+		anonymousAllowed = anonymousDecision.Allow
+	}
+	if anonymousAllowed {
+		out.Authenticators = []string{"anonymous"}
+		out.Result = anonymous
+		out.Rule = ruleForAudit(matchedPolicy, anonymousDecision)
+		return out.allow()
+	}
+
+	// 3. 尝试各种认证方式（按优先级）
+	result := p.authenticate(ctx, out, req)
+
+	// 4. 检查策略约束（先解析组成员关系，再按 [roles] 继承关系展开角色）
+	if result != nil {
+		result = expandRoles(cfg, auth.ResolveGroups(result, store))
+		out.Result = result
+
+		// 凭证有效但不满足策略：返回 403（授权失败），避免客户端重新提示输入凭证
+		_, checkSpan := tracing.Start(ctx, "policy.check")
+		var ruleDecision policy.RuleDecision
+		if violation := policy.CheckPolicy(matchedPolicy, result, store); violation != nil {
+			out.Requirement = violation.String()
+			out.Challenges = scopeChallenges(matchedPolicy, result.Method, violation)
+			out.forbid(fiber.StatusForbidden, "policy_requirements_not_met", violation.AppError())
+		} else if !policy.CredentialInSchedule(result, store, policyReq.Time) {
+			out.forbid(fiber.StatusForbidden, "outside_schedule",
+				apperrors.NewAppError(apperrors.ErrCodeAuthzDenied, "Access not allowed at this time", nil).
+					WithDetail("reason", "outside_schedule"))
+		} else if ruleDecision = p.evaluateRules(matchedPolicy, result, policyReq); !ruleDecision.Allow {
+			out.forbid(denyStatusFor(matchedPolicy), "explicit_deny", apperrors.AuthzDenied("explicit_deny"))
+		} else if !p.checkCondition(matchedPolicy, result, policyReq) {
+			out.forbid(fiber.StatusForbidden, "condition_not_met", apperrors.AuthzDenied("condition_not_met"))
+		}
+		out.Rule = ruleForAudit(matchedPolicy, ruleDecision)
+		if checkSpan.IsRecording() {
+			checkSpan.SetAttributes(
+				attribute.Bool("tinyauth.allowed", out.Reason == ""),
+				attribute.String("tinyauth.reason", out.Reason),
+				attribute.String("tinyauth.rule", out.Rule),
+			)
+		}
+		checkSpan.End()
+		if out.Reason != "" {
+			return out
+		}
+
+		// 5. 外部授权 Webhook（仅在本地策略检查通过后调用）
+		if req.SkipWebhook {
+			out.WebhookSkipped = matchedPolicy != nil && matchedPolicy.AuthzWebhook != nil
+			return out.allow()
+		}
+		decision, cache, denyReason := p.checkAuthzWebhook(ctx, matchedPolicy, result, authz.RequestInfo{
+			Host:     policyReq.Host,
+			URI:      policyReq.URI,
+			Method:   policyReq.Method,
+			ClientIP: policyReq.ClientIP,
+		})
+		out.Authz = decision
+		out.AuthzCache = cache
+		// Webhook 授予的角色同样按 [roles] 继承关系展开
+		out.Result = expandRoles(cfg, applyAuthzDecision(result, decision))
+		if denyReason != "" {
+			return out.forbid(fiber.StatusForbidden, denyReason, apperrors.AuthzDenied(denyReason))
+		}
+		return out.allow()
+	}
+
+	// 6. 匿名请求被 deny 规则拒绝且未提供凭证：按显式拒绝处理
+	if !anonymousDecision.Allow && anonymousDecision.Rule != "" && req.Authorization == "" && req.APIKey == "" {
+		out.Authenticators = []string{"anonymous"}
+		out.Result = anonymous
+		out.Rule = anonymousDecision.Rule
+		return out.forbid(denyStatusFor(matchedPolicy), "explicit_deny", apperrors.AuthzDenied("explicit_deny"))
+	}
+
+	// 7. 认证失败
+	return out.deny(fiber.StatusUnauthorized, "invalid_credentials", "Unauthorized")
+}
+
+// Authenticate 只验证凭证（不匹配路由策略），返回解析了组成员关系和角色继承的结果（用于管理 API）
+// 凭证无效时返回 nil
+func (p *Pipeline) Authenticate(req *AuthRequest) *auth.AuthResult {
+	result := p.authenticate(context.Background(), &Outcome{}, req)
+	if result == nil {
+		return nil
+	}
+	return expandRoles(p.config, auth.ResolveGroups(result, p.store))
+}
+
+// authenticate 按优先级尝试各种认证方式，记录尝试过的方式
+func (p *Pipeline) authenticate(ctx context.Context, out *Outcome, req *AuthRequest) *auth.AuthResult {
+	cfg := p.config
+	store := p.store
+	authScheme, authToken := auth.ParseAuthHeader(req.Authorization)
+	var result *auth.AuthResult
+
+	// 优先级 1: JWT（如果配置了且看起来像 JWT）
+	if cfg.JWT.Secret != "" && strings.EqualFold(authScheme, "Bearer") {
+		if auth.IsJWT(authToken) {
+			result = attempt(ctx, out, "jwt", func() *auth.AuthResult { return auth.TryJWT(authToken, &cfg.JWT) })
+		}
+	}
+
+	// 优先级 2: Bearer Token（静态 token）
+	if result == nil && strings.EqualFold(authScheme, "Bearer") {
+		result = attempt(ctx, out, "bearer", func() *auth.AuthResult { return auth.TryBearer(req.Authorization, store) })
+	}
+
+	// 优先级 3: Basic Auth
+	if result == nil && strings.EqualFold(authScheme, "Basic") {
+		result = attempt(ctx, out, "basic", func() *auth.AuthResult { return auth.TryBasic(req.Authorization, store) })
+	}
+
+	// 优先级 4: API Key (Authorization: ApiKey xxx)
+	if result == nil && strings.EqualFold(authScheme, "ApiKey") {
+		result = attempt(ctx, out, "apikey", func() *auth.AuthResult { return auth.TryAPIKeyAuth(req.Authorization, store) })
+	}
+
+	// 优先级 5: API Key (X-Api-Key header)
+	if result == nil && req.APIKey != "" {
+		result = attempt(ctx, out, "apikey", func() *auth.AuthResult { return auth.TryAPIKeyHeader(req.APIKey, store) })
+	}
+
+	return result
+}
+
+// attempt 记录尝试的认证方式并执行认证，每次尝试对应一个 span
+func attempt(ctx context.Context, out *Outcome, method string, try func() *auth.AuthResult) *auth.AuthResult {
+	out.Authenticators = append(out.Authenticators, method)
+	_, span := tracing.Start(ctx, "auth.attempt")
+	result := try()
+	if span.IsRecording() {
+		span.SetAttributes(
+			attribute.String("tinyauth.auth_method", method),
+			attribute.Bool("tinyauth.authenticated", result != nil),
+		)
+	}
+	span.End()
+	return result
+}
+
+func (o *Outcome) allow() *Outcome {
+	o.Allowed = true
+	o.Status = fiber.StatusOK
+	return o
+}
+
+func (o *Outcome) deny(status int, reason, message string) *Outcome {
+	o.Allowed = false
+	o.Status = status
+	o.Reason = reason
+	o.Message = message
+	return o
+}
+
+// bearerFailed 客户端提供了 Bearer token（JWT 或静态 token）但认证失败
+func (o *Outcome) bearerFailed() bool {
+	if o.Result != nil {
+		return false
+	}
+	for _, method := range o.Authenticators {
+		if method == "jwt" || method == "bearer" {
+			return true
+		}
+	}
+	return false
+}
+
+// forbid 授权失败（凭证有效或被显式拒绝）：策略 deny_response = "not_found" 时改为 404
+func (o *Outcome) forbid(status int, reason string, appErr *apperrors.AppError) *Outcome {
+	if o.Policy != nil && o.Policy.DenyResponse == "not_found" {
+		status = fiber.StatusNotFound
+	}
+	o.Error = appErr
+	return o.deny(status, reason, appErr.Message)
+}
+
+// Headers 返回认证成功时注入给上游的 headers（拒绝时为 nil）
+// route 对应 X-Auth-Route 的值（转发的 host + uri）
+func (o *Outcome) Headers(cfg *config.Config, route string) map[string]string {
+	if !o.Allowed {
+		return nil
+	}
+	// Webhook headers 先写入，身份相关的 headers 不会被覆盖
+	headers := authzHeaders(o.Authz)
+	for name, value := range successHeaders(cfg, o.Result, o.Policy, route) {
+		headers[name] = value
+	}
+	return headers
+}
+
+// anonymousResult 匿名访问的认证结果
+func anonymousResult() *auth.AuthResult {
+	return &auth.AuthResult{
+		Method: "anonymous",
+		Roles:  []string{"anonymous"},
+	}
+}
+
+// expandRoles 返回角色按继承关系展开后的认证结果副本（不修改原结果）
+func expandRoles(cfg *config.Config, result *auth.AuthResult) *auth.AuthResult {
+	if len(cfg.Roles) == 0 {
+		return result
+	}
+	expanded := *result
+	expanded.Roles = cfg.ExpandRoles(result.Roles)
+	return &expanded
+}
+
+// checkCondition 对策略条件表达式求值，求值出错时记录日志并按不满足处理
+func (p *Pipeline) checkCondition(matchedPolicy *config.RoutePolicy, result *auth.AuthResult, req *policy.Request) bool {
+	ok, err := policy.CheckCondition(matchedPolicy, result, req)
+	if err != nil {
+		p.logger.Error("policy condition evaluation failed",
+			zap.String("policy", matchedPolicy.Name),
+			zap.String("condition", matchedPolicy.Condition),
+			zap.Error(err),
+		)
+		return false
+	}
+	return ok
+}
+
+// evaluateRules 评估策略的 allow/deny 规则，求值出错时记录日志并按拒绝处理
+func (p *Pipeline) evaluateRules(matchedPolicy *config.RoutePolicy, result *auth.AuthResult, req *policy.Request) policy.RuleDecision {
+	decision, err := policy.EvaluateRules(matchedPolicy, result, req)
+	if err != nil {
+		p.logger.Error("policy rule evaluation failed",
+			zap.String("policy", matchedPolicy.Name),
+			zap.String("rule", decision.Rule),
+			zap.Error(err),
+		)
+	}
+	return decision
+}
+
+// ruleForAudit 返回审计日志中记录的规则名称（策略没有规则时为空）
+func ruleForAudit(matchedPolicy *config.RoutePolicy, decision policy.RuleDecision) string {
+	if matchedPolicy == nil || len(matchedPolicy.Rules) == 0 {
+		return ""
+	}
+	return decision.Rule
+}
+
+// denyStatusFor 返回策略显式拒绝时的 HTTP 状态码
+func denyStatusFor(matchedPolicy *config.RoutePolicy) int {
+	if matchedPolicy != nil && matchedPolicy.DenyStatus != 0 {
+		return matchedPolicy.DenyStatus
+	}
+	return fiber.StatusForbidden
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/policy"
+)
+
+// TestPipeline_Evaluate 测试认证决策流水线的诊断信息
+func TestPipeline_Evaluate(t *testing.T) {
+	cfg := &config.Config{
+		BasicAuths: []config.BasicAuthConfig{
+			{Name: "alice", User: "alice", Pass: "alice-password", Roles: []string{"user"}},
+		},
+		APIKeys: []config.APIKeyConfig{
+			{Name: "ci", Key: "ci-key-1234567890", Roles: []string{"api"}},
+		},
+		RoutePolicies: []config.RoutePolicy{
+			{Name: "admin", Host: "admin.example.com", RequireAllRoles: []string{"admin"}},
+			{Name: "public", Host: "www.example.com", AllowAnonymous: true},
+			{
+				Name:                "hooked",
+				Host:                "hooks.example.com",
+				InjectAuthorization: "Bearer upstream",
+				AuthzWebhook:        &config.AuthzWebhookConfig{URL: "http://127.0.0.1:1/authz"},
+			},
+		},
+		Headers: config.HeadersConfig{UserHeader: "X-Auth-User"},
+	}
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:alice-password"))
+
+	tests := []struct {
+		name           string
+		req            AuthRequest
+		allowed        bool
+		reason         string
+		authenticators []string
+		requirement    string
+		webhookSkipped bool
+		headers        map[string]string
+	}{
+		{
+			name:           "Missing role",
+			req:            AuthRequest{Request: policy.Request{Host: "admin.example.com", URI: "/"}, Authorization: basic},
+			reason:         "policy_requirements_not_met",
+			authenticators: []string{"basic"},
+			requirement:    "require_all_roles: missing admin",
+		},
+		{
+			name:           "Anonymous",
+			req:            AuthRequest{Request: policy.Request{Host: "www.example.com", URI: "/"}},
+			allowed:        true,
+			authenticators: []string{"anonymous"},
+			headers:        map[string]string{},
+		},
+		{
+			name:           "Invalid API key falls through",
+			req:            AuthRequest{Request: policy.Request{Host: "admin.example.com", URI: "/"}, Authorization: "Bearer nope", APIKey: "wrong"},
+			reason:         "invalid_credentials",
+			authenticators: []string{"bearer", "apikey"},
+		},
+		{
+			name:           "Webhook skipped",
+			req:            AuthRequest{Request: policy.Request{Host: "hooks.example.com", URI: "/"}, Authorization: basic, SkipWebhook: true},
+			allowed:        true,
+			authenticators: []string{"basic"},
+			webhookSkipped: true,
+			headers:        map[string]string{"X-Auth-User": "alice", "Authorization": "Bearer upstream"},
+		},
+		{
+			// 调用方已匹配的策略优先于策略索引（HandleAuth 每个请求只匹配一次）
+			name: "Pre-matched policy",
+			req: AuthRequest{
+				Request:       policy.Request{Host: "www.example.com", URI: "/"},
+				Authorization: basic,
+				Matched:       true,
+				Policy:        &cfg.RoutePolicies[0],
+			},
+			reason:         "policy_requirements_not_met",
+			authenticators: []string{"basic"},
+			requirement:    "require_all_roles: missing admin",
+		},
+	}
+
+	p := NewPipeline(cfg, auth.BuildStore(cfg), zap.NewNop())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Time = time.Now()
+			out := p.Evaluate(context.Background(), &tt.req)
+
+			if out.Allowed != tt.allowed || out.Reason != tt.reason {
+				t.Fatalf("expected allowed=%v reason=%q, got allowed=%v reason=%q", tt.allowed, tt.reason, out.Allowed, out.Reason)
+			}
+			if !equalStrings(out.Authenticators, tt.authenticators) {
+				t.Errorf("expected authenticators %v, got %v", tt.authenticators, out.Authenticators)
+			}
+			if out.Requirement != tt.requirement {
+				t.Errorf("expected requirement %q, got %q", tt.requirement, out.Requirement)
+			}
+			if out.WebhookSkipped != tt.webhookSkipped {
+				t.Errorf("expected webhook skipped=%v, got %v", tt.webhookSkipped, out.WebhookSkipped)
+			}
+
+			headers := out.Headers(cfg, tt.req.Host+tt.req.URI)
+			if tt.headers == nil {
+				if headers != nil {
+					t.Errorf("expected no headers for denied request, got %v", headers)
+				}
+				return
+			}
+			for name, value := range tt.headers {
+				if headers[name] != value {
+					t.Errorf("expected header %s=%q, got %q", name, value, headers[name])
+				}
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/quota"
+)
+
+// openQuotaStore 打开用量配额存储（没有凭证配置配额时返回 nil）
+func openQuotaStore(cfg *config.Config, logger *zap.Logger) (*quota.Store, error) {
+	if !cfg.HasQuotas() {
+		return nil, nil
+	}
+	return quota.Open(
+		cfg.Quota.Path,
+		cfg.Quota.Location(),
+		time.Duration(cfg.Quota.FlushInterval)*time.Second,
+		func(err error) {
+			logger.Error("failed to persist quota usage", zap.Error(err))
+		},
+	)
+}
+
+// sameQuotaStore 两个配置是否使用同一个配额存储（数据库路径、时区和写入间隔都相同）
+func sameQuotaStore(a, b config.QuotaStoreConfig) bool {
+	return a.Path == b.Path && a.Timezone == b.Timezone && a.FlushInterval == b.FlushInterval
+}
+
+// quotaKey 返回认证结果对应的配额 key（只有 Bearer Token / API Key 支持配额）
+func quotaKey(result *auth.AuthResult) string {
+	if result == nil || (result.Method != "bearer" && result.Method != "apikey") {
+		return ""
+	}
+	return quota.Key(result.Method, result.Name)
+}
+
+// setQuotaHeaders 写入 X-Quota-Remaining（所有周期中最小的剩余次数）
+// 以及按周期的 X-Quota-Remaining-Day / X-Quota-Remaining-Month
+func setQuotaHeaders(c *fiber.Ctx, r quota.Result) {
+	c.Set("X-Quota-Remaining", strconv.FormatInt(r.Remaining(), 10))
+	if r.RemainingDay >= 0 {
+		c.Set("X-Quota-Remaining-Day", strconv.FormatInt(r.RemainingDay, 10))
+	}
+	if r.RemainingMonth >= 0 {
+		c.Set("X-Quota-Remaining-Month", strconv.FormatInt(r.RemainingMonth, 10))
+	}
+}
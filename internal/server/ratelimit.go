@@ -0,0 +1,278 @@
+package server
+
+import (
+	"crypto/tls"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/auth"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/ratelimit"
+)
+
+// limiterFactory 按 rate_limit.backend 创建认证失败计数后端
+type limiterFactory struct {
+	redis      redis.UniversalClient // Redis 客户端（nil 表示内存后端）
+	cfg        config.RedisConfig
+	maxEntries int // 内存限流器（包括 Redis 不可用时的本地计数）的记录数上限
+	logger     *zap.Logger
+}
+
+// newRedisClient 按 rate_limit.redis 创建 Redis 客户端（后端不是 redis 时返回 nil）
+// 连接是惰性的：Redis 暂时不可用不影响启动，限流器会退回本地计数
+func newRedisClient(cfg config.RateLimitConfig) redis.UniversalClient {
+	if cfg.Backend != "redis" {
+		return nil
+	}
+	opts := &redis.Options{
+		Addr:         cfg.Redis.Addr,
+		Username:     cfg.Redis.Username,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		DialTimeout:  time.Second,
+		ReadTimeout:  time.Duration(cfg.Redis.TimeoutMs) * time.Millisecond,
+		WriteTimeout: time.Duration(cfg.Redis.TimeoutMs) * time.Millisecond,
+		MaxRetries:   -1, // 超时后直接退回本地计数，不重试
+	}
+	if cfg.Redis.TLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return redis.NewClient(opts)
+}
+
+// new 创建计数后端；scope 区分不同限流器在 Redis 中的 key（如 "global"、"policy:login"）
+func (f *limiterFactory) new(scope string, maxAttempts int, window, banDuration time.Duration) ratelimit.Backend {
+	if f.redis == nil {
+		return ratelimit.NewLimiterWithOptions(maxAttempts, window, banDuration, ratelimit.Options{MaxEntries: f.maxEntries})
+	}
+
+	logger := f.logger.With(zap.String("scope", scope))
+	return ratelimit.NewRedisLimiter(f.redis, maxAttempts, window, banDuration, ratelimit.RedisOptions{
+		Prefix:     f.cfg.KeyPrefix + scope + ":",
+		Timeout:    time.Duration(f.cfg.TimeoutMs) * time.Millisecond,
+		MaxEntries: f.maxEntries,
+		OnStatus: func(err error) {
+			if err != nil {
+				logger.Warn("rate limit redis unavailable - falling back to local limiting", zap.Error(err))
+			} else {
+				logger.Info("rate limit redis recovered")
+			}
+		},
+	})
+}
+
+// policyLimiter 路由策略的速率限制（与全局 [rate_limit] 的暴力破解封禁相互独立）
+type policyLimiter struct {
+	key        string                 // 吞吐量计数维度: ip / credential / user
+	failures   ratelimit.Backend      // 认证失败计数和封禁（nil 表示不限制）
+	throughput *ratelimit.TokenBucket // 吞吐量令牌桶（nil 表示不限制，始终在进程内计数）
+}
+
+// buildPolicyLimiters 为配置了 rate_limit 的策略创建限流器（按策略名称索引）
+func buildPolicyLimiters(cfg *config.Config, factory *limiterFactory) map[string]*policyLimiter {
+	limiters := make(map[string]*policyLimiter)
+	for i := range cfg.RoutePolicies {
+		p := &cfg.RoutePolicies[i]
+		if p.RateLimit == nil {
+			continue
+		}
+
+		l := &policyLimiter{key: p.RateLimit.Key}
+		if p.RateLimit.FailedAttempts > 0 {
+			l.failures = factory.new(
+				"policy:"+p.Name,
+				p.RateLimit.FailedAttempts,
+				time.Duration(p.RateLimit.FailedWindowSecs)*time.Second,
+				time.Duration(p.RateLimit.FailedBanSecs)*time.Second,
+			)
+		}
+		if p.RateLimit.Requests > 0 {
+			l.throughput = ratelimit.NewTokenBucketWithOptions(
+				p.RateLimit.Requests,
+				time.Duration(p.RateLimit.PeriodSecs)*time.Second,
+				p.RateLimit.Burst,
+				ratelimit.Options{MaxEntries: factory.maxEntries},
+			)
+		}
+		if l.failures != nil || l.throughput != nil {
+			limiters[p.Name] = l
+		}
+	}
+	return limiters
+}
+
+// newGlobalLimiter 按 [rate_limit] 创建全局速率限制器（未启用时返回 nil）
+func newGlobalLimiter(cfg config.RateLimitConfig, factory *limiterFactory) ratelimit.Backend {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Mode == "backoff" {
+		return ratelimit.NewBackoff(ratelimit.BackoffOptions{
+			Base:         time.Duration(cfg.Backoff.BaseMs) * time.Millisecond,
+			Factor:       cfg.Backoff.Factor,
+			Max:          time.Duration(cfg.Backoff.MaxSecs) * time.Second,
+			SuccessDecay: cfg.Backoff.SuccessDecay,
+			Forget:       time.Duration(cfg.WindowSecs) * time.Second,
+			MaxEntries:   cfg.MaxEntries,
+		})
+	}
+	return factory.new(
+		"global",
+		cfg.MaxAttempts,
+		time.Duration(cfg.WindowSecs)*time.Second,
+		time.Duration(cfg.BanSecs)*time.Second,
+	)
+}
+
+// inheritLimiter 将旧限流器的记录复制到新限流器（只有内存后端需要，Redis 中的记录本来就是共享的）
+// 切换 mode（ban / backoff）时两者的记录不兼容，不复制
+func inheritLimiter(l, old ratelimit.Backend) {
+	switch dst := l.(type) {
+	case *ratelimit.Limiter:
+		if src, ok := old.(*ratelimit.Limiter); ok {
+			dst.Inherit(src)
+		}
+	case *ratelimit.Backoff:
+		if src, ok := old.(*ratelimit.Backoff); ok {
+			dst.Inherit(src)
+		}
+	}
+}
+
+// inheritPolicyLimiters 将同名策略旧限流器中的记录复制到新限流器（配置重载时使用）
+func inheritPolicyLimiters(limiters, old map[string]*policyLimiter) {
+	for name, l := range limiters {
+		prev, exists := old[name]
+		if !exists {
+			continue
+		}
+		if l.failures != nil && prev.failures != nil {
+			inheritLimiter(l.failures, prev.failures)
+		}
+		if l.throughput != nil && prev.throughput != nil {
+			l.throughput.Inherit(prev.throughput)
+		}
+	}
+}
+
+// stopPolicyLimiters 停止所有策略限流器的后台清理任务
+func stopPolicyLimiters(limiters map[string]*policyLimiter) {
+	for _, l := range limiters {
+		if l.failures != nil {
+			l.failures.Stop()
+		}
+		if l.throughput != nil {
+			l.throughput.Stop()
+		}
+	}
+}
+
+// restoreRateLimitState 从 rate_limit.state_file 恢复全局和策略级封禁（Redis 后端的封禁保存在 Redis 中，不需要快照）
+func (s *Server) restoreRateLimitState(path string) {
+	snapshot, err := ratelimit.LoadSnapshot(path)
+	if err != nil {
+		s.Logger.Warn("failed to load rate limit state", zap.String("path", path), zap.Error(err))
+		return
+	}
+
+	restored := 0
+	if store, ok := s.RateLimiter.(ratelimit.BanStore); ok {
+		restored += store.RestoreBans(snapshot.Global)
+	}
+	for name, bans := range snapshot.Policies {
+		if l := s.policyLimiters[name]; l != nil {
+			if store, ok := l.failures.(ratelimit.BanStore); ok {
+				restored += store.RestoreBans(bans)
+			}
+		}
+	}
+	s.Logger.Info("rate limit state restored", zap.String("path", path), zap.Int("bans", restored))
+}
+
+// saveRateLimitState 将当前的全局和策略级封禁写入 rate_limit.state_file
+func (s *Server) saveRateLimitState(path string) {
+	snapshot := &ratelimit.Snapshot{SavedAt: time.Now().UTC()}
+	if store, ok := s.RateLimiter.(ratelimit.BanStore); ok {
+		snapshot.Global = store.Bans()
+	}
+	for name, l := range s.policyLimiters {
+		store, ok := l.failures.(ratelimit.BanStore)
+		if !ok {
+			continue
+		}
+		if bans := store.Bans(); len(bans) > 0 {
+			if snapshot.Policies == nil {
+				snapshot.Policies = make(map[string][]ratelimit.Ban)
+			}
+			snapshot.Policies[name] = bans
+		}
+	}
+
+	if err := snapshot.Save(path); err != nil {
+		s.Logger.Error("failed to save rate limit state", zap.String("path", path), zap.Error(err))
+		return
+	}
+	s.Logger.Info("rate limit state saved", zap.String("path", path), zap.Int("bans", countBans(snapshot)))
+}
+
+// countBans 返回快照中的封禁总数
+func countBans(snapshot *ratelimit.Snapshot) int {
+	n := len(snapshot.Global)
+	for _, bans := range snapshot.Policies {
+		n += len(bans)
+	}
+	return n
+}
+
+// throughputKey 返回吞吐量计数的 key
+// credential / user 维度在没有对应身份时（如匿名访问）退回按客户端 key（IPv6 按前缀聚合）计数
+func (l *policyLimiter) throughputKey(clientKey string, result *auth.AuthResult) string {
+	if result != nil && result.Method != "anonymous" {
+		switch l.key {
+		case "credential":
+			if result.Name != "" {
+				return "credential:" + result.Method + ":" + result.Name
+			}
+		case "user":
+			if result.User != "" {
+				return "user:" + result.User
+			}
+			if result.Name != "" {
+				return "user:" + result.Name
+			}
+		}
+	}
+	return "ip:" + clientKey
+}
+
+// setRateLimitHeaders 写入 IETF RateLimit header 草案定义的 headers
+// （RateLimit-Limit / RateLimit-Remaining / RateLimit-Reset / RateLimit-Policy）
+func setRateLimitHeaders(c *fiber.Ctx, q ratelimit.Quota) {
+	c.Set("RateLimit-Limit", strconv.Itoa(q.Limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(q.Remaining))
+	c.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(q.Reset), 10))
+
+	policy := strconv.Itoa(q.Requests) + ";w=" + strconv.FormatInt(ceilSeconds(q.Period), 10)
+	if q.Limit != q.Requests {
+		policy += ";burst=" + strconv.Itoa(q.Limit)
+	}
+	c.Set("RateLimit-Policy", policy)
+}
+
+// ceilSeconds 将时长向上取整为秒
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// retryAfterSeconds 返回 Retry-After 的秒数（至少 1 秒）
+func retryAfterSeconds(d time.Duration) int64 {
+	if s := ceilSeconds(d); s > 1 {
+		return s
+	}
+	return 1
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -9,70 +10,79 @@ import (
 
 	"github.com/nerdneilsfield/tiny-auth/internal/auth"
 	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	apperrors "github.com/nerdneilsfield/tiny-auth/internal/errors"
 )
 
 // SuccessResponse 返回认证成功响应
 func SuccessResponse(c *fiber.Ctx, cfg *config.Config, result *auth.AuthResult, policy *config.RoutePolicy) error {
-	setMethodHeader(c, cfg, result)
-	setUserHeader(c, cfg, result)
-	setRoleHeader(c, cfg, result)
-	setExtraHeaders(c, cfg)
-	setJWTMetadataHeaders(c, cfg, result)
-	setInjectedAuthorization(c, policy)
+	route := c.Get("X-Forwarded-Host") + c.Get("X-Forwarded-Uri")
+	for name, value := range successHeaders(cfg, result, policy, route) {
+		c.Set(name, value)
+	}
 
 	// 返回 200 OK
 	c.Status(fiber.StatusOK)
 	return c.SendString("ok")
 }
 
-func setMethodHeader(c *fiber.Ctx, cfg *config.Config, result *auth.AuthResult) {
+// successHeaders 计算认证成功时注入的 headers（按顺序写入，后写入的同名 header 覆盖先写入的）
+func successHeaders(cfg *config.Config, result *auth.AuthResult, policy *config.RoutePolicy, route string) map[string]string {
+	headers := make(map[string]string)
+	setMethodHeader(headers, cfg, result)
+	setUserHeader(headers, cfg, result)
+	setRoleHeader(headers, cfg, result)
+	setExtraHeaders(headers, cfg, route)
+	setJWTMetadataHeaders(headers, cfg, result)
+	setInjectedAuthorization(headers, policy)
+	return headers
+}
+
+func setMethodHeader(headers map[string]string, cfg *config.Config, result *auth.AuthResult) {
 	if cfg.Headers.MethodHeader == "" {
 		return
 	}
 
 	// 虽然 method 是系统生成的，但为了一致性也进行清理
-	c.Set(cfg.Headers.MethodHeader, sanitizeHeaderValue(result.Method))
+	headers[cfg.Headers.MethodHeader] = sanitizeHeaderValue(result.Method)
 }
 
-func setUserHeader(c *fiber.Ctx, cfg *config.Config, result *auth.AuthResult) {
+func setUserHeader(headers map[string]string, cfg *config.Config, result *auth.AuthResult) {
 	if cfg.Headers.UserHeader == "" {
 		return
 	}
 
 	if result.User != "" {
-		c.Set(cfg.Headers.UserHeader, sanitizeHeaderValue(result.User))
+		headers[cfg.Headers.UserHeader] = sanitizeHeaderValue(result.User)
 		return
 	}
 
 	if result.Name != "" {
 		// 如果没有用户名，使用配置名称
-		c.Set(cfg.Headers.UserHeader, sanitizeHeaderValue(result.Name))
+		headers[cfg.Headers.UserHeader] = sanitizeHeaderValue(result.Name)
 	}
 }
 
-func setRoleHeader(c *fiber.Ctx, cfg *config.Config, result *auth.AuthResult) {
+func setRoleHeader(headers map[string]string, cfg *config.Config, result *auth.AuthResult) {
 	if cfg.Headers.RoleHeader == "" || len(result.Roles) == 0 {
 		return
 	}
 
 	roles := strings.Join(result.Roles, ",")
-	c.Set(cfg.Headers.RoleHeader, sanitizeHeaderValue(roles))
+	headers[cfg.Headers.RoleHeader] = sanitizeHeaderValue(roles)
 }
 
-func setExtraHeaders(c *fiber.Ctx, cfg *config.Config) {
+func setExtraHeaders(headers map[string]string, cfg *config.Config, route string) {
 	for _, h := range cfg.Headers.ExtraHeaders {
 		switch h {
 		case "X-Auth-Timestamp":
-			c.Set(h, fmt.Sprintf("%d", time.Now().Unix()))
+			headers[h] = fmt.Sprintf("%d", time.Now().Unix())
 		case "X-Auth-Route":
-			host := c.Get("X-Forwarded-Host")
-			uri := c.Get("X-Forwarded-Uri")
-			c.Set(h, sanitizeHeaderValue(host+uri))
+			headers[h] = sanitizeHeaderValue(route)
 		}
 	}
 }
 
-func setJWTMetadataHeaders(c *fiber.Ctx, cfg *config.Config, result *auth.AuthResult) {
+func setJWTMetadataHeaders(headers map[string]string, cfg *config.Config, result *auth.AuthResult) {
 	if !cfg.Headers.IncludeJWTMetadata || result.Metadata == nil {
 		return
 	}
@@ -80,46 +90,73 @@ func setJWTMetadataHeaders(c *fiber.Ctx, cfg *config.Config, result *auth.AuthRe
 	for k, v := range result.Metadata {
 		// 首字母大写
 		headerName := "X-Auth-" + strings.ToUpper(k[:1]) + k[1:]
-		c.Set(headerName, sanitizeHeaderValue(v))
+		headers[headerName] = sanitizeHeaderValue(v)
 	}
 }
 
-func setInjectedAuthorization(c *fiber.Ctx, policy *config.RoutePolicy) {
+func setInjectedAuthorization(headers map[string]string, policy *config.RoutePolicy) {
 	if policy == nil || policy.InjectAuthorization == "" {
 		return
 	}
 
 	// 清理并限制长度，防止超长 header 导致 HTTP 431
-	sanitized := sanitizeHeaderValue(policy.InjectAuthorization)
-	c.Set("Authorization", sanitized)
+	headers["Authorization"] = sanitizeHeaderValue(policy.InjectAuthorization)
 }
 
-// UnauthorizedResponse 返回认证失败响应
-func UnauthorizedResponse(c *fiber.Ctx, cfg *config.Config, message string) error {
-	// 设置 WWW-Authenticate headers
-	authenticateMethods := []string{}
+// UnauthorizedResponse 返回认证失败响应，challenges 写入 WWW-Authenticate
+// 策略配置了 unauthenticated_redirect 时，浏览器请求被重定向到登录地址（rd 为原始请求地址）
+func UnauthorizedResponse(c *fiber.Ctx, cfg *config.Config, policy *config.RoutePolicy, challenges []string, message, returnURL string) error {
+	// 设置缓存控制
+	c.Set("Cache-Control", "no-store")
 
-	if len(cfg.BasicAuths) > 0 {
-		authenticateMethods = append(authenticateMethods, `Basic realm="api"`)
+	if policy != nil && policy.UnauthenticatedRedirect != "" && negotiateFormat(c) == formatHTML {
+		return c.Redirect(redirectLocation(policy.UnauthenticatedRedirect, returnURL), fiber.StatusFound)
 	}
 
-	if len(cfg.BearerTokens) > 0 || cfg.JWT.Secret != "" {
-		authenticateMethods = append(authenticateMethods, `Bearer realm="api"`)
+	setChallenges(c, challenges)
+	return sendErrorPage(c, cfg, policy, newErrorPage(c, fiber.StatusUnauthorized, message))
+}
+
+// ForbiddenResponse 返回授权失败响应（凭证有效但无权访问，或被 deny 规则拒绝）
+// 只有 Bearer 凭证 insufficient_scope 时才有 challenges，客户端不应重新提示输入凭证。响应内容由策略的 deny_response 决定：
+// detailed 返回错误代码和详情，generic 隐藏原因，not_found 返回与不存在的路由相同的 404
+func ForbiddenResponse(c *fiber.Ctx, cfg *config.Config, policy *config.RoutePolicy, challenges []string, status int, appErr *apperrors.AppError) error {
+	c.Set("Cache-Control", "no-store")
+	setChallenges(c, challenges)
+
+	mode := "detailed"
+	if policy != nil && policy.DenyResponse != "" {
+		mode = policy.DenyResponse
 	}
 
-	// 添加所有 WWW-Authenticate headers
-	for _, method := range authenticateMethods {
-		c.Append("WWW-Authenticate", method)
+	switch mode {
+	case "not_found":
+		return sendErrorPage(c, cfg, policy, newErrorPage(c, status, "Not Found"))
+	case "generic":
+		return sendErrorPage(c, cfg, policy, newErrorPage(c, status, "Access denied"))
 	}
 
-	// 设置缓存控制
+	page := newErrorPage(c, status, appErr.Message)
+	page.Code = string(appErr.Code)
+	page.Details = appErr.Details
+	return sendErrorPage(c, cfg, policy, page)
+}
+
+// setChallenges 写入 WWW-Authenticate headers（每个 challenge 一个 header）
+func setChallenges(c *fiber.Ctx, challenges []string) {
+	for _, challenge := range challenges {
+		c.Append("WWW-Authenticate", challenge)
+	}
+}
+
+// TooManyRequestsResponse 返回速率限制响应
+func TooManyRequestsResponse(c *fiber.Ctx, cfg *config.Config, policy *config.RoutePolicy, message string, retryAfterSeconds int64) error {
+	c.Set("Retry-After", strconv.FormatInt(retryAfterSeconds, 10))
 	c.Set("Cache-Control", "no-store")
 
-	// 返回 JSON 错误响应
-	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-		"error":     message,
-		"timestamp": time.Now().Unix(),
-	})
+	page := newErrorPage(c, fiber.StatusTooManyRequests, message)
+	page.RetryAfter = retryAfterSeconds
+	return sendErrorPage(c, cfg, policy, page)
 }
 
 // sanitizeHeaderValue 清理 header 值，防止 header 注入攻击
@@ -197,7 +197,7 @@ func TestUnauthorizedResponse(t *testing.T) {
 	}
 
 	app.Get("/test", func(c *fiber.Ctx) error {
-		return UnauthorizedResponse(c, cfg, "Invalid credentials")
+		return UnauthorizedResponse(c, cfg, nil, authChallenges(cfg, nil, ""), "Invalid credentials", "")
 	})
 
 	req := httptest.NewRequest("GET", "/test", http.NoBody)
@@ -1,19 +1,28 @@
 package server
 
 import (
+	"context"
 	"net"
+	"net/http"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
 	"github.com/nerdneilsfield/tiny-auth/internal/audit"
 	"github.com/nerdneilsfield/tiny-auth/internal/auth"
 	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/geoip"
+	"github.com/nerdneilsfield/tiny-auth/internal/ipfilter"
+	"github.com/nerdneilsfield/tiny-auth/internal/metrics"
+	"github.com/nerdneilsfield/tiny-auth/internal/quota"
 	"github.com/nerdneilsfield/tiny-auth/internal/ratelimit"
+	"github.com/nerdneilsfield/tiny-auth/internal/tracing"
 )
 
 // Server 封装 Fiber 应用和配置
@@ -23,9 +32,24 @@ type Server struct {
 	Store        *auth.AuthStore
 	Logger       *zap.Logger
 	Audit        *audit.Logger
-	RateLimiter  *ratelimit.Limiter // 速率限制器
-	trustedCIDRs []*net.IPNet       // 可信代理 CIDR 列表（解析后）
-	mu           sync.RWMutex       // 用于配置热重载时的并发控制
+	RateLimiter  ratelimit.Backend // 速率限制器
+	trustedCIDRs []*net.IPNet      // 可信代理 CIDR 列表（解析后）
+	pipeline     *Pipeline         // 认证决策流水线（策略索引、Webhook 客户端，随配置一起替换）
+	now          func() time.Time  // 时钟（用于时间窗口和条件表达式，测试中可替换）
+	mu           sync.RWMutex      // 用于配置热重载时的并发控制
+
+	policyLimiters map[string]*policyLimiter // 策略级速率限制（按策略名称索引，随配置一起替换）
+	quotas         *quota.Store              // 凭证用量配额计数（nil 表示没有配置配额）
+	quotaLimits    map[string]quota.Limits   // 凭证配额限制（按 quota.Key 索引）
+	redis          redis.UniversalClient     // 速率限制 Redis 后端客户端（nil 表示内存后端）
+	ipFilter       *ipfilter.Filter          // 全局 IP 允许 / 拒绝列表（nil 表示未配置）
+	geoDB          *geoip.DB                 // GeoIP 数据库（nil 表示未配置）
+	metrics        *metrics.Metrics          // Prometheus 指标（nil 表示未启用，修改后需要重启）
+	metricsServer  *http.Server              // 单独的指标监听器（nil 表示在主端口上提供或未启用）
+	tracer         *tracing.Provider         // OpenTelemetry 追踪导出器（nil 表示未启用，修改后需要重启）
+
+	inflight *sync.WaitGroup // 使用当前配额存储 / 审计日志的请求（资源替换时一起替换，旧资源在计数归零后关闭）
+	retiring sync.WaitGroup  // 等待关闭旧资源的后台任务
 }
 
 // NewServer 创建新的 HTTP 服务器
@@ -44,13 +68,16 @@ func NewServer(cfg *config.Config, store *auth.AuthStore, logger *zap.Logger) (*
 	}
 
 	// 初始化速率限制器
-	var rateLimiter *ratelimit.Limiter
-	if cfg.RateLimit.Enabled {
-		rateLimiter = ratelimit.NewLimiter(
-			cfg.RateLimit.MaxAttempts,
-			time.Duration(cfg.RateLimit.WindowSecs)*time.Second,
-			time.Duration(cfg.RateLimit.BanSecs)*time.Second,
+	redisClient := newRedisClient(cfg.RateLimit)
+	factory := &limiterFactory{redis: redisClient, cfg: cfg.RateLimit.Redis, maxEntries: cfg.RateLimit.MaxEntries, logger: logger}
+	if redisClient != nil {
+		logger.Info("rate limit backend: redis",
+			zap.String("addr", cfg.RateLimit.Redis.Addr),
+			zap.String("key_prefix", cfg.RateLimit.Redis.KeyPrefix),
 		)
+	}
+	rateLimiter := newGlobalLimiter(cfg.RateLimit, factory)
+	if rateLimiter != nil {
 		logger.Info("rate limiting enabled",
 			zap.Int("max_attempts", cfg.RateLimit.MaxAttempts),
 			zap.Int("window_secs", cfg.RateLimit.WindowSecs),
@@ -60,10 +87,38 @@ func NewServer(cfg *config.Config, store *auth.AuthStore, logger *zap.Logger) (*
 		logger.Info("rate limiting disabled")
 	}
 
-	auditLogger, err := audit.NewLogger(cfg.Audit)
+	ipFilter, err := NewIPFilter(cfg.IPFilter, logger)
+	if err != nil {
+		return nil, err
+	}
+	if ipFilter != nil {
+		allow, deny := ipFilter.Len()
+		logger.Info("ip filter enabled", zap.Int("allow_entries", allow), zap.Int("deny_entries", deny))
+	}
+
+	geoDB, err := openGeoIP(cfg.GeoIP, logger)
+	if err != nil {
+		stopIPFilter(ipFilter)
+		return nil, err
+	}
+
+	auditLogger, err := newAuditLogger(cfg.Audit, logger)
+	if err != nil {
+		stopIPFilter(ipFilter)
+		closeGeoIP(geoDB)
+		return nil, err
+	}
+
+	quotas, err := openQuotaStore(cfg, logger)
 	if err != nil {
+		stopIPFilter(ipFilter)
+		closeGeoIP(geoDB)
+		_ = auditLogger.Close()
 		return nil, err
 	}
+	if quotas != nil {
+		logger.Info("usage quotas enabled", zap.String("path", cfg.Quota.Path))
+	}
 
 	srv := &Server{
 		Config:       cfg,
@@ -72,6 +127,22 @@ func NewServer(cfg *config.Config, store *auth.AuthStore, logger *zap.Logger) (*
 		Audit:        auditLogger,
 		RateLimiter:  rateLimiter,
 		trustedCIDRs: trustedCIDRs,
+		pipeline:     NewPipeline(cfg, store, logger),
+		now:          time.Now,
+
+		policyLimiters: buildPolicyLimiters(cfg, factory),
+		quotas:         quotas,
+		quotaLimits:    quota.LimitsFromConfig(cfg),
+		redis:          redisClient,
+		ipFilter:       ipFilter,
+		geoDB:          geoDB,
+		tracer:         newTracer(cfg.Tracing, logger),
+		inflight:       &sync.WaitGroup{},
+	}
+
+	// 恢复上次关闭时保存的封禁
+	if cfg.RateLimit.StateFile != "" {
+		srv.restoreRateLimitState(cfg.RateLimit.StateFile)
 	}
 
 	// 创建 Fiber 应用
@@ -92,6 +163,9 @@ func NewServer(cfg *config.Config, store *auth.AuthStore, logger *zap.Logger) (*
 		EnableStackTrace: true,
 	}))
 
+	// Prometheus 指标（可选）
+	srv.setupMetrics(app)
+
 	// 注册路由
 	app.All(cfg.Server.AuthPath, func(c *fiber.Ctx) error {
 		return srv.HandleAuth(c)
@@ -101,6 +175,9 @@ func NewServer(cfg *config.Config, store *auth.AuthStore, logger *zap.Logger) (*
 		return srv.HandleHealth(c)
 	})
 
+	// 管理 API（admin.enabled 为 false 时返回 404）
+	srv.registerAdminRoutes(app)
+
 	// 调试端点（可选）
 	if cfg.Server.EnableDebug {
 		app.Get("/debug/config", func(c *fiber.Ctx) error {
@@ -127,16 +204,49 @@ func (s *Server) Start() error {
 		zap.Int("route_policies", len(s.Config.RoutePolicies)),
 	)
 
+	s.startMetricsServer()
 	return s.App.Listen(":" + port)
 }
 
 // Shutdown 优雅关闭服务器
+// 先等待处理中的请求完成，再保存限流状态、写入配额计数并关闭审计日志，关闭期间完成的请求不会丢失
 func (s *Server) Shutdown() error {
 	s.Logger.Info("shutting down server")
+	err := s.App.Shutdown()
+
+	if s.metricsServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = s.metricsServer.Shutdown(ctx)
+		cancel()
+	}
+	// 等待重载替换下的旧资源关闭
+	s.retiring.Wait()
+
+	s.mu.Lock()
+	// 保存封禁状态，下次启动时恢复
+	if path := s.Config.RateLimit.StateFile; path != "" {
+		s.saveRateLimitState(path)
+	}
+	if s.quotas != nil {
+		if err := s.quotas.Close(); err != nil {
+			s.Logger.Error("failed to persist quota usage", zap.Error(err))
+		}
+		s.quotas = nil
+	}
+	if s.redis != nil {
+		_ = s.redis.Close()
+		s.redis = nil
+	}
+	stopIPFilter(s.ipFilter)
+	closeGeoIP(s.geoDB)
 	if s.Audit != nil {
 		_ = s.Audit.Close()
 	}
-	return s.App.Shutdown()
+	s.mu.Unlock()
+
+	// 最后导出 span，包括关闭期间完成的请求
+	shutdownTracer(s.tracer, s.Logger)
+	return err
 }
 
 // Reload 重新加载配置（用于热重载）
@@ -144,33 +254,106 @@ func (s *Server) Reload(cfg *config.Config, store *auth.AuthStore) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	oldCfg := s.Config
+
 	// 重新解析可信代理
 	s.trustedCIDRs = parseTrustedProxies(cfg.Server.TrustedProxies)
 
 	s.Config = cfg
 	s.Store = store
+	s.pipeline = NewPipeline(cfg, store, s.Logger)
+
+	if cfg.Metrics != oldCfg.Metrics {
+		s.Logger.Warn("metrics configuration changed - restart required to apply")
+	}
+	if !reflect.DeepEqual(cfg.Tracing, oldCfg.Tracing) {
+		s.Logger.Warn("tracing configuration changed - restart required to apply")
+	}
+
+	// 速率限制：新的限流器继承旧限流器的记录（按新的阈值），重载不会解除封禁
+	// Redis 连接配置不变时复用客户端（记录本来就保存在 Redis 中）
+	oldRedis := s.redis
+	redisClient := oldRedis
+	if oldRedis == nil || cfg.RateLimit.Backend != "redis" || cfg.RateLimit.Redis != oldCfg.RateLimit.Redis {
+		redisClient = newRedisClient(cfg.RateLimit)
+	}
+	factory := &limiterFactory{redis: redisClient, cfg: cfg.RateLimit.Redis, maxEntries: cfg.RateLimit.MaxEntries, logger: s.Logger}
+
+	rateLimiter := newGlobalLimiter(cfg.RateLimit, factory)
 	if s.RateLimiter != nil {
+		if rateLimiter != nil {
+			inheritLimiter(rateLimiter, s.RateLimiter)
+		}
 		s.RateLimiter.Stop()
 	}
-	if cfg.RateLimit.Enabled {
-		s.RateLimiter = ratelimit.NewLimiter(
-			cfg.RateLimit.MaxAttempts,
-			time.Duration(cfg.RateLimit.WindowSecs)*time.Second,
-			time.Duration(cfg.RateLimit.BanSecs)*time.Second,
-		)
+	s.RateLimiter = rateLimiter
+
+	policyLimiters := buildPolicyLimiters(cfg, factory)
+	inheritPolicyLimiters(policyLimiters, s.policyLimiters)
+	stopPolicyLimiters(s.policyLimiters)
+	s.policyLimiters = policyLimiters
+
+	s.redis = redisClient
+	if oldRedis != nil && oldRedis != redisClient {
+		_ = oldRedis.Close()
+	}
+
+	// IP 过滤：重新加载静态列表和所有文件
+	ipFilter, err := NewIPFilter(cfg.IPFilter, s.Logger)
+	if err != nil {
+		s.Logger.Error("failed to initialize ip filter, keeping previous filter", zap.Error(err))
 	} else {
-		s.RateLimiter = nil
+		stopIPFilter(s.ipFilter)
+		s.ipFilter = ipFilter
 	}
 
-	newAudit, err := audit.NewLogger(cfg.Audit)
+	// GeoIP：重新加载数据库文件（失败时继续使用原来的数据库）
+	geoDB, err := openGeoIP(cfg.GeoIP, s.Logger)
+	if err != nil {
+		s.Logger.Error("failed to open geoip database, keeping previous database", zap.Error(err))
+	} else {
+		closeGeoIP(s.geoDB)
+		s.geoDB = geoDB
+	}
+
+	// 配额：数据库文件和写入设置不变时保留原来的存储，只替换限制。
+	// 否则切换到新存储，旧存储在使用它的请求结束后关闭并写入剩余增量，新存储随后重新合并即包含这些计数
+	var retired []func()
+	if s.quotas == nil || !cfg.HasQuotas() || !sameQuotaStore(cfg.Quota, oldCfg.Quota) {
+		newQuotas, err := openQuotaStore(cfg, s.Logger)
+		if err != nil {
+			s.Logger.Error("failed to open quota store, keeping previous store", zap.Error(err))
+		} else {
+			if oldQuotas := s.quotas; oldQuotas != nil {
+				retired = append(retired, func() {
+					if err := oldQuotas.Close(); err != nil {
+						s.Logger.Error("failed to persist quota usage", zap.Error(err))
+					}
+					if newQuotas != nil {
+						if err := newQuotas.Flush(); err != nil {
+							s.Logger.Error("failed to load quota usage", zap.Error(err))
+						}
+					}
+				})
+			}
+			s.quotas = newQuotas
+		}
+	}
+	s.quotaLimits = quota.LimitsFromConfig(cfg)
+
+	// 审计日志：处理中的请求继续写入旧记录器，旧记录器在这些请求结束后关闭
+	newAudit, err := newAuditLogger(cfg.Audit, s.Logger)
 	if err != nil {
 		s.Logger.Error("failed to initialize audit logger", zap.Error(err))
 	} else {
-		if s.Audit != nil {
-			_ = s.Audit.Close()
+		if oldAudit := s.Audit; oldAudit != nil {
+			retired = append(retired, func() { _ = oldAudit.Close() })
 		}
 		s.Audit = newAudit
 	}
+	if len(retired) > 0 {
+		s.retire(retired...)
+	}
 
 	s.Logger.Info("configuration reloaded",
 		zap.Int("basic_auth_users", len(cfg.BasicAuths)),
@@ -182,6 +365,28 @@ func (s *Server) Reload(cfg *config.Config, store *auth.AuthStore) {
 	)
 }
 
+// retire 在使用旧资源的请求全部结束后依次执行 closers（后台执行，不阻塞重载），调用方持有写锁
+// 之后开始的请求计入新的 in-flight 计数
+func (s *Server) retire(closers ...func()) {
+	old := s.inflight
+	s.inflight = &sync.WaitGroup{}
+	s.retiring.Add(1)
+	go func() {
+		defer s.retiring.Done()
+		old.Wait()
+		for _, closeFn := range closers {
+			closeFn()
+		}
+	}()
+}
+
+// stopIPFilter 停止 IP 过滤器的文件检查（nil 时忽略）
+func stopIPFilter(f *ipfilter.Filter) {
+	if f != nil {
+		f.Stop()
+	}
+}
+
 // GetConfig 获取当前配置（线程安全）
 func (s *Server) GetConfig() *config.Config {
 	s.mu.RLock()
@@ -1,10 +1,20 @@
 package server
 
 import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
 
 	"github.com/nerdneilsfield/tiny-auth/internal/auth"
 	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/quota"
 )
 
 func TestServerReload_RateLimiter(t *testing.T) {
@@ -57,3 +67,335 @@ func TestServerReload_RateLimiter(t *testing.T) {
 		t.Fatal("expected rate limiter to be nil after disabling")
 	}
 }
+
+// TestServerReload_KeepsBans 测试热重载和重启后封禁保留
+func TestServerReload_KeepsBans(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "bans.json")
+	newCfg := func(maxAttempts int) *config.Config {
+		return &config.Config{
+			Server: config.ServerConfig{Port: "3000", AuthPath: "/auth", HealthPath: "/health"},
+			RateLimit: config.RateLimitConfig{
+				Enabled:     true,
+				MaxAttempts: maxAttempts,
+				WindowSecs:  60,
+				BanSecs:     300,
+				StateFile:   statePath,
+			},
+			RoutePolicies: []config.RoutePolicy{{
+				Name:       "login",
+				PathPrefix: "/login",
+				RateLimit:  &config.PolicyRateLimit{FailedAttempts: 1, FailedWindowSecs: 60, FailedBanSecs: 300},
+			}},
+		}
+	}
+
+	cfg := newCfg(1)
+	srv := createTestServer(t, cfg)
+	srv.RateLimiter.Allow("10.0.0.1")
+	if allowed, _ := srv.RateLimiter.Allow("10.0.0.1"); allowed {
+		t.Fatal("expected 10.0.0.1 to be banned")
+	}
+	srv.policyLimiters["login"].failures.RecordFailure("10.0.0.2")
+
+	// 修改阈值后重载：封禁保留
+	cfg = newCfg(3)
+	srv.Reload(cfg, auth.BuildStore(cfg))
+	if banned, _ := srv.RateLimiter.Banned("10.0.0.1"); !banned {
+		t.Error("expected global ban to survive reload")
+	}
+	if banned, _ := srv.policyLimiters["login"].failures.Banned("10.0.0.2"); !banned {
+		t.Error("expected policy ban to survive reload")
+	}
+
+	// 关闭时写入快照，新进程启动时恢复
+	if err := srv.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	restarted := createTestServer(t, cfg)
+	if banned, _ := restarted.RateLimiter.Banned("10.0.0.1"); !banned {
+		t.Error("expected global ban to be restored on startup")
+	}
+	if banned, _ := restarted.policyLimiters["login"].failures.Banned("10.0.0.2"); !banned {
+		t.Error("expected policy ban to be restored on startup")
+	}
+}
+
+// TestServer_RedisRateLimit 测试多个副本通过 Redis 共享封禁
+func TestServer_RedisRateLimit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := &config.Config{
+		Server:     config.ServerConfig{Port: "3000", AuthPath: "/auth", HealthPath: "/health", ReadTimeout: 5, WriteTimeout: 5},
+		BasicAuths: []config.BasicAuthConfig{{Name: "u", User: "u", Pass: "p"}},
+		RateLimit: config.RateLimitConfig{
+			Enabled:     true,
+			MaxAttempts: 2,
+			WindowSecs:  60,
+			BanSecs:     300,
+			Backend:     "redis",
+			Redis:       config.RedisConfig{Addr: mr.Addr(), KeyPrefix: "tiny-auth:", TimeoutMs: 100},
+		},
+	}
+
+	replicas := []*Server{createTestServer(t, cfg), createTestServer(t, cfg)}
+	defer func() {
+		for _, srv := range replicas {
+			_ = srv.Shutdown()
+		}
+	}()
+
+	send := func(srv *Server) int {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/auth", http.NoBody)
+		req.Header.Set("Authorization", "Basic dTp3cm9uZw==")
+		resp, err := srv.App.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	// 每个副本各失败一次，共计达到上限
+	if status := send(replicas[0]); status != 401 {
+		t.Fatalf("expected 401, got %d", status)
+	}
+	if status := send(replicas[1]); status != 401 {
+		t.Fatalf("expected 401, got %d", status)
+	}
+	for i, srv := range replicas {
+		if status := send(srv); status != 429 {
+			t.Errorf("replica %d: expected shared ban (429), got %d", i, status)
+		}
+	}
+	if !mr.Exists("tiny-auth:global:ban:0.0.0.0") {
+		t.Errorf("expected ban key in redis, got keys %v", mr.Keys())
+	}
+}
+
+// TestServerShutdown_DrainsBeforeFlushing 测试关闭时先等待处理中的请求完成，再写入配额计数和关闭审计日志
+func TestServerShutdown_DrainsBeforeFlushing(t *testing.T) {
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "audit.log")
+	quotaPath := filepath.Join(dir, "quota.db")
+
+	// 慢速 Webhook：请求到达后通知测试开始关闭
+	arrived := make(chan struct{})
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"allow": true}`))
+	}))
+	defer webhook.Close()
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Port:         "3000",
+			AuthPath:     "/auth",
+			ReadTimeout:  30,
+			WriteTimeout: 30,
+		},
+		Audit: config.AuditConfig{Enabled: true, Output: auditPath},
+		Quota: config.QuotaStoreConfig{Path: quotaPath, FlushInterval: 3600},
+		APIKeys: []config.APIKeyConfig{
+			{Name: "partner", Key: "partner-key-1234567890", Quota: &config.QuotaConfig{PerDay: 10}},
+		},
+		RoutePolicies: []config.RoutePolicy{
+			{
+				Name:         "hooked",
+				PathPrefix:   "/",
+				AuthzWebhook: &config.AuthzWebhookConfig{URL: webhook.URL, TimeoutMs: 5000, FailureMode: "closed"},
+			},
+		},
+	}
+	srv := createTestServer(t, cfg)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.App.Listener(ln) }()
+
+	status := make(chan int, 1)
+	go func() {
+		req, _ := http.NewRequest("GET", "http://"+ln.Addr().String()+"/auth", http.NoBody)
+		req.Header.Set("X-Api-Key", "partner-key-1234567890")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			status <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	<-arrived
+	if err := srv.Shutdown(); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if code := <-status; code != http.StatusOK {
+		t.Fatalf("in-flight request should complete during shutdown, got %d", code)
+	}
+
+	usage, err := quota.List(quotaPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 1 || usage[0].DayCount != 1 {
+		t.Errorf("expected the drained request to be persisted, got %+v", usage)
+	}
+	logData, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(logData), `"result":"success"`) {
+		t.Errorf("expected audit event for the drained request, got %q", logData)
+	}
+}
+
+// TestServerReload_QuotaStore 测试重载时配额存储不变则保留，改变时等待处理中的请求完成后再关闭旧存储
+func TestServerReload_QuotaStore(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.db")
+	newPath := filepath.Join(dir, "new.db")
+
+	// 慢速 Webhook：请求到达后通知测试开始重载
+	arrived := make(chan struct{}, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"allow": true}`))
+	}))
+	defer webhook.Close()
+
+	newConfig := func(path string, perDay int64) *config.Config {
+		return &config.Config{
+			Server: config.ServerConfig{Port: "3000", AuthPath: "/auth"},
+			Quota:  config.QuotaStoreConfig{Path: path, FlushInterval: 3600},
+			APIKeys: []config.APIKeyConfig{
+				{Name: "partner", Key: "partner-key-1234567890", Quota: &config.QuotaConfig{PerDay: perDay}},
+			},
+			RoutePolicies: []config.RoutePolicy{
+				{
+					Name:         "hooked",
+					PathPrefix:   "/",
+					AuthzWebhook: &config.AuthzWebhookConfig{URL: webhook.URL, TimeoutMs: 5000, FailureMode: "closed"},
+				},
+			},
+		}
+	}
+	cfg := newConfig(oldPath, 10)
+	srv := createTestServer(t, cfg)
+
+	send := func() int {
+		req := httptest.NewRequest("GET", "/auth", http.NoBody)
+		req.Header.Set("X-Api-Key", "partner-key-1234567890")
+		resp, err := srv.App.Test(req, -1)
+		if err != nil {
+			t.Errorf("Failed to test request: %v", err)
+			return 0
+		}
+		return resp.StatusCode
+	}
+
+	// 数据库文件不变：保留存储和计数，只替换限制
+	store := srv.quotas
+	if status := send(); status != 200 {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	<-arrived
+	srv.Reload(newConfig(oldPath, 1), auth.BuildStore(cfg))
+	if srv.quotas != store {
+		t.Fatal("expected the quota store to be kept when its settings are unchanged")
+	}
+	if status := send(); status != 429 {
+		t.Errorf("expected the new limit to apply to the existing count, got %d", status)
+	}
+	<-arrived
+	srv.Reload(newConfig(oldPath, 10), auth.BuildStore(cfg))
+
+	// 数据库文件改变：处理中的请求仍然计入旧存储，旧存储在请求结束后写入
+	status := make(chan int, 1)
+	go func() { status <- send() }()
+	<-arrived
+	srv.Reload(newConfig(newPath, 10), auth.BuildStore(cfg))
+	if code := <-status; code != 200 {
+		t.Fatalf("in-flight request should complete during reload, got %d", code)
+	}
+	if err := srv.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := quota.List(oldPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 1 || usage[0].DayCount != 2 {
+		t.Errorf("expected the in-flight request to be persisted to the old store, got %+v", usage)
+	}
+}
+
+// TestServerReload_AuditLogger 测试重载替换审计日志时，处理中的请求写入旧文件后才关闭旧记录器
+func TestServerReload_AuditLogger(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.log")
+	newPath := filepath.Join(dir, "new.log")
+
+	// 慢速 Webhook：请求到达后通知测试开始重载
+	arrived := make(chan struct{})
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"allow": true}`))
+	}))
+	defer webhook.Close()
+
+	newConfig := func(path string) *config.Config {
+		return &config.Config{
+			Server: config.ServerConfig{Port: "3000", AuthPath: "/auth"},
+			Audit:  config.AuditConfig{Enabled: true, Output: path},
+			APIKeys: []config.APIKeyConfig{
+				{Name: "partner", Key: "partner-key-1234567890"},
+			},
+			RoutePolicies: []config.RoutePolicy{
+				{
+					Name:         "hooked",
+					PathPrefix:   "/",
+					AuthzWebhook: &config.AuthzWebhookConfig{URL: webhook.URL, TimeoutMs: 5000, FailureMode: "closed"},
+				},
+			},
+		}
+	}
+	cfg := newConfig(oldPath)
+	srv := createTestServer(t, cfg)
+
+	status := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest("GET", "/auth", http.NoBody)
+		req.Header.Set("X-Api-Key", "partner-key-1234567890")
+		resp, err := srv.App.Test(req, -1)
+		if err != nil {
+			status <- 0
+			return
+		}
+		status <- resp.StatusCode
+	}()
+
+	<-arrived
+	srv.Reload(newConfig(newPath), auth.BuildStore(cfg))
+	if code := <-status; code != 200 {
+		t.Fatalf("in-flight request should complete during reload, got %d", code)
+	}
+	if err := srv.ReopenAudit(); err != nil {
+		t.Errorf("reopen after reload failed: %v", err)
+	}
+	if err := srv.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	logData, err := os.ReadFile(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(logData), `"result":"success"`) {
+		t.Errorf("expected the in-flight request to be audited to the old file, got %q", logData)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/audit"
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/tracing"
+)
+
+// newTracer 按 [tracing] 创建追踪导出器（未启用时返回 nil）
+// 追踪只用于观测，创建失败时记录错误并继续运行（不启用追踪）
+func newTracer(cfg config.TracingConfig, logger *zap.Logger) *tracing.Provider {
+	if !cfg.Enabled {
+		return nil
+	}
+	tracer, err := tracing.NewProvider(tracing.Options{
+		Endpoint:    cfg.Endpoint,
+		Headers:     cfg.Headers,
+		ServiceName: cfg.ServiceName,
+		SampleRatio: cfg.SampleRatio,
+		Timeout:     time.Duration(cfg.TimeoutMs) * time.Millisecond,
+	})
+	if err != nil {
+		logger.Error("failed to initialize tracing, continuing without it", zap.Error(err))
+		return nil
+	}
+	logger.Info("tracing enabled",
+		zap.String("endpoint", cfg.Endpoint),
+		zap.Float64("sample_ratio", cfg.SampleRatio),
+	)
+	return tracer
+}
+
+// shutdownTracer 导出剩余的 span 并停止导出器
+func shutdownTracer(tracer *tracing.Provider, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		logger.Warn("failed to flush traces", zap.Error(err))
+	}
+}
+
+// startAuthSpan 为 forward-auth 请求创建 server span，并把它放入请求的 UserContext
+// 只有来自可信代理的请求才延续其 traceparent
+func (s *Server) startAuthSpan(c *fiber.Ctx, trustedProxy bool) trace.Span {
+	var carrier headerCarrier
+	if trustedProxy {
+		carrier.c = c
+	}
+	ctx, span := s.tracer.StartRequest(c.UserContext(), "forward_auth", carrier)
+	c.SetUserContext(ctx)
+	return span
+}
+
+// annotateAuthSpan 把审计事件中的结果写入请求的 span（不包含用户名和客户端 IP）
+func annotateAuthSpan(c *fiber.Ctx, event *audit.Event) {
+	span := trace.SpanFromContext(c.UserContext())
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("tinyauth.result", event.Result),
+		attribute.String("tinyauth.reason", event.Reason),
+		attribute.String("tinyauth.policy", event.Policy),
+		attribute.String("tinyauth.auth_method", event.AuthMethod),
+		attribute.String("tinyauth.forwarded_host", event.Host),
+		attribute.String("tinyauth.forwarded_method", event.Method),
+		attribute.Int("http.response.status_code", event.Status),
+	)
+}
+
+// headerCarrier 从 Fiber 请求读取 trace context headers（c 为 nil 时不读取）
+// 读取的值会被复制：Fiber 复用请求缓冲区，而 tracestate 在请求结束后仍由导出器持有
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+// Get 实现 propagation.TextMapCarrier
+func (h headerCarrier) Get(key string) string {
+	if h.c == nil {
+		return ""
+	}
+	return strings.Clone(h.c.Get(key))
+}
+
+// Set 实现 propagation.TextMapCarrier（入口请求只读取，不写入）
+func (h headerCarrier) Set(string, string) {}
+
+// Keys 实现 propagation.TextMapCarrier
+func (h headerCarrier) Keys() []string {
+	if h.c == nil {
+		return nil
+	}
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
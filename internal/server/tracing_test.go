@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/config"
+	"github.com/nerdneilsfield/tiny-auth/internal/tracing/tracingtest"
+)
+
+// TestHandleAuth_Tracing 测试 forward-auth 流水线的 span 和向 Webhook 传递 trace context
+func TestHandleAuth_Tracing(t *testing.T) {
+	collector := tracingtest.NewCollector()
+	defer collector.Close()
+
+	var (
+		mu            sync.Mutex
+		webhookParent string
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		webhookParent = r.Header.Get("traceparent")
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"allow": true}`))
+	}))
+	defer webhook.Close()
+
+	newConfig := func(trustedProxies []string) *config.Config {
+		return &config.Config{
+			Server: config.ServerConfig{
+				Port:           "3000",
+				AuthPath:       "/auth",
+				ReadTimeout:    30,
+				WriteTimeout:   30,
+				TrustedProxies: trustedProxies,
+			},
+			Tracing: config.TracingConfig{
+				Enabled:     true,
+				Endpoint:    collector.URL(),
+				ServiceName: "tiny-auth",
+				SampleRatio: 1,
+				TimeoutMs:   1000,
+			},
+			BasicAuths: []config.BasicAuthConfig{
+				{Name: "user1", User: "user1", Pass: "pass1", Roles: []string{"user"}},
+			},
+			RoutePolicies: []config.RoutePolicy{
+				{
+					Name:         "tenants",
+					PathPrefix:   "/tenants",
+					AuthzWebhook: &config.AuthzWebhookConfig{URL: webhook.URL, TimeoutMs: 1000, FailureMode: "closed"},
+				},
+			},
+		}
+	}
+
+	const traceID = "4bf92f3577b34e9d8a7b1c2d3e4f5a6b"
+	const parentID = "00f067aa0ba902b7"
+	send := func(srv *Server) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/auth", http.NoBody)
+		req.Header.Set("X-Forwarded-Uri", "/tenants/1")
+		req.Header.Set("Authorization", "Basic dXNlcjE6cGFzczE=") // user1:pass1
+		req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+		resp, err := srv.App.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		if err := srv.tracer.ForceFlush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := createTestServer(t, newConfig(nil))
+	defer shutdownTracer(srv.tracer, srv.Logger)
+	send(srv)
+
+	spans := map[string]tracingtest.Span{}
+	for _, s := range collector.Spans() {
+		if s.TraceID != traceID {
+			t.Errorf("span %s has trace %s, want %s", s.Name, s.TraceID, traceID)
+		}
+		spans[s.Name] = s
+	}
+	root, ok := spans["forward_auth"]
+	if !ok {
+		t.Fatalf("missing forward_auth span in %v", collector.Spans())
+	}
+	if root.ParentSpanID != parentID || root.Kind != "server" {
+		t.Errorf("root span should continue the incoming traceparent: %+v", root)
+	}
+	if root.Attributes["tinyauth.result"] != "success" || root.Attributes["tinyauth.policy"] != "tenants" ||
+		root.Attributes["http.response.status_code"] != "200" {
+		t.Errorf("unexpected root span attributes %v", root.Attributes)
+	}
+	for _, name := range []string{"policy.match", "auth.attempt", "policy.check", "authz.webhook"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("missing %s span", name)
+			continue
+		}
+		if s.ParentSpanID != root.SpanID {
+			t.Errorf("%s should be a child of forward_auth", name)
+		}
+	}
+	if got := spans["auth.attempt"].Attributes; got["tinyauth.auth_method"] != "basic" || got["tinyauth.authenticated"] != "true" {
+		t.Errorf("unexpected auth.attempt attributes %v", got)
+	}
+	if spans["authz.webhook"].Kind != "client" {
+		t.Errorf("authz.webhook should be a client span")
+	}
+
+	mu.Lock()
+	parent := webhookParent
+	mu.Unlock()
+	if want := "00-" + traceID + "-" + spans["authz.webhook"].SpanID + "-01"; parent != want {
+		t.Errorf("webhook traceparent = %q, want %q", parent, want)
+	}
+	for _, s := range collector.Spans() {
+		for _, v := range s.Attributes {
+			if strings.Contains(v, "user1") {
+				t.Errorf("span %s must not contain the username", s.Name)
+			}
+		}
+	}
+
+	// 不是来自可信代理的请求：不延续其 traceparent
+	untrusted := createTestServer(t, newConfig([]string{"10.0.0.0/8"}))
+	defer shutdownTracer(untrusted.tracer, untrusted.Logger)
+	before := len(collector.Spans())
+	send(untrusted)
+	if len(collector.Spans()) == before {
+		t.Fatal("expected spans for the untrusted request")
+	}
+	for _, s := range collector.Spans()[before:] {
+		if s.TraceID == traceID {
+			t.Errorf("span %s should not continue an untrusted traceparent", s.Name)
+		}
+	}
+}
@@ -84,7 +84,8 @@ func getClientIP(c *fiber.Ctx, cfg *config.Config, trustedCIDRs []*net.IPNet) st
 	if len(ips) > 0 {
 		clientIP := strings.TrimSpace(ips[0])
 		if clientIP != "" {
-			return clientIP
+			// Fiber 的 header 值引用请求缓冲区（请求结束后会被复用），复制后才能作为限流 key 保存
+			return strings.Clone(clientIP)
 		}
 	}
 
@@ -156,3 +157,28 @@ func normalizeHost(host string) string {
 
 	return strings.ToLower(host)
 }
+
+// getForwardedURL 根据可信代理转发的 X-Forwarded-Proto / Host / Uri 还原原始请求地址
+// 请求不是来自可信代理或缺少 X-Forwarded-Host 时返回空字符串（不使用客户端可控的值构建跳转地址）
+func getForwardedURL(c *fiber.Ctx, trustedCIDRs []*net.IPNet) string {
+	if !isTrustedProxy(c.IP(), trustedCIDRs) {
+		return ""
+	}
+
+	host := strings.TrimSpace(strings.Split(c.Get("X-Forwarded-Host"), ",")[0])
+	if host == "" || strings.ContainsAny(host, "/\\@ ") {
+		return ""
+	}
+
+	scheme := strings.ToLower(strings.TrimSpace(strings.Split(c.Get("X-Forwarded-Proto"), ",")[0]))
+	if scheme != "http" {
+		scheme = "https"
+	}
+
+	uri := c.Get("X-Forwarded-Uri")
+	if !strings.HasPrefix(uri, "/") {
+		uri = "/"
+	}
+
+	return scheme + "://" + host + uri
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/gofiber/fiber/v2"
 )
 
 func TestParseTrustedProxies(t *testing.T) {
@@ -174,6 +178,47 @@ func TestNormalizeHost(t *testing.T) {
 	}
 }
 
+func TestGetForwardedURL(t *testing.T) {
+	// app.Test 的直接连接 IP 为 0.0.0.0
+	trusted := parseTrustedProxies([]string{"0.0.0.0"})
+	untrusted := parseTrustedProxies([]string{"10.0.0.0/8"})
+
+	tests := []struct {
+		name    string
+		cidrs   []*net.IPNet
+		headers map[string]string
+		want    string
+	}{
+		{"full", trusted, map[string]string{"X-Forwarded-Proto": "http", "X-Forwarded-Host": "app.example.com:8443", "X-Forwarded-Uri": "/a?b=1"}, "http://app.example.com:8443/a?b=1"},
+		{"default https and path", trusted, map[string]string{"X-Forwarded-Host": "app.example.com"}, "https://app.example.com/"},
+		{"first host value", trusted, map[string]string{"X-Forwarded-Host": "app.example.com, proxy.local", "X-Forwarded-Uri": "/x"}, "https://app.example.com/x"},
+		{"no host", trusted, map[string]string{"X-Forwarded-Uri": "/x"}, ""},
+		{"host with userinfo", trusted, map[string]string{"X-Forwarded-Host": "evil.com@app.example.com"}, ""},
+		{"untrusted proxy", untrusted, map[string]string{"X-Forwarded-Host": "app.example.com"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				got = getForwardedURL(c, tt.cidrs)
+				return nil
+			})
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if _, err := app.Test(req, -1); err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("getForwardedURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 // 性能基准测试
 func BenchmarkIsTrustedProxy_Match(b *testing.B) {
 	_, cidr, _ := net.ParseCIDR("192.168.0.0/16")
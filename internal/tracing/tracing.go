@@ -0,0 +1,117 @@
+// Package tracing 通过 OTLP/HTTP 导出 forward-auth 流水线的 OpenTelemetry 追踪
+// 入口请求的 span 延续上游（如 Traefik）的 W3C traceparent；流水线各阶段用 Start 从 context 中的 span 派生子 span。
+// 未启用追踪时 context 中没有 span，Start 直接返回，不分配内存
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 创建 tracer 使用的名称
+const instrumentationName = "github.com/nerdneilsfield/tiny-auth"
+
+// defaultTracesPath endpoint 没有路径时使用的 OTLP/HTTP 路径
+const defaultTracesPath = "/v1/traces"
+
+// propagator W3C Trace Context（traceparent / tracestate）
+var propagator = propagation.TraceContext{}
+
+// Options 导出配置
+type Options struct {
+	Endpoint    string            // OTLP/HTTP 地址（如 http://otel-collector:4318），没有路径时使用 /v1/traces
+	Headers     map[string]string // 导出请求附加的 headers
+	ServiceName string            // service.name 资源属性
+	SampleRatio float64           // 没有上游采样决定时的采样比例（0 < ratio <= 1）
+	Timeout     time.Duration     // 单次导出超时（<= 0 时使用导出器默认值）
+}
+
+// Provider 追踪导出器，所有方法都可以在 nil *Provider 上调用（未启用追踪）
+type Provider struct {
+	tp     *sdktrace.TracerProvider
+	tracer trace.Tracer
+}
+
+// NewProvider 创建追踪导出器（span 在后台批量导出）
+func NewProvider(opts Options) (*Provider, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid endpoint %q: must be an http:// or https:// URL", opts.Endpoint)
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = defaultTracesPath
+	}
+
+	exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(endpoint.String())}
+	if len(opts.Headers) > 0 {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithHeaders(opts.Headers))
+	}
+	if opts.Timeout > 0 {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithTimeout(opts.Timeout))
+	}
+	// 导出器在创建时不连接 collector，collector 暂时不可用不影响启动
+	exporter, err := otlptracehttp.New(context.Background(), exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", opts.ServiceName))),
+	)
+	return &Provider{tp: tp, tracer: tp.Tracer(instrumentationName)}, nil
+}
+
+// StartRequest 为入口请求创建 server span；carrier 中有有效的 traceparent 时延续上游的 trace
+// 未启用追踪时原样返回 ctx 和不记录的 span
+func (p *Provider) StartRequest(ctx context.Context, name string, carrier propagation.TextMapCarrier) (context.Context, trace.Span) {
+	if p == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	if carrier != nil {
+		ctx = propagator.Extract(ctx, carrier)
+	}
+	return p.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// ForceFlush 立即导出所有已结束的 span
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	return p.tp.ForceFlush(ctx)
+}
+
+// Shutdown 导出剩余的 span 并停止导出器
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	return p.tp.Shutdown(ctx)
+}
+
+// Start 在 ctx 中的 span 下创建子 span
+// ctx 中的 span 不记录（未启用追踪或未被采样）时直接返回该 span，调用方设置属性前应先检查 IsRecording
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.IsRecording() {
+		return ctx, parent
+	}
+	return parent.TracerProvider().Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// Inject 将 ctx 中的 trace context 写入外发请求的 headers（没有有效的 span 时不写入）
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
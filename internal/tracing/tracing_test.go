@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/nerdneilsfield/tiny-auth/internal/tracing/tracingtest"
+)
+
+// TestProvider_ContinuesTraceparent 测试入口 span 延续上游的 traceparent，子 span 和外发 headers 使用同一个 trace
+func TestProvider_ContinuesTraceparent(t *testing.T) {
+	collector := tracingtest.NewCollector()
+	defer collector.Close()
+
+	p, err := NewProvider(Options{
+		Endpoint:    collector.URL(),
+		Headers:     map[string]string{"X-Collector-Token": "secret"},
+		ServiceName: "tiny-auth-test",
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const traceID = "4bf92f3577b34e9d8a7b1c2d3e4f5a6b"
+	const parentID = "00f067aa0ba902b7"
+	carrier := propagation.MapCarrier{"traceparent": "00-" + traceID + "-" + parentID + "-01"}
+
+	ctx, root := p.StartRequest(context.Background(), "forward_auth", carrier)
+	childCtx, child := Start(ctx, "policy.match")
+	header := http.Header{}
+	Inject(childCtx, header)
+	child.End()
+	root.End()
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := collector.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %+v", spans)
+	}
+	byName := map[string]tracingtest.Span{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	rootSpan, childSpan := byName["forward_auth"], byName["policy.match"]
+	if rootSpan.TraceID != traceID || rootSpan.ParentSpanID != parentID || rootSpan.Kind != "server" {
+		t.Errorf("root span should continue the incoming trace: %+v", rootSpan)
+	}
+	if childSpan.TraceID != traceID || childSpan.ParentSpanID != rootSpan.SpanID {
+		t.Errorf("child span should be a child of the root span: %+v", childSpan)
+	}
+	if rootSpan.Service != "tiny-auth-test" {
+		t.Errorf("service.name = %q", rootSpan.Service)
+	}
+	if want := "00-" + traceID + "-" + childSpan.SpanID + "-01"; header.Get("traceparent") != want {
+		t.Errorf("traceparent = %q, want %q", header.Get("traceparent"), want)
+	}
+	if collector.Header("X-Collector-Token") != "secret" {
+		t.Error("expected configured headers on export requests")
+	}
+}
+
+// TestDisabled 测试未启用追踪时不创建 span、不写入 headers、不分配内存
+func TestDisabled(t *testing.T) {
+	var p *Provider
+	ctx, span := p.StartRequest(context.Background(), "forward_auth", propagation.MapCarrier{})
+	if span.IsRecording() {
+		t.Fatal("expected a non-recording span")
+	}
+	header := http.Header{}
+	Inject(ctx, header)
+	if len(header) != 0 {
+		t.Errorf("expected no headers, got %v", header)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, span := Start(ctx, "policy.match")
+		span.End()
+	})
+	if allocs != 0 {
+		t.Errorf("Start allocated %v times per call when disabled", allocs)
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestNewProvider_InvalidEndpoint 测试无效的 endpoint
+func TestNewProvider_InvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "otel-collector:4318", "grpc://collector:4317", "http://"} {
+		if _, err := NewProvider(Options{Endpoint: endpoint, SampleRatio: 1}); err == nil {
+			t.Errorf("expected error for endpoint %q", endpoint)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package tracingtest 提供进程内的 OTLP/HTTP collector，用于测试导出的 span
+package tracingtest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Span collector 收到的一个 span（ID 为十六进制字符串，属性值转换为字符串）
+type Span struct {
+	Name         string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Kind         string // "server" / "client" / "internal" ...
+	Service      string // 资源属性 service.name
+	Attributes   map[string]string
+}
+
+// Collector 接收 OTLP/HTTP protobuf 导出请求（POST /v1/traces）并保存 span
+type Collector struct {
+	server  *httptest.Server
+	mu      sync.Mutex
+	spans   []Span
+	headers http.Header // 最近一次导出请求的 headers
+}
+
+// NewCollector 启动 collector，测试结束时调用 Close
+func NewCollector() *Collector {
+	c := &Collector{}
+	c.server = httptest.NewServer(http.HandlerFunc(c.handle))
+	return c
+}
+
+// URL 返回 collector 的地址（不含路径）
+func (c *Collector) URL() string {
+	return c.server.URL
+}
+
+// Close 停止 collector
+func (c *Collector) Close() {
+	c.server.Close()
+}
+
+// Spans 返回目前收到的所有 span
+func (c *Collector) Spans() []Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Span(nil), c.spans...)
+}
+
+// Header 返回最近一次导出请求的 header
+func (c *Collector) Header(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.headers.Get(name)
+}
+
+func (c *Collector) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &collectortrace.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(body, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var spans []Span
+	for _, rs := range req.GetResourceSpans() {
+		service := attributes(rs.GetResource().GetAttributes())["service.name"]
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				spans = append(spans, Span{
+					Name:         s.GetName(),
+					TraceID:      hex.EncodeToString(s.GetTraceId()),
+					SpanID:       hex.EncodeToString(s.GetSpanId()),
+					ParentSpanID: hex.EncodeToString(s.GetParentSpanId()),
+					Kind:         kindName(int32(s.GetKind())),
+					Service:      service,
+					Attributes:   attributes(s.GetAttributes()),
+				})
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.spans = append(c.spans, spans...)
+	c.headers = r.Header.Clone()
+	c.mu.Unlock()
+
+	resp, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+// attributes 将属性转换为字符串
+func attributes(kvs []*commonpb.KeyValue) map[string]string {
+	out := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		v := kv.GetValue()
+		switch v.GetValue().(type) {
+		case *commonpb.AnyValue_StringValue:
+			out[kv.GetKey()] = v.GetStringValue()
+		case *commonpb.AnyValue_BoolValue:
+			out[kv.GetKey()] = fmt.Sprint(v.GetBoolValue())
+		case *commonpb.AnyValue_IntValue:
+			out[kv.GetKey()] = fmt.Sprint(v.GetIntValue())
+		case *commonpb.AnyValue_DoubleValue:
+			out[kv.GetKey()] = fmt.Sprint(v.GetDoubleValue())
+		default:
+			out[kv.GetKey()] = v.String()
+		}
+	}
+	return out
+}
+
+// kindName 返回 span kind 的名称（OTLP 枚举值）
+func kindName(kind int32) string {
+	switch kind {
+	case 1:
+		return "internal"
+	case 2:
+		return "server"
+	case 3:
+		return "client"
+	case 4:
+		return "producer"
+	case 5:
+		return "consumer"
+	default:
+		return "unspecified"
+	}
+}